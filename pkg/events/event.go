@@ -0,0 +1,104 @@
+// Package events carries domain events between services. An Event is
+// published once to a Broker and delivered at least once to every consumer
+// subscribed to it, so handlers must tolerate duplicates; the event ID is
+// stable across redeliveries for that purpose.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+type Event struct {
+	ID         string    `bson:"_id" json:"id"`
+	Type       string    `bson:"type" json:"type"`
+	Source     string    `bson:"source" json:"source"`
+	Subject    string    `bson:"subject" json:"subject"`
+	OccurredAt time.Time `bson:"occurred_at" json:"occurred_at"`
+	Data       Data      `bson:"data" json:"data"`
+}
+
+// Data is an event's JSON payload. It is kept in MongoDB as an embedded
+// document rather than opaque bytes so stored events can be queried.
+type Data json.RawMessage
+
+// NewData encodes value as an event payload.
+func NewData(value interface{}) (Data, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return Data(raw), nil
+}
+
+// Decode unmarshals the payload into value.
+func (d Data) Decode(value interface{}) error {
+	return json.Unmarshal(d, value)
+}
+
+func (d Data) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+func (d *Data) UnmarshalJSON(raw []byte) error {
+	*d = append((*d)[:0], raw...)
+	return nil
+}
+
+func (d Data) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if len(d) == 0 || string(d) == "null" {
+		return bson.MarshalValue(nil)
+	}
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON(d, false, &doc); err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(doc)
+}
+
+func (d *Data) UnmarshalBSONValue(t bsontype.Type, raw []byte) error {
+	switch t {
+	case bsontype.Null:
+		*d = nil
+		return nil
+	case bsontype.EmbeddedDocument:
+		out, err := bson.MarshalExtJSON(bson.Raw(raw), false, false)
+		if err != nil {
+			return err
+		}
+		*d = out
+		return nil
+	default:
+		return errors.New("events: payload must be a document")
+	}
+}
+
+// Handler processes one delivered event. Returning an error has the event
+// delivered again later.
+type Handler func(ctx context.Context, event Event) error
+
+type Publisher interface {
+	// Publish hands the event to the broker. Publishing an event whose ID
+	// was already published is not an error and delivers nothing new.
+	Publish(ctx context.Context, event Event) error
+}
+
+type Subscriber interface {
+	// Subscribe delivers every event published from now on, and for
+	// durable brokers every event not yet handled by the named consumer,
+	// to handler in publication order. It blocks until ctx is done.
+	Subscribe(ctx context.Context, consumer string, handler Handler) error
+}
+
+type Broker interface {
+	Publisher
+	Subscriber
+}
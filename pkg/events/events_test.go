@@ -0,0 +1,110 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ecommerce/pkg/events"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDataRoundTrip(t *testing.T) {
+	data, err := events.NewData(map[string]interface{}{"order_id": "abc", "total": map[string]string{"amount": "12.50", "currency": "USD"}, "items": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := events.Event{ID: "1", Type: "order.created", Data: data}
+
+	raw, err := bson.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The payload is stored as a document, not as binary
+	if kind := bson.Raw(raw).Lookup("data", "total", "currency"); kind.StringValue() != "USD" {
+		t.Fatalf("data.total.currency = %v, want USD", kind)
+	}
+
+	var decoded events.Event
+	if err := bson.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		OrderID string `json:"order_id"`
+		Items   int    `json:"items"`
+	}
+	if err := decoded.Data.Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.OrderID != "abc" || payload.Items != 2 {
+		t.Fatalf("decoded payload = %+v", payload)
+	}
+
+	out, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back events.Event
+	if err := json.Unmarshal(out, &back); err != nil || back.Type != "order.created" || len(back.Data) == 0 {
+		t.Fatalf("JSON round trip = %+v, %v", back, err)
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := events.NewMemoryBroker(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan string, 10)
+	failures := 1
+	subscribed := make(chan struct{})
+	var once sync.Once
+	go broker.Subscribe(ctx, "test", func(ctx context.Context, event events.Event) error {
+		if strings.HasPrefix(event.ID, "ready-") {
+			once.Do(func() { close(subscribed) })
+			return nil
+		}
+		if event.ID == "2" && failures > 0 {
+			failures--
+			return errors.New("temporarily unavailable")
+		}
+		received <- event.ID
+		return nil
+	})
+
+	// Events published before the subscription starts are not delivered
+	for i := 0; ; i++ {
+		broker.Publish(ctx, events.Event{ID: "ready-" + strconv.Itoa(i)})
+		select {
+		case <-subscribed:
+		case <-time.After(time.Millisecond):
+			continue
+		}
+		break
+	}
+
+	for _, id := range []string{"1", "2", "2", "3"} {
+		if err := broker.Publish(ctx, events.Event{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The failed event is redelivered before later ones and the
+	// duplicate is dropped
+	for _, want := range []string{"1", "2", "3"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("received %s, want %s", got, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for event %s", want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBroker delivers events to subscribers in the same process. It keeps
+// nothing once an event is handled, so events published while no one is
+// subscribed are lost; it suits tests and single-process setups.
+type MemoryBroker struct {
+	retryDelay time.Duration
+
+	mu          sync.Mutex
+	published   map[string]struct{}
+	subscribers map[*memorySubscription]struct{}
+}
+
+type memorySubscription struct {
+	mu     sync.Mutex
+	queue  []Event
+	notify chan struct{}
+}
+
+// NewMemoryBroker returns a broker that redelivers an event retryDelay
+// after its handler failed.
+func NewMemoryBroker(retryDelay time.Duration) *MemoryBroker {
+	return &MemoryBroker{
+		retryDelay:  retryDelay,
+		published:   make(map[string]struct{}),
+		subscribers: make(map[*memorySubscription]struct{}),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.published[event.ID]; ok {
+		return nil
+	}
+	b.published[event.ID] = struct{}{}
+
+	for sub := range b.subscribers {
+		sub.mu.Lock()
+		sub.queue = append(sub.queue, event)
+		sub.mu.Unlock()
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe delivers events published while it runs. The consumer name is
+// not used, since nothing outlives the subscription.
+func (b *MemoryBroker) Subscribe(ctx context.Context, consumer string, handler Handler) error {
+	sub := &memorySubscription{notify: make(chan struct{}, 1)}
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subscribers, sub)
+		b.mu.Unlock()
+	}()
+
+	for {
+		sub.mu.Lock()
+		var next *Event
+		if len(sub.queue) > 0 {
+			next = &sub.queue[0]
+		}
+		sub.mu.Unlock()
+
+		if next == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-sub.notify:
+				continue
+			}
+		}
+
+		if err := handler(ctx, *next); err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(b.retryDelay):
+				continue
+			}
+		}
+
+		sub.mu.Lock()
+		sub.queue = sub.queue[1:]
+		sub.mu.Unlock()
+	}
+}
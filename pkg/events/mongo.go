@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoBatchSize is how many events a subscription reads per poll.
+const mongoBatchSize = 100
+
+// MongoBroker keeps published events in a MongoDB collection that
+// consumers in any service poll. Each event records the consumers that
+// handled it, so a consumer that was down picks up where it left off.
+type MongoBroker struct {
+	events       *mongo.Collection
+	pollInterval time.Duration
+}
+
+type storedEvent struct {
+	Event       `bson:",inline"`
+	PublishedAt time.Time `bson:"published_at"`
+	HandledBy   []string  `bson:"handled_by"`
+}
+
+// NewMongoBroker uses the collection for events and polls it for new ones
+// every pollInterval, which is also the delay before a failed event is
+// delivered again.
+func NewMongoBroker(events *mongo.Collection, pollInterval time.Duration) *MongoBroker {
+	return &MongoBroker{
+		events:       events,
+		pollInterval: pollInterval,
+	}
+}
+
+// EnsureMongoBrokerIndexes orders events for polling and lets MongoDB
+// delete them once they are older than retention.
+func EnsureMongoBrokerIndexes(events *mongo.Collection, retention time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "published_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+	})
+	return err
+}
+
+func (b *MongoBroker) Publish(ctx context.Context, event Event) error {
+	_, err := b.events.InsertOne(ctx, storedEvent{
+		Event:       event,
+		PublishedAt: time.Now(),
+		HandledBy:   []string{},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// Subscribe delivers the events the consumer has not handled, oldest
+// first. Several instances of one consumer may each handle an event before
+// either records it.
+func (b *MongoBroker) Subscribe(ctx context.Context, consumer string, handler Handler) error {
+	for {
+		if err := b.deliver(ctx, consumer, handler); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.pollInterval):
+		}
+	}
+}
+
+// deliver hands the consumer's pending events to handler until one fails
+// or none are left.
+func (b *MongoBroker) deliver(ctx context.Context, consumer string, handler Handler) error {
+	for {
+		cursor, err := b.events.Find(ctx,
+			bson.M{"handled_by": bson.M{"$ne": consumer}},
+			options.Find().
+				SetSort(bson.D{{Key: "published_at", Value: 1}, {Key: "_id", Value: 1}}).
+				SetLimit(mongoBatchSize).
+				SetProjection(bson.M{"handled_by": 0}),
+		)
+		if err != nil {
+			return err
+		}
+		var batch []storedEvent
+		if err := cursor.All(ctx, &batch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, stored := range batch {
+			if err := handler(ctx, stored.Event); err != nil {
+				return err
+			}
+			_, err := b.events.UpdateOne(ctx,
+				bson.M{"_id": stored.ID},
+				bson.M{"$addToSet": bson.M{"handled_by": consumer}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// jsonMoney is the JSON form, {"amount": "12.34", "currency": "USD"}. The
+// amount is a decimal string in major units so that no client has to
+// parse it as a float.
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, _ := json.Marshal(m.Decimal())
+	return json.Marshal(jsonMoney{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts the amount as a decimal string or a JSON number,
+// and a bare number without currency for clients that still send floats.
+// The currency is then left empty for the caller to fill in.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var value jsonMoney
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	} else {
+		value.Amount = data
+	}
+
+	amount := "0"
+	if value.Amount != nil {
+		if err := json.Unmarshal(value.Amount, &amount); err != nil {
+			var number json.Number
+			if err := json.Unmarshal(value.Amount, &number); err != nil {
+				return fmt.Errorf("%w: amount must be a decimal string or number", ErrInvalidAmount)
+			}
+			amount = number.String()
+		}
+	}
+
+	parsed, err := Parse(amount, value.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// bsonMoney is the BSON form, an embedded document holding the minor-unit
+// amount as an int64.
+type bsonMoney struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(bsonMoney{Amount: m.Amount, Currency: m.Currency})
+}
+
+// UnmarshalBSONValue also reads the float64 and integer amounts stored
+// before this type existed, as major units with no currency, so documents
+// can be read while they are being migrated.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.EmbeddedDocument:
+		var value bsonMoney
+		if err := raw.Unmarshal(&value); err != nil {
+			return err
+		}
+		*m = New(value.Amount, value.Currency)
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+	default:
+		legacy, ok := FromBSONNumber(raw, "")
+		if !ok {
+			return fmt.Errorf("%w: cannot decode BSON %s", ErrInvalidAmount, t)
+		}
+		*m = legacy
+	}
+	return nil
+}
+
+// FromBSONNumber converts an amount stored the old way, as a float64 or
+// integer number of major units, into money of currency. It reports false
+// for any other BSON value.
+func FromBSONNumber(value bson.RawValue, currency string) (Money, bool) {
+	switch value.Type {
+	case bsontype.Double:
+		return FromMajor(value.Double(), currency), true
+	case bsontype.Int32:
+		return FromMajor(float64(value.Int32()), currency), true
+	case bsontype.Int64:
+		return FromMajor(float64(value.Int64()), currency), true
+	default:
+		return Money{}, false
+	}
+}
@@ -0,0 +1,276 @@
+// Package money holds exact amounts of money as an integer number of the
+// currency's minor unit, so that sums and splits never drift the way
+// float64 amounts do.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid money amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Money is Amount minor units (cents for USD) of the ISO 4217 Currency.
+// The zero value is zero with no currency, and adds to money of any
+// currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// exponents lists the currencies whose minor unit is not a hundredth.
+var exponents = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// Exponent is the number of decimal places of the currency's minor unit.
+func Exponent(currency string) int {
+	if exponent, ok := exponents[strings.ToUpper(currency)]; ok {
+		return exponent
+	}
+	return 2
+}
+
+func scale(currency string) float64 {
+	return math.Pow10(Exponent(currency))
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code: three
+// upper-case letters.
+func ValidCurrency(code string) bool {
+	return len(code) == 3 && strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// FromMajor converts an amount in major units, such as 12.34 dollars,
+// rounding half away from zero to the minor unit. It is meant for legacy
+// float64 values only.
+func FromMajor(amount float64, currency string) Money {
+	return New(int64(math.Round(amount*scale(currency))), currency)
+}
+
+// Parse reads a decimal amount in major units, such as "12.34", exactly.
+// It rejects amounts more precise than the currency's minor unit.
+func Parse(amount string, currency string) (Money, error) {
+	exponent := Exponent(currency)
+	text := strings.TrimSpace(amount)
+
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if len(fraction) > exponent {
+		if strings.Trim(fraction[exponent:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, amount, exponent)
+		}
+		fraction = fraction[:exponent]
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	digits := whole + fraction
+	if digits == "" {
+		digits = "0"
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+		}
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return New(minor, currency), nil
+}
+
+// Major is the amount in major units. It is for display and for systems
+// that still take float64 amounts, never for further arithmetic.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / scale(m.Currency)
+}
+
+// Decimal formats the amount in major units, such as "12.34".
+func (m Money) Decimal() string {
+	exponent := Exponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// SameCurrency reports whether m and other can be added. Zero money with
+// no currency goes with every currency.
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency ||
+		(m.Currency == "" && m.Amount == 0) ||
+		(other.Currency == "" && other.Amount == 0)
+}
+
+func (m Money) currencyWith(other Money) string {
+	if !m.SameCurrency(other) {
+		panic(fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency))
+	}
+	if m.Currency == "" {
+		return other.Currency
+	}
+	return m.Currency
+}
+
+// Add returns m + other. Mixing currencies is a programming error and
+// panics; check SameCurrency first when the currencies come from input.
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currencyWith(other)}
+}
+
+// Sub returns m - other, panicking like Add on mixed currencies.
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currencyWith(other)}
+}
+
+// Cmp returns -1, 0 or 1 as m is less than, equal to or greater than
+// other, panicking like Add on mixed currencies.
+func (m Money) Cmp(other Money) int {
+	m.currencyWith(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Percent returns percent percent of m, rounded half away from zero to
+// the minor unit.
+func (m Money) Percent(percent float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * percent / 100)), Currency: m.Currency}
+}
+
+// Convert converts m into currency at rate, the number of units of
+// currency one unit of m's currency buys, rounding half away from zero to
+// the minor unit of currency.
+func (m Money) Convert(rate float64, currency string) Money {
+	return New(int64(math.Round(float64(m.Amount)*rate*scale(currency)/scale(m.Currency))), currency)
+}
+
+// Allocate splits m in proportion to the weights so that the parts add up
+// to m exactly. Minor units left over by rounding down go to the parts
+// with the largest remainders, earlier parts first on ties.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+	var total int64
+	for i, weight := range weights {
+		parts[i] = Zero(m.Currency)
+		if weight > 0 {
+			total += weight
+		}
+	}
+	if total == 0 {
+		return parts
+	}
+
+	remainders := make([]float64, len(weights))
+	allocated := int64(0)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		share := float64(m.Amount) * float64(weight) / float64(total)
+		parts[i].Amount = int64(math.Trunc(share))
+		remainders[i] = math.Abs(share - math.Trunc(share))
+		allocated += parts[i].Amount
+	}
+
+	step := int64(1)
+	if m.Amount < 0 {
+		step = -1
+	}
+	for left := m.Amount - allocated; left != 0; left -= step {
+		best := -1
+		for i, weight := range weights {
+			if weight > 0 && (best < 0 || remainders[i] > remainders[best]) {
+				best = i
+			}
+		}
+		parts[best].Amount += step
+		remainders[best] = -1
+	}
+	return parts
+}
+
+// Min returns the smaller of a and b, panicking like Add on mixed
+// currencies.
+func Min(a Money, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// Sum adds up amounts, all of the same currency.
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total
+}
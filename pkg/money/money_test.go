@@ -0,0 +1,129 @@
+package money_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseAndDecimal(t *testing.T) {
+	cases := []struct {
+		amount   string
+		currency string
+		minor    int64
+		decimal  string
+	}{
+		{"12.34", "USD", 1234, "12.34"},
+		{"12.3", "usd", 1230, "12.30"},
+		{"0.05", "USD", 5, "0.05"},
+		{"-1.50", "EUR", -150, "-1.50"},
+		{"1500", "JPY", 1500, "1500"},
+		{"1.234", "KWD", 1234, "1.234"},
+		{"7.100", "USD", 710, "7.10"},
+	}
+	for _, tc := range cases {
+		m, err := money.Parse(tc.amount, tc.currency)
+		if err != nil {
+			t.Fatalf("Parse(%q, %s): %v", tc.amount, tc.currency, err)
+		}
+		if m.Amount != tc.minor || m.Decimal() != tc.decimal {
+			t.Errorf("Parse(%q, %s) = %d (%s), want %d (%s)", tc.amount, tc.currency, m.Amount, m.Decimal(), tc.minor, tc.decimal)
+		}
+	}
+
+	for _, amount := range []string{"", "abc", "1.001", "1.2.3", "--1"} {
+		if _, err := money.Parse(amount, "USD"); err == nil {
+			t.Errorf("Parse(%q) should fail", amount)
+		}
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	// 0.1 + 0.2 drifts as float64 but not in minor units
+	total := money.Sum(money.New(10, "USD"), money.New(20, "USD"))
+	if total != money.New(30, "USD") {
+		t.Errorf("Sum = %v", total)
+	}
+
+	if got := money.New(1005, "USD").Percent(10); got.Amount != 101 {
+		t.Errorf("Percent rounds half away from zero, got %v", got)
+	}
+
+	parts := money.New(1000, "USD").Allocate([]int64{1, 1, 1})
+	if parts[0].Amount != 334 || parts[1].Amount != 333 || parts[2].Amount != 333 {
+		t.Errorf("Allocate = %v", parts)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("adding different currencies should panic")
+		}
+	}()
+	money.New(1, "USD").Add(money.New(1, "EUR"))
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		from money.Money
+		rate float64
+		to   string
+		want money.Money
+	}{
+		{money.New(1000, "USD"), 0.92, "EUR", money.New(920, "EUR")},
+		{money.New(1999, "USD"), 151.37, "JPY", money.New(3026, "JPY")},
+		{money.New(3026, "JPY"), 1 / 151.37, "USD", money.New(1999, "USD")},
+		{money.New(1, "USD"), 0.005, "EUR", money.New(0, "EUR")},
+	}
+	for _, tc := range cases {
+		if got := tc.from.Convert(tc.rate, tc.to); got != tc.want {
+			t.Errorf("%v.Convert(%v, %s) = %v, want %v", tc.from, tc.rate, tc.to, got, tc.want)
+		}
+	}
+
+	if !money.ValidCurrency("USD") || money.ValidCurrency("usd") || money.ValidCurrency("US") {
+		t.Error("ValidCurrency accepts only three upper-case letters")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	raw, err := json.Marshal(money.New(1234, "USD"))
+	if err != nil || string(raw) != `{"amount":"12.34","currency":"USD"}` {
+		t.Fatalf("Marshal = %s, %v", raw, err)
+	}
+
+	for input, want := range map[string]money.Money{
+		`{"amount":"12.34","currency":"USD"}`: money.New(1234, "USD"),
+		`{"amount":12.34,"currency":"usd"}`:   money.New(1234, "USD"),
+		`{"amount":"1500","currency":"JPY"}`:  money.New(1500, "JPY"),
+		`12.5`:                                money.New(1250, ""),
+	} {
+		var m money.Money
+		if err := json.Unmarshal([]byte(input), &m); err != nil || m != want {
+			t.Errorf("Unmarshal(%s) = %v, %v; want %v", input, m, err, want)
+		}
+	}
+}
+
+func TestBSON(t *testing.T) {
+	type doc struct {
+		Price money.Money `bson:"price"`
+	}
+
+	raw, err := bson.Marshal(doc{Price: money.New(1234, "USD")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded doc
+	if err := bson.Unmarshal(raw, &decoded); err != nil || decoded.Price != money.New(1234, "USD") {
+		t.Errorf("round trip = %v, %v", decoded.Price, err)
+	}
+
+	// Documents written before the migration hold float64 amounts
+	legacy, _ := bson.Marshal(bson.M{"price": 19.99})
+	if err := bson.Unmarshal(legacy, &decoded); err != nil || decoded.Price != money.New(1999, "") {
+		t.Errorf("legacy = %v, %v", decoded.Price, err)
+	}
+}
@@ -0,0 +1,79 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type productClient struct {
+	baseURL         string
+	defaultCurrency string
+	httpClient      *http.Client
+}
+
+func NewProductClient(baseURL string, defaultCurrency string, timeout time.Duration) models.ProductCatalog {
+	return &productClient{
+		baseURL:         strings.TrimRight(baseURL, "/"),
+		defaultCurrency: defaultCurrency,
+		httpClient:      &http.Client{Timeout: timeout},
+	}
+}
+
+type product struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	CategoryID string      `json:"category_id"`
+	Price      money.Money `json:"price"`
+}
+
+func (c *productClient) GetProduct(id primitive.ObjectID) (*models.ProductSnapshot, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/products/" + id.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("product service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, models.ErrProductNotFound
+	default:
+		return nil, fmt.Errorf("failed to get product %s: product service returned %d", id.Hex(), resp.StatusCode)
+	}
+
+	var p product
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to decode product %s: %w", id.Hex(), err)
+	}
+
+	// Prices sent as a bare number carry no currency
+	price := p.Price
+	if price.Currency == "" {
+		parsed, err := money.Parse(price.Decimal(), c.defaultCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for product %s: %w", id.Hex(), err)
+		}
+		price = parsed
+	}
+
+	// Products without a category carry a zero ID
+	var categoryID *primitive.ObjectID
+	if id, err := primitive.ObjectIDFromHex(p.CategoryID); err == nil && !id.IsZero() {
+		categoryID = &id
+	}
+
+	return &models.ProductSnapshot{
+		ID:         id,
+		Name:       p.Name,
+		CategoryID: categoryID,
+		Price:      price,
+	}, nil
+}
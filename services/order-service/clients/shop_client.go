@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type shopClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewShopClient(baseURL string, timeout time.Duration) models.ShopDirectory {
+	return &shopClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type shop struct {
+	ID                         string   `json:"id"`
+	Name                       string   `json:"name"`
+	Location                   string   `json:"location"`
+	Warehouses                 []string `json:"warehouses"`
+	SourcingStrategy           string   `json:"sourcing_strategy"`
+	PendingOrderTimeoutMinutes int      `json:"pending_order_timeout_minutes"`
+}
+
+func (c *shopClient) GetShop(id primitive.ObjectID) (*models.ShopInfo, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/shops/" + id.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("shop service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, models.ErrShopNotFound
+	default:
+		return nil, fmt.Errorf("failed to get shop %s: shop service returned %d", id.Hex(), resp.StatusCode)
+	}
+
+	var s shop
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to decode shop %s: %w", id.Hex(), err)
+	}
+
+	return &models.ShopInfo{
+		ID:                  id,
+		Name:                s.Name,
+		Location:            s.Location,
+		Warehouses:          s.Warehouses,
+		SourcingStrategy:    models.SourcingStrategy(s.SourcingStrategy),
+		PendingOrderTimeout: time.Duration(s.PendingOrderTimeoutMinutes) * time.Minute,
+	}, nil
+}
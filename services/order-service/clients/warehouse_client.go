@@ -0,0 +1,237 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type warehouseClient struct {
+	baseURL    string
+	jwtSecret  string
+	httpClient *http.Client
+}
+
+func NewWarehouseClient(baseURL string, jwtSecret string, timeout time.Duration) models.WarehouseClient {
+	return &warehouseClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		jwtSecret:  jwtSecret,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type reservationItem struct {
+	ProductID   string `json:"product_id"`
+	Quantity    int    `json:"quantity"`
+	WarehouseID string `json:"warehouse_id,omitempty"`
+}
+
+type reservationRequest struct {
+	Reference  string            `json:"reference"`
+	TTLSeconds int               `json:"ttl_seconds"`
+	Items      []reservationItem `json:"items"`
+}
+
+type reservation struct {
+	ID          string    `json:"id"`
+	WarehouseID string    `json:"warehouse_id"`
+	ProductID   string    `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type shortageResponse struct {
+	Error     string                 `json:"error"`
+	Shortages []models.StockShortage `json:"shortages"`
+}
+
+func (c *warehouseClient) ReserveStock(reference string, allocations []models.Allocation, ttl time.Duration) ([]models.StockReservation, error) {
+	req := reservationRequest{
+		Reference:  reference,
+		TTLSeconds: int(ttl.Seconds()),
+		Items:      make([]reservationItem, len(allocations)),
+	}
+	for i, allocation := range allocations {
+		req.Items[i] = reservationItem{
+			ProductID:   allocation.ProductID.Hex(),
+			Quantity:    allocation.Quantity,
+			WarehouseID: allocation.WarehouseID,
+		}
+	}
+
+	resp, err := c.do(http.MethodPost, "/api/v1/reservations/", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusConflict:
+		var body shortageResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("failed to decode stock shortage response: %w", err)
+		}
+		return nil, &models.InsufficientStockError{Shortages: body.Shortages}
+	default:
+		return nil, c.unexpectedStatus("reserve stock", resp)
+	}
+
+	var created []reservation
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("failed to decode reservations: %w", err)
+	}
+
+	reservations := make([]models.StockReservation, len(created))
+	for i, r := range created {
+		productID, err := primitive.ObjectIDFromHex(r.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid product ID in reservation %s: %w", r.ID, err)
+		}
+		reservations[i] = models.StockReservation{
+			ID:          r.ID,
+			WarehouseID: r.WarehouseID,
+			ProductID:   productID,
+			Quantity:    r.Quantity,
+			ExpiresAt:   r.ExpiresAt,
+		}
+	}
+
+	return reservations, nil
+}
+
+func (c *warehouseClient) HoldReservation(id string) error {
+	return c.post("/api/v1/reservations/"+id+"/hold", "hold reservation")
+}
+
+func (c *warehouseClient) ConfirmReservation(id string) error {
+	return c.post("/api/v1/reservations/"+id+"/confirm", "confirm reservation")
+}
+
+func (c *warehouseClient) ReleaseReservation(id string) error {
+	return c.post("/api/v1/reservations/"+id+"/release", "release reservation")
+}
+
+type stockLevel struct {
+	Available int `json:"available"`
+}
+
+// GetAvailableStock returns how much of the product the warehouse can still
+// reserve. A warehouse that no longer exists has none.
+func (c *warehouseClient) GetAvailableStock(warehouseID string, productID primitive.ObjectID) (int, error) {
+	resp, err := c.do(http.MethodGet, "/api/v1/warehouses/"+warehouseID+"/stock/"+productID.Hex(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return 0, nil
+	default:
+		return 0, c.unexpectedStatus("get stock level", resp)
+	}
+
+	var level stockLevel
+	if err := json.NewDecoder(resp.Body).Decode(&level); err != nil {
+		return 0, fmt.Errorf("failed to decode stock level: %w", err)
+	}
+	return level.Available, nil
+}
+
+type stockUpdate struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+func (c *warehouseClient) RestockItem(warehouseID string, productID primitive.ObjectID, quantity int) error {
+	resp, err := c.do(http.MethodPut, "/api/v1/warehouses/"+warehouseID+"/stock", stockUpdate{
+		ProductID: productID.Hex(),
+		Quantity:  quantity,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.unexpectedStatus("restock item", resp)
+	}
+	return nil
+}
+
+func (c *warehouseClient) post(path string, action string) error {
+	resp, err := c.do(http.MethodPost, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.unexpectedStatus(action, resp)
+	}
+	return nil
+}
+
+func (c *warehouseClient) do(method string, path string, payload interface{}) (*http.Response, error) {
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.serviceToken()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.StockCheckErrors.Inc()
+		return nil, fmt.Errorf("warehouse service request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// serviceToken signs a short-lived token that lets order-service act on
+// warehouse stock with the inventory role.
+func (c *warehouseClient) serviceToken() (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "order-service",
+		"roles":   []string{"inventory"},
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Minute).Unix(),
+	})
+	return token.SignedString([]byte(c.jwtSecret))
+}
+
+func (c *warehouseClient) unexpectedStatus(action string, resp *http.Response) error {
+	metrics.StockCheckErrors.Inc()
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Error != "" {
+		return fmt.Errorf("failed to %s: %s", action, body.Error)
+	}
+	return fmt.Errorf("failed to %s: warehouse service returned %d", action, resp.StatusCode)
+}
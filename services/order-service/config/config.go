@@ -7,11 +7,19 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	MongoDB  MongoDBConfig
-	JWT      JWTConfig
-	LogLevel string
-	Services ServicesConfig
+	Server      ServerConfig
+	MongoDB     MongoDBConfig
+	JWT         JWTConfig
+	LogLevel    string
+	Services    ServicesConfig
+	Reservation ReservationConfig
+	Pricing     PricingConfig
+	Payment     PaymentConfig
+	Idempotency IdempotencyConfig
+	Sourcing    SourcingConfig
+	Cart        CartConfig
+	Events      EventsConfig
+	AutoCancel  AutoCancelConfig
 }
 
 type ServerConfig struct {
@@ -32,8 +40,63 @@ type JWTConfig struct {
 }
 
 type ServicesConfig struct {
-	ProductServiceURL string
-	ShopServiceURL    string
+	ProductServiceURL   string
+	ShopServiceURL      string
+	WarehouseServiceURL string
+	RequestTimeout      time.Duration
+}
+
+type ReservationConfig struct {
+	TTL time.Duration
+}
+
+type PricingConfig struct {
+	DefaultCurrency string
+	// ExchangeRatesFile, when set, is a JSON file of exchange rates loaded
+	// at startup.
+	ExchangeRatesFile string
+}
+
+type PaymentConfig struct {
+	Provider         string
+	FakeDeclineAbove float64
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration
+}
+
+type SourcingConfig struct {
+	DefaultStrategy string
+}
+
+type CartConfig struct {
+	// AnonymousTTL is how long an anonymous cart is kept after its last
+	// change.
+	AnonymousTTL time.Duration
+}
+
+type EventsConfig struct {
+	// Broker is "mongo", a collection other services can consume, or
+	// "memory", which only reaches subscribers in this process.
+	Broker string
+	// BusDatabase holds the mongo broker's events collection. Services
+	// consuming order events must use the same database.
+	BusDatabase   string
+	RelayInterval time.Duration
+	// MaxAttempts is how many times the relay tries to publish an event,
+	// one attempt per run, before dead-lettering it.
+	MaxAttempts int
+	// Retention is how long published events are kept in the outbox and
+	// the mongo broker.
+	Retention time.Duration
+}
+
+type AutoCancelConfig struct {
+	// PendingTimeout is how long orders of shops without their own
+	// timeout may stay pending before they are cancelled.
+	PendingTimeout time.Duration
+	Interval       time.Duration
 }
 
 func LoadConfig() *Config {
@@ -54,8 +117,41 @@ func LoadConfig() *Config {
 		},
 		LogLevel: getEnv("LOG_LEVEL", "info"),
 		Services: ServicesConfig{
-			ProductServiceURL: getEnv("PRODUCT_SERVICE_URL", "http://product-service:8082"),
-			ShopServiceURL:    getEnv("SHOP_SERVICE_URL", "http://shop-service:8083"),
+			ProductServiceURL:   getEnv("PRODUCT_SERVICE_URL", "http://product-service:8082"),
+			ShopServiceURL:      getEnv("SHOP_SERVICE_URL", "http://shop-service:8083"),
+			WarehouseServiceURL: getEnv("WAREHOUSE_SERVICE_URL", "http://warehouse-service:8084"),
+			RequestTimeout:      time.Duration(getEnvAsInt("SERVICE_REQUEST_TIMEOUT", 5)) * time.Second,
+		},
+		Reservation: ReservationConfig{
+			TTL: time.Duration(getEnvAsInt("RESERVATION_TTL_MINUTES", 30)) * time.Minute,
+		},
+		Pricing: PricingConfig{
+			DefaultCurrency:   getEnv("DEFAULT_CURRENCY", "USD"),
+			ExchangeRatesFile: getEnv("EXCHANGE_RATES_FILE", ""),
+		},
+		Payment: PaymentConfig{
+			Provider:         getEnv("PAYMENT_PROVIDER", "fake"),
+			FakeDeclineAbove: getEnvAsFloat("FAKE_PAYMENT_DECLINE_ABOVE", 0),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: time.Duration(getEnvAsInt("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour,
+		},
+		Sourcing: SourcingConfig{
+			DefaultStrategy: getEnv("SOURCING_STRATEGY", "single_warehouse_first"),
+		},
+		Cart: CartConfig{
+			AnonymousTTL: time.Duration(getEnvAsInt("CART_ANONYMOUS_TTL_DAYS", 30)) * 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			Broker:        getEnv("EVENT_BROKER", "mongo"),
+			BusDatabase:   getEnv("EVENT_BUS_DATABASE", "event_bus"),
+			RelayInterval: time.Duration(getEnvAsInt("OUTBOX_RELAY_INTERVAL_SECONDS", 2)) * time.Second,
+			MaxAttempts:   getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 150),
+			Retention:     time.Duration(getEnvAsInt("EVENT_RETENTION_DAYS", 7)) * 24 * time.Hour,
+		},
+		AutoCancel: AutoCancelConfig{
+			PendingTimeout: time.Duration(getEnvAsInt("PENDING_ORDER_TIMEOUT_MINUTES", 30)) * time.Minute,
+			Interval:       time.Duration(getEnvAsInt("AUTO_CANCEL_INTERVAL_SECONDS", 60)) * time.Second,
 		},
 	}
 }
@@ -75,3 +171,12 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/middleware"
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CartTokenHeader carries the token of an anonymous cart.
+const CartTokenHeader = "X-Cart-Token"
+
+type CartHandler struct {
+	cartService models.CartService
+}
+
+func NewCartHandler(cartService models.CartService) *CartHandler {
+	return &CartHandler{
+		cartService: cartService,
+	}
+}
+
+type addCartItemRequest struct {
+	ShopID    string `json:"shop_id" binding:"required"`
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required"`
+}
+
+type updateCartItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+type mergeCartRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// GetCart godoc
+// @Summary Get the cart
+// @Description Get the signed-in user's cart, or the anonymous cart of the X-Cart-Token header, priced at current catalog prices and checked against the shop's stock
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param X-Cart-Token header string false "Token of an anonymous cart"
+// @Success 200 {object} models.CartView
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /cart [get]
+func (h *CartHandler) GetCart(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	view, err := h.cartService.GetCart(owner)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	respondCart(c, view)
+}
+
+// AddCartItem godoc
+// @Summary Add an item to the cart
+// @Description Add a quantity of a product to the cart, creating the cart if needed. A new anonymous cart's token is returned in the X-Cart-Token header. A cart only holds products of one shop.
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param X-Cart-Token header string false "Token of an anonymous cart"
+// @Param item body addCartItemRequest true "Item"
+// @Success 200 {object} models.CartView
+// @Failure 400 {object} map[string]interface{} "Invalid item"
+// @Failure 409 {object} map[string]interface{} "Cart holds products of another shop"
+// @Failure 422 {object} map[string]interface{} "Unknown or deleted product"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /cart/items [post]
+func (h *CartHandler) AddCartItem(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	var req addCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shopID, err := primitive.ObjectIDFromHex(req.ShopID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop ID format"})
+		return
+	}
+	productID, err := primitive.ObjectIDFromHex(req.ProductID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	view, err := h.cartService.AddItem(owner, shopID, productID, req.Quantity)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	respondCart(c, view)
+}
+
+// UpdateCartItem godoc
+// @Summary Change the quantity of a cart item
+// @Description Set the quantity of a product in the cart. A quantity of zero removes it.
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param X-Cart-Token header string false "Token of an anonymous cart"
+// @Param productId path string true "Product ID"
+// @Param item body updateCartItemRequest true "Quantity"
+// @Success 200 {object} models.CartView
+// @Failure 400 {object} map[string]interface{} "Invalid quantity"
+// @Failure 404 {object} map[string]interface{} "Product is not in the cart"
+// @Failure 409 {object} map[string]interface{} "Cart was modified concurrently"
+// @Failure 422 {object} map[string]interface{} "Unknown or deleted product"
+// @Router /cart/items/{productId} [put]
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	productID, err := primitive.ObjectIDFromHex(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+	var req updateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view, err := h.cartService.UpdateItem(owner, productID, req.Quantity)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	respondCart(c, view)
+}
+
+// RemoveCartItem godoc
+// @Summary Remove an item from the cart
+// @Description Remove a product from the cart
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param X-Cart-Token header string false "Token of an anonymous cart"
+// @Param productId path string true "Product ID"
+// @Success 200 {object} models.CartView
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 404 {object} map[string]interface{} "Product is not in the cart"
+// @Failure 409 {object} map[string]interface{} "Cart was modified concurrently"
+// @Router /cart/items/{productId} [delete]
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	productID, err := primitive.ObjectIDFromHex(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	view, err := h.cartService.RemoveItem(owner, productID)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	respondCart(c, view)
+}
+
+// ClearCart godoc
+// @Summary Empty the cart
+// @Description Remove every item from the cart
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param X-Cart-Token header string false "Token of an anonymous cart"
+// @Success 204 "No Content"
+// @Failure 409 {object} map[string]interface{} "Cart was modified concurrently"
+// @Router /cart [delete]
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := h.cartService.ClearCart(owner); err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MergeCart godoc
+// @Summary Merge an anonymous cart
+// @Description Move the anonymous cart with the token into the signed-in user's cart, typically right after login. Quantities of the same product are added up; a cart of another shop is replaced by the anonymous one.
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param cart body mergeCartRequest true "Anonymous cart token"
+// @Success 200 {object} models.CartView
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Anonymous cart not found"
+// @Failure 409 {object} map[string]interface{} "Cart was modified concurrently"
+// @Security BearerAuth
+// @Router /cart/merge [post]
+func (h *CartHandler) MergeCart(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	var req mergeCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view, err := h.cartService.MergeCart(*owner.UserID, req.Token)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	respondCart(c, view)
+}
+
+// Checkout godoc
+// @Summary Check out the cart
+// @Description Place an order for the signed-in user's cart and empty it. The cart is checked again first; if any item is unavailable or out of stock the cart is returned with the problems marked and no order is placed.
+// @Tags cart
+// @Accept  json
+// @Produce  json
+// @Param checkout body models.CheckoutRequest false "Coupons and settlement currency"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 201 {object} models.Order
+// @Failure 400 {object} map[string]interface{} "Bad Request or invalid currency"
+// @Failure 402 {object} map[string]interface{} "Payment declined"
+// @Failure 409 {object} map[string]interface{} "Cart has problems, insufficient stock or coupon limit reached"
+// @Failure 422 {object} map[string]interface{} "Empty cart, coupon not applicable or no exchange rate"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /cart/checkout [post]
+func (h *CartHandler) Checkout(c *gin.Context) {
+	owner, ok := cartOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.CheckoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	req.Customer = nil
+	if principal, ok := middleware.PrincipalFromContext(c); ok {
+		req.Customer = principal.Customer()
+	}
+
+	order, err := h.cartService.Checkout(*owner.UserID, req)
+	if err != nil {
+		var cartErr *models.CartInvalidError
+		switch {
+		case errors.As(err, &cartErr):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "cart": cartErr.View})
+		case errors.Is(err, models.ErrCartEmpty):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrCartConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			respondCreateOrderError(c, err)
+		}
+		return
+	}
+
+	recordCreatedOrder(order)
+	c.JSON(http.StatusCreated, order)
+}
+
+// cartOwner identifies the cart of the request: the authenticated user's,
+// or else the anonymous cart of the X-Cart-Token header.
+func cartOwner(c *gin.Context) (models.CartOwner, bool) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return models.CartOwner{Token: c.GetHeader(CartTokenHeader)}, true
+	}
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return models.CartOwner{}, false
+	}
+	return models.CartOwner{UserID: &id}, true
+}
+
+func respondCart(c *gin.Context, view *models.CartView) {
+	if view.Cart.Token != "" {
+		c.Header(CartTokenHeader, view.Cart.Token)
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func respondCartError(c *gin.Context, err error) {
+	var productErr *models.ProductUnavailableError
+	switch {
+	case errors.As(err, &productErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "products not available", "product_ids": productErr.ProductIDs})
+	case errors.Is(err, models.ErrInvalidCartItem):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrCartNotFound), errors.Is(err, models.ErrCartItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrCartShopMismatch), errors.Is(err, models.ErrCartConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/middleware"
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CouponHandler struct {
+	promotionService models.PromotionService
+}
+
+func NewCouponHandler(promotionService models.PromotionService) *CouponHandler {
+	return &CouponHandler{
+		promotionService: promotionService,
+	}
+}
+
+// CreateCoupon godoc
+// @Summary Create a coupon
+// @Description Create a percentage or fixed-amount coupon. Shop staff may only create coupons scoped to their shop.
+// @Tags coupons
+// @Accept  json
+// @Produce  json
+// @Param coupon body models.Coupon true "Coupon"
+// @Success 201 {object} models.Coupon
+// @Failure 400 {object} map[string]interface{} "Invalid coupon"
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage coupons for this scope"
+// @Failure 409 {object} map[string]interface{} "Coupon code already exists"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /coupons [post]
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var coupon models.Coupon
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canManageCoupon(c, &coupon) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to manage coupons for this scope"})
+		return
+	}
+
+	if err := h.promotionService.CreateCoupon(&coupon); err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// GetCoupon godoc
+// @Summary Get a coupon
+// @Description Get a coupon and how often it has been redeemed
+// @Tags coupons
+// @Accept  json
+// @Produce  json
+// @Param code path string true "Coupon code"
+// @Success 200 {object} models.Coupon
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage this coupon"
+// @Failure 404 {object} map[string]interface{} "Coupon not found"
+// @Security BearerAuth
+// @Router /coupons/{code} [get]
+func (h *CouponHandler) GetCoupon(c *gin.Context) {
+	coupon, err := h.promotionService.GetCoupon(c.Param("code"))
+	if err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canManageCoupon(c, coupon) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to manage this coupon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// ActivateCoupon godoc
+// @Summary Activate a coupon
+// @Description Let customers apply the coupon again
+// @Tags coupons
+// @Accept  json
+// @Produce  json
+// @Param code path string true "Coupon code"
+// @Success 200 {object} models.Coupon
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage this coupon"
+// @Failure 404 {object} map[string]interface{} "Coupon not found"
+// @Security BearerAuth
+// @Router /coupons/{code}/activate [post]
+func (h *CouponHandler) ActivateCoupon(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// DeactivateCoupon godoc
+// @Summary Deactivate a coupon
+// @Description Stop customers from applying the coupon. Orders that already used it keep their discount.
+// @Tags coupons
+// @Accept  json
+// @Produce  json
+// @Param code path string true "Coupon code"
+// @Success 200 {object} models.Coupon
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage this coupon"
+// @Failure 404 {object} map[string]interface{} "Coupon not found"
+// @Security BearerAuth
+// @Router /coupons/{code}/deactivate [post]
+func (h *CouponHandler) DeactivateCoupon(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *CouponHandler) setActive(c *gin.Context, active bool) {
+	coupon, err := h.promotionService.GetCoupon(c.Param("code"))
+	if err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canManageCoupon(c, coupon) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to manage this coupon"})
+		return
+	}
+
+	coupon, err = h.promotionService.SetCouponActive(coupon.Code, active)
+	if err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// canManageCoupon lets admins manage every coupon and shop staff the
+// coupons scoped to their shop.
+func canManageCoupon(c *gin.Context, coupon *models.Coupon) bool {
+	principal, ok := middleware.PrincipalFromContext(c)
+	if !ok {
+		return false
+	}
+	if principal.IsAdmin() {
+		return true
+	}
+	return coupon.ShopID != nil && principal.IsShopStaff(*coupon.ShopID)
+}
+
+func couponErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidCoupon):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrCouponNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrCouponExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeRateHandler struct {
+	exchangeService models.ExchangeService
+}
+
+func NewExchangeRateHandler(exchangeService models.ExchangeService) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		exchangeService: exchangeService,
+	}
+}
+
+type setExchangeRateRequest struct {
+	Rate float64 `json:"rate" binding:"required"`
+}
+
+// ListExchangeRates godoc
+// @Summary List exchange rates
+// @Description Get every exchange rate, with where it came from and when it was last set
+// @Tags exchange-rates
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.ExchangeRate
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /exchange-rates [get]
+func (h *ExchangeRateHandler) ListExchangeRates(c *gin.Context) {
+	rates, err := h.exchangeService.ListRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// SetExchangeRate godoc
+// @Summary Set an exchange rate
+// @Description Create or replace the rate of a currency pair, as the number of units of quote one unit of base buys. Orders already placed keep the rate they were converted at.
+// @Tags exchange-rates
+// @Accept  json
+// @Produce  json
+// @Param base path string true "Base currency"
+// @Param quote path string true "Quote currency"
+// @Param rate body setExchangeRateRequest true "Rate"
+// @Success 200 {object} models.ExchangeRate
+// @Failure 400 {object} map[string]interface{} "Invalid exchange rate"
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /exchange-rates/{base}/{quote} [put]
+func (h *ExchangeRateHandler) SetExchangeRate(c *gin.Context) {
+	var req setExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate := &models.ExchangeRate{
+		Base:   c.Param("base"),
+		Quote:  c.Param("quote"),
+		Rate:   req.Rate,
+		Source: models.ExchangeRateSourceAdmin,
+	}
+	if err := h.exchangeService.SetRate(rate); err != nil {
+		c.JSON(exchangeRateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DeleteExchangeRate godoc
+// @Summary Delete an exchange rate
+// @Description Delete the rate of a currency pair. Orders already placed keep the rate they were converted at.
+// @Tags exchange-rates
+// @Accept  json
+// @Produce  json
+// @Param base path string true "Base currency"
+// @Param quote path string true "Quote currency"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 404 {object} map[string]interface{} "Exchange rate not found"
+// @Security BearerAuth
+// @Router /exchange-rates/{base}/{quote} [delete]
+func (h *ExchangeRateHandler) DeleteExchangeRate(c *gin.Context) {
+	if err := h.exchangeService.DeleteRate(c.Param("base"), c.Param("quote")); err != nil {
+		c.JSON(exchangeRateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func exchangeRateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidExchangeRate):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrExchangeRateNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
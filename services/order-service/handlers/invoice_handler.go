@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ecommerce/order-service/invoices"
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InvoiceHandler struct {
+	orderService   models.OrderService
+	invoiceService models.InvoiceService
+}
+
+func NewInvoiceHandler(orderService models.OrderService, invoiceService models.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{
+		orderService:   orderService,
+		invoiceService: invoiceService,
+	}
+}
+
+// GetOrderInvoice godoc
+// @Summary Get an order's invoice
+// @Description Get the invoice issued when the order was completed, as JSON or, with format=pdf or an Accept header of application/pdf, as a PDF document. Invoices are numbered per shop without gaps and never change once issued.
+// @Tags invoices
+// @Produce  json
+// @Produce  application/pdf
+// @Param id path string true "Order ID"
+// @Param format query string false "Response format" Enums(json, pdf)
+// @Success 200 {object} models.Invoice
+// @Failure 400 {object} map[string]interface{} "Invalid ID format or format"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Failure 409 {object} map[string]interface{} "Order is not completed"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/invoice [get]
+func (h *InvoiceHandler) GetOrderInvoice(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" && strings.Contains(c.GetHeader("Accept"), "application/pdf") {
+		format = "pdf"
+	}
+	if format != "" && format != "json" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or pdf"})
+		return
+	}
+
+	order, err := h.orderService.GetOrder(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetInvoice(order)
+	if err != nil {
+		if errors.Is(err, models.ErrOrderNotCompleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoice.Number+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", invoices.RenderPDF(invoice))
+		return
+	}
+	c.JSON(http.StatusOK, invoice)
+}
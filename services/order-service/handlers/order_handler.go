@@ -1,13 +1,29 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/middleware"
 	"ecommerce/order-service/models"
+	"ecommerce/order-service/reports"
+	"ecommerce/pkg/money"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// exportFlushInterval is how many exported orders are buffered before
+	// they are sent to the client.
+	exportFlushInterval = 500
+	exportErrorTrailer  = "X-Export-Error"
 )
 
 type OrderHandler struct {
@@ -22,14 +38,19 @@ func NewOrderHandler(orderService models.OrderService) *OrderHandler {
 
 // CreateOrder godoc
 // @Summary Create a new order
-// @Description Create a new order with the input payload
+// @Description Create a new order with the input payload. Item names and prices are taken from product-service; client-supplied values are ignored. Items are sourced from the shop's warehouses using its sourcing strategy. Coupons given in coupon_codes are applied as discounts on the lines they cover. Items priced in another currency than the order's currency, the first item's by default, are converted at the current exchange rate and the rates used are recorded on the order.
 // @Tags orders
 // @Accept  json
 // @Produce  json
 // @Param order body models.Order true "Create order"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Success 201 {object} models.Order
-// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 400 {object} map[string]interface{} "Bad Request or invalid currency"
+// @Failure 402 {object} map[string]interface{} "Payment declined"
+// @Failure 409 {object} map[string]interface{} "Insufficient stock or coupon limit reached"
+// @Failure 422 {object} map[string]interface{} "Unknown or deleted products, unknown shop, coupon not applicable or no exchange rate"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Failure 502 {object} map[string]interface{} "Payment provider unavailable"
 // @Security BearerAuth
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
@@ -53,19 +74,61 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 	order.UserID = userID
+	order.Customer = nil
+	if principal, ok := middleware.PrincipalFromContext(c); ok {
+		order.Customer = principal.Customer()
+	}
 
 	if err := h.orderService.CreateOrder(&order); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondCreateOrderError(c, err)
 		return
 	}
 
-	// Record metrics
-	metrics.OrderOperations.WithLabelValues("create", string(order.Status)).Inc()
-	metrics.OrderTotalAmount.WithLabelValues(string(order.Status)).Observe(order.TotalAmount)
-
+	recordCreatedOrder(&order)
 	c.JSON(http.StatusCreated, order)
 }
 
+// respondCreateOrderError maps the errors of placing an order, directly or
+// from a cart, to responses.
+func respondCreateOrderError(c *gin.Context, err error) {
+	var stockErr *models.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": "insufficient stock", "shortages": stockErr.Shortages})
+		return
+	}
+	if errors.Is(err, models.ErrPaymentDeclined) {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrPaymentUnavailable) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	var productErr *models.ProductUnavailableError
+	if errors.As(err, &productErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "products not available", "product_ids": productErr.ProductIDs})
+		return
+	}
+	if errors.Is(err, models.ErrInvalidCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrShopNotFound) || errors.Is(err, models.ErrCouponNotApplicable) || errors.Is(err, models.ErrExchangeRateNotFound) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrCouponExhausted) || errors.Is(err, models.ErrCouponUserLimitReached) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func recordCreatedOrder(order *models.Order) {
+	metrics.OrderOperations.WithLabelValues("create", string(order.Status)).Inc()
+	metrics.OrderTotalAmount.WithLabelValues(string(order.Status)).Observe(order.TotalAmount.Major())
+}
+
 // GetOrder godoc
 // @Summary Get an order by ID
 // @Description Get details of a specific order by its ID
@@ -96,11 +159,21 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 
 // GetUserOrders godoc
 // @Summary Get user's orders
-// @Description Get all orders for the authenticated user
+// @Description Get a page of the authenticated user's orders
 // @Tags orders
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Order
+// @Param status query string false "Comma-separated statuses"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param min_amount query number false "Minimum total amount"
+// @Param max_amount query number false "Maximum total amount"
+// @Param currency query string false "Currency of min_amount and max_amount, required with them"
+// @Param sort query string false "created_at_desc (default), created_at_asc, total_amount_desc or total_amount_asc"
+// @Param limit query int false "Page size, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.OrderPage
+// @Failure 400 {object} map[string]interface{} "Invalid query"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Security BearerAuth
@@ -119,24 +192,40 @@ func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 		return
 	}
 
-	orders, err := h.orderService.GetUserOrders(userID)
+	query, err := parseOrderQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	page, err := h.orderService.GetUserOrders(userID, query)
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
 // GetShopOrders godoc
 // @Summary Get shop's orders
-// @Description Get all orders for a specific shop
+// @Description Get a page of a specific shop's orders
 // @Tags orders
 // @Accept  json
 // @Produce  json
 // @Param shopId path string true "Shop ID"
-// @Success 200 {array} models.Order
-// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Param status query string false "Comma-separated statuses"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param min_amount query number false "Minimum total amount"
+// @Param max_amount query number false "Maximum total amount"
+// @Param currency query string false "Currency of min_amount and max_amount, required with them"
+// @Param sort query string false "created_at_desc (default), created_at_asc, total_amount_desc or total_amount_asc"
+// @Param limit query int false "Page size, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.OrderPage
+// @Failure 400 {object} map[string]interface{} "Invalid ID format or query"
+// @Failure 403 {object} map[string]interface{} "Not staff of the shop"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Security BearerAuth
 // @Router /orders/shop/{shopId} [get]
@@ -147,13 +236,202 @@ func (h *OrderHandler) GetShopOrders(c *gin.Context) {
 		return
 	}
 
-	orders, err := h.orderService.GetShopOrders(shopID)
+	query, err := parseOrderQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.orderService.GetShopOrders(shopID, query)
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SearchOrders godoc
+// @Summary Search all orders
+// @Description Get a page of orders across all users and shops for support staff. q matches an order, user, shop or payment ID exactly, or the start of the customer's email, regardless of case, or phone.
+// @Tags orders
+// @Accept  json
+// @Produce  json
+// @Param q query string false "Order, user, shop or payment ID, or the start of the customer's email or phone"
+// @Param shop_id query string false "Shop ID"
+// @Param user_id query string false "User ID"
+// @Param status query string false "Comma-separated statuses"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param min_amount query number false "Minimum total amount"
+// @Param max_amount query number false "Maximum total amount"
+// @Param currency query string false "Currency of min_amount and max_amount, required with them"
+// @Param sort query string false "created_at_desc (default), created_at_asc, total_amount_desc or total_amount_asc"
+// @Param limit query int false "Page size, at most 100" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.OrderPage
+// @Failure 400 {object} map[string]interface{} "Invalid query"
+// @Failure 403 {object} map[string]interface{} "Not admin or support staff"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/search [get]
+func (h *OrderHandler) SearchOrders(c *gin.Context) {
+	query, err := parseSearchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.orderService.SearchOrders(query)
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ExportOrders godoc
+// @Summary Export orders
+// @Description Stream every order matching the search as CSV, one row per order, or as newline-delimited JSON, one order per line. Results are not paged. If the export fails after it started, the response is cut short and its X-Export-Error trailer carries the error.
+// @Tags orders
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Param format query string false "Export format" Enums(csv, ndjson) default(csv)
+// @Param q query string false "Order, user, shop or payment ID, or the start of the customer's email or phone"
+// @Param shop_id query string false "Shop ID"
+// @Param user_id query string false "User ID"
+// @Param status query string false "Comma-separated statuses"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param min_amount query number false "Minimum total amount"
+// @Param max_amount query number false "Maximum total amount"
+// @Param currency query string false "Currency of min_amount and max_amount, required with them"
+// @Param sort query string false "created_at_desc (default), created_at_asc, total_amount_desc or total_amount_asc"
+// @Success 200 {string} string "Exported orders"
+// @Failure 400 {object} map[string]interface{} "Invalid query or format"
+// @Failure 403 {object} map[string]interface{} "Not admin or support staff"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/export [get]
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	format := c.DefaultQuery("format", reports.FormatCSV)
+	if !reports.ValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	query, err := parseSearchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	writer, err := reports.NewOrderWriter(format, c.Writer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	// Headers go out with the first order, so a query rejected before
+	// then still gets a JSON error
+	started := false
+	start := func() {
+		started = true
+		// Large exports outlive the server's write timeout
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.Header("Content-Type", writer.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+			"orders-"+time.Now().UTC().Format("20060102T150405Z")+"."+format))
+		c.Header("Trailer", exportErrorTrailer)
+		c.Status(http.StatusOK)
+	}
+
+	exported := 0
+	err = h.orderService.ExportOrders(c.Request.Context(), query.Filter, query.Sort, func(order *models.Order) error {
+		if !started {
+			start()
+		}
+		if err := writer.Write(order); err != nil {
+			return err
+		}
+		exported++
+		if exported%exportFlushInterval == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if !started {
+		start()
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		c.Writer.Header().Set(exportErrorTrailer, err.Error())
+	}
+	metrics.OrderExports.WithLabelValues(format).Add(float64(exported))
+}
+
+// parseSearchQuery reads the search text and the shop and user filters on
+// top of the listing query.
+func parseSearchQuery(c *gin.Context) (models.OrderQuery, error) {
+	query, err := parseOrderQuery(c)
+	if err != nil {
+		return query, err
+	}
+	query.Filter.Search = c.Query("q")
+
+	for param, target := range map[string]**primitive.ObjectID{
+		"shop_id": &query.Filter.ShopID,
+		"user_id": &query.Filter.UserID,
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s", param)
+			}
+			*target = &id
+		}
+	}
+
+	return query, nil
+}
+
+// PayOrder godoc
+// @Summary Pay for an order
+// @Description Capture the order's authorized payment and change order status to paid
+// @Tags orders
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} map[string]interface{} "Success message"
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/pay [post]
+func (h *OrderHandler) PayOrder(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := h.orderService.PayOrder(id, actorFromContext(c)); err != nil {
+		c.JSON(transitionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order paid successfully"})
 }
 
 // ProcessOrder godoc
@@ -163,8 +441,11 @@ func (h *OrderHandler) GetShopOrders(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Order ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Success 200 {object} map[string]interface{} "Success message"
 // @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Security BearerAuth
 // @Router /orders/{id}/process [post]
@@ -175,13 +456,11 @@ func (h *OrderHandler) ProcessOrder(c *gin.Context) {
 		return
 	}
 
-	if err := h.orderService.ProcessOrder(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.orderService.ProcessOrder(id, actorFromContext(c)); err != nil {
+		c.JSON(transitionErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	metrics.OrderStatusTransitions.WithLabelValues(string(models.OrderStatusPending), string(models.OrderStatusProcessing)).Inc()
-
 	c.JSON(http.StatusOK, gin.H{"message": "Order processed successfully"})
 }
 
@@ -192,8 +471,11 @@ func (h *OrderHandler) ProcessOrder(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Order ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Success 200 {object} map[string]interface{} "Success message"
 // @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Security BearerAuth
 // @Router /orders/{id}/complete [post]
@@ -204,16 +486,18 @@ func (h *OrderHandler) CompleteOrder(c *gin.Context) {
 		return
 	}
 
-	if err := h.orderService.CompleteOrder(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.orderService.CompleteOrder(id, actorFromContext(c)); err != nil {
+		c.JSON(transitionErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	metrics.OrderStatusTransitions.WithLabelValues(string(models.OrderStatusProcessing), string(models.OrderStatusCompleted)).Inc()
-
 	c.JSON(http.StatusOK, gin.H{"message": "Order completed successfully"})
 }
 
+type CancelOrderRequest struct {
+	Reason string `json:"reason"`
+}
+
 // CancelOrder godoc
 // @Summary Cancel an order
 // @Description Change order status to cancelled
@@ -221,8 +505,11 @@ func (h *OrderHandler) CompleteOrder(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Order ID"
+// @Param cancel body CancelOrderRequest false "Cancellation reason"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Success 200 {object} map[string]interface{} "Success message"
 // @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Security BearerAuth
 // @Router /orders/{id}/cancel [post]
@@ -233,12 +520,279 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	if err := h.orderService.CancelOrder(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	var req CancelOrderRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	metrics.OrderStatusTransitions.WithLabelValues(string(models.OrderStatusPending), string(models.OrderStatusCancelled)).Inc()
+	if err := h.orderService.CancelOrder(id, actorFromContext(c), req.Reason); err != nil {
+		c.JSON(transitionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Order cancelled successfully"})
 }
+
+type AddOrderItemRequest struct {
+	ProductID primitive.ObjectID `json:"product_id" binding:"required"`
+	Quantity  int                `json:"quantity" binding:"required,gt=0"`
+}
+
+type CancelOrderItemRequest struct {
+	Quantity int    `json:"quantity" binding:"required,gt=0"`
+	Reason   string `json:"reason"`
+}
+
+// AddOrderItem godoc
+// @Summary Add an item to an order
+// @Description Add units of a product to a pending order. A product already on the order keeps its ordered price; a new one is priced from the catalog. Coupons, taxes and totals are recalculated, the extra stock is reserved and the payment is reauthorized for the new total.
+// @Tags orders
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param item body AddOrderItemRequest true "Item to add"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 402 {object} map[string]interface{} "Payment declined"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Failure 409 {object} map[string]interface{} "Order no longer editable, insufficient stock or concurrent update"
+// @Failure 422 {object} map[string]interface{} "Unknown or deleted product, coupon no longer applicable or no exchange rate"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Failure 502 {object} map[string]interface{} "Payment provider unavailable"
+// @Security BearerAuth
+// @Router /orders/{id}/items [post]
+func (h *OrderHandler) AddOrderItem(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req AddOrderItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.orderService.AddOrderItem(id, req.ProductID, req.Quantity, actorFromContext(c))
+	if err != nil {
+		respondEditOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// RemoveOrderItem godoc
+// @Summary Remove an item from an order
+// @Description Remove a product's line from a pending order. Totals are recalculated, its stock is released and the payment is reauthorized for the new total. The last item cannot be removed.
+// @Tags orders
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param productId path string true "Product ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Failure 409 {object} map[string]interface{} "Order no longer editable or concurrent update"
+// @Failure 422 {object} map[string]interface{} "Coupon no longer applicable"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/items/{productId} [delete]
+func (h *OrderHandler) RemoveOrderItem(c *gin.Context) {
+	id, productID, ok := orderItemParams(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.RemoveOrderItem(id, productID, actorFromContext(c))
+	if err != nil {
+		respondEditOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// CancelOrderItem godoc
+// @Summary Cancel part of an order item
+// @Description Cancel some units of a product on a pending or paid order. Totals are recalculated and the units' stock is released. A pending order's payment is reauthorized for the new total; a paid order is refunded the difference.
+// @Tags orders
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param productId path string true "Product ID"
+// @Param cancel body CancelOrderItemRequest true "Units to cancel"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Failure 409 {object} map[string]interface{} "Order no longer editable or concurrent update"
+// @Failure 422 {object} map[string]interface{} "Coupon no longer applicable"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/items/{productId}/cancel [post]
+func (h *OrderHandler) CancelOrderItem(c *gin.Context) {
+	id, productID, ok := orderItemParams(c)
+	if !ok {
+		return
+	}
+
+	var req CancelOrderItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.orderService.CancelOrderItem(id, productID, req.Quantity, actorFromContext(c), req.Reason)
+	if err != nil {
+		respondEditOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func orderItemParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	productID, err := primitive.ObjectIDFromHex(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return id, productID, true
+}
+
+// respondEditOrderError maps the errors of editing an order's lines to
+// responses, falling back to those of placing an order.
+func respondEditOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidOrderEdit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, mongo.ErrNoDocuments):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, models.ErrOrderNotEditable), transitionErrorStatus(err) == http.StatusConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondCreateOrderError(c, err)
+	}
+}
+
+// GetOrderHistory godoc
+// @Summary Get an order's status history
+// @Description Get every status change of an order with its actor, reason and timestamp
+// @Tags orders
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Success 200 {array} models.StatusChange
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Security BearerAuth
+// @Router /orders/{id}/history [get]
+func (h *OrderHandler) GetOrderHistory(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	history, err := h.orderService.GetOrderHistory(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// actorFromContext returns the authenticated user recorded as the actor of
+// a status change.
+func actorFromContext(c *gin.Context) string {
+	if userID, exists := c.Get("user_id"); exists {
+		return userID.(string)
+	}
+	return ""
+}
+
+// parseOrderQuery reads the listing filters, sort and page from the query
+// string. Values are checked further by the order service.
+func parseOrderQuery(c *gin.Context) (models.OrderQuery, error) {
+	query := models.OrderQuery{
+		Sort:   models.OrderSort(c.Query("sort")),
+		Cursor: c.Query("cursor"),
+	}
+
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			query.Filter.Statuses = append(query.Filter.Statuses, models.OrderStatus(strings.TrimSpace(s)))
+		}
+	}
+
+	for param, target := range map[string]**time.Time{
+		"created_from": &query.Filter.CreatedFrom,
+		"created_to":   &query.Filter.CreatedTo,
+	} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: %w", param, err)
+			}
+			*target = &t
+		}
+	}
+
+	// Amounts are compared in the currency's minor units, so it must be known
+	for param, target := range map[string]**money.Money{
+		"min_amount": &query.Filter.MinAmount,
+		"max_amount": &query.Filter.MaxAmount,
+	} {
+		if value := c.Query(param); value != "" {
+			currency := c.Query("currency")
+			if currency == "" {
+				return query, fmt.Errorf("currency is required with %s", param)
+			}
+			amount, err := money.Parse(value, currency)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: %w", param, err)
+			}
+			*target = &amount
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return query, errors.New("limit must be a positive integer")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func listErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidOrderQuery) || errors.Is(err, models.ErrInvalidCursor) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func transitionErrorStatus(err error) int {
+	var transitionErr *models.InvalidTransitionError
+	var conflictErr *models.OrderConflictError
+	if errors.As(err, &transitionErr) || errors.As(err, &conflictErr) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PaymentHandler struct {
+	orderService   models.OrderService
+	paymentService models.PaymentService
+}
+
+func NewPaymentHandler(orderService models.OrderService, paymentService models.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		orderService:   orderService,
+		paymentService: paymentService,
+	}
+}
+
+// RefundRequest takes the amount as money; a bare number is read in the
+// payment's currency.
+type RefundRequest struct {
+	Amount money.Money `json:"amount"`
+	Reason string      `json:"reason"`
+}
+
+// GetOrderPayment godoc
+// @Summary Get an order's payment
+// @Description Get the payment record, including provider attempts, for an order
+// @Tags payments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.Payment
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 404 {object} map[string]interface{} "Payment not found"
+// @Security BearerAuth
+// @Router /orders/{id}/payment [get]
+func (h *PaymentHandler) GetOrderPayment(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	payment, err := h.paymentService.GetOrderPayment(id)
+	if err != nil {
+		if errors.Is(err, models.ErrPaymentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// RefundOrder godoc
+// @Summary Refund an order
+// @Description Refund part of a completed order's payment, or all of it when no amount is given. The order moves to refunded once fully refunded.
+// @Tags payments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param refund body RefundRequest false "Refund amount"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Payment
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 409 {object} map[string]interface{} "Payment cannot be refunded"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/refund [post]
+func (h *PaymentHandler) RefundOrder(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req RefundRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	payment, err := h.orderService.RefundOrder(id, req.Amount, actorFromContext(c), req.Reason)
+	if err != nil {
+		switch {
+		case transitionErrorStatus(err) == http.StatusConflict:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidPaymentState), errors.Is(err, models.ErrRefundExceedsAmount):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidRefund):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrPaymentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
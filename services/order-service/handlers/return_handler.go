@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ReturnHandler struct {
+	returnService models.ReturnService
+}
+
+func NewReturnHandler(returnService models.ReturnService) *ReturnHandler {
+	return &ReturnHandler{
+		returnService: returnService,
+	}
+}
+
+type OpenReturnRequest struct {
+	Items  []models.ReturnItem `json:"items" binding:"required,min=1,dive"`
+	Reason string              `json:"reason" binding:"required"`
+}
+
+type ApproveReturnRequest struct {
+	RefundAmount money.Money `json:"refund_amount"`
+}
+
+type RejectReturnRequest struct {
+	Reason string `json:"reason"`
+}
+
+// OpenReturn godoc
+// @Summary Open a return
+// @Description Request to return some items of a completed order
+// @Tags returns
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param return body OpenReturnRequest true "Items to return"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 201 {object} models.Return
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Order belongs to another user"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Failure 409 {object} map[string]interface{} "Order cannot be returned"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/returns [post]
+func (h *ReturnHandler) OpenReturn(c *gin.Context) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req OpenReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(actorFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	orderReturn, err := h.returnService.OpenReturn(orderID, userID, req.Items, req.Reason)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, orderReturn)
+}
+
+// GetOrderReturns godoc
+// @Summary List an order's returns
+// @Description Get every return opened for an order
+// @Tags returns
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Success 200 {array} models.Return
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/returns [get]
+func (h *ReturnHandler) GetOrderReturns(c *gin.Context) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	returns, err := h.returnService.GetOrderReturns(orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetReturn godoc
+// @Summary Get a return
+// @Description Get a return of an order with its status history
+// @Tags returns
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param returnId path string true "Return ID"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 404 {object} map[string]interface{} "Return not found"
+// @Security BearerAuth
+// @Router /orders/{id}/returns/{returnId} [get]
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	orderReturn, err := h.returnService.GetReturn(orderID, returnID)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orderReturn)
+}
+
+// ApproveReturn godoc
+// @Summary Approve a return
+// @Description Approve a requested return, restock its items and refund the given amount, or the full value of the items when no amount is given. Retrying an approved return resumes a failed restock or refund.
+// @Tags returns
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param returnId path string true "Return ID"
+// @Param approval body ApproveReturnRequest false "Refund amount"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 404 {object} map[string]interface{} "Return not found"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/returns/{returnId}/approve [post]
+func (h *ReturnHandler) ApproveReturn(c *gin.Context) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	var req ApproveReturnRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	orderReturn, err := h.returnService.ApproveReturn(orderID, returnID, req.RefundAmount, actorFromContext(c))
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orderReturn)
+}
+
+// RejectReturn godoc
+// @Summary Reject a return
+// @Description Reject a requested return
+// @Tags returns
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param returnId path string true "Return ID"
+// @Param rejection body RejectReturnRequest false "Rejection reason"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 404 {object} map[string]interface{} "Return not found"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
+// @Security BearerAuth
+// @Router /orders/{id}/returns/{returnId}/reject [post]
+func (h *ReturnHandler) RejectReturn(c *gin.Context) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	var req RejectReturnRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	orderReturn, err := h.returnService.RejectReturn(orderID, returnID, actorFromContext(c), req.Reason)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orderReturn)
+}
+
+// CancelReturn godoc
+// @Summary Cancel a return
+// @Description Withdraw a return the shop has not decided on yet
+// @Tags returns
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param returnId path string true "Return ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 403 {object} map[string]interface{} "Return belongs to another user"
+// @Failure 404 {object} map[string]interface{} "Return not found"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
+// @Security BearerAuth
+// @Router /orders/{id}/returns/{returnId}/cancel [post]
+func (h *ReturnHandler) CancelReturn(c *gin.Context) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(actorFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	orderReturn, err := h.returnService.CancelReturn(orderID, returnID, userID)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orderReturn)
+}
+
+// returnIDs parses the order and return IDs from the path, answering 400
+// when either is malformed.
+func returnIDs(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	returnID, err := primitive.ObjectIDFromHex(c.Param("returnId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return orderID, returnID, true
+}
+
+func returnErrorStatus(err error) int {
+	var transitionErr *models.InvalidReturnTransitionError
+	switch {
+	case errors.Is(err, models.ErrInvalidReturn), errors.Is(err, models.ErrRefundTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrOrderNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrReturnNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrOrderNotEligible), errors.Is(err, models.ErrReturnConflict), errors.As(err, &transitionErr):
+		return http.StatusConflict
+	default:
+		return transitionErrorStatus(err)
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ShipmentHandler struct {
+	shipmentService models.ShipmentService
+}
+
+func NewShipmentHandler(shipmentService models.ShipmentService) *ShipmentHandler {
+	return &ShipmentHandler{
+		shipmentService: shipmentService,
+	}
+}
+
+type CreateShipmentRequest struct {
+	WarehouseID    string                `json:"warehouse_id" binding:"required"`
+	Carrier        string                `json:"carrier"`
+	TrackingNumber string                `json:"tracking_number"`
+	Items          []models.ShipmentItem `json:"items" binding:"required,min=1,dive"`
+}
+
+type UpdateShipmentRequest struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+// CreateShipment godoc
+// @Summary Create a shipment
+// @Description Create a shipment for some of a processing order's items, sent from one warehouse
+// @Tags shipments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param shipment body CreateShipmentRequest true "Shipment"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 201 {object} models.Shipment
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 404 {object} map[string]interface{} "Order not found"
+// @Failure 409 {object} map[string]interface{} "Order cannot be shipped, or another shipment was created concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/shipments [post]
+func (h *ShipmentHandler) CreateShipment(c *gin.Context) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shipment := &models.Shipment{
+		WarehouseID:    req.WarehouseID,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Items:          req.Items,
+	}
+	if err := h.shipmentService.CreateShipment(orderID, shipment); err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, shipment)
+}
+
+// GetOrderShipments godoc
+// @Summary List an order's shipments
+// @Description Get every shipment of an order
+// @Tags shipments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Success 200 {array} models.Shipment
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/shipments [get]
+func (h *ShipmentHandler) GetOrderShipments(c *gin.Context) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	shipments, err := h.shipmentService.GetOrderShipments(orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipments)
+}
+
+// GetShipment godoc
+// @Summary Get a shipment
+// @Description Get a shipment of an order
+// @Tags shipments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param shipmentId path string true "Shipment ID"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 404 {object} map[string]interface{} "Shipment not found"
+// @Security BearerAuth
+// @Router /orders/{id}/shipments/{shipmentId} [get]
+func (h *ShipmentHandler) GetShipment(c *gin.Context) {
+	orderID, shipmentID, ok := shipmentIDs(c)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.shipmentService.GetShipment(orderID, shipmentID)
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// UpdateShipment godoc
+// @Summary Update a shipment
+// @Description Change the carrier or tracking number of a shipment that has not been delivered
+// @Tags shipments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param shipmentId path string true "Shipment ID"
+// @Param shipment body UpdateShipmentRequest true "Tracking details"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 404 {object} map[string]interface{} "Shipment not found"
+// @Failure 409 {object} map[string]interface{} "Shipment already delivered or concurrent update"
+// @Security BearerAuth
+// @Router /orders/{id}/shipments/{shipmentId} [put]
+func (h *ShipmentHandler) UpdateShipment(c *gin.Context) {
+	orderID, shipmentID, ok := shipmentIDs(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shipment, err := h.shipmentService.UpdateTracking(orderID, shipmentID, req.Carrier, req.TrackingNumber)
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// ShipShipment godoc
+// @Summary Mark a shipment as shipped
+// @Description Record that the shipment left its warehouse. The first shipment to leave moves the order to shipped.
+// @Tags shipments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param shipmentId path string true "Shipment ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {object} map[string]interface{} "Missing carrier or tracking number"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 404 {object} map[string]interface{} "Shipment not found"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/shipments/{shipmentId}/ship [post]
+func (h *ShipmentHandler) ShipShipment(c *gin.Context) {
+	orderID, shipmentID, ok := shipmentIDs(c)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.shipmentService.MarkShipped(orderID, shipmentID, actorFromContext(c))
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// DeliverShipment godoc
+// @Summary Mark a shipment as delivered
+// @Description Record that the shipment reached the customer. The order is delivered and completed once every item has been delivered.
+// @Tags shipments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Order ID"
+// @Param shipmentId path string true "Shipment ID"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 403 {object} map[string]interface{} "Not staff of the order's shop"
+// @Failure 404 {object} map[string]interface{} "Shipment not found"
+// @Failure 409 {object} map[string]interface{} "Invalid status transition or concurrent update"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /orders/{id}/shipments/{shipmentId}/deliver [post]
+func (h *ShipmentHandler) DeliverShipment(c *gin.Context) {
+	orderID, shipmentID, ok := shipmentIDs(c)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.shipmentService.MarkDelivered(orderID, shipmentID, actorFromContext(c))
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// shipmentIDs parses the order and shipment IDs from the path, answering
+// 400 when either is malformed.
+func shipmentIDs(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	shipmentID, err := primitive.ObjectIDFromHex(c.Param("shipmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return orderID, shipmentID, true
+}
+
+func shipmentErrorStatus(err error) int {
+	var transitionErr *models.InvalidShipmentTransitionError
+	switch {
+	case errors.Is(err, models.ErrInvalidShipment):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrShipmentNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrOrderNotShippable), errors.Is(err, models.ErrShipmentDelivered),
+		errors.Is(err, models.ErrShipmentConflict), errors.As(err, &transitionErr):
+		return http.StatusConflict
+	default:
+		return transitionErrorStatus(err)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TaxHandler struct {
+	taxService models.TaxService
+}
+
+func NewTaxHandler(taxService models.TaxService) *TaxHandler {
+	return &TaxHandler{
+		taxService: taxService,
+	}
+}
+
+// CreateTaxRate godoc
+// @Summary Create a tax rate
+// @Description Create the tax rate for a shop location and product category. Leave location or category_id out to match all of them.
+// @Tags taxes
+// @Accept  json
+// @Produce  json
+// @Param rate body models.TaxRate true "Tax rate"
+// @Success 201 {object} models.TaxRate
+// @Failure 400 {object} map[string]interface{} "Invalid tax rate"
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 409 {object} map[string]interface{} "A rate already exists for this location and category"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /tax-rates [post]
+func (h *TaxHandler) CreateTaxRate(c *gin.Context) {
+	var rate models.TaxRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.taxService.CreateRate(&rate); err != nil {
+		c.JSON(taxErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// ListTaxRates godoc
+// @Summary List tax rates
+// @Description Get every configured tax rate
+// @Tags taxes
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.TaxRate
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /tax-rates [get]
+func (h *TaxHandler) ListTaxRates(c *gin.Context) {
+	rates, err := h.taxService.ListRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// GetTaxRate godoc
+// @Summary Get a tax rate
+// @Description Get a tax rate by its ID
+// @Tags taxes
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Tax rate ID"
+// @Success 200 {object} models.TaxRate
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 404 {object} map[string]interface{} "Tax rate not found"
+// @Security BearerAuth
+// @Router /tax-rates/{id} [get]
+func (h *TaxHandler) GetTaxRate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	rate, err := h.taxService.GetRate(id)
+	if err != nil {
+		c.JSON(taxErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// UpdateTaxRate godoc
+// @Summary Update a tax rate
+// @Description Replace a tax rate. Orders already placed keep the tax they were charged.
+// @Tags taxes
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Tax rate ID"
+// @Param rate body models.TaxRate true "Tax rate"
+// @Success 200 {object} models.TaxRate
+// @Failure 400 {object} map[string]interface{} "Invalid tax rate"
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 404 {object} map[string]interface{} "Tax rate not found"
+// @Failure 409 {object} map[string]interface{} "A rate already exists for this location and category"
+// @Security BearerAuth
+// @Router /tax-rates/{id} [put]
+func (h *TaxHandler) UpdateTaxRate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var rate models.TaxRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate.ID = id
+
+	if err := h.taxService.UpdateRate(&rate); err != nil {
+		c.JSON(taxErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DeleteTaxRate godoc
+// @Summary Delete a tax rate
+// @Description Delete a tax rate. Orders already placed keep the tax they were charged.
+// @Tags taxes
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Tax rate ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 403 {object} map[string]interface{} "Not an admin"
+// @Failure 404 {object} map[string]interface{} "Tax rate not found"
+// @Security BearerAuth
+// @Router /tax-rates/{id} [delete]
+func (h *TaxHandler) DeleteTaxRate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := h.taxService.DeleteRate(id); err != nil {
+		c.JSON(taxErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func taxErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidTaxRate):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrTaxRateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrTaxRateExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,88 @@
+package invoices
+
+import (
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+)
+
+// Build copies a completed order, its shop and its customer into an
+// unnumbered invoice. Lines charged at the same tax are summed into one
+// entry of the tax breakdown, in the order they first appear.
+func Build(order *models.Order, shop *models.ShopInfo, issuedAt time.Time) *models.Invoice {
+	invoice := &models.Invoice{
+		ShopID:  order.ShopID,
+		OrderID: order.ID,
+		Seller: models.InvoiceSeller{
+			ShopID:   order.ShopID,
+			Name:     shop.Name,
+			Location: shop.Location,
+		},
+		Buyer:         models.InvoiceBuyer{UserID: order.UserID},
+		Lines:         make([]models.InvoiceLine, len(order.Items)),
+		CouponCodes:   order.CouponCodes,
+		Currency:      order.Currency,
+		Subtotal:      order.Subtotal,
+		Discount:      order.Discount,
+		Tax:           order.Tax,
+		Shipping:      order.Shipping,
+		Total:         order.TotalAmount,
+		ExchangeRates: order.ExchangeRates,
+		OrderedAt:     order.CreatedAt,
+		IssuedAt:      issuedAt,
+	}
+	if order.Customer != nil {
+		invoice.Buyer.Email = order.Customer.Email
+		invoice.Buyer.Phone = order.Customer.Phone
+	}
+
+	for i, item := range order.Items {
+		invoice.Lines[i] = models.InvoiceLine{
+			ProductID: item.ProductID,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: item.Price,
+			Discount:  item.Discount(),
+			Net:       item.Net(),
+			TaxName:   item.Tax.Name,
+			TaxRate:   item.Tax.Rate,
+			TaxMode:   item.Tax.Mode,
+			Tax:       taxAmount(item.Tax.Amount, order.Currency),
+			Total:     item.Total(),
+		}
+		invoice.Taxes = addTax(invoice.Taxes, invoice.Lines[i])
+	}
+
+	return invoice
+}
+
+func addTax(taxes []models.InvoiceTax, line models.InvoiceLine) []models.InvoiceTax {
+	if line.Tax.IsZero() {
+		return taxes
+	}
+	for i := range taxes {
+		tax := &taxes[i]
+		if tax.Name == line.TaxName && tax.Rate == line.TaxRate && tax.Mode == line.TaxMode {
+			tax.Taxable = tax.Taxable.Add(line.Net)
+			tax.Amount = tax.Amount.Add(line.Tax)
+			return taxes
+		}
+	}
+	return append(taxes, models.InvoiceTax{
+		Name:    line.TaxName,
+		Rate:    line.TaxRate,
+		Mode:    line.TaxMode,
+		Taxable: line.Net,
+		Amount:  line.Tax,
+	})
+}
+
+// taxAmount fills in the currency of lines no rate matched, whose zero tax
+// may have been stored without one.
+func taxAmount(amount money.Money, currency string) money.Money {
+	if amount.IsZero() {
+		return money.Zero(currency)
+	}
+	return amount
+}
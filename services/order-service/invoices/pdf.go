@@ -0,0 +1,295 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ecommerce/order-service/models"
+)
+
+// A4 in points, and the margins the content stays within.
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginLeft   = 50
+	marginRight  = 545
+	marginTop    = 790
+	marginBottom = 60
+)
+
+// Right edges of the numeric columns of the line table.
+const (
+	columnQuantity = 300
+	columnPrice    = 370
+	columnDiscount = 430
+	columnTax      = 480
+	columnTotal    = marginRight
+)
+
+// RenderPDF lays the invoice out on A4 pages. It only uses the standard
+// Helvetica fonts, which PDF readers provide, so nothing is embedded and
+// text outside Windows-1252 is shown as "?".
+func RenderPDF(invoice *models.Invoice) []byte {
+	doc := newDocument()
+
+	doc.write(marginLeft, 20, true, "INVOICE")
+	doc.right(marginRight, 12, true, invoice.Number)
+	doc.skip(30)
+	doc.field("Issued", invoice.IssuedAt.UTC().Format("2006-01-02"))
+	doc.field("Order", invoice.OrderID.Hex())
+	doc.field("Ordered", invoice.OrderedAt.UTC().Format("2006-01-02"))
+	doc.field("Currency", invoice.Currency)
+	doc.skip(16)
+
+	top := doc.y
+	doc.write(marginLeft, 10, true, "Seller")
+	doc.skip(14)
+	doc.write(marginLeft, 10, false, invoice.Seller.Name)
+	doc.skip(14)
+	doc.write(marginLeft, 10, false, invoice.Seller.Location)
+	doc.skip(14)
+	doc.write(marginLeft, 10, false, "Shop "+invoice.Seller.ShopID.Hex())
+	bottom := doc.y
+
+	doc.y = top
+	doc.write(320, 10, true, "Buyer")
+	doc.skip(14)
+	doc.write(320, 10, false, "Customer "+invoice.Buyer.UserID.Hex())
+	doc.skip(14)
+	doc.write(320, 10, false, invoice.Buyer.Email)
+	doc.skip(14)
+	doc.write(320, 10, false, invoice.Buyer.Phone)
+	if bottom < doc.y {
+		doc.y = bottom
+	}
+	doc.skip(30)
+
+	lineHeader := func() {
+		doc.write(marginLeft, 9, true, "Item")
+		doc.right(columnQuantity, 9, true, "Qty")
+		doc.right(columnPrice, 9, true, "Unit price")
+		doc.right(columnDiscount, 9, true, "Discount")
+		doc.right(columnTax, 9, true, "Tax")
+		doc.right(columnTotal, 9, true, "Total")
+		doc.skip(6)
+		doc.rule()
+		doc.skip(14)
+	}
+	lineHeader()
+	for _, line := range invoice.Lines {
+		if doc.full(14) {
+			doc.newPage()
+			lineHeader()
+		}
+		doc.write(marginLeft, 9, false, truncate(line.Name, 38))
+		doc.right(columnQuantity, 9, false, strconv.Itoa(line.Quantity))
+		doc.right(columnPrice, 9, false, line.UnitPrice.Decimal())
+		doc.right(columnDiscount, 9, false, line.Discount.Decimal())
+		doc.right(columnTax, 9, false, line.Tax.Decimal())
+		doc.right(columnTotal, 9, false, line.Total.Decimal())
+		doc.skip(14)
+	}
+	doc.rule()
+	doc.skip(20)
+
+	if len(invoice.Taxes) > 0 {
+		if doc.full(14 * (len(invoice.Taxes) + 1)) {
+			doc.newPage()
+		}
+		doc.write(marginLeft, 9, true, "Taxes")
+		doc.skip(14)
+		for _, tax := range invoice.Taxes {
+			name := tax.Name
+			if name == "" {
+				name = "Tax"
+			}
+			doc.write(marginLeft, 9, false, fmt.Sprintf("%s %s%% (%s) on %s", truncate(name, 30), strconv.FormatFloat(tax.Rate, 'f', -1, 64), tax.Mode, tax.Taxable))
+			doc.right(columnTotal, 9, false, tax.Amount.String())
+			doc.skip(14)
+		}
+		doc.skip(10)
+	}
+
+	if doc.full(16 * 6) {
+		doc.newPage()
+	}
+	doc.total("Subtotal", invoice.Subtotal.String(), false)
+	doc.total("Discount", invoice.Discount.Neg().String(), false)
+	doc.total("Tax", invoice.Tax.String(), false)
+	doc.total("Shipping", invoice.Shipping.String(), false)
+	doc.total("Total", invoice.Total.String(), true)
+	doc.skip(10)
+
+	var notes []string
+	if len(invoice.CouponCodes) > 0 {
+		notes = append(notes, "Coupons: "+strings.Join(invoice.CouponCodes, ", "))
+	}
+	for _, rate := range invoice.ExchangeRates {
+		notes = append(notes, fmt.Sprintf("Converted from %s at 1 %s = %s %s (%s, %s)",
+			rate.From, rate.From, strconv.FormatFloat(rate.Rate, 'f', -1, 64), rate.To, rate.Source, rate.AsOf.UTC().Format("2006-01-02")))
+	}
+	for _, note := range notes {
+		if doc.full(12) {
+			doc.newPage()
+		}
+		doc.write(marginLeft, 8, false, note)
+		doc.skip(12)
+	}
+
+	return doc.bytes()
+}
+
+// document collects the text of each page and writes the PDF objects at
+// the end, once the number of pages is known.
+type document struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newDocument() *document {
+	doc := &document{}
+	doc.newPage()
+	return doc
+}
+
+func (d *document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = marginTop
+}
+
+func (d *document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// full reports whether height more points would run into the bottom margin.
+func (d *document) full(height int) bool {
+	return d.y-float64(height) < marginBottom
+}
+
+func (d *document) skip(height float64) {
+	d.y -= height
+}
+
+func (d *document) write(x float64, size float64, bold bool, text string) {
+	if text == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, number(size), number(x), number(d.y), escape(text))
+}
+
+// right writes text ending at x.
+func (d *document) right(x float64, size float64, bold bool, text string) {
+	d.write(x-textWidth(text, size), size, bold, text)
+}
+
+func (d *document) field(label string, value string) {
+	d.write(marginLeft, 10, true, label)
+	d.write(marginLeft+70, 10, false, value)
+	d.skip(14)
+}
+
+func (d *document) total(label string, value string, bold bool) {
+	d.write(columnDiscount-60, 10, bold, label)
+	d.right(columnTotal, 10, bold, value)
+	d.skip(16)
+}
+
+func (d *document) rule() {
+	fmt.Fprintf(d.page(), "0.5 w %d %s m %d %s l S\n", marginLeft, number(d.y), marginRight, number(d.y))
+}
+
+func (d *document) bytes() []byte {
+	// Objects 1 and 2 are the catalog and page tree, 3 and 4 the fonts,
+	// then a page object and its content stream for every page.
+	var objects []string
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range d.pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+func number(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// escape encodes text as a PDF string in Windows-1252, which matches
+// Latin-1 outside 0x80-0x9F.
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7F:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth estimates the width of Helvetica text from the widths of its
+// digits and punctuation, which is what right-aligned columns hold.
+func textWidth(text string, size float64) float64 {
+	units := 0
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			units += 556
+		case r == '.' || r == ',' || r == ' ':
+			units += 278
+		case r == '-':
+			units += 333
+		case r >= 'A' && r <= 'Z':
+			units += 667
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/events"
+
+	"go.uber.org/zap"
+)
+
+// OutboxRelay publishes the events in the outbox to the broker, oldest
+// first. An event that fails to publish is retried on the next run before
+// any later event, so consumers see each order's events in order. After
+// maxAttempts failed attempts the event is dead-lettered and the relay
+// moves on, so one event the broker never accepts cannot hold up the rest.
+type OutboxRelay struct {
+	outboxRepo  models.OutboxRepository
+	publisher   events.Publisher
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	logger      *zap.Logger
+}
+
+func NewOutboxRelay(outboxRepo models.OutboxRepository, publisher events.Publisher, interval time.Duration, batchSize, maxAttempts int, logger *zap.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo:  outboxRepo,
+		publisher:   publisher,
+		interval:    interval,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+		logger:      logger,
+	}
+}
+
+func (j *OutboxRelay) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				published, err := j.Relay(ctx)
+				if err != nil {
+					j.logger.Error("Outbox relay failed", zap.Int("published", published), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Relay publishes pending events until the outbox is empty or publishing
+// an event fails short of its last attempt, and returns how many it
+// published.
+func (j *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		entries, err := j.outboxRepo.Pending(j.batchSize)
+		if err != nil {
+			return published, err
+		}
+		if len(entries) == 0 {
+			return published, nil
+		}
+
+		for _, entry := range entries {
+			if err := j.publisher.Publish(ctx, entry.Event); err != nil {
+				if entry.Attempts+1 >= j.maxAttempts {
+					metrics.OutboxEvents.WithLabelValues(entry.Event.Type, "dead").Inc()
+					j.logger.Error("Dead-lettering outbox event", zap.String("event_id", entry.Event.ID),
+						zap.String("type", entry.Event.Type), zap.Int("attempts", entry.Attempts+1), zap.Error(err))
+					if markErr := j.outboxRepo.MarkDead(entry.Event.ID, err); markErr != nil {
+						return published, markErr
+					}
+					continue
+				}
+				metrics.OutboxEvents.WithLabelValues(entry.Event.Type, "failed").Inc()
+				if markErr := j.outboxRepo.MarkFailed(entry.Event.ID, err); markErr != nil {
+					j.logger.Error("Failed to record outbox failure", zap.String("event_id", entry.Event.ID), zap.Error(markErr))
+				}
+				return published, err
+			}
+			if err := j.outboxRepo.MarkPublished(entry.Event.ID); err != nil {
+				return published, err
+			}
+			metrics.OutboxEvents.WithLabelValues(entry.Event.Type, "published").Inc()
+			published++
+		}
+	}
+}
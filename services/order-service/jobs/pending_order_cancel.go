@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// PendingOrderCancelJob cancels orders whose payment was abandoned: orders
+// still pending after their shop's timeout. Cancelling releases the stock
+// reservations and voids the payment authorization like any other
+// cancellation, and the reason is recorded in the order's history.
+//
+// Timeouts are kept between models.MinPendingOrderTimeout and the stock
+// reservation TTL: an order whose reservations have lapsed is cancelled
+// rather than left pending without stock held for it.
+type PendingOrderCancelJob struct {
+	orderRepo      models.OrderRepository
+	orderService   models.OrderService
+	shopDirectory  models.ShopDirectory
+	defaultTimeout time.Duration
+	reservationTTL time.Duration
+	interval       time.Duration
+	logger         *zap.Logger
+}
+
+func NewPendingOrderCancelJob(orderRepo models.OrderRepository, orderService models.OrderService, shopDirectory models.ShopDirectory, defaultTimeout, reservationTTL, interval time.Duration, logger *zap.Logger) *PendingOrderCancelJob {
+	return &PendingOrderCancelJob{
+		orderRepo:      orderRepo,
+		orderService:   orderService,
+		shopDirectory:  shopDirectory,
+		defaultTimeout: defaultTimeout,
+		reservationTTL: reservationTTL,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+func (j *PendingOrderCancelJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				cancelled, err := j.CancelStale(ctx, time.Now())
+				if err != nil {
+					j.logger.Error("Pending order cancel job failed", zap.Int("cancelled", cancelled), zap.Error(err))
+				} else if cancelled > 0 {
+					j.logger.Info("Pending order cancel job cancelled orders", zap.Int("cancelled", cancelled))
+				}
+			}
+		}
+	}()
+}
+
+// CancelStale cancels every order that has been pending longer than its
+// shop's timeout at now and returns how many it cancelled. Orders it fails
+// to cancel, including ones paid in the meantime, are logged and left for
+// the next run.
+func (j *PendingOrderCancelJob) CancelStale(ctx context.Context, now time.Time) (int, error) {
+	timeouts := make(map[primitive.ObjectID]time.Duration)
+	cancelled := 0
+
+	// No order is due before the shortest timeout, so younger ones are not
+	// read at all
+	cutoff := now.Add(-models.MinPendingOrderTimeout)
+	query := models.OrderQuery{
+		Filter: models.OrderFilter{
+			Statuses:  []models.OrderStatus{models.OrderStatusPending},
+			CreatedTo: &cutoff,
+		},
+		Sort:  models.OrderSortCreatedAtAsc,
+		Limit: models.MaxOrderPageSize,
+	}
+	for ctx.Err() == nil {
+		page, err := j.orderRepo.List(query)
+		if err != nil {
+			return cancelled, err
+		}
+
+		for _, order := range page.Orders {
+			timeout, known := timeouts[order.ShopID]
+			if !known {
+				timeout, err = j.shopTimeout(order.ShopID)
+				if err != nil {
+					j.logger.Warn("Skipping pending orders of shop", zap.String("shop_id", order.ShopID.Hex()), zap.Error(err))
+				}
+				timeouts[order.ShopID] = timeout
+			}
+			if timeout == 0 || now.Before(order.CreatedAt.Add(timeout)) {
+				continue
+			}
+
+			reason := fmt.Sprintf("payment not completed within %s", timeout)
+			if err := j.orderService.CancelOrder(order.ID, models.AutoCancelActor, reason); err != nil {
+				metrics.OrdersAutoCancelled.WithLabelValues("failed").Inc()
+				j.logger.Warn("Failed to cancel stale pending order", zap.String("order_id", order.ID.Hex()), zap.Error(err))
+				continue
+			}
+			metrics.OrdersAutoCancelled.WithLabelValues("cancelled").Inc()
+			cancelled++
+		}
+
+		if page.NextCursor == "" {
+			return cancelled, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+	return cancelled, ctx.Err()
+}
+
+// shopTimeout returns the shop's pending order timeout, the default for
+// shops without one or unknown to shop-service, and zero when shop-service
+// cannot be asked. Timeouts are kept between models.MinPendingOrderTimeout
+// and the reservation TTL.
+func (j *PendingOrderCancelJob) shopTimeout(shopID primitive.ObjectID) (time.Duration, error) {
+	shop, err := j.shopDirectory.GetShop(shopID)
+	if errors.Is(err, models.ErrShopNotFound) {
+		return j.clamp(j.defaultTimeout), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if shop.PendingOrderTimeout > 0 {
+		if shop.PendingOrderTimeout > j.reservationTTL {
+			j.logger.Warn("Shop pending order timeout outlasts stock reservations, cancelling at the reservation TTL",
+				zap.String("shop_id", shopID.Hex()), zap.Duration("timeout", shop.PendingOrderTimeout), zap.Duration("reservation_ttl", j.reservationTTL))
+		}
+		return j.clamp(shop.PendingOrderTimeout), nil
+	}
+	return j.clamp(j.defaultTimeout), nil
+}
+
+func (j *PendingOrderCancelJob) clamp(timeout time.Duration) time.Duration {
+	if timeout > j.reservationTTL {
+		timeout = j.reservationTTL
+	}
+	if timeout < models.MinPendingOrderTimeout {
+		timeout = models.MinPendingOrderTimeout
+	}
+	return timeout
+}
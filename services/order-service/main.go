@@ -9,12 +9,18 @@ import (
 	"syscall"
 	"time"
 
+	"ecommerce/order-service/clients"
 	"ecommerce/order-service/config"
 	"ecommerce/order-service/handlers"
+	"ecommerce/order-service/jobs"
 	"ecommerce/order-service/middleware"
+	"ecommerce/order-service/migrations"
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/payments"
 	"ecommerce/order-service/repository"
 	"ecommerce/order-service/services"
 	"ecommerce/order-service/utils"
+	"ecommerce/pkg/events"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -50,6 +56,7 @@ func main() {
 		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
 	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
 
 	// Connect to MongoDB
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.MongoDB.Timeout)
@@ -63,14 +70,125 @@ func main() {
 
 	db := mongoClient.Database(cfg.MongoDB.Database)
 
+	// Run database migrations
+	if err := migrations.RunMigrations(db, cfg.Pricing.DefaultCurrency); err != nil {
+		logger.Fatal("Failed to run migrations", zap.Error(err))
+	}
+
+	transactor, err := repository.NewTransactor(mongoClient)
+	if err != nil {
+		logger.Fatal("Failed to inspect MongoDB deployment", zap.Error(err))
+	}
+	if !transactor.Supported() {
+		logger.Warn("MongoDB does not support transactions; order changes and their outbox events are written separately")
+	}
+
 	// Initialize repositories
-	orderRepo := repository.NewMongoOrderRepository(db.Collection("orders"))
+	orderRepo := repository.NewMongoOrderRepository(db.Collection("orders"), db.Collection("outbox"), transactor)
+	outboxRepo := repository.NewMongoOutboxRepository(db.Collection("outbox"))
+	if err := repository.EnsureOutboxIndexes(db.Collection("outbox"), cfg.Events.Retention); err != nil {
+		logger.Fatal("Failed to create outbox indexes", zap.Error(err))
+	}
+	paymentRepo := repository.NewMongoPaymentRepository(db.Collection("payments"))
+	returnRepo := repository.NewMongoReturnRepository(db.Collection("returns"))
+	shipmentRepo := repository.NewMongoShipmentRepository(db.Collection("shipments"))
+	if err := repository.EnsureShipmentIndexes(db.Collection("shipments")); err != nil {
+		logger.Fatal("Failed to create shipment indexes", zap.Error(err))
+	}
+	idempotencyRepo := repository.NewMongoIdempotencyRepository(db.Collection("idempotency_keys"))
+	if err := repository.EnsureIdempotencyIndexes(db.Collection("idempotency_keys")); err != nil {
+		logger.Fatal("Failed to create idempotency indexes", zap.Error(err))
+	}
+	taxRateRepo := repository.NewMongoTaxRateRepository(db.Collection("tax_rates"))
+	if err := repository.EnsureTaxRateIndexes(db.Collection("tax_rates")); err != nil {
+		logger.Fatal("Failed to create tax rate indexes", zap.Error(err))
+	}
+	couponRepo := repository.NewMongoCouponRepository(db)
+	if err := repository.EnsureCouponIndexes(db); err != nil {
+		logger.Fatal("Failed to create coupon indexes", zap.Error(err))
+	}
+	exchangeRateRepo := repository.NewMongoExchangeRateRepository(db.Collection("exchange_rates"))
+	if err := repository.EnsureExchangeRateIndexes(db.Collection("exchange_rates")); err != nil {
+		logger.Fatal("Failed to create exchange rate indexes", zap.Error(err))
+	}
+	invoiceRepo := repository.NewMongoInvoiceRepository(db.Collection("invoices"))
+	if err := repository.EnsureInvoiceIndexes(db.Collection("invoices")); err != nil {
+		logger.Fatal("Failed to create invoice indexes", zap.Error(err))
+	}
+	cartRepo := repository.NewMongoCartRepository(db.Collection("carts"))
+	if err := repository.EnsureCartIndexes(db.Collection("carts")); err != nil {
+		logger.Fatal("Failed to create cart indexes", zap.Error(err))
+	}
+
+	// Initialize clients
+	warehouseClient := clients.NewWarehouseClient(cfg.Services.WarehouseServiceURL, cfg.JWT.Secret, cfg.Services.RequestTimeout)
+	productClient := clients.NewProductClient(cfg.Services.ProductServiceURL, cfg.Pricing.DefaultCurrency, cfg.Services.RequestTimeout)
+	shopClient := clients.NewShopClient(cfg.Services.ShopServiceURL, cfg.Services.RequestTimeout)
+
+	// Initialize payment provider
+	var paymentProvider models.PaymentProvider
+	switch cfg.Payment.Provider {
+	case "fake":
+		paymentProvider = payments.NewFakeProvider(cfg.Payment.FakeDeclineAbove)
+	default:
+		logger.Fatal("Unknown payment provider", zap.String("provider", cfg.Payment.Provider))
+	}
+
+	sourcingStrategy := models.SourcingStrategy(cfg.Sourcing.DefaultStrategy)
+	if !sourcingStrategy.Valid() {
+		logger.Fatal("Unknown sourcing strategy", zap.String("strategy", cfg.Sourcing.DefaultStrategy))
+	}
+	if cfg.AutoCancel.PendingTimeout < models.MinPendingOrderTimeout || cfg.AutoCancel.PendingTimeout > cfg.Reservation.TTL {
+		logger.Fatal("Pending order timeout must be between the minimum timeout and the reservation TTL",
+			zap.Duration("timeout", cfg.AutoCancel.PendingTimeout),
+			zap.Duration("minimum", models.MinPendingOrderTimeout),
+			zap.Duration("reservation_ttl", cfg.Reservation.TTL))
+	}
+
+	// Initialize event broker
+	var broker events.Broker
+	switch cfg.Events.Broker {
+	case "mongo":
+		busEvents := mongoClient.Database(cfg.Events.BusDatabase).Collection("events")
+		if err := events.EnsureMongoBrokerIndexes(busEvents, cfg.Events.Retention); err != nil {
+			logger.Fatal("Failed to create event bus indexes", zap.Error(err))
+		}
+		broker = events.NewMongoBroker(busEvents, cfg.Events.RelayInterval)
+	case "memory":
+		broker = events.NewMemoryBroker(cfg.Events.RelayInterval)
+	default:
+		logger.Fatal("Unknown event broker", zap.String("broker", cfg.Events.Broker))
+	}
 
 	// Initialize services
-	orderService := services.NewOrderService(orderRepo)
+	paymentService := services.NewPaymentService(paymentRepo, paymentProvider)
+	sourcingService := services.NewSourcingService(shopClient, warehouseClient, sourcingStrategy)
+	promotionService := services.NewPromotionService(couponRepo)
+	taxService := services.NewTaxService(taxRateRepo, shopClient)
+	exchangeService := services.NewExchangeService(exchangeRateRepo)
+	invoiceService := services.NewInvoiceService(invoiceRepo, shopClient)
+	if path := cfg.Pricing.ExchangeRatesFile; path != "" {
+		count, err := exchangeService.LoadRatesFile(path)
+		if err != nil {
+			logger.Fatal("Failed to load exchange rates", zap.String("path", path), zap.Error(err))
+		}
+		logger.Info("Loaded exchange rates", zap.String("path", path), zap.Int("count", count))
+	}
+	orderService := services.NewOrderService(orderRepo, warehouseClient, productClient, sourcingService, exchangeService, promotionService, taxService, paymentService, invoiceService, cfg.Reservation.TTL)
+	returnService := services.NewReturnService(returnRepo, orderService, warehouseClient)
+	shipmentService := services.NewShipmentService(shipmentRepo, orderService)
+	cartService := services.NewCartService(cartRepo, productClient, sourcingService, orderService, cfg.Cart.AnonymousTTL)
 
 	// Initialize handlers
 	orderHandler := handlers.NewOrderHandler(orderService)
+	paymentHandler := handlers.NewPaymentHandler(orderService, paymentService)
+	invoiceHandler := handlers.NewInvoiceHandler(orderService, invoiceService)
+	returnHandler := handlers.NewReturnHandler(returnService)
+	shipmentHandler := handlers.NewShipmentHandler(shipmentService)
+	couponHandler := handlers.NewCouponHandler(promotionService)
+	taxHandler := handlers.NewTaxHandler(taxService)
+	exchangeRateHandler := handlers.NewExchangeRateHandler(exchangeService)
+	cartHandler := handlers.NewCartHandler(cartService)
 	healthHandler := handlers.NewHealthHandler()
 
 	// Initialize Gin router
@@ -92,20 +210,90 @@ func main() {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// API routes
+	auth := middleware.AuthMiddleware(cfg.JWT.Secret)
+	idempotent := middleware.Idempotency(idempotencyRepo, cfg.Idempotency.TTL)
+	viewer := middleware.RequireOrderAccess(orderService, models.OrderAccessView)
+	customer := middleware.RequireOrderAccess(orderService, models.OrderAccessCustomer)
+	shopStaff := middleware.RequireOrderAccess(orderService, models.OrderAccessShop)
+	support := middleware.RequireRoles(models.RoleAdmin, models.RoleSupport)
 	api := router.Group("/api/v1")
 	{
 		orders := api.Group("/orders")
 		{
-			orders.POST("/", middleware.AuthMiddleware(cfg.JWT.Secret), orderHandler.CreateOrder)
-			orders.GET("/:id", orderHandler.GetOrder)
-			orders.GET("/user", middleware.AuthMiddleware(cfg.JWT.Secret), orderHandler.GetUserOrders)
-			orders.GET("/shop/:shopId", orderHandler.GetShopOrders)
-			orders.POST("/:id/process", middleware.AuthMiddleware(cfg.JWT.Secret), orderHandler.ProcessOrder)
-			orders.POST("/:id/complete", middleware.AuthMiddleware(cfg.JWT.Secret), orderHandler.CompleteOrder)
-			orders.POST("/:id/cancel", middleware.AuthMiddleware(cfg.JWT.Secret), orderHandler.CancelOrder)
+			orders.POST("/", auth, idempotent, orderHandler.CreateOrder)
+			orders.GET("/:id", auth, viewer, orderHandler.GetOrder)
+			orders.GET("/user", auth, orderHandler.GetUserOrders)
+			orders.GET("/shop/:shopId", auth, middleware.RequireShopAccess(), orderHandler.GetShopOrders)
+			orders.GET("/search", auth, support, orderHandler.SearchOrders)
+			orders.GET("/export", auth, support, orderHandler.ExportOrders)
+			orders.GET("/:id/history", auth, viewer, orderHandler.GetOrderHistory)
+			orders.POST("/:id/pay", auth, viewer, idempotent, orderHandler.PayOrder)
+			orders.POST("/:id/process", auth, shopStaff, idempotent, orderHandler.ProcessOrder)
+			orders.POST("/:id/complete", auth, shopStaff, idempotent, orderHandler.CompleteOrder)
+			orders.POST("/:id/cancel", auth, viewer, idempotent, orderHandler.CancelOrder)
+			orders.POST("/:id/items", auth, customer, idempotent, orderHandler.AddOrderItem)
+			orders.DELETE("/:id/items/:productId", auth, customer, idempotent, orderHandler.RemoveOrderItem)
+			orders.POST("/:id/items/:productId/cancel", auth, viewer, idempotent, orderHandler.CancelOrderItem)
+			orders.GET("/:id/payment", auth, viewer, paymentHandler.GetOrderPayment)
+			orders.POST("/:id/refund", auth, shopStaff, idempotent, paymentHandler.RefundOrder)
+			orders.GET("/:id/invoice", auth, viewer, invoiceHandler.GetOrderInvoice)
+			orders.POST("/:id/returns", auth, customer, idempotent, returnHandler.OpenReturn)
+			orders.GET("/:id/returns", auth, viewer, returnHandler.GetOrderReturns)
+			orders.GET("/:id/returns/:returnId", auth, viewer, returnHandler.GetReturn)
+			orders.POST("/:id/returns/:returnId/approve", auth, shopStaff, idempotent, returnHandler.ApproveReturn)
+			orders.POST("/:id/returns/:returnId/reject", auth, shopStaff, idempotent, returnHandler.RejectReturn)
+			orders.POST("/:id/returns/:returnId/cancel", auth, customer, idempotent, returnHandler.CancelReturn)
+			orders.POST("/:id/shipments", auth, shopStaff, idempotent, shipmentHandler.CreateShipment)
+			orders.GET("/:id/shipments", auth, viewer, shipmentHandler.GetOrderShipments)
+			orders.GET("/:id/shipments/:shipmentId", auth, viewer, shipmentHandler.GetShipment)
+			orders.PUT("/:id/shipments/:shipmentId", auth, shopStaff, shipmentHandler.UpdateShipment)
+			orders.POST("/:id/shipments/:shipmentId/ship", auth, shopStaff, idempotent, shipmentHandler.ShipShipment)
+			orders.POST("/:id/shipments/:shipmentId/deliver", auth, shopStaff, idempotent, shipmentHandler.DeliverShipment)
+		}
+
+		coupons := api.Group("/coupons")
+		{
+			coupons.POST("/", auth, couponHandler.CreateCoupon)
+			coupons.GET("/:code", auth, couponHandler.GetCoupon)
+			coupons.POST("/:code/activate", auth, couponHandler.ActivateCoupon)
+			coupons.POST("/:code/deactivate", auth, couponHandler.DeactivateCoupon)
+		}
+
+		taxRates := api.Group("/tax-rates", auth, middleware.RequireRoles(models.RoleAdmin))
+		{
+			taxRates.POST("/", taxHandler.CreateTaxRate)
+			taxRates.GET("/", taxHandler.ListTaxRates)
+			taxRates.GET("/:id", taxHandler.GetTaxRate)
+			taxRates.PUT("/:id", taxHandler.UpdateTaxRate)
+			taxRates.DELETE("/:id", taxHandler.DeleteTaxRate)
+		}
+
+		exchangeRates := api.Group("/exchange-rates", auth, middleware.RequireRoles(models.RoleAdmin))
+		{
+			exchangeRates.GET("/", exchangeRateHandler.ListExchangeRates)
+			exchangeRates.PUT("/:base/:quote", exchangeRateHandler.SetExchangeRate)
+			exchangeRates.DELETE("/:base/:quote", exchangeRateHandler.DeleteExchangeRate)
+		}
+
+		cart := api.Group("/cart")
+		{
+			optionalAuth := middleware.OptionalAuthMiddleware(cfg.JWT.Secret)
+			cart.GET("/", optionalAuth, cartHandler.GetCart)
+			cart.DELETE("/", optionalAuth, cartHandler.ClearCart)
+			cart.POST("/items", optionalAuth, cartHandler.AddCartItem)
+			cart.PUT("/items/:productId", optionalAuth, cartHandler.UpdateCartItem)
+			cart.DELETE("/items/:productId", optionalAuth, cartHandler.RemoveCartItem)
+			cart.POST("/merge", auth, cartHandler.MergeCart)
+			cart.POST("/checkout", auth, idempotent, cartHandler.Checkout)
 		}
 	}
 
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobs.NewOutboxRelay(outboxRepo, broker, cfg.Events.RelayInterval, 100, cfg.Events.MaxAttempts, logger).Start(jobsCtx)
+	jobs.NewPendingOrderCancelJob(orderRepo, orderService, shopClient, cfg.AutoCancel.PendingTimeout, cfg.Reservation.TTL, cfg.AutoCancel.Interval, logger).Start(jobsCtx)
+
 	// Start server
 	srv := &http.Server{
 		Addr:         cfg.Server.Port,
@@ -54,4 +54,75 @@ var (
 			Help: "Total number of stock check errors",
 		},
 	)
+
+	OrderExports = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_exported_orders_total",
+			Help: "Total number of orders written to exports",
+		},
+		[]string{"format"},
+	)
+
+	PaymentOperations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_payment_operations_total",
+			Help: "Total number of payment provider operations",
+		},
+		[]string{"operation", "status"},
+	)
+
+	IdempotentRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_idempotent_requests_total",
+			Help: "Total number of requests carrying an Idempotency-Key",
+		},
+		[]string{"result"},
+	)
+
+	ReturnStatusTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_return_status_transitions_total",
+			Help: "Total number of return status transitions",
+		},
+		[]string{"from", "to"},
+	)
+
+	ShipmentStatusTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_shipment_status_transitions_total",
+			Help: "Total number of shipment status transitions",
+		},
+		[]string{"from", "to"},
+	)
+
+	CouponRedemptions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_coupon_redemptions_total",
+			Help: "Total number of coupon applications, redemptions and releases",
+		},
+		[]string{"result"},
+	)
+
+	OutboxEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_outbox_events_total",
+			Help: "Total number of outbox events the relay published, failed to publish or dead-lettered",
+		},
+		[]string{"type", "result"},
+	)
+
+	OrdersAutoCancelled = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_orders_auto_cancelled_total",
+			Help: "Total number of stale pending orders the auto-cancel job cancelled or failed to cancel",
+		},
+		[]string{"result"},
+	)
+
+	InvoiceIssueFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "order_service_invoice_issue_failures_total",
+			Help: "Total number of invoices that failed to issue when their order was completed",
+		},
+	)
 )
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"ecommerce/order-service/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func AuthMiddleware(secretKey string) gin.HandlerFunc {
@@ -41,13 +46,84 @@ func AuthMiddleware(secretKey string) gin.HandlerFunc {
 			return
 		}
 
-		if userID, ok := claims["user_id"].(string); ok {
-			c.Set("user_id", userID)
-			c.Next()
-		} else {
+		userID, ok := claims["user_id"].(string)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
 			c.Abort()
 			return
 		}
+
+		principal, err := principalFromClaims(userID, claims)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", userID)
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware authenticates requests that carry an
+// Authorization header like AuthMiddleware and lets the others through
+// anonymously.
+func OptionalAuthMiddleware(secretKey string) gin.HandlerFunc {
+	authenticate := AuthMiddleware(secretKey)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authenticate(c)
+	}
+}
+
+// principalFromClaims reads the optional email, phone, roles and shop_ids
+// claims. Tokens without roles belong to customers.
+func principalFromClaims(userID string, claims jwt.MapClaims) (*models.Principal, error) {
+	principal := &models.Principal{UserID: userID}
+	principal.Email, _ = claims["email"].(string)
+	principal.Phone, _ = claims["phone"].(string)
+
+	roles, err := stringsClaim(claims, "roles")
+	if err != nil {
+		return nil, err
+	}
+	principal.Roles = roles
+
+	shopIDs, err := stringsClaim(claims, "shop_ids")
+	if err != nil {
+		return nil, err
+	}
+	for _, hex := range shopIDs {
+		shopID, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, errors.New("Invalid shop ID in token")
+		}
+		principal.ShopIDs = append(principal.ShopIDs, shopID)
+	}
+
+	return principal, nil
+}
+
+func stringsClaim(claims jwt.MapClaims, name string) ([]string, error) {
+	raw, exists := claims[name]
+	if !exists {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s in token", name)
+	}
+	values := make([]string, len(list))
+	for i, item := range list {
+		value, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid %s in token", name)
+		}
+		values[i] = value
 	}
+	return values, nil
 }
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// responseRecorder copies everything written to the client so the response
+// can be stored against the idempotency key.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes a request safe to retry when the client sends an
+// Idempotency-Key header. The first response for a user's key is stored for
+// ttl and replayed for later requests with the same key; reusing the key for
+// a different request is rejected. It must run after AuthMiddleware.
+func Idempotency(repo models.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, body)
+		reserved, err := repo.Reserve(&models.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(ttl),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !reserved {
+			replayIdempotentResponse(c, repo, userID, key, requestHash)
+			return
+		}
+
+		// A panicking handler leaves no response to store; let the key go so
+		// the client can retry instead of waiting out the ttl
+		defer func() {
+			if r := recover(); r != nil {
+				repo.Delete(userID, key)
+				metrics.IdempotentRequests.WithLabelValues("failed").Inc()
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored so the client can retry them
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			repo.Delete(userID, key)
+			metrics.IdempotentRequests.WithLabelValues("failed").Inc()
+			return
+		}
+		if err := repo.Complete(userID, key, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			c.Error(err)
+			return
+		}
+		metrics.IdempotentRequests.WithLabelValues("stored").Inc()
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, repo models.IdempotencyRepository, userID string, key string, requestHash string) {
+	defer c.Abort()
+
+	record, err := repo.Get(userID, key)
+	if err == models.ErrIdempotencyRecordNotFound {
+		// The record expired between the reserve and the lookup
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key expired while the request was checked, retry the request"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if record.RequestHash != requestHash {
+		metrics.IdempotentRequests.WithLabelValues("mismatch").Inc()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+		return
+	}
+	if record.Status != models.IdempotencyStatusCompleted {
+		metrics.IdempotentRequests.WithLabelValues("in_progress").Inc()
+		c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	metrics.IdempotentRequests.WithLabelValues("replayed").Inc()
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(record.ResponseStatus, record.ResponseType, record.ResponseBody)
+}
+
+func hashRequest(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
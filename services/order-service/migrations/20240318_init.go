@@ -2,6 +2,7 @@ package migrations
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -18,7 +19,7 @@ func InitMigration(db *mongo.Database) error {
 	err := db.CreateCollection(ctx, "orders")
 	if err != nil {
 		// Ignore error if collection already exists
-		if !mongo.IsDuplicateKeyError(err) {
+		if !mongo.IsDuplicateKeyError(err) && !collectionExists(err) {
 			return err
 		}
 	}
@@ -77,3 +78,9 @@ func InitMigration(db *mongo.Database) error {
 
 	return nil
 }
+
+// collectionExists reports whether err is MongoDB's NamespaceExists error.
+func collectionExists(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 48
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CustomerEmailMigration lower-cases the customer emails stored on orders,
+// as new orders store them, so the order search can match them by prefix
+// on their index.
+func CustomerEmailMigration(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	_, err := db.Collection("orders").UpdateMany(ctx,
+		bson.M{"customer.email": bson.M{"$type": "string"}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"customer.email": bson.M{"$toLower": "$customer.email"}}}},
+		},
+	)
+	return err
+}
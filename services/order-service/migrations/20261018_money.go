@@ -0,0 +1,131 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// orderAmounts are the order fields that held float64 major units before
+// the money type.
+var orderAmounts = []string{"subtotal", "discount", "tax", "shipping", "total_amount"}
+
+// MoneyMigration converts the amounts of orders stored as float64 major
+// units into money documents: the order totals and, on every item, the
+// price, the coupon adjustments and the tax. Amounts are taken to be in the
+// order's currency, or in defaultCurrency when it has none, and the items'
+// own currency field is dropped. Each update only applies while the total
+// is still a number, so running it twice is harmless.
+func MoneyMigration(defaultCurrency string) func(*mongo.Database) error {
+	return func(db *mongo.Database) error {
+		ctx := context.Background()
+		orders := db.Collection("orders")
+		legacy := bson.M{"total_amount": bson.M{"$type": "number"}}
+
+		cursor, err := orders.Find(ctx, legacy)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		converted := 0
+		for cursor.Next(ctx) {
+			set, err := convertOrder(cursor.Current, defaultCurrency)
+			if err != nil {
+				return err
+			}
+
+			_, err = orders.UpdateOne(ctx,
+				bson.M{"_id": cursor.Current.Lookup("_id"), "total_amount": bson.M{"$type": "number"}},
+				bson.M{"$set": set},
+			)
+			if err != nil {
+				return err
+			}
+			converted++
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		log.Printf("Converted the amounts of %d orders to money", converted)
+		return nil
+	}
+}
+
+// convertOrder builds the $set document that rewrites one order's amounts.
+func convertOrder(order bson.Raw, defaultCurrency string) (bson.M, error) {
+	currency, _ := order.Lookup("currency").StringValueOK()
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	set := bson.M{"currency": currency}
+	for _, field := range orderAmounts {
+		if amount, ok := money.FromBSONNumber(order.Lookup(field), currency); ok {
+			set[field] = amount
+		}
+	}
+
+	array, ok := order.Lookup("items").ArrayOK()
+	if !ok {
+		return set, nil
+	}
+	values, err := array.Values()
+	if err != nil {
+		return nil, err
+	}
+	items := make(bson.A, 0, len(values))
+	for _, value := range values {
+		doc := value.Document()
+		item, err := convertAmounts(doc, currency, "price")
+		if err != nil {
+			return nil, err
+		}
+		delete(item, "currency")
+
+		if tax, ok := doc.Lookup("tax").DocumentOK(); ok {
+			if item["tax"], err = convertAmounts(tax, currency, "amount"); err != nil {
+				return nil, err
+			}
+		}
+
+		if adjustments, ok := doc.Lookup("adjustments").ArrayOK(); ok {
+			adjustmentValues, err := adjustments.Values()
+			if err != nil {
+				return nil, err
+			}
+			converted := make(bson.A, 0, len(adjustmentValues))
+			for _, adjustment := range adjustmentValues {
+				doc, err := convertAmounts(adjustment.Document(), currency, "amount")
+				if err != nil {
+					return nil, err
+				}
+				converted = append(converted, doc)
+			}
+			item["adjustments"] = converted
+		}
+		items = append(items, item)
+	}
+	set["items"] = items
+	return set, nil
+}
+
+// convertAmounts decodes doc and replaces the numeric fields among fields
+// with money of currency.
+func convertAmounts(doc bson.Raw, currency string, fields ...string) (bson.M, error) {
+	var converted bson.M
+	if err := bson.Unmarshal(doc, &converted); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if amount, ok := money.FromBSONNumber(doc.Lookup(field), currency); ok {
+			converted[field] = amount
+		}
+	}
+	return converted, nil
+}
@@ -0,0 +1,173 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PaymentMoneyMigration converts the float64 amounts left after
+// MoneyMigration into money documents:
+//
+//   - payments: the amount, the refunded amount and every attempt's amount
+//   - returns: the refund amount, and each item's unit price into the value
+//     of its returned units
+//   - coupons: the value into the percentage of percentage coupons or the
+//     amount of fixed amount coupons, and the minimum order value
+//
+// Amounts are taken to be in the document's currency, or in defaultCurrency
+// when it has none. Each update only applies while the converted field is
+// still a number, so running it twice is harmless.
+func PaymentMoneyMigration(defaultCurrency string) func(*mongo.Database) error {
+	return func(db *mongo.Database) error {
+		ctx := context.Background()
+		collections := []struct {
+			name    string
+			legacy  bson.M
+			convert func(bson.Raw, string) (bson.M, error)
+		}{
+			{"payments", bson.M{"amount": bson.M{"$type": "number"}}, convertPayment},
+			{"returns", bson.M{"refund_amount": bson.M{"$type": "number"}}, convertReturn},
+			{"coupons", bson.M{"value": bson.M{"$type": "number"}}, convertCoupon},
+		}
+
+		for _, collection := range collections {
+			converted, err := convertDocuments(ctx, db.Collection(collection.name), collection.legacy, func(doc bson.Raw) (bson.M, error) {
+				return collection.convert(doc, defaultCurrency)
+			})
+			if err != nil {
+				return err
+			}
+			log.Printf("Converted the amounts of %d %s to money", converted, collection.name)
+		}
+		return nil
+	}
+}
+
+// convertDocuments applies the update convert builds to every document
+// matching legacy, as long as it still matches.
+func convertDocuments(ctx context.Context, collection *mongo.Collection, legacy bson.M, convert func(bson.Raw) (bson.M, error)) (int, error) {
+	cursor, err := collection.Find(ctx, legacy)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	converted := 0
+	for cursor.Next(ctx) {
+		update, err := convert(cursor.Current)
+		if err != nil {
+			return converted, err
+		}
+
+		filter := bson.M{"_id": cursor.Current.Lookup("_id")}
+		for field, condition := range legacy {
+			filter[field] = condition
+		}
+		if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+			return converted, err
+		}
+		converted++
+	}
+	return converted, cursor.Err()
+}
+
+func documentCurrency(doc bson.Raw, defaultCurrency string) string {
+	if currency, _ := doc.Lookup("currency").StringValueOK(); currency != "" {
+		return currency
+	}
+	return defaultCurrency
+}
+
+func convertPayment(payment bson.Raw, defaultCurrency string) (bson.M, error) {
+	currency := documentCurrency(payment, defaultCurrency)
+	set := bson.M{"currency": currency, "refunded_amount": money.Zero(currency)}
+	for _, field := range []string{"amount", "refunded_amount"} {
+		if amount, ok := money.FromBSONNumber(payment.Lookup(field), currency); ok {
+			set[field] = amount
+		}
+	}
+
+	if array, ok := payment.Lookup("attempts").ArrayOK(); ok {
+		values, err := array.Values()
+		if err != nil {
+			return nil, err
+		}
+		attempts := make(bson.A, 0, len(values))
+		for _, value := range values {
+			attempt, err := convertAmounts(value.Document(), currency, "amount")
+			if err != nil {
+				return nil, err
+			}
+			attempts = append(attempts, attempt)
+		}
+		set["attempts"] = attempts
+	}
+	return bson.M{"$set": set}, nil
+}
+
+func convertReturn(orderReturn bson.Raw, defaultCurrency string) (bson.M, error) {
+	currency := documentCurrency(orderReturn, defaultCurrency)
+	set := bson.M{"currency": currency}
+	if amount, ok := money.FromBSONNumber(orderReturn.Lookup("refund_amount"), currency); ok {
+		set["refund_amount"] = amount
+	}
+
+	if array, ok := orderReturn.Lookup("items").ArrayOK(); ok {
+		values, err := array.Values()
+		if err != nil {
+			return nil, err
+		}
+		items := make(bson.A, 0, len(values))
+		for _, value := range values {
+			doc := value.Document()
+			var item bson.M
+			if err := bson.Unmarshal(doc, &item); err != nil {
+				return nil, err
+			}
+			// Multiply before rounding, so a third of 10.00 times three
+			// is 10.00 again
+			var price float64
+			if err := doc.Lookup("price").Unmarshal(&price); err != nil {
+				return nil, err
+			}
+			quantity, _ := doc.Lookup("quantity").AsInt64OK()
+			item["value"] = money.FromMajor(price*float64(quantity), currency)
+			delete(item, "price")
+			items = append(items, item)
+		}
+		set["items"] = items
+	}
+	return bson.M{"$set": set}, nil
+}
+
+// convertCoupon moves the value into percent or amount. Coupons without a
+// currency are taken to be in defaultCurrency, and a zero minimum order
+// value is dropped.
+func convertCoupon(coupon bson.Raw, defaultCurrency string) (bson.M, error) {
+	currency := documentCurrency(coupon, defaultCurrency)
+	set := bson.M{}
+	unset := bson.M{"value": "", "currency": ""}
+
+	value := coupon.Lookup("value")
+	if kind, _ := coupon.Lookup("type").StringValueOK(); kind == "fixed_amount" {
+		set["amount"], _ = money.FromBSONNumber(value, currency)
+	} else {
+		var percent float64
+		if err := value.Unmarshal(&percent); err != nil {
+			return nil, err
+		}
+		set["percent"] = percent
+	}
+
+	if minimum, ok := money.FromBSONNumber(coupon.Lookup("min_order_value"), currency); ok && minimum.IsPositive() {
+		set["min_order_value"] = minimum
+	} else {
+		unset["min_order_value"] = ""
+	}
+	return bson.M{"$set": set, "$unset": unset}, nil
+}
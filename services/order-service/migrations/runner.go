@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Migration struct {
+	Version   int
+	Name      string
+	Timestamp time.Time
+	Up        func(*mongo.Database) error
+	Down      func(*mongo.Database) error
+}
+
+// migrations lists every migration in version order. Documents older than
+// the money type that carry no currency are taken to be in defaultCurrency.
+func migrations(defaultCurrency string) []Migration {
+	return []Migration{
+		{
+			Version:   1,
+			Name:      "init",
+			Timestamp: time.Now(),
+			Up:        InitMigration,
+			Down:      nil,
+		},
+		{
+			Version:   2,
+			Name:      "money",
+			Timestamp: time.Now(),
+			Up:        MoneyMigration(defaultCurrency),
+			Down:      nil,
+		},
+		{
+			Version:   3,
+			Name:      "order_search",
+			Timestamp: time.Now(),
+			Up:        OrderSearchMigration,
+			Down:      nil,
+		},
+		{
+			Version:   4,
+			Name:      "payment_money",
+			Timestamp: time.Now(),
+			Up:        PaymentMoneyMigration(defaultCurrency),
+			Down:      nil,
+		},
+		{
+			Version:   5,
+			Name:      "customer_email",
+			Timestamp: time.Now(),
+			Up:        CustomerEmailMigration,
+			Down:      nil,
+		},
+	}
+}
+
+func RunMigrations(db *mongo.Database, defaultCurrency string) error {
+	ctx := context.Background()
+
+	// Create migrations collection if not exists
+	migrationsCollection := db.Collection("migrations")
+
+	for _, migration := range migrations(defaultCurrency) {
+		// Check if migration has been applied
+		count, err := migrationsCollection.CountDocuments(ctx, map[string]interface{}{
+			"version": migration.Version,
+		})
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			log.Printf("Running migration %d: %s", migration.Version, migration.Name)
+
+			if err := migration.Up(db); err != nil {
+				return err
+			}
+
+			// Record migration
+			_, err = migrationsCollection.InsertOne(ctx, map[string]interface{}{
+				"version":   migration.Version,
+				"name":      migration.Name,
+				"timestamp": time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrCartItemNotFound = errors.New("product is not in the cart")
+	ErrCartEmpty        = errors.New("cart is empty")
+	ErrCartConflict     = errors.New("cart was modified concurrently")
+	ErrCartShopMismatch = errors.New("cart holds products of another shop")
+	ErrInvalidCartItem  = errors.New("invalid cart item")
+)
+
+// CartOwner identifies a cart: the signed-in user's, or the anonymous cart
+// with the given token.
+type CartOwner struct {
+	UserID *primitive.ObjectID
+	Token  string
+}
+
+// Cart is a basket of products from one shop that has not been ordered
+// yet. Anonymous carts are found by their token and expire at ExpiresAt;
+// a user's cart never expires.
+type Cart struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Token     string              `bson:"token,omitempty" json:"token,omitempty"`
+	ShopID    primitive.ObjectID  `bson:"shop_id,omitempty" json:"shop_id,omitempty"`
+	Items     []CartItem          `bson:"items" json:"items"`
+	Version   int                 `bson:"version" json:"version"`
+	ExpiresAt *time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// CartItem is a product in a cart. Price is the catalog price when the
+// item was last added or changed, to tell the customer about changes.
+type CartItem struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Quantity  int                `bson:"quantity" json:"quantity"`
+	Price     money.Money        `bson:"price" json:"price"`
+}
+
+// Find returns the index of the product in the cart, or -1.
+func (c *Cart) Find(productID primitive.ObjectID) int {
+	for i, item := range c.Items {
+		if item.ProductID == productID {
+			return i
+		}
+	}
+	return -1
+}
+
+type CartProblem string
+
+const (
+	// CartProblemUnavailable marks products that no longer exist.
+	CartProblemUnavailable CartProblem = "unavailable"
+	// CartProblemOutOfStock marks products the shop's warehouses cannot
+	// cover in the requested quantity.
+	CartProblemOutOfStock CartProblem = "out_of_stock"
+)
+
+// CartLine is a cart item checked against the catalog and the shop's stock
+// when the cart was read.
+type CartLine struct {
+	ProductID     primitive.ObjectID `json:"product_id"`
+	Name          string             `json:"name"`
+	Quantity      int                `json:"quantity"`
+	Price         money.Money        `json:"price"`
+	PreviousPrice *money.Money       `json:"previous_price,omitempty"`
+	Subtotal      money.Money        `json:"subtotal"`
+	Available     *int               `json:"available,omitempty"`
+	Problem       CartProblem        `json:"problem,omitempty"`
+}
+
+// CartView is a cart priced at current catalog prices. Subtotal is left
+// out when the lines are in more than one currency, since they are only
+// converted at checkout. A cart can be checked out when it is Valid.
+type CartView struct {
+	Cart     *Cart        `json:"cart"`
+	Lines    []CartLine   `json:"lines"`
+	Subtotal *money.Money `json:"subtotal,omitempty"`
+	Valid    bool         `json:"valid"`
+}
+
+// CartInvalidError is returned when a cart with problems is checked out.
+type CartInvalidError struct {
+	View *CartView
+}
+
+func (e *CartInvalidError) Error() string {
+	return "cart has unavailable or out of stock items"
+}
+
+// CheckoutRequest carries the order details that are not in the cart.
+// Customer comes from the caller's token rather than the request body.
+type CheckoutRequest struct {
+	CouponCodes []string  `json:"coupon_codes"`
+	Currency    string    `json:"currency"`
+	Customer    *Customer `json:"-"`
+}
+
+type CartRepository interface {
+	Get(owner CartOwner) (*Cart, error)
+	// Save inserts a new cart or replaces the stored one when its version
+	// is still the one read, and returns ErrCartConflict otherwise.
+	Save(cart *Cart) error
+	// Delete removes the cart if its version is still the one read.
+	Delete(cart *Cart) error
+}
+
+type CartService interface {
+	// GetCart returns the owner's cart, empty if there is none yet.
+	GetCart(owner CartOwner) (*CartView, error)
+	// AddItem adds quantity of a product of the shop to the cart, creating
+	// the cart if needed. A new anonymous cart gets a new token.
+	AddItem(owner CartOwner, shopID primitive.ObjectID, productID primitive.ObjectID, quantity int) (*CartView, error)
+	// UpdateItem sets the quantity of a product; zero removes it.
+	UpdateItem(owner CartOwner, productID primitive.ObjectID, quantity int) (*CartView, error)
+	RemoveItem(owner CartOwner, productID primitive.ObjectID) (*CartView, error)
+	ClearCart(owner CartOwner) error
+	// MergeCart moves the anonymous cart with the token into the user's
+	// cart, adding up quantities of the same product. When the carts are
+	// from different shops, the anonymous cart replaces the user's.
+	MergeCart(userID primitive.ObjectID, token string) (*CartView, error)
+	// Checkout places an order for the user's cart and empties it.
+	Checkout(userID primitive.ObjectID, req CheckoutRequest) (*Order, error)
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CouponType string
+
+const (
+	// CouponTypePercentage takes Percent percent off the lines in scope.
+	CouponTypePercentage CouponType = "percentage"
+	// CouponTypeFixedAmount takes Amount off the lines in scope, split in
+	// proportion to their totals.
+	CouponTypeFixedAmount CouponType = "fixed_amount"
+)
+
+var (
+	ErrCouponNotFound         = errors.New("coupon not found")
+	ErrInvalidCoupon          = errors.New("invalid coupon")
+	ErrCouponExists           = errors.New("coupon code already exists")
+	ErrCouponNotApplicable    = errors.New("coupon not applicable")
+	ErrCouponExhausted        = errors.New("coupon has no redemptions left")
+	ErrCouponUserLimitReached = errors.New("coupon redemption limit reached for this user")
+)
+
+// Coupon is a discount customers can apply to an order by its code. A nil
+// ShopID and an empty ProductIDs leave the coupon unrestricted; a zero
+// limit means no limit. Coupons with an Amount or a MinOrderValue only
+// apply to orders in that currency.
+type Coupon struct {
+	ID                    primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Code                  string               `bson:"code" json:"code"`
+	Type                  CouponType           `bson:"type" json:"type"`
+	Percent               float64              `bson:"percent,omitempty" json:"percent,omitempty"`
+	Amount                *money.Money         `bson:"amount,omitempty" json:"amount,omitempty"`
+	ShopID                *primitive.ObjectID  `bson:"shop_id,omitempty" json:"shop_id,omitempty"`
+	ProductIDs            []primitive.ObjectID `bson:"product_ids,omitempty" json:"product_ids,omitempty"`
+	MinOrderValue         *money.Money         `bson:"min_order_value,omitempty" json:"min_order_value,omitempty"`
+	MaxRedemptions        int                  `bson:"max_redemptions" json:"max_redemptions"`
+	MaxRedemptionsPerUser int                  `bson:"max_redemptions_per_user" json:"max_redemptions_per_user"`
+	Redemptions           int                  `bson:"redemptions" json:"redemptions"`
+	ValidFrom             *time.Time           `bson:"valid_from,omitempty" json:"valid_from,omitempty"`
+	ValidUntil            *time.Time           `bson:"valid_until,omitempty" json:"valid_until,omitempty"`
+	Active                bool                 `bson:"active" json:"active"`
+	CreatedAt             time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt             time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// NormalizeCouponCode makes codes case-insensitive.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate upper-cases the currencies of the amounts and checks the coupon.
+func (c *Coupon) Validate() error {
+	for _, amount := range []*money.Money{c.Amount, c.MinOrderValue} {
+		if amount != nil {
+			amount.Currency = strings.ToUpper(strings.TrimSpace(amount.Currency))
+		}
+	}
+
+	if c.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidCoupon)
+	}
+	switch c.Type {
+	case CouponTypePercentage:
+		if c.Percent <= 0 || c.Percent > 100 {
+			return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidCoupon)
+		}
+	case CouponTypeFixedAmount:
+		if c.Amount == nil || !c.Amount.IsPositive() {
+			return fmt.Errorf("%w: amount must be greater than 0", ErrInvalidCoupon)
+		}
+		if !money.ValidCurrency(c.Amount.Currency) {
+			return fmt.Errorf("%w: amount must have an ISO 4217 currency", ErrInvalidCoupon)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidCoupon, c.Type)
+	}
+	if c.MinOrderValue != nil {
+		if c.MinOrderValue.IsNegative() {
+			return fmt.Errorf("%w: limits cannot be negative", ErrInvalidCoupon)
+		}
+		if !money.ValidCurrency(c.MinOrderValue.Currency) {
+			return fmt.Errorf("%w: minimum order value must have an ISO 4217 currency", ErrInvalidCoupon)
+		}
+		if c.Amount != nil && c.Amount.Currency != c.MinOrderValue.Currency {
+			return fmt.Errorf("%w: amount and minimum order value must be in the same currency", ErrInvalidCoupon)
+		}
+	}
+	if c.MaxRedemptions < 0 || c.MaxRedemptionsPerUser < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidCoupon)
+	}
+	if c.ValidFrom != nil && c.ValidUntil != nil && !c.ValidUntil.After(*c.ValidFrom) {
+		return fmt.Errorf("%w: valid_until must be after valid_from", ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// Covers reports whether the coupon's product scope includes the product.
+func (c *Coupon) Covers(productID primitive.ObjectID) bool {
+	if len(c.ProductIDs) == 0 {
+		return true
+	}
+	for _, id := range c.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// Adjustment is a discount a coupon took off one order line.
+type Adjustment struct {
+	CouponID primitive.ObjectID `bson:"coupon_id" json:"coupon_id"`
+	Code     string             `bson:"code" json:"code"`
+	Amount   money.Money        `bson:"amount" json:"amount"`
+}
+
+type CouponRepository interface {
+	Create(coupon *Coupon) error
+	GetByCode(code string) (*Coupon, error)
+	SetActive(code string, active bool) (*Coupon, error)
+	// Redeem atomically counts one use of the coupon by the user for the
+	// order, failing with ErrCouponExhausted or ErrCouponUserLimitReached
+	// when a limit is reached. Redeeming again for the same order is a
+	// no-op.
+	Redeem(coupon *Coupon, userID primitive.ObjectID, orderID primitive.ObjectID) error
+	// Release gives back the use counted for the order, if there was one.
+	Release(couponID primitive.ObjectID, orderID primitive.ObjectID) error
+}
+
+type PromotionService interface {
+	CreateCoupon(coupon *Coupon) error
+	GetCoupon(code string) (*Coupon, error)
+	SetCouponActive(code string, active bool) (*Coupon, error)
+	// ApplyCoupons checks the order's coupon codes and records their
+	// discounts as adjustments on its items. Nothing is redeemed yet.
+	ApplyCoupons(order *Order) error
+	// RedeemCoupons counts a use of every coupon applied to the order
+	// except those listed in counted, giving back the ones it counted if
+	// any limit is reached.
+	RedeemCoupons(order *Order, counted ...primitive.ObjectID) error
+	// ReleaseCoupons gives back the uses counted for the order.
+	ReleaseCoupons(order *Order) error
+	// ReleaseCouponUses gives back the uses the order counted of the given
+	// coupons, such as those an edit left without a discount.
+	ReleaseCouponUses(order *Order, couponIDs []primitive.ObjectID) error
+}
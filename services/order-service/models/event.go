@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventSource names order-service as the source of the events it publishes.
+const EventSource = "order-service"
+
+// Order event types. A status change is published as the event of the
+// status the order moved to.
+const (
+	EventOrderCreated    = "order.created"
+	EventOrderUpdated    = "order.updated"
+	EventOrderPaid       = "order.paid"
+	EventOrderProcessing = "order.processing"
+	EventOrderShipped    = "order.shipped"
+	EventOrderDelivered  = "order.delivered"
+	EventOrderCompleted  = "order.completed"
+	EventOrderCancelled  = "order.cancelled"
+	EventOrderRefunded   = "order.refunded"
+)
+
+// OrderEventTypes lists every event type order-service publishes.
+var OrderEventTypes = []string{
+	EventOrderCreated, EventOrderUpdated, EventOrderPaid, EventOrderProcessing, EventOrderShipped,
+	EventOrderDelivered, EventOrderCompleted, EventOrderCancelled, EventOrderRefunded,
+}
+
+// StatusEventType returns the event published when an order moves to
+// status.
+func StatusEventType(status OrderStatus) string {
+	return "order." + string(status)
+}
+
+// OrderEventData is the payload of every order event: the order as it was
+// after the change, and for status changes the change itself.
+type OrderEventData struct {
+	OrderID   primitive.ObjectID `json:"order_id"`
+	UserID    primitive.ObjectID `json:"user_id"`
+	ShopID    primitive.ObjectID `json:"shop_id"`
+	Customer  *Customer          `json:"customer,omitempty"`
+	Status    OrderStatus        `json:"status"`
+	Change    *StatusChange      `json:"change,omitempty"`
+	Items     []OrderEventItem   `json:"items"`
+	Total     money.Money        `json:"total"`
+	Version   int                `json:"version"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type OrderEventItem struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	Name      string             `json:"name"`
+	Quantity  int                `json:"quantity"`
+	Price     money.Money        `json:"price"`
+}
+
+// NewOrderEvent describes a change to the order as an event of the given
+// type. The change is nil unless the order's status changed.
+func NewOrderEvent(eventType string, order *Order, change *StatusChange) (events.Event, error) {
+	data := OrderEventData{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		ShopID:    order.ShopID,
+		Customer:  order.Customer,
+		Status:    order.Status,
+		Change:    change,
+		Items:     make([]OrderEventItem, len(order.Items)),
+		Total:     order.TotalAmount,
+		Version:   order.Version,
+		CreatedAt: order.CreatedAt,
+	}
+	for i, item := range order.Items {
+		data.Items[i] = OrderEventItem{ProductID: item.ProductID, Name: item.Name, Quantity: item.Quantity, Price: item.Price}
+	}
+	payload, err := events.NewData(data)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	occurredAt := time.Now()
+	if change != nil {
+		occurredAt = change.At
+	}
+	return events.Event{
+		ID:         primitive.NewObjectID().Hex(),
+		Type:       eventType,
+		Source:     EventSource,
+		Subject:    order.ID.Hex(),
+		OccurredAt: occurredAt,
+		Data:       payload,
+	}, nil
+}
+
+// OutboxEntry is an event waiting in the outbox, written together with the
+// order change it describes, until the relay publishes it. An event that
+// keeps failing is dead-lettered: it stays in the outbox with its last
+// error but is no longer relayed.
+type OutboxEntry struct {
+	Event       events.Event `bson:",inline"`
+	CreatedAt   time.Time    `bson:"created_at"`
+	PublishedAt *time.Time   `bson:"published_at,omitempty"`
+	DeadAt      *time.Time   `bson:"dead_at,omitempty"`
+	Attempts    int          `bson:"attempts"`
+	LastError   string       `bson:"last_error,omitempty"`
+}
+
+type OutboxRepository interface {
+	// Pending returns the oldest entries that are neither published nor
+	// dead-lettered, oldest first.
+	Pending(limit int) ([]OutboxEntry, error)
+	MarkPublished(id string) error
+	// MarkFailed counts a failed attempt to publish the entry.
+	MarkFailed(id string, cause error) error
+	// MarkDead counts a last failed attempt and dead-letters the entry.
+	MarkDead(id string, cause error) error
+}
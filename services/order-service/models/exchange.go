@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// ExchangeRateSourceFile marks rates loaded from the rates file at
+	// startup.
+	ExchangeRateSourceFile = "file"
+	// ExchangeRateSourceAdmin marks rates set through the admin API.
+	ExchangeRateSourceAdmin = "admin"
+)
+
+var (
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+	ErrInvalidExchangeRate  = errors.New("invalid exchange rate")
+	ErrInvalidCurrency      = errors.New("invalid currency")
+)
+
+// ExchangeRate is the number of units of Quote one unit of Base buys. A
+// rate also converts from Quote to Base, at its inverse.
+type ExchangeRate struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Base      string             `bson:"base" json:"base"`
+	Quote     string             `bson:"quote" json:"quote"`
+	Rate      float64            `bson:"rate" json:"rate"`
+	Source    string             `bson:"source" json:"source"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Validate upper-cases the currencies and checks the rate.
+func (r *ExchangeRate) Validate() error {
+	r.Base = strings.ToUpper(strings.TrimSpace(r.Base))
+	r.Quote = strings.ToUpper(strings.TrimSpace(r.Quote))
+	if !money.ValidCurrency(r.Base) || !money.ValidCurrency(r.Quote) {
+		return fmt.Errorf("%w: base and quote must be ISO 4217 codes", ErrInvalidExchangeRate)
+	}
+	if r.Base == r.Quote {
+		return fmt.Errorf("%w: base and quote must differ", ErrInvalidExchangeRate)
+	}
+	if r.Rate <= 0 {
+		return fmt.Errorf("%w: rate must be greater than 0", ErrInvalidExchangeRate)
+	}
+	return nil
+}
+
+// AppliedExchangeRate records a conversion made when an order was placed,
+// so its prices can be traced back to the catalog after rates change.
+type AppliedExchangeRate struct {
+	From   string    `bson:"from" json:"from"`
+	To     string    `bson:"to" json:"to"`
+	Rate   float64   `bson:"rate" json:"rate"`
+	Source string    `bson:"source" json:"source"`
+	AsOf   time.Time `bson:"as_of" json:"as_of"`
+}
+
+type ExchangeRateRepository interface {
+	// Upsert creates or replaces the rate for its base and quote.
+	Upsert(rate *ExchangeRate) error
+	Get(base string, quote string) (*ExchangeRate, error)
+	List() ([]ExchangeRate, error)
+	Delete(base string, quote string) error
+}
+
+type ExchangeService interface {
+	SetRate(rate *ExchangeRate) error
+	ListRates() ([]ExchangeRate, error)
+	DeleteRate(base string, quote string) error
+	// LoadRatesFile sets every rate listed in the JSON file at path and
+	// returns how many there were.
+	LoadRatesFile(path string) (int, error)
+	// ConvertOrder converts the prices of the order's items into the
+	// order's currency and records the rates it used on the order.
+	ConvertOrder(order *Order) error
+}
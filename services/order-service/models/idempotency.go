@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	IdempotencyStatusInProgress = "in_progress"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+var ErrIdempotencyRecordNotFound = errors.New("idempotency record not found")
+
+// IdempotencyRecord stores the first response to a request made with an
+// Idempotency-Key so retries with the same key can be answered from it.
+type IdempotencyRecord struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	Key            string             `bson:"key" json:"key"`
+	RequestHash    string             `bson:"request_hash" json:"request_hash"`
+	Status         string             `bson:"status" json:"status"`
+	ResponseStatus int                `bson:"response_status,omitempty" json:"response_status,omitempty"`
+	ResponseType   string             `bson:"response_type,omitempty" json:"response_type,omitempty"`
+	ResponseBody   []byte             `bson:"response_body,omitempty" json:"response_body,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt      time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+type IdempotencyRepository interface {
+	// Reserve stores a new in-progress record and reports whether it was
+	// stored. It returns false when a live record already holds the key.
+	Reserve(record *IdempotencyRecord) (bool, error)
+	Get(userID string, key string) (*IdempotencyRecord, error)
+	Complete(userID string, key string, status int, contentType string, body []byte) error
+	Delete(userID string, key string) error
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvoiceNotFound   = errors.New("invoice not found")
+	ErrOrderNotCompleted = errors.New("invoices are only issued for completed orders")
+)
+
+// Invoice is the bill issued for a completed order. Its number comes from
+// a gapless sequence per shop, and once issued it is never changed: it
+// keeps the seller, buyer and amounts as they were at that moment.
+type Invoice struct {
+	ID            primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
+	Number        string                `bson:"number" json:"number"`
+	ShopID        primitive.ObjectID    `bson:"shop_id" json:"shop_id"`
+	Sequence      int64                 `bson:"sequence" json:"sequence"`
+	OrderID       primitive.ObjectID    `bson:"order_id" json:"order_id"`
+	Seller        InvoiceSeller         `bson:"seller" json:"seller"`
+	Buyer         InvoiceBuyer          `bson:"buyer" json:"buyer"`
+	Lines         []InvoiceLine         `bson:"lines" json:"lines"`
+	Taxes         []InvoiceTax          `bson:"taxes,omitempty" json:"taxes,omitempty"`
+	CouponCodes   []string              `bson:"coupon_codes,omitempty" json:"coupon_codes,omitempty"`
+	Currency      string                `bson:"currency" json:"currency"`
+	Subtotal      money.Money           `bson:"subtotal" json:"subtotal"`
+	Discount      money.Money           `bson:"discount" json:"discount"`
+	Tax           money.Money           `bson:"tax" json:"tax"`
+	Shipping      money.Money           `bson:"shipping" json:"shipping"`
+	Total         money.Money           `bson:"total" json:"total"`
+	ExchangeRates []AppliedExchangeRate `bson:"exchange_rates,omitempty" json:"exchange_rates,omitempty"`
+	OrderedAt     time.Time             `bson:"ordered_at" json:"ordered_at"`
+	IssuedAt      time.Time             `bson:"issued_at" json:"issued_at"`
+}
+
+type InvoiceSeller struct {
+	ShopID   primitive.ObjectID `bson:"shop_id" json:"shop_id"`
+	Name     string             `bson:"name" json:"name"`
+	Location string             `bson:"location,omitempty" json:"location,omitempty"`
+}
+
+type InvoiceBuyer struct {
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email  string             `bson:"email,omitempty" json:"email,omitempty"`
+	Phone  string             `bson:"phone,omitempty" json:"phone,omitempty"`
+}
+
+type InvoiceLine struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Name      string             `bson:"name" json:"name"`
+	Quantity  int                `bson:"quantity" json:"quantity"`
+	UnitPrice money.Money        `bson:"unit_price" json:"unit_price"`
+	Discount  money.Money        `bson:"discount" json:"discount"`
+	Net       money.Money        `bson:"net" json:"net"`
+	TaxName   string             `bson:"tax_name,omitempty" json:"tax_name,omitempty"`
+	TaxRate   float64            `bson:"tax_rate" json:"tax_rate"`
+	TaxMode   TaxMode            `bson:"tax_mode" json:"tax_mode"`
+	Tax       money.Money        `bson:"tax" json:"tax"`
+	Total     money.Money        `bson:"total" json:"total"`
+}
+
+// InvoiceTax sums the tax charged at one rate across the invoice's lines.
+type InvoiceTax struct {
+	Name    string      `bson:"name,omitempty" json:"name,omitempty"`
+	Rate    float64     `bson:"rate" json:"rate"`
+	Mode    TaxMode     `bson:"mode" json:"mode"`
+	Taxable money.Money `bson:"taxable" json:"taxable"`
+	Amount  money.Money `bson:"amount" json:"amount"`
+}
+
+// InvoiceNumber formats an invoice number from the shop and its sequence.
+func InvoiceNumber(shopID primitive.ObjectID, sequence int64) string {
+	return fmt.Sprintf("INV-%s-%06d", strings.ToUpper(shopID.Hex()), sequence)
+}
+
+type InvoiceRepository interface {
+	// Issue stores the invoice with the next number of its shop's
+	// sequence. If the order already has an invoice, that one is returned
+	// instead and nothing is stored.
+	Issue(invoice *Invoice) (*Invoice, error)
+	GetByOrderID(orderID primitive.ObjectID) (*Invoice, error)
+}
+
+type InvoiceService interface {
+	// IssueInvoice issues the invoice of a completed order, or returns the
+	// one already issued.
+	IssueInvoice(order *Order) (*Invoice, error)
+	// GetInvoice returns the order's invoice, issuing it first if the order
+	// is completed but its invoice could not be issued at the time.
+	GetInvoice(order *Order) (*Invoice, error)
+}
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"context"
+	"fmt"
 	"time"
 
+	"ecommerce/pkg/money"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -10,46 +14,179 @@ type OrderStatus string
 
 const (
 	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusPaid       OrderStatus = "paid"
 	OrderStatusProcessing OrderStatus = "processing"
+	OrderStatusShipped    OrderStatus = "shipped"
+	OrderStatusDelivered  OrderStatus = "delivered"
 	OrderStatusCompleted  OrderStatus = "completed"
 	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusRefunded   OrderStatus = "refunded"
 )
 
+// OrderItem carries a snapshot of the product's name, category and unit
+// price taken from product-service when the order was created, the
+// discounts coupons took off the line and the tax charged on it. Price is
+// in the order's currency; CatalogPrice keeps the catalog price when it had
+// to be converted.
 type OrderItem struct {
-	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
-	Quantity  int                `bson:"quantity" json:"quantity"`
-	Price     float64            `bson:"price" json:"price"`
+	ProductID    primitive.ObjectID  `bson:"product_id" json:"product_id"`
+	Name         string              `bson:"name" json:"name"`
+	CategoryID   *primitive.ObjectID `bson:"category_id,omitempty" json:"category_id,omitempty"`
+	Quantity     int                 `bson:"quantity" json:"quantity"`
+	Price        money.Money         `bson:"price" json:"price"`
+	CatalogPrice *money.Money        `bson:"catalog_price,omitempty" json:"catalog_price,omitempty"`
+	Adjustments  []Adjustment        `bson:"adjustments,omitempty" json:"adjustments,omitempty"`
+	Tax          LineTax             `bson:"tax" json:"tax"`
+}
+
+// Subtotal is the line's value before discounts.
+func (i OrderItem) Subtotal() money.Money {
+	return i.Price.Mul(int64(i.Quantity))
+}
+
+func (i OrderItem) Discount() money.Money {
+	discount := money.Zero(i.Price.Currency)
+	for _, adjustment := range i.Adjustments {
+		discount = discount.Add(adjustment.Amount)
+	}
+	return discount
+}
+
+// Net is the line's value after discounts.
+func (i OrderItem) Net() money.Money {
+	return i.Subtotal().Sub(i.Discount())
+}
+
+// Total is what the customer pays for the line, including tax.
+func (i OrderItem) Total() money.Money {
+	if i.Tax.Mode == TaxModeExclusive {
+		return i.Net().Add(i.Tax.Amount)
+	}
+	return i.Net()
+}
+
+// Customer is how to reach the customer, as their token described them
+// when the order was placed.
+type Customer struct {
+	Email string `bson:"email,omitempty" json:"email,omitempty"`
+	Phone string `bson:"phone,omitempty" json:"phone,omitempty"`
 }
 
 type Order struct {
-	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	UserID      primitive.ObjectID  `bson:"user_id" json:"user_id"`
-	ShopID      primitive.ObjectID  `bson:"shop_id" json:"shop_id"`
-	Items       []OrderItem         `bson:"items" json:"items"`
-	TotalAmount float64             `bson:"total_amount" json:"total_amount"`
-	Status      OrderStatus         `bson:"status" json:"status"`
-	PaymentID   *primitive.ObjectID `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
-	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`
+	ID            primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID    `bson:"user_id" json:"user_id"`
+	Customer      *Customer             `bson:"customer,omitempty" json:"customer,omitempty"`
+	ShopID        primitive.ObjectID    `bson:"shop_id" json:"shop_id"`
+	Items         []OrderItem           `bson:"items" json:"items"`
+	CouponCodes   []string              `bson:"coupon_codes,omitempty" json:"coupon_codes,omitempty"`
+	Subtotal      money.Money           `bson:"subtotal" json:"subtotal"`
+	Discount      money.Money           `bson:"discount" json:"discount"`
+	Tax           money.Money           `bson:"tax" json:"tax"`
+	Shipping      money.Money           `bson:"shipping" json:"shipping"`
+	TotalAmount   money.Money           `bson:"total_amount" json:"total_amount"`
+	Currency      string                `bson:"currency" json:"currency"`
+	ExchangeRates []AppliedExchangeRate `bson:"exchange_rates,omitempty" json:"exchange_rates,omitempty"`
+	Status        OrderStatus           `bson:"status" json:"status"`
+	PaymentID     *primitive.ObjectID   `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
+	Reservations  []StockReservation    `bson:"reservations,omitempty" json:"reservations,omitempty"`
+	Sourcing      *SourcingPlan         `bson:"sourcing,omitempty" json:"sourcing,omitempty"`
+	StatusHistory []StatusChange        `bson:"status_history" json:"status_history"`
+	Claim         *TransitionClaim      `bson:"claim,omitempty" json:"claim,omitempty"`
+	Edits         []OrderEdit           `bson:"edits,omitempty" json:"edits,omitempty"`
+	Version       int                   `bson:"version" json:"version"`
+	CreatedAt     time.Time             `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time             `bson:"updated_at" json:"updated_at"`
+}
+
+// CalculateTotals sums the lines into the order's subtotal, discount, tax
+// and grand total. Tax included in the lines' prices is reported in Tax but
+// not added again.
+func (o *Order) CalculateTotals() {
+	zero := money.Zero(o.Currency)
+	o.Subtotal, o.Discount, o.Tax = zero, zero, zero
+	total := zero.Add(o.Shipping)
+	for _, item := range o.Items {
+		o.Subtotal = o.Subtotal.Add(item.Subtotal())
+		o.Discount = o.Discount.Add(item.Discount())
+		o.Tax = o.Tax.Add(item.Tax.Amount)
+		total = total.Add(item.Total())
+	}
+	o.TotalAmount = total
+}
+
+// AppliedCoupons lists the coupons that discount at least one of the
+// order's lines, in the order they were applied.
+func (o *Order) AppliedCoupons() []primitive.ObjectID {
+	var coupons []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for _, item := range o.Items {
+		for _, adjustment := range item.Adjustments {
+			if !seen[adjustment.CouponID] {
+				seen[adjustment.CouponID] = true
+				coupons = append(coupons, adjustment.CouponID)
+			}
+		}
+	}
+	return coupons
+}
+
+// OrderConflictError is returned when an order changed between being read
+// and being written, so the write was not applied.
+type OrderConflictError struct {
+	OrderID primitive.ObjectID
+}
+
+func (e *OrderConflictError) Error() string {
+	return fmt.Sprintf("order %s was modified concurrently", e.OrderID.Hex())
 }
 
 type OrderRepository interface {
 	Create(order *Order) error
 	GetByID(id primitive.ObjectID) (*Order, error)
-	GetByUserID(userID primitive.ObjectID) ([]Order, error)
-	GetByShopID(shopID primitive.ObjectID) ([]Order, error)
+	List(query OrderQuery) (*OrderPage, error)
+	// Stream calls fn with every order matching the filter, in the given
+	// order, reading them in batches rather than all at once. It stops at
+	// the first error fn returns.
+	Stream(ctx context.Context, filter OrderFilter, sort OrderSort, fn func(*Order) error) error
 	Update(order *Order) error
-	UpdateStatus(id primitive.ObjectID, status OrderStatus) error
+	// ClaimTransition claims the order's move from its current status to
+	// another before the move's side effects run, and records the claim on
+	// the order. It returns an OrderConflictError if the order changed
+	// status or another transition holds a live claim.
+	ClaimTransition(order *Order, to OrderStatus, actor string) error
+	// ReleaseTransition gives up the order's claim after its side effects
+	// failed.
+	ReleaseTransition(order *Order) error
+	// UpdateStatus records a status change and clears the order's claim.
+	// It returns an OrderConflictError if the order is no longer in
+	// change.From or another transition holds a live claim.
+	UpdateStatus(id primitive.ObjectID, change StatusChange) error
 	Delete(id primitive.ObjectID) error
 }
 
 type OrderService interface {
 	CreateOrder(order *Order) error
 	GetOrder(id primitive.ObjectID) (*Order, error)
-	GetUserOrders(userID primitive.ObjectID) ([]Order, error)
-	GetShopOrders(shopID primitive.ObjectID) ([]Order, error)
-	UpdateOrder(order *Order) error
-	ProcessOrder(id primitive.ObjectID) error
-	CompleteOrder(id primitive.ObjectID) error
-	CancelOrder(id primitive.ObjectID) error
+	GetUserOrders(userID primitive.ObjectID, query OrderQuery) (*OrderPage, error)
+	GetShopOrders(shopID primitive.ObjectID, query OrderQuery) (*OrderPage, error)
+	// SearchOrders returns a page of orders across all users and shops.
+	SearchOrders(query OrderQuery) (*OrderPage, error)
+	// ExportOrders streams every order matching the filter to fn.
+	ExportOrders(ctx context.Context, filter OrderFilter, sort OrderSort, fn func(*Order) error) error
+	GetOrderHistory(id primitive.ObjectID) ([]StatusChange, error)
+	// AddOrderItem adds units of a product to a pending order, on its
+	// existing line or a new one priced from the catalog.
+	AddOrderItem(id primitive.ObjectID, productID primitive.ObjectID, quantity int, actor string) (*Order, error)
+	// RemoveOrderItem drops a product's line from a pending order.
+	RemoveOrderItem(id primitive.ObjectID, productID primitive.ObjectID, actor string) (*Order, error)
+	// CancelOrderItem cancels some units of a product on a pending or paid
+	// order, refunding them if the payment was already captured.
+	CancelOrderItem(id primitive.ObjectID, productID primitive.ObjectID, quantity int, actor string, reason string) (*Order, error)
+	PayOrder(id primitive.ObjectID, actor string) error
+	ProcessOrder(id primitive.ObjectID, actor string) error
+	ShipOrder(id primitive.ObjectID, actor string) error
+	DeliverOrder(id primitive.ObjectID, actor string) error
+	CompleteOrder(id primitive.ObjectID, actor string) error
+	CancelOrder(id primitive.ObjectID, actor string, reason string) error
+	RefundOrder(id primitive.ObjectID, amount money.Money, actor string, reason string) (*Payment, error)
 }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrOrderNotEditable = errors.New("order can no longer be edited")
+	ErrInvalidOrderEdit = errors.New("invalid order edit")
+)
+
+type OrderEditType string
+
+const (
+	OrderEditAddItem    OrderEditType = "add_item"
+	OrderEditRemoveItem OrderEditType = "remove_item"
+	OrderEditCancelItem OrderEditType = "cancel_item"
+)
+
+// OrderEdit is one entry of an order's append-only log of line changes.
+// Quantity is how many units were added, removed or cancelled, and the
+// totals are the order's grand total before and after the change.
+type OrderEdit struct {
+	Type          OrderEditType      `bson:"type" json:"type"`
+	ProductID     primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Quantity      int                `bson:"quantity" json:"quantity"`
+	PreviousTotal money.Money        `bson:"previous_total" json:"previous_total"`
+	NewTotal      money.Money        `bson:"new_total" json:"new_total"`
+	Actor         string             `bson:"actor" json:"actor"`
+	Reason        string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	At            time.Time          `bson:"at" json:"at"`
+}
+
+// Editable reports whether the order's lines may still change: items can
+// be added or removed while it is pending, and cancelled until it is being
+// processed.
+func (s OrderStatus) Editable(edit OrderEditType) bool {
+	switch edit {
+	case OrderEditCancelItem:
+		return s == OrderStatusPending || s == OrderStatusPaid
+	default:
+		return s == OrderStatusPending
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrderSort string
+
+const (
+	OrderSortCreatedAtDesc   OrderSort = "created_at_desc"
+	OrderSortCreatedAtAsc    OrderSort = "created_at_asc"
+	OrderSortTotalAmountDesc OrderSort = "total_amount_desc"
+	OrderSortTotalAmountAsc  OrderSort = "total_amount_asc"
+)
+
+const (
+	DefaultOrderPageSize = 20
+	MaxOrderPageSize     = 100
+	MaxOrderSearchLength = 100
+)
+
+var (
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidOrderQuery = errors.New("invalid order query")
+)
+
+func (s OrderSort) Valid() bool {
+	switch s {
+	case OrderSortCreatedAtDesc, OrderSortCreatedAtAsc, OrderSortTotalAmountDesc, OrderSortTotalAmountAsc:
+		return true
+	default:
+		return false
+	}
+}
+
+// OrderFilter narrows an order listing. Nil and empty fields do not filter.
+// The amount bounds only match orders in their currency. Search matches
+// an order, user, shop or payment ID exactly, or the start of the
+// customer's email, regardless of case, or phone.
+type OrderFilter struct {
+	UserID      *primitive.ObjectID
+	ShopID      *primitive.ObjectID
+	Statuses    []OrderStatus
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	MinAmount   *money.Money
+	MaxAmount   *money.Money
+	Search      string
+}
+
+// OrderQuery asks for one page of orders. Cursor is the NextCursor of the
+// previous page and must be used with the same filter and sort.
+type OrderQuery struct {
+	Filter OrderFilter
+	Sort   OrderSort
+	Limit  int
+	Cursor string
+}
+
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	TotalCount int64   `json:"total_count"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// orderTransitions lists, for every status, the statuses an order may move
+// to next. Statuses without an entry are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusPaid, OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusPaid:       {OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusProcessing: {OrderStatusShipped, OrderStatusCompleted, OrderStatusCancelled},
+	OrderStatusShipped:    {OrderStatusDelivered},
+	OrderStatusDelivered:  {OrderStatusCompleted},
+	OrderStatusCompleted:  {OrderStatusRefunded},
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to
+// next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether s is one of the known order statuses.
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusProcessing, OrderStatusShipped,
+		OrderStatusDelivered, OrderStatusCompleted, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTerminal reports whether no further transitions are possible from s.
+func (s OrderStatus) IsTerminal() bool {
+	return len(orderTransitions[s]) == 0
+}
+
+// AutoCancelActor is the actor recorded when order-service cancels an
+// order that stayed pending too long.
+const AutoCancelActor = "system:auto-cancel"
+
+// MinPendingOrderTimeout is the shortest time an order stays pending before
+// it is cancelled, whatever its shop's timeout. shop-service rejects
+// shorter timeouts.
+const MinPendingOrderTimeout = 5 * time.Minute
+
+// StatusChange is one entry of an order's append-only status history.
+type StatusChange struct {
+	From   OrderStatus `bson:"from,omitempty" json:"from,omitempty"`
+	To     OrderStatus `bson:"to" json:"to"`
+	Actor  string      `bson:"actor" json:"actor"`
+	Reason string      `bson:"reason,omitempty" json:"reason,omitempty"`
+	At     time.Time   `bson:"at" json:"at"`
+}
+
+// TransitionClaimTTL is how long a claimed transition keeps other
+// transitions off an order. A claim left behind by a crash lapses after it.
+const TransitionClaimTTL = 5 * time.Minute
+
+// TransitionClaim marks an order whose move to To has started: its side
+// effects are running and no other transition may begin until the move is
+// recorded or given up.
+type TransitionClaim struct {
+	To        OrderStatus `bson:"to" json:"to"`
+	Actor     string      `bson:"actor" json:"actor"`
+	ClaimedAt time.Time   `bson:"claimed_at" json:"claimed_at"`
+}
+
+type InvalidTransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+// CheckTransition returns an InvalidTransitionError when the state machine
+// does not allow moving from one status to the other.
+func CheckTransition(from OrderStatus, to OrderStatus) error {
+	if !from.CanTransitionTo(to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+	return nil
+}
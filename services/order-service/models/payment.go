@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusAuthorized        PaymentStatus = "authorized"
+	PaymentStatusCaptured          PaymentStatus = "captured"
+	PaymentStatusVoided            PaymentStatus = "voided"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusFailed            PaymentStatus = "failed"
+)
+
+var (
+	ErrPaymentDeclined     = errors.New("payment declined")
+	ErrPaymentUnavailable  = errors.New("payment provider unavailable")
+	ErrPaymentNotFound     = errors.New("payment not found")
+	ErrInvalidPaymentState = errors.New("payment is not in a valid state for this operation")
+	ErrRefundExceedsAmount = errors.New("refund amount exceeds captured amount")
+	ErrInvalidRefund       = errors.New("invalid refund amount")
+)
+
+// PaymentAttempt records a single call to the payment provider.
+type PaymentAttempt struct {
+	Operation         string      `bson:"operation" json:"operation"`
+	Amount            money.Money `bson:"amount" json:"amount"`
+	Success           bool        `bson:"success" json:"success"`
+	ProviderReference string      `bson:"provider_reference,omitempty" json:"provider_reference,omitempty"`
+	Error             string      `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt         time.Time   `bson:"created_at" json:"created_at"`
+}
+
+type Payment struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID           primitive.ObjectID `bson:"order_id" json:"order_id"`
+	Amount            money.Money        `bson:"amount" json:"amount"`
+	RefundedAmount    money.Money        `bson:"refunded_amount" json:"refunded_amount"`
+	Currency          string             `bson:"currency" json:"currency"`
+	Status            PaymentStatus      `bson:"status" json:"status"`
+	Provider          string             `bson:"provider" json:"provider"`
+	ProviderReference string             `bson:"provider_reference,omitempty" json:"provider_reference,omitempty"`
+	Attempts          []PaymentAttempt   `bson:"attempts" json:"attempts"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Refundable is what is left of the captured amount to refund.
+func (p *Payment) Refundable() money.Money {
+	return p.Amount.Sub(p.RefundedAmount)
+}
+
+// PaymentProvider is the gateway that actually moves money. Authorize
+// returns the provider's reference for the authorization; the other
+// operations act on that reference.
+type PaymentProvider interface {
+	Name() string
+	Authorize(reference string, amount money.Money) (string, error)
+	Capture(providerReference string, amount money.Money) error
+	Void(providerReference string) error
+	Refund(providerReference string, amount money.Money) error
+}
+
+type PaymentRepository interface {
+	Create(payment *Payment) error
+	GetByID(id primitive.ObjectID) (*Payment, error)
+	GetByOrderID(orderID primitive.ObjectID) (*Payment, error)
+	Update(payment *Payment) error
+}
+
+type PaymentService interface {
+	Authorize(order *Order) (*Payment, error)
+	Capture(id primitive.ObjectID) (*Payment, error)
+	Void(id primitive.ObjectID) (*Payment, error)
+	// Reauthorize replaces an authorization with one for the order's
+	// current total. The old one stays in place if the new one is declined.
+	Reauthorize(id primitive.ObjectID, order *Order) (*Payment, error)
+	// Refund returns part of a captured payment, or all that is left of it
+	// when amount is zero. An amount without a currency is taken to be in
+	// the payment's.
+	Refund(id primitive.ObjectID, amount money.Money) (*Payment, error)
+	GetPayment(id primitive.ObjectID) (*Payment, error)
+	GetOrderPayment(orderID primitive.ObjectID) (*Payment, error)
+}
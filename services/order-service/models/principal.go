@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleShopStaff = "shop_staff"
+	// RoleSupport may search and export orders across all shops.
+	RoleSupport = "support"
+)
+
+// OrderAccess is what a caller wants to do with an order.
+type OrderAccess int
+
+const (
+	// OrderAccessView covers reading an order and the actions both sides
+	// may take, such as cancelling it.
+	OrderAccessView OrderAccess = iota
+	// OrderAccessCustomer covers actions only the customer may take.
+	OrderAccessCustomer
+	// OrderAccessShop covers actions only the shop may take.
+	OrderAccessShop
+)
+
+// Principal is the authenticated caller as described by its JWT claims.
+// Callers without roles are customers.
+type Principal struct {
+	UserID  string
+	Email   string
+	Phone   string
+	Roles   []string
+	ShopIDs []primitive.ObjectID
+}
+
+// Customer returns the caller's contact details, or nil when the token
+// carries none. The email is lower-cased so order search can match it by
+// prefix on its index.
+func (p *Principal) Customer() *Customer {
+	if p.Email == "" && p.Phone == "" {
+		return nil
+	}
+	return &Customer{Email: strings.ToLower(strings.TrimSpace(p.Email)), Phone: p.Phone}
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// IsShopStaff reports whether the caller works for the given shop.
+func (p *Principal) IsShopStaff(shopID primitive.ObjectID) bool {
+	if !p.HasRole(RoleShopStaff) {
+		return false
+	}
+	for _, id := range p.ShopIDs {
+		if id == shopID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) Owns(order *Order) bool {
+	return order.UserID.Hex() == p.UserID
+}
+
+// CanAccess applies the ownership rules: customers act on their own orders,
+// shop staff on their shop's orders, admins on every order.
+func (p *Principal) CanAccess(order *Order, access OrderAccess) bool {
+	if p.IsAdmin() {
+		return true
+	}
+	switch access {
+	case OrderAccessCustomer:
+		return p.Owns(order)
+	case OrderAccessShop:
+		return p.IsShopStaff(order.ShopID)
+	default:
+		return p.Owns(order) || p.IsShopStaff(order.ShopID)
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrProductNotFound = errors.New("product not found")
+
+// ProductSnapshot is the catalog data copied onto an order item when the
+// order is created. Later catalog changes never touch existing orders.
+type ProductSnapshot struct {
+	ID         primitive.ObjectID
+	Name       string
+	CategoryID *primitive.ObjectID
+	Price      money.Money
+}
+
+// ProductUnavailableError is returned when order items reference products
+// that do not exist in the catalog or have been deleted.
+type ProductUnavailableError struct {
+	ProductIDs []string
+}
+
+func (e *ProductUnavailableError) Error() string {
+	return "products not available: " + strings.Join(e.ProductIDs, ", ")
+}
+
+type ProductCatalog interface {
+	GetProduct(id primitive.ObjectID) (*ProductSnapshot, error)
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockReservation is a warehouse-service reservation held for an order item.
+// Restocked marks a confirmed reservation whose units were put back into
+// stock when its order was cancelled.
+type StockReservation struct {
+	ID          string             `bson:"id" json:"id"`
+	WarehouseID string             `bson:"warehouse_id" json:"warehouse_id"`
+	ProductID   primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Quantity    int                `bson:"quantity" json:"quantity"`
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"`
+	Confirmed   bool               `bson:"confirmed" json:"confirmed"`
+	Restocked   bool               `bson:"restocked,omitempty" json:"restocked,omitempty"`
+}
+
+type StockShortage struct {
+	ProductID   string `json:"product_id"`
+	WarehouseID string `json:"warehouse_id,omitempty"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+// InsufficientStockError is returned when one or more order items cannot be
+// reserved. It carries the per-item shortage report from warehouse-service.
+type InsufficientStockError struct {
+	Shortages []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	products := make([]string, len(e.Shortages))
+	for i, shortage := range e.Shortages {
+		products[i] = fmt.Sprintf("%s (requested %d, available %d)", shortage.ProductID, shortage.Requested, shortage.Available)
+	}
+	return "insufficient stock for products: " + strings.Join(products, ", ")
+}
+
+type WarehouseClient interface {
+	ReserveStock(reference string, allocations []Allocation, ttl time.Duration) ([]StockReservation, error)
+	GetAvailableStock(warehouseID string, productID primitive.ObjectID) (int, error)
+	// HoldReservation stops a reservation from expiring, for orders that
+	// were paid or are being processed.
+	HoldReservation(id string) error
+	ConfirmReservation(id string) error
+	ReleaseReservation(id string) error
+	// RestockItem puts returned units back into a warehouse's on-hand stock.
+	RestockItem(warehouseID string, productID primitive.ObjectID, quantity int) error
+}
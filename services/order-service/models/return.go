@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnStatusRequested ReturnStatus = "requested"
+	ReturnStatusApproved  ReturnStatus = "approved"
+	ReturnStatusRejected  ReturnStatus = "rejected"
+	ReturnStatusCancelled ReturnStatus = "cancelled"
+	ReturnStatusRefunded  ReturnStatus = "refunded"
+)
+
+var (
+	ErrReturnNotFound   = errors.New("return not found")
+	ErrInvalidReturn    = errors.New("invalid return request")
+	ErrReturnConflict   = errors.New("return was modified concurrently")
+	ErrOrderNotOwned    = errors.New("order does not belong to user")
+	ErrRefundTooLarge   = errors.New("refund amount exceeds the value of the returned items")
+	ErrOrderNotEligible = errors.New("only completed orders can be returned")
+)
+
+// returnTransitions lists, for every return status, the statuses it may
+// move to next. An approved return becomes refunded once its items are
+// restocked and its refund is issued.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected, ReturnStatusCancelled},
+	ReturnStatusApproved:  {ReturnStatusRefunded},
+}
+
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether the return's items still count against the
+// quantity that can be returned.
+func (s ReturnStatus) IsOpen() bool {
+	return s != ReturnStatusRejected && s != ReturnStatusCancelled
+}
+
+type InvalidReturnTransitionError struct {
+	From ReturnStatus
+	To   ReturnStatus
+}
+
+func (e *InvalidReturnTransitionError) Error() string {
+	return fmt.Sprintf("cannot move return from %s to %s", e.From, e.To)
+}
+
+// ReturnItem is a quantity of one order item sent back by the customer.
+// Value is what the customer paid for those units on the order, after
+// discounts. Restocks records the units put back so far, per reservation
+// they came from; Restocked is set once all of them are.
+type ReturnItem struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id" binding:"required"`
+	Quantity  int                `bson:"quantity" json:"quantity" binding:"required,gt=0"`
+	Value     money.Money        `bson:"value" json:"value"`
+	Restocks  []ReturnRestock    `bson:"restocks,omitempty" json:"restocks,omitempty"`
+	Restocked bool               `bson:"restocked" json:"restocked"`
+}
+
+// ReturnRestock is a quantity of returned units put back into the
+// warehouse of one of the order's stock reservations.
+type ReturnRestock struct {
+	ReservationID string `bson:"reservation_id" json:"reservation_id"`
+	WarehouseID   string `bson:"warehouse_id" json:"warehouse_id"`
+	Quantity      int    `bson:"quantity" json:"quantity"`
+}
+
+type ReturnStatusChange struct {
+	From   ReturnStatus `bson:"from,omitempty" json:"from,omitempty"`
+	To     ReturnStatus `bson:"to" json:"to"`
+	Actor  string       `bson:"actor" json:"actor"`
+	Reason string       `bson:"reason,omitempty" json:"reason,omitempty"`
+	At     time.Time    `bson:"at" json:"at"`
+}
+
+type Return struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	OrderID       primitive.ObjectID   `bson:"order_id" json:"order_id"`
+	UserID        primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	ShopID        primitive.ObjectID   `bson:"shop_id" json:"shop_id"`
+	Items         []ReturnItem         `bson:"items" json:"items"`
+	Reason        string               `bson:"reason" json:"reason"`
+	Status        ReturnStatus         `bson:"status" json:"status"`
+	RefundAmount  money.Money          `bson:"refund_amount" json:"refund_amount"`
+	RefundedAt    *time.Time           `bson:"refunded_at,omitempty" json:"refunded_at,omitempty"`
+	Currency      string               `bson:"currency" json:"currency"`
+	StatusHistory []ReturnStatusChange `bson:"status_history" json:"status_history"`
+	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// ItemsValue is what the returned items cost on the order.
+func (r *Return) ItemsValue() money.Money {
+	value := money.Zero(r.Currency)
+	for _, item := range r.Items {
+		value = value.Add(item.Value)
+	}
+	return value
+}
+
+type ReturnRepository interface {
+	Create(orderReturn *Return) error
+	GetByID(id primitive.ObjectID) (*Return, error)
+	GetByOrderID(orderID primitive.ObjectID) ([]Return, error)
+	// Update replaces the return only if it is still in status from.
+	Update(orderReturn *Return, from ReturnStatus) error
+}
+
+type ReturnService interface {
+	OpenReturn(orderID primitive.ObjectID, userID primitive.ObjectID, items []ReturnItem, reason string) (*Return, error)
+	GetReturn(orderID primitive.ObjectID, id primitive.ObjectID) (*Return, error)
+	GetOrderReturns(orderID primitive.ObjectID) ([]Return, error)
+	// ApproveReturn refunds refundAmount, or the items' value when it is
+	// zero. An amount without a currency is taken to be in the return's.
+	ApproveReturn(orderID primitive.ObjectID, id primitive.ObjectID, refundAmount money.Money, actor string) (*Return, error)
+	RejectReturn(orderID primitive.ObjectID, id primitive.ObjectID, actor string, reason string) (*Return, error)
+	CancelReturn(orderID primitive.ObjectID, id primitive.ObjectID, userID primitive.ObjectID) (*Return, error)
+}
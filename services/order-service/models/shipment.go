@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusPending   ShipmentStatus = "pending"
+	ShipmentStatusShipped   ShipmentStatus = "shipped"
+	ShipmentStatusDelivered ShipmentStatus = "delivered"
+)
+
+var (
+	ErrShipmentNotFound  = errors.New("shipment not found")
+	ErrInvalidShipment   = errors.New("invalid shipment")
+	ErrShipmentConflict  = errors.New("shipment was modified concurrently")
+	ErrOrderNotShippable = errors.New("only processing or shipped orders can be shipped")
+	ErrShipmentDelivered = errors.New("delivered shipments cannot be changed")
+)
+
+var shipmentTransitions = map[ShipmentStatus][]ShipmentStatus{
+	ShipmentStatusPending: {ShipmentStatusShipped},
+	ShipmentStatusShipped: {ShipmentStatusDelivered},
+}
+
+func (s ShipmentStatus) CanTransitionTo(next ShipmentStatus) bool {
+	for _, allowed := range shipmentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type InvalidShipmentTransitionError struct {
+	From ShipmentStatus
+	To   ShipmentStatus
+}
+
+func (e *InvalidShipmentTransitionError) Error() string {
+	return fmt.Sprintf("cannot move shipment from %s to %s", e.From, e.To)
+}
+
+type ShipmentItem struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id" binding:"required"`
+	Quantity  int                `bson:"quantity" json:"quantity" binding:"required,gt=0"`
+}
+
+// Shipment is a parcel sent from one warehouse carrying some or all of an
+// order's items. Sequence numbers the order's shipments from 1.
+type Shipment struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID        primitive.ObjectID `bson:"order_id" json:"order_id"`
+	Sequence       int                `bson:"sequence" json:"sequence"`
+	ShopID         primitive.ObjectID `bson:"shop_id" json:"shop_id"`
+	WarehouseID    string             `bson:"warehouse_id" json:"warehouse_id"`
+	Carrier        string             `bson:"carrier" json:"carrier"`
+	TrackingNumber string             `bson:"tracking_number" json:"tracking_number"`
+	Items          []ShipmentItem     `bson:"items" json:"items"`
+	Status         ShipmentStatus     `bson:"status" json:"status"`
+	ShippedAt      *time.Time         `bson:"shipped_at,omitempty" json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type ShipmentRepository interface {
+	// Create stores the shipment, or returns ErrShipmentConflict when the
+	// order already has a shipment with its sequence number.
+	Create(shipment *Shipment) error
+	GetByID(id primitive.ObjectID) (*Shipment, error)
+	GetByOrderID(orderID primitive.ObjectID) ([]Shipment, error)
+	// Update replaces the shipment only if it is still in status from.
+	Update(shipment *Shipment, from ShipmentStatus) error
+}
+
+type ShipmentService interface {
+	CreateShipment(orderID primitive.ObjectID, shipment *Shipment) error
+	GetShipment(orderID primitive.ObjectID, id primitive.ObjectID) (*Shipment, error)
+	GetOrderShipments(orderID primitive.ObjectID) ([]Shipment, error)
+	UpdateTracking(orderID primitive.ObjectID, id primitive.ObjectID, carrier string, trackingNumber string) (*Shipment, error)
+	MarkShipped(orderID primitive.ObjectID, id primitive.ObjectID, actor string) (*Shipment, error)
+	MarkDelivered(orderID primitive.ObjectID, id primitive.ObjectID, actor string) (*Shipment, error)
+}
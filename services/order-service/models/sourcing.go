@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SourcingStrategy string
+
+const (
+	// SourcingSingleWarehouseFirst ships everything from the first warehouse
+	// that can, and splits by priority only when none can.
+	SourcingSingleWarehouseFirst SourcingStrategy = "single_warehouse_first"
+	// SourcingMinimizeSplits repeatedly picks the warehouse covering the most
+	// of what is still unallocated, to use as few warehouses as possible.
+	SourcingMinimizeSplits SourcingStrategy = "minimize_splits"
+	// SourcingPriority drains warehouses in the shop's listed order.
+	SourcingPriority SourcingStrategy = "priority"
+)
+
+func (s SourcingStrategy) Valid() bool {
+	switch s {
+	case SourcingSingleWarehouseFirst, SourcingMinimizeSplits, SourcingPriority:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	ErrUnknownSourcingStrategy = errors.New("unknown sourcing strategy")
+	ErrShopNotFound            = errors.New("shop not found")
+)
+
+// Allocation is a quantity of one product taken from one warehouse. An empty
+// WarehouseID leaves the choice to warehouse-service.
+type Allocation struct {
+	ProductID   primitive.ObjectID `bson:"product_id" json:"product_id"`
+	WarehouseID string             `bson:"warehouse_id,omitempty" json:"warehouse_id,omitempty"`
+	Quantity    int                `bson:"quantity" json:"quantity"`
+}
+
+// SourcingPlan records where an order's items were sourced from and why.
+type SourcingPlan struct {
+	Strategy    SourcingStrategy `bson:"strategy" json:"strategy"`
+	Allocations []Allocation     `bson:"allocations" json:"allocations"`
+}
+
+// ShopInfo is what order-service needs to know about a shop from
+// shop-service.
+type ShopInfo struct {
+	ID               primitive.ObjectID
+	Name             string
+	Location         string
+	Warehouses       []string
+	SourcingStrategy SourcingStrategy
+	// PendingOrderTimeout is how long the shop's orders may stay pending
+	// before they are cancelled. Zero means the configured default.
+	PendingOrderTimeout time.Duration
+}
+
+type ShopDirectory interface {
+	GetShop(id primitive.ObjectID) (*ShopInfo, error)
+}
+
+type Sourcer interface {
+	// PlanSourcing allocates the items to the shop's warehouses. It returns a
+	// nil plan when the shop has no warehouses, and an InsufficientStockError
+	// when the warehouses cannot cover the items together.
+	PlanSourcing(shopID primitive.ObjectID, items []OrderItem) (*SourcingPlan, error)
+}
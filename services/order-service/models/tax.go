@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TaxMode string
+
+const (
+	// TaxModeExclusive adds the tax on top of the catalog price.
+	TaxModeExclusive TaxMode = "exclusive"
+	// TaxModeInclusive treats the catalog price as already including the
+	// tax.
+	TaxModeInclusive TaxMode = "inclusive"
+)
+
+var (
+	ErrTaxRateNotFound = errors.New("tax rate not found")
+	ErrInvalidTaxRate  = errors.New("invalid tax rate")
+	ErrTaxRateExists   = errors.New("a tax rate already exists for this location and category")
+)
+
+// TaxRate is the percentage charged on lines sold from shops in Location
+// for products in CategoryID. An empty Location or nil CategoryID matches
+// every location or category.
+type TaxRate struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name       string              `bson:"name" json:"name"`
+	Location   string              `bson:"location,omitempty" json:"location,omitempty"`
+	CategoryID *primitive.ObjectID `bson:"category_id,omitempty" json:"category_id,omitempty"`
+	Rate       float64             `bson:"rate" json:"rate"`
+	Mode       TaxMode             `bson:"mode" json:"mode"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+func (r *TaxRate) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTaxRate)
+	}
+	if r.Rate < 0 || r.Rate > 100 {
+		return fmt.Errorf("%w: rate must be between 0 and 100", ErrInvalidTaxRate)
+	}
+	if r.Mode != TaxModeExclusive && r.Mode != TaxModeInclusive {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidTaxRate, r.Mode)
+	}
+	return nil
+}
+
+// LineTax is the tax charged on one order line. Lines no rate matched
+// carry a zero exclusive tax.
+type LineTax struct {
+	RateID *primitive.ObjectID `bson:"rate_id,omitempty" json:"rate_id,omitempty"`
+	Name   string              `bson:"name,omitempty" json:"name,omitempty"`
+	Rate   float64             `bson:"rate" json:"rate"`
+	Mode   TaxMode             `bson:"mode" json:"mode"`
+	Amount money.Money         `bson:"amount" json:"amount"`
+}
+
+type TaxRateRepository interface {
+	Create(rate *TaxRate) error
+	GetByID(id primitive.ObjectID) (*TaxRate, error)
+	List() ([]TaxRate, error)
+	Update(rate *TaxRate) error
+	Delete(id primitive.ObjectID) error
+}
+
+type TaxService interface {
+	CreateRate(rate *TaxRate) error
+	GetRate(id primitive.ObjectID) (*TaxRate, error)
+	ListRates() ([]TaxRate, error)
+	UpdateRate(rate *TaxRate) error
+	DeleteRate(id primitive.ObjectID) error
+	// ApplyTaxes sets the tax of each order line from the rate for the
+	// shop's location and the product's category.
+	ApplyTaxes(order *Order) error
+}
@@ -0,0 +1,64 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const fakeReferencePrefix = "fake_"
+
+// FakeProvider is a PaymentProvider for local runs and tests that never
+// talks to a gateway. It approves every authorization up to DeclineAbove
+// (when set) and accepts any follow-up operation on references it issued.
+// DeclineAbove is in major units of whatever currency is charged. It keeps
+// no state, so payments survive service restarts; amount and
+// status rules are enforced by the payment service.
+type FakeProvider struct {
+	DeclineAbove float64
+}
+
+func NewFakeProvider(declineAbove float64) *FakeProvider {
+	return &FakeProvider{
+		DeclineAbove: declineAbove,
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Authorize(reference string, amount money.Money) (string, error) {
+	if !amount.IsPositive() {
+		return "", errors.New("amount must be greater than 0")
+	}
+	if p.DeclineAbove > 0 && amount.Cmp(money.FromMajor(p.DeclineAbove, amount.Currency)) > 0 {
+		return "", fmt.Errorf("%w: %s exceeds the test card limit", models.ErrPaymentDeclined, amount)
+	}
+
+	return fakeReferencePrefix + primitive.NewObjectID().Hex(), nil
+}
+
+func (p *FakeProvider) Capture(providerReference string, amount money.Money) error {
+	return p.check(providerReference)
+}
+
+func (p *FakeProvider) Void(providerReference string) error {
+	return p.check(providerReference)
+}
+
+func (p *FakeProvider) Refund(providerReference string, amount money.Money) error {
+	return p.check(providerReference)
+}
+
+func (p *FakeProvider) check(providerReference string) error {
+	if !strings.HasPrefix(providerReference, fakeReferencePrefix) {
+		return fmt.Errorf("unknown authorization %s", providerReference)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package promotions
+
+import (
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+)
+
+// Apply checks that the coupon can be used on the order at the given time
+// and adds its discount to the order's items as adjustments. Discounts of
+// coupons applied before it are taken into account, so no line ever goes
+// below zero.
+func Apply(coupon *models.Coupon, order *models.Order, at time.Time) error {
+	if err := check(coupon, order, at); err != nil {
+		return err
+	}
+
+	var lines []int
+	base := money.Zero(order.Currency)
+	for i, item := range order.Items {
+		if coupon.Covers(item.ProductID) && item.Net().IsPositive() {
+			lines = append(lines, i)
+			base = base.Add(item.Net())
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: %s does not cover any item of the order", models.ErrCouponNotApplicable, coupon.Code)
+	}
+
+	discounts := make([]money.Money, len(lines))
+	switch coupon.Type {
+	case models.CouponTypePercentage:
+		for n, i := range lines {
+			discounts[n] = order.Items[i].Net().Percent(coupon.Percent)
+		}
+	case models.CouponTypeFixedAmount:
+		// Split the amount in proportion to the lines' totals. The parts add
+		// up to the amount exactly and none exceeds its line.
+		weights := make([]int64, len(lines))
+		for n, i := range lines {
+			weights[n] = order.Items[i].Net().Amount
+		}
+		discounts = money.Min(*coupon.Amount, base).Allocate(weights)
+	}
+
+	for n, i := range lines {
+		if !discounts[n].IsPositive() {
+			continue
+		}
+		order.Items[i].Adjustments = append(order.Items[i].Adjustments, models.Adjustment{
+			CouponID: coupon.ID,
+			Code:     coupon.Code,
+			Amount:   discounts[n],
+		})
+	}
+	return nil
+}
+
+func check(coupon *models.Coupon, order *models.Order, at time.Time) error {
+	if !coupon.Active {
+		return fmt.Errorf("%w: %s is not active", models.ErrCouponNotApplicable, coupon.Code)
+	}
+	if coupon.ValidFrom != nil && at.Before(*coupon.ValidFrom) {
+		return fmt.Errorf("%w: %s is not valid yet", models.ErrCouponNotApplicable, coupon.Code)
+	}
+	if coupon.ValidUntil != nil && !at.Before(*coupon.ValidUntil) {
+		return fmt.Errorf("%w: %s has expired", models.ErrCouponNotApplicable, coupon.Code)
+	}
+	if coupon.ShopID != nil && *coupon.ShopID != order.ShopID {
+		return fmt.Errorf("%w: %s is for another shop", models.ErrCouponNotApplicable, coupon.Code)
+	}
+	for _, amount := range []*money.Money{coupon.Amount, coupon.MinOrderValue} {
+		if amount != nil && amount.Currency != order.Currency {
+			return fmt.Errorf("%w: %s is in %s, the order is in %s", models.ErrCouponNotApplicable, coupon.Code, amount.Currency, order.Currency)
+		}
+	}
+	if coupon.MinOrderValue == nil {
+		return nil
+	}
+
+	// The minimum applies to the order before any discount
+	subtotal := money.Zero(order.Currency)
+	for _, item := range order.Items {
+		subtotal = subtotal.Add(item.Subtotal())
+	}
+	if subtotal.Cmp(*coupon.MinOrderValue) < 0 {
+		return fmt.Errorf("%w: %s needs an order of at least %s", models.ErrCouponNotApplicable, coupon.Code, coupon.MinOrderValue)
+	}
+	return nil
+}
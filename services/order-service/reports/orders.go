@@ -0,0 +1,125 @@
+package reports
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce/order-service/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// OrderWriter writes orders one at a time in an export format. Writes are
+// buffered until Flush.
+type OrderWriter interface {
+	Write(order *models.Order) error
+	Flush() error
+	ContentType() string
+}
+
+// ValidFormat reports whether format is a supported export format.
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatNDJSON
+}
+
+// NewOrderWriter returns a writer for the format. A CSV export starts with
+// a header row.
+func NewOrderWriter(format string, w io.Writer) (OrderWriter, error) {
+	switch format {
+	case FormatCSV:
+		writer := &csvOrderWriter{w: csv.NewWriter(w)}
+		return writer, writer.w.Write(csvHeader)
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(w)
+		return &ndjsonOrderWriter{w: buffered, encoder: json.NewEncoder(buffered)}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+var csvHeader = []string{
+	"order_id", "created_at", "status", "shop_id", "user_id", "customer_email", "customer_phone",
+	"items", "currency", "subtotal", "discount", "tax", "shipping", "total", "payment_id",
+}
+
+type csvOrderWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvOrderWriter) Write(order *models.Order) error {
+	var email, phone, paymentID string
+	if order.Customer != nil {
+		email, phone = order.Customer.Email, order.Customer.Phone
+	}
+	if order.PaymentID != nil {
+		paymentID = order.PaymentID.Hex()
+	}
+	units := 0
+	for _, item := range order.Items {
+		units += item.Quantity
+	}
+
+	return w.w.Write([]string{
+		order.ID.Hex(),
+		order.CreatedAt.UTC().Format(time.RFC3339),
+		string(order.Status),
+		order.ShopID.Hex(),
+		order.UserID.Hex(),
+		cell(email),
+		cell(phone),
+		strconv.Itoa(units),
+		order.Currency,
+		order.Subtotal.Decimal(),
+		order.Discount.Decimal(),
+		order.Tax.Decimal(),
+		order.Shipping.Decimal(),
+		order.TotalAmount.Decimal(),
+		paymentID,
+	})
+}
+
+func (w *csvOrderWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvOrderWriter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// cell keeps customer-supplied text from being read as a formula by
+// spreadsheets: values starting with a formula character get a leading
+// quote. Phone numbers such as +62... are quoted too, since a spreadsheet
+// reads +1+HYPERLINK(...) as a formula just the same.
+func cell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+type ndjsonOrderWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *ndjsonOrderWriter) Write(order *models.Order) error {
+	return w.encoder.Encode(order)
+}
+
+func (w *ndjsonOrderWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *ndjsonOrderWriter) ContentType() string {
+	return "application/x-ndjson"
+}
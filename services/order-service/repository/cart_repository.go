@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoCartRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoCartRepository(db *mongo.Collection) models.CartRepository {
+	return &mongoCartRepository{
+		db: db,
+	}
+}
+
+// EnsureCartIndexes allows one cart per user and per token, and lets
+// MongoDB delete anonymous carts once they expire.
+func EnsureCartIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"user_id": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "token", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"token": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+func (r *mongoCartRepository) Get(owner models.CartOwner) (*models.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"token": owner.Token}
+	if owner.UserID != nil {
+		filter = bson.M{"user_id": *owner.UserID}
+	}
+
+	var cart models.Cart
+	err := r.db.FindOne(ctx, filter).Decode(&cart)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &cart, nil
+}
+
+// Save replaces the cart only while its version is unchanged. The upsert
+// also puts back a cart deleted in between, and a cart changed in between
+// makes it collide with the existing _id.
+func (r *mongoCartRepository) Save(cart *models.Cart) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	if cart.ID.IsZero() {
+		cart.ID = primitive.NewObjectID()
+		cart.CreatedAt = now
+	}
+	cart.UpdatedAt = now
+	expected := cart.Version
+	cart.Version++
+
+	_, err := r.db.ReplaceOne(ctx,
+		bson.M{"_id": cart.ID, "version": expected},
+		cart,
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		cart.Version = expected
+		return models.ErrCartConflict
+	}
+	if err != nil {
+		cart.Version = expected
+		return err
+	}
+
+	return nil
+}
+
+func (r *mongoCartRepository) Delete(cart *models.Cart) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.db.DeleteOne(ctx, bson.M{"_id": cart.ID, "version": cart.Version})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrCartConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	couponsCollection           = "coupons"
+	couponUsagesCollection      = "coupon_usages"
+	couponRedemptionsCollection = "coupon_redemptions"
+)
+
+// couponRedemption records that an order used a coupon. Its unique index
+// makes redeeming for the same order twice a no-op.
+type couponRedemption struct {
+	CouponID  primitive.ObjectID `bson:"coupon_id"`
+	OrderID   primitive.ObjectID `bson:"order_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type mongoCouponRepository struct {
+	coupons     *mongo.Collection
+	usages      *mongo.Collection
+	redemptions *mongo.Collection
+}
+
+// NewMongoCouponRepository keeps coupons, per-user usage counters and
+// redemptions in three collections of the database.
+func NewMongoCouponRepository(db *mongo.Database) models.CouponRepository {
+	return &mongoCouponRepository{
+		coupons:     db.Collection(couponsCollection),
+		usages:      db.Collection(couponUsagesCollection),
+		redemptions: db.Collection(couponRedemptionsCollection),
+	}
+}
+
+// EnsureCouponIndexes creates the unique indexes the redemption counting
+// relies on.
+func EnsureCouponIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	unique := options.Index().SetUnique(true)
+	if _, err := db.Collection(couponsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: unique,
+	}); err != nil {
+		return err
+	}
+	if _, err := db.Collection(couponUsagesCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "coupon_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: unique,
+	}); err != nil {
+		return err
+	}
+	_, err := db.Collection(couponRedemptionsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "coupon_id", Value: 1}, {Key: "order_id", Value: 1}},
+		Options: unique,
+	})
+	return err
+}
+
+func (r *mongoCouponRepository) Create(coupon *models.Coupon) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	coupon.CreatedAt = time.Now()
+	coupon.UpdatedAt = time.Now()
+
+	result, err := r.coupons.InsertOne(ctx, coupon)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrCouponExists
+	}
+	if err != nil {
+		return err
+	}
+
+	coupon.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoCouponRepository) GetByCode(code string) (*models.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var coupon models.Coupon
+	err := r.coupons.FindOne(ctx, bson.M{"code": code}).Decode(&coupon)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+func (r *mongoCouponRepository) SetActive(code string, active bool) (*models.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var coupon models.Coupon
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.coupons.FindOneAndUpdate(ctx,
+		bson.M{"code": code},
+		bson.M{"$set": bson.M{"active": active, "updated_at": time.Now()}},
+		opts,
+	).Decode(&coupon)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+func (r *mongoCouponRepository) Redeem(coupon *models.Coupon, userID primitive.ObjectID, orderID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.redemptions.InsertOne(ctx, couponRedemption{
+		CouponID:  coupon.ID,
+		OrderID:   orderID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	undoRedemption := func() {
+		r.redemptions.DeleteOne(ctx, bson.M{"coupon_id": coupon.ID, "order_id": orderID})
+	}
+
+	// The per-user counter is upserted only while it is under the limit.
+	// Once it has reached it the filter misses the existing document and
+	// the upsert trips the unique index instead.
+	usageFilter := bson.M{"coupon_id": coupon.ID, "user_id": userID}
+	if coupon.MaxRedemptionsPerUser > 0 {
+		usageFilter["count"] = bson.M{"$lt": coupon.MaxRedemptionsPerUser}
+	}
+	_, err = r.usages.UpdateOne(ctx, usageFilter,
+		bson.M{"$inc": bson.M{"count": 1}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		undoRedemption()
+		if mongo.IsDuplicateKeyError(err) {
+			return models.ErrCouponUserLimitReached
+		}
+		return err
+	}
+
+	couponFilter := bson.M{"_id": coupon.ID}
+	if coupon.MaxRedemptions > 0 {
+		couponFilter["redemptions"] = bson.M{"$lt": coupon.MaxRedemptions}
+	}
+	result, err := r.coupons.UpdateOne(ctx, couponFilter, bson.M{"$inc": bson.M{"redemptions": 1}})
+	if err == nil && result.MatchedCount == 0 {
+		err = models.ErrCouponExhausted
+	}
+	if err != nil {
+		r.usages.UpdateOne(ctx, bson.M{"coupon_id": coupon.ID, "user_id": userID}, bson.M{"$inc": bson.M{"count": -1}})
+		undoRedemption()
+		return err
+	}
+
+	return nil
+}
+
+func (r *mongoCouponRepository) Release(couponID primitive.ObjectID, orderID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var redemption couponRedemption
+	err := r.redemptions.FindOneAndDelete(ctx, bson.M{"coupon_id": couponID, "order_id": orderID}).Decode(&redemption)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.usages.UpdateOne(ctx,
+		bson.M{"coupon_id": couponID, "user_id": redemption.UserID},
+		bson.M{"$inc": bson.M{"count": -1}},
+	); err != nil {
+		return err
+	}
+	_, err = r.coupons.UpdateOne(ctx,
+		bson.M{"_id": couponID},
+		bson.M{"$inc": bson.M{"redemptions": -1}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
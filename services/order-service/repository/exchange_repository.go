@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoExchangeRateRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoExchangeRateRepository(db *mongo.Collection) models.ExchangeRateRepository {
+	return &mongoExchangeRateRepository{
+		db: db,
+	}
+}
+
+// EnsureExchangeRateIndexes allows a single rate per currency pair.
+func EnsureExchangeRateIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "base", Value: 1}, {Key: "quote", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *mongoExchangeRateRepository) Upsert(rate *models.ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rate.UpdatedAt = time.Now()
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := r.db.FindOneAndUpdate(ctx,
+		bson.M{"base": rate.Base, "quote": rate.Quote},
+		bson.M{"$set": bson.M{
+			"rate":       rate.Rate,
+			"source":     rate.Source,
+			"updated_at": rate.UpdatedAt,
+		}},
+		opts,
+	).Decode(rate)
+	return err
+}
+
+func (r *mongoExchangeRateRepository) Get(base string, quote string) (*models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var rate models.ExchangeRate
+	err := r.db.FindOne(ctx, bson.M{"base": base, "quote": quote}).Decode(&rate)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrExchangeRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (r *mongoExchangeRateRepository) List() ([]models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "base", Value: 1}, {Key: "quote", Value: 1}})
+	cursor, err := r.db.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []models.ExchangeRate{}
+	if err = cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+func (r *mongoExchangeRateRepository) Delete(base string, quote string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.db.DeleteOne(ctx, bson.M{"base": base, "quote": quote})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrExchangeRateNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoIdempotencyRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoIdempotencyRepository(db *mongo.Collection) models.IdempotencyRepository {
+	return &mongoIdempotencyRepository{
+		db: db,
+	}
+}
+
+// EnsureIdempotencyIndexes creates the unique user/key index and the TTL
+// index that lets MongoDB drop records once they expire.
+func EnsureIdempotencyIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+func (r *mongoIdempotencyRepository) Reserve(record *models.IdempotencyRecord) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record.Status = models.IdempotencyStatusInProgress
+	record.CreatedAt = time.Now()
+
+	_, err := r.db.InsertOne(ctx, record)
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	// The TTL monitor runs only periodically, so an expired record may still
+	// be holding the key. Replace it.
+	result, err := r.db.DeleteOne(ctx, bson.M{
+		"user_id":    record.UserID,
+		"key":        record.Key,
+		"expires_at": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return false, err
+	}
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	_, err = r.db.InsertOne(ctx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *mongoIdempotencyRepository) Get(userID string, key string) (*models.IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var record models.IdempotencyRecord
+	err := r.db.FindOne(ctx, bson.M{"user_id": userID, "key": key}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrIdempotencyRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (r *mongoIdempotencyRepository) Complete(userID string, key string, status int, contentType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.UpdateOne(
+		ctx,
+		bson.M{"user_id": userID, "key": key},
+		bson.M{
+			"$set": bson.M{
+				"status":          models.IdempotencyStatusCompleted,
+				"response_status": status,
+				"response_type":   contentType,
+				"response_body":   body,
+			},
+		},
+	)
+	return err
+}
+
+func (r *mongoIdempotencyRepository) Delete(userID string, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.DeleteOne(ctx, bson.M{"user_id": userID, "key": key})
+	return err
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxIssueAttempts bounds how often Issue retries when concurrent invoices
+// of the same shop take the number it picked.
+const maxIssueAttempts = 10
+
+type mongoInvoiceRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoInvoiceRepository(db *mongo.Collection) models.InvoiceRepository {
+	return &mongoInvoiceRepository{
+		db: db,
+	}
+}
+
+// EnsureInvoiceIndexes makes invoice numbers unique within a shop and
+// allows one invoice per order.
+func EnsureInvoiceIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "shop_id", Value: 1}, {Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "order_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	return err
+}
+
+// Issue numbers the invoice one past the shop's last invoice. There is no
+// separate counter, so a number is only taken by an invoice that was
+// stored and the sequence has no gaps; the unique indexes turn a race for
+// the same number or the same order into a retry.
+func (r *mongoInvoiceRepository) Issue(invoice *models.Invoice) (*models.Invoice, error) {
+	for attempt := 0; attempt < maxIssueAttempts; attempt++ {
+		existing, err := r.GetByOrderID(invoice.OrderID)
+		if err == nil {
+			return existing, nil
+		}
+		if err != models.ErrInvoiceNotFound {
+			return nil, err
+		}
+
+		last, err := r.lastSequence(invoice.ShopID)
+		if err != nil {
+			return nil, err
+		}
+		invoice.ID = primitive.NewObjectID()
+		invoice.Sequence = last + 1
+		invoice.Number = models.InvoiceNumber(invoice.ShopID, invoice.Sequence)
+
+		err = r.insert(invoice)
+		if err == nil {
+			return invoice, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("failed to number invoice of order %s after %d attempts", invoice.OrderID.Hex(), maxIssueAttempts)
+}
+
+func (r *mongoInvoiceRepository) GetByOrderID(orderID primitive.ObjectID) (*models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var invoice models.Invoice
+	err := r.db.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+func (r *mongoInvoiceRepository) lastSequence(shopID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var last struct {
+		Sequence int64 `bson:"sequence"`
+	}
+	err := r.db.FindOne(ctx,
+		bson.M{"shop_id": shopID},
+		options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}}).SetProjection(bson.M{"sequence": 1}),
+	).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return last.Sequence, nil
+}
+
+func (r *mongoInvoiceRepository) insert(invoice *models.Invoice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.InsertOne(ctx, invoice)
+	return err
+}
@@ -2,6 +2,10 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
 	"time"
 
 	"ecommerce/order-service/models"
@@ -9,15 +13,26 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// streamBatchSize is how many orders Stream fetches from MongoDB at a time.
+const streamBatchSize = 500
+
+// mongoOrderRepository writes an event describing every change to an order
+// to the outbox, in the same transaction as the change. Without an outbox
+// collection no events are written.
 type mongoOrderRepository struct {
-	db *mongo.Collection
+	db         *mongo.Collection
+	outbox     *mongo.Collection
+	transactor *Transactor
 }
 
-func NewMongoOrderRepository(db *mongo.Collection) models.OrderRepository {
+func NewMongoOrderRepository(db *mongo.Collection, outbox *mongo.Collection, transactor *Transactor) models.OrderRepository {
 	return &mongoOrderRepository{
-		db: db,
+		db:         db,
+		outbox:     outbox,
+		transactor: transactor,
 	}
 }
 
@@ -25,17 +40,25 @@ func (r *mongoOrderRepository) Create(order *models.Order) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+	}
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
 	order.Status = models.OrderStatusPending
+	order.Version = 1
 
-	result, err := r.db.InsertOne(ctx, order)
+	event, err := models.NewOrderEvent(models.EventOrderCreated, order, nil)
 	if err != nil {
 		return err
 	}
 
-	order.ID = result.InsertedID.(primitive.ObjectID)
-	return nil
+	return r.transactor.Run(ctx, func(ctx context.Context) error {
+		if _, err := r.db.InsertOne(ctx, order); err != nil {
+			return err
+		}
+		return appendEvent(ctx, r.outbox, event)
+	})
 }
 
 func (r *mongoOrderRepository) GetByID(id primitive.ObjectID) (*models.Order, error) {
@@ -51,71 +74,388 @@ func (r *mongoOrderRepository) GetByID(id primitive.ObjectID) (*models.Order, er
 	return &order, nil
 }
 
-func (r *mongoOrderRepository) GetByUserID(userID primitive.ObjectID) ([]models.Order, error) {
+// List returns one page of the orders matching the query, using keyset
+// pagination on the sort field and _id. Filtering on user_id and status
+// lets MongoDB use the user_id/status compound index.
+func (r *mongoOrderRepository) List(query models.OrderQuery) (*models.OrderPage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := r.db.Find(ctx, bson.M{"user_id": userID})
+	field, direction := sortField(query.Sort)
+	filter := orderFilter(query.Filter)
+
+	total, err := r.db.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pageFilter := filter
+	if query.Cursor != "" {
+		after, err := decodeOrderCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return nil, err
+		}
+		op := "$gt"
+		if direction < 0 {
+			op = "$lt"
+		}
+		pageFilter = bson.M{"$and": bson.A{
+			filter,
+			bson.M{"$or": bson.A{
+				bson.M{field: bson.M{op: after.value()}},
+				bson.M{field: after.value(), "_id": bson.M{op: after.ID}},
+			}},
+		}}
+	}
+
+	// Fetch one extra order to learn whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit + 1))
+	cursor, err := r.db.Find(ctx, pageFilter, opts)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var orders []models.Order
+	orders := []models.Order{}
 	if err = cursor.All(ctx, &orders); err != nil {
 		return nil, err
 	}
 
-	return orders, nil
+	page := &models.OrderPage{Orders: orders, TotalCount: total}
+	if len(orders) > query.Limit {
+		page.Orders = orders[:query.Limit]
+		page.NextCursor = encodeOrderCursor(page.Orders[query.Limit-1], query.Sort)
+	}
+	return page, nil
 }
 
-func (r *mongoOrderRepository) GetByShopID(shopID primitive.ObjectID) ([]models.Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	cursor, err := r.db.Find(ctx, bson.M{"shop_id": shopID})
+// Stream reads the matching orders through a single cursor, so memory use
+// does not grow with the result. It runs until ctx is done rather than
+// under the repository's usual timeout, since exports can be large.
+func (r *mongoOrderRepository) Stream(ctx context.Context, filter models.OrderFilter, sort models.OrderSort, fn func(*models.Order) error) error {
+	field, direction := sortField(sort)
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetBatchSize(streamBatchSize)
+	cursor, err := r.db.Find(ctx, orderFilter(filter), opts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer cursor.Close(ctx)
 
-	var orders []models.Order
-	if err = cursor.All(ctx, &orders); err != nil {
-		return nil, err
+	for cursor.Next(ctx) {
+		var order models.Order
+		if err := cursor.Decode(&order); err != nil {
+			return err
+		}
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func orderFilter(filter models.OrderFilter) bson.M {
+	doc := bson.M{}
+	if filter.UserID != nil {
+		doc["user_id"] = *filter.UserID
+	}
+	if filter.ShopID != nil {
+		doc["shop_id"] = *filter.ShopID
+	}
+	if len(filter.Statuses) > 0 {
+		doc["status"] = bson.M{"$in": filter.Statuses}
+	}
+
+	createdAt := bson.M{}
+	if filter.CreatedFrom != nil {
+		createdAt["$gte"] = *filter.CreatedFrom
+	}
+	if filter.CreatedTo != nil {
+		createdAt["$lt"] = *filter.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		doc["created_at"] = createdAt
+	}
+
+	amount := bson.M{}
+	if filter.MinAmount != nil {
+		amount["$gte"] = filter.MinAmount.Amount
+		doc["currency"] = filter.MinAmount.Currency
+	}
+	if filter.MaxAmount != nil {
+		amount["$lte"] = filter.MaxAmount.Amount
+		doc["currency"] = filter.MaxAmount.Currency
+	}
+	if len(amount) > 0 {
+		doc["total_amount.amount"] = amount
+	}
+
+	if filter.Search != "" {
+		doc["$or"] = searchFilter(filter.Search)
+	}
+
+	return doc
+}
+
+// searchFilter matches the text as any of the order's IDs when it is one,
+// and as the start of the customer's email or phone otherwise. Emails are
+// stored lower-cased, and anchored, case-sensitive patterns let MongoDB
+// scan only the matching range of their indexes.
+func searchFilter(text string) bson.A {
+	if id, err := primitive.ObjectIDFromHex(text); err == nil {
+		return bson.A{
+			bson.M{"_id": id},
+			bson.M{"user_id": id},
+			bson.M{"shop_id": id},
+			bson.M{"payment_id": id},
+		}
+	}
+	return bson.A{
+		bson.M{"customer.email": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(text))}},
+		bson.M{"customer.phone": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(text)}},
+	}
+}
+
+func sortField(sort models.OrderSort) (string, int) {
+	switch sort {
+	case models.OrderSortCreatedAtAsc:
+		return "created_at", 1
+	case models.OrderSortTotalAmountDesc:
+		return "total_amount.amount", -1
+	case models.OrderSortTotalAmountAsc:
+		return "total_amount.amount", 1
+	default:
+		return "created_at", -1
+	}
+}
+
+// orderCursor is the position of the last order on a page. It is handed
+// to clients as opaque base64-encoded JSON.
+type orderCursor struct {
+	Sort      models.OrderSort   `json:"s"`
+	CreatedAt time.Time          `json:"c,omitempty"`
+	Amount    int64              `json:"a,omitempty"`
+	ID        primitive.ObjectID `json:"id"`
+}
+
+func (c orderCursor) value() interface{} {
+	switch c.Sort {
+	case models.OrderSortTotalAmountAsc, models.OrderSortTotalAmountDesc:
+		return c.Amount
+	default:
+		return c.CreatedAt
 	}
+}
+
+func encodeOrderCursor(order models.Order, sort models.OrderSort) string {
+	raw, _ := json.Marshal(orderCursor{
+		Sort:      sort,
+		CreatedAt: order.CreatedAt,
+		Amount:    order.TotalAmount.Amount,
+		ID:        order.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
 
-	return orders, nil
+func decodeOrderCursor(encoded string, sort models.OrderSort) (*orderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, models.ErrInvalidCursor
+	}
+	var cursor orderCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Sort != sort || cursor.ID.IsZero() {
+		return nil, models.ErrInvalidCursor
+	}
+	return &cursor, nil
 }
 
+// Update replaces the order document except for its status and status
+// history, which only UpdateStatus may change. The write only applies if
+// the stored version still matches the order's version.
 func (r *mongoOrderRepository) Update(order *models.Order) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	order.UpdatedAt = time.Now()
 
-	_, err := r.db.UpdateOne(
+	raw, err := bson.Marshal(order)
+	if err != nil {
+		return err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	delete(doc, "_id")
+	delete(doc, "status")
+	delete(doc, "status_history")
+	delete(doc, "version")
+	delete(doc, "claim")
+
+	updated := *order
+	updated.Version++
+	event, err := models.NewOrderEvent(models.EventOrderUpdated, &updated, nil)
+	if err != nil {
+		return err
+	}
+
+	err = r.transactor.Run(ctx, func(ctx context.Context) error {
+		result, err := r.db.UpdateOne(
+			ctx,
+			bson.M{"_id": order.ID, "version": versionFilter(order.Version)},
+			bson.M{
+				"$set": doc,
+				"$inc": bson.M{"version": 1},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return r.conflictOrMissing(ctx, order.ID)
+		}
+		return appendEvent(ctx, r.outbox, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	order.Version++
+	return nil
+}
+
+// UpdateStatus moves the order from change.From to change.To, appends the
+// change to its status history and clears its claim. It is a
+// compare-and-set: if the order is no longer in change.From, or another
+// transition has claimed it, nothing is written and an OrderConflictError
+// is returned.
+func (r *mongoOrderRepository) UpdateStatus(id primitive.ObjectID, change models.StatusChange) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return r.transactor.Run(ctx, func(ctx context.Context) error {
+		var order models.Order
+		err := r.db.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": id, "status": change.From, "$or": claimFreeFor(change.To, change.At)},
+			bson.M{
+				"$set": bson.M{
+					"status":     change.To,
+					"updated_at": change.At,
+				},
+				"$unset": bson.M{
+					"claim": "",
+				},
+				"$push": bson.M{
+					"status_history": change,
+				},
+				"$inc": bson.M{
+					"version": 1,
+				},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			return r.conflictOrMissing(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		event, err := models.NewOrderEvent(models.StatusEventType(change.To), &order, &change)
+		if err != nil {
+			return err
+		}
+		return appendEvent(ctx, r.outbox, event)
+	})
+}
+
+func (r *mongoOrderRepository) ClaimTransition(order *models.Order, to models.OrderStatus, actor string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	claim := &models.TransitionClaim{To: to, Actor: actor, ClaimedAt: time.Now()}
+	result, err := r.db.UpdateOne(
 		ctx,
-		bson.M{"_id": order.ID},
-		bson.M{"$set": order},
+		bson.M{"_id": order.ID, "status": order.Status, "$or": claimFreeFor("", claim.ClaimedAt)},
+		bson.M{
+			"$set": bson.M{"claim": claim},
+			"$inc": bson.M{"version": 1},
+		},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return r.conflictOrMissing(ctx, order.ID)
+	}
+
+	order.Claim = claim
+	order.Version++
+	return nil
 }
 
-func (r *mongoOrderRepository) UpdateStatus(id primitive.ObjectID, status models.OrderStatus) error {
+func (r *mongoOrderRepository) ReleaseTransition(order *models.Order) error {
+	if order.Claim == nil {
+		return nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	_, err := r.db.UpdateOne(
 		ctx,
-		bson.M{"_id": id},
+		bson.M{"_id": order.ID, "claim.to": order.Claim.To, "claim.claimed_at": order.Claim.ClaimedAt},
 		bson.M{
-			"$set": bson.M{
-				"status":     status,
-				"updated_at": time.Now(),
-			},
+			"$unset": bson.M{"claim": ""},
+			"$inc":   bson.M{"version": 1},
 		},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	order.Claim = nil
+	order.Version++
+	return nil
+}
+
+// claimFreeFor matches orders no other transition has a live claim on: the
+// order is unclaimed, claimed for to, or its claim has lapsed. An empty to
+// only lets unclaimed or lapsed orders through.
+func claimFreeFor(to models.OrderStatus, now time.Time) bson.A {
+	free := bson.A{
+		bson.M{"claim": nil},
+		bson.M{"claim.claimed_at": bson.M{"$lt": now.Add(-models.TransitionClaimTTL)}},
+	}
+	if to != "" {
+		free = append(free, bson.M{"claim.to": to})
+	}
+	return free
+}
+
+// conflictOrMissing explains why a conditional write matched nothing.
+func (r *mongoOrderRepository) conflictOrMissing(ctx context.Context, id primitive.ObjectID) error {
+	count, err := r.db.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return &models.OrderConflictError{OrderID: id}
+}
+
+// versionFilter matches the given version. Orders written before versioning
+// have no version field and count as version 0.
+func versionFilter(version int) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
 }
 
 func (r *mongoOrderRepository) Delete(id primitive.ObjectID) error {
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/events"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Transactor runs writes to several collections in one transaction. Only
+// replica sets and sharded clusters support transactions; against a
+// standalone server the writes run one after the other.
+type Transactor struct {
+	client    *mongo.Client
+	supported bool
+}
+
+// NewTransactor asks the server whether it supports transactions.
+func NewTransactor(client *mongo.Client) (*Transactor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return nil, err
+	}
+
+	return &Transactor{
+		client:    client,
+		supported: hello.SetName != "" || hello.Msg == "isdbgrid",
+	}, nil
+}
+
+// Supported reports whether writes run in transactions.
+func (t *Transactor) Supported() bool {
+	return t != nil && t.supported
+}
+
+// Run calls fn in a transaction, which MongoDB retries as a whole on
+// transient errors, so fn must only write through the context it is given.
+func (t *Transactor) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !t.Supported() {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+type mongoOutboxRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoOutboxRepository(db *mongo.Collection) models.OutboxRepository {
+	return &mongoOutboxRepository{
+		db: db,
+	}
+}
+
+// EnsureOutboxIndexes orders pending entries for the relay and lets MongoDB
+// delete published entries once they are older than retention. Dead entries
+// are kept until someone looks at them.
+func EnsureOutboxIndexes(db *mongo.Collection, retention time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "published_at", Value: 1}, {Key: "dead_at", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "published_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+		},
+	})
+	return err
+}
+
+// appendEvent adds the event to the outbox. Called with a transaction's
+// context, it is written or discarded together with the change it
+// describes.
+func appendEvent(ctx context.Context, outbox *mongo.Collection, event events.Event) error {
+	if outbox == nil {
+		return nil
+	}
+	_, err := outbox.InsertOne(ctx, models.OutboxEntry{
+		Event:     event,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+func (r *mongoOutboxRepository) Pending(limit int) ([]models.OutboxEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.db.Find(ctx,
+		bson.M{"published_at": nil, "dead_at": nil},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.OutboxEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *mongoOutboxRepository) MarkPublished(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$set":   bson.M{"published_at": time.Now()},
+			"$inc":   bson.M{"attempts": 1},
+			"$unset": bson.M{"last_error": ""},
+		},
+	)
+	return err
+}
+
+func (r *mongoOutboxRepository) MarkFailed(id string, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$set": bson.M{"last_error": cause.Error()},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	return err
+}
+
+func (r *mongoOutboxRepository) MarkDead(id string, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$set": bson.M{"dead_at": time.Now(), "last_error": cause.Error()},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	return err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoPaymentRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoPaymentRepository(db *mongo.Collection) models.PaymentRepository {
+	return &mongoPaymentRepository{
+		db: db,
+	}
+}
+
+func (r *mongoPaymentRepository) Create(payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payment.CreatedAt = time.Now()
+	payment.UpdatedAt = time.Now()
+
+	result, err := r.db.InsertOne(ctx, payment)
+	if err != nil {
+		return err
+	}
+
+	payment.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoPaymentRepository) GetByID(id primitive.ObjectID) (*models.Payment, error) {
+	return r.findOne(bson.M{"_id": id})
+}
+
+func (r *mongoPaymentRepository) GetByOrderID(orderID primitive.ObjectID) (*models.Payment, error) {
+	return r.findOne(bson.M{"order_id": orderID})
+}
+
+func (r *mongoPaymentRepository) Update(payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payment.UpdatedAt = time.Now()
+
+	_, err := r.db.UpdateOne(
+		ctx,
+		bson.M{"_id": payment.ID},
+		bson.M{"$set": payment},
+	)
+	return err
+}
+
+func (r *mongoPaymentRepository) findOne(filter bson.M) (*models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var payment models.Payment
+	err := r.db.FindOne(ctx, filter).Decode(&payment)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoReturnRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoReturnRepository(db *mongo.Collection) models.ReturnRepository {
+	return &mongoReturnRepository{
+		db: db,
+	}
+}
+
+func (r *mongoReturnRepository) Create(orderReturn *models.Return) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderReturn.CreatedAt = time.Now()
+	orderReturn.UpdatedAt = time.Now()
+
+	result, err := r.db.InsertOne(ctx, orderReturn)
+	if err != nil {
+		return err
+	}
+
+	orderReturn.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoReturnRepository) GetByID(id primitive.ObjectID) (*models.Return, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var orderReturn models.Return
+	err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&orderReturn)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrReturnNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderReturn, nil
+}
+
+func (r *mongoReturnRepository) GetByOrderID(orderID primitive.ObjectID) ([]models.Return, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.db.Find(ctx, bson.M{"order_id": orderID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	returns := []models.Return{}
+	if err = cursor.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+
+	return returns, nil
+}
+
+func (r *mongoReturnRepository) Update(orderReturn *models.Return, from models.ReturnStatus) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderReturn.UpdatedAt = time.Now()
+
+	result, err := r.db.ReplaceOne(
+		ctx,
+		bson.M{"_id": orderReturn.ID, "status": from},
+		orderReturn,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := r.db.CountDocuments(ctx, bson.M{"_id": orderReturn.ID})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return models.ErrReturnNotFound
+		}
+		return models.ErrReturnConflict
+	}
+
+	return nil
+}
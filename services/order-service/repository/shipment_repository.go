@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoShipmentRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoShipmentRepository(db *mongo.Collection) models.ShipmentRepository {
+	return &mongoShipmentRepository{
+		db: db,
+	}
+}
+
+// EnsureShipmentIndexes makes shipment sequence numbers unique within an
+// order. Shipments created before they were numbered are left out.
+func EnsureShipmentIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "order_id", Value: 1}, {Key: "sequence", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"sequence": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+func (r *mongoShipmentRepository) Create(shipment *models.Shipment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shipment.CreatedAt = time.Now()
+	shipment.UpdatedAt = time.Now()
+
+	result, err := r.db.InsertOne(ctx, shipment)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrShipmentConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	shipment.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoShipmentRepository) GetByID(id primitive.ObjectID) (*models.Shipment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var shipment models.Shipment
+	err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&shipment)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+func (r *mongoShipmentRepository) GetByOrderID(orderID primitive.ObjectID) ([]models.Shipment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.db.Find(ctx, bson.M{"order_id": orderID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	shipments := []models.Shipment{}
+	if err = cursor.All(ctx, &shipments); err != nil {
+		return nil, err
+	}
+
+	return shipments, nil
+}
+
+func (r *mongoShipmentRepository) Update(shipment *models.Shipment, from models.ShipmentStatus) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shipment.UpdatedAt = time.Now()
+
+	result, err := r.db.ReplaceOne(
+		ctx,
+		bson.M{"_id": shipment.ID, "status": from},
+		shipment,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := r.db.CountDocuments(ctx, bson.M{"_id": shipment.ID})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return models.ErrShipmentNotFound
+		}
+		return models.ErrShipmentConflict
+	}
+
+	return nil
+}
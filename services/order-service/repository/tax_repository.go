@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoTaxRateRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoTaxRateRepository(db *mongo.Collection) models.TaxRateRepository {
+	return &mongoTaxRateRepository{
+		db: db,
+	}
+}
+
+// EnsureTaxRateIndexes allows a single rate per location and category.
+// Missing fields index as null, so there is also at most one rate for
+// every location and one for every category.
+func EnsureTaxRateIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "location", Value: 1}, {Key: "category_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *mongoTaxRateRepository) Create(rate *models.TaxRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rate.CreatedAt = time.Now()
+	rate.UpdatedAt = time.Now()
+
+	result, err := r.db.InsertOne(ctx, rate)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrTaxRateExists
+	}
+	if err != nil {
+		return err
+	}
+
+	rate.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoTaxRateRepository) GetByID(id primitive.ObjectID) (*models.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var rate models.TaxRate
+	err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&rate)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrTaxRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (r *mongoTaxRateRepository) List() ([]models.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "location", Value: 1}, {Key: "name", Value: 1}})
+	cursor, err := r.db.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []models.TaxRate{}
+	if err = cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+func (r *mongoTaxRateRepository) Update(rate *models.TaxRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rate.UpdatedAt = time.Now()
+
+	result, err := r.db.ReplaceOne(ctx, bson.M{"_id": rate.ID}, rate)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrTaxRateExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrTaxRateNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoTaxRateRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.db.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrTaxRateNotFound
+	}
+
+	return nil
+}
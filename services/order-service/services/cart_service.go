@@ -0,0 +1,341 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type cartService struct {
+	cartRepo       models.CartRepository
+	productCatalog models.ProductCatalog
+	sourcer        models.Sourcer
+	orderService   models.OrderService
+	anonymousTTL   time.Duration
+}
+
+func NewCartService(cartRepo models.CartRepository, productCatalog models.ProductCatalog, sourcer models.Sourcer, orderService models.OrderService, anonymousTTL time.Duration) models.CartService {
+	return &cartService{
+		cartRepo:       cartRepo,
+		productCatalog: productCatalog,
+		sourcer:        sourcer,
+		orderService:   orderService,
+		anonymousTTL:   anonymousTTL,
+	}
+}
+
+func (s *cartService) GetCart(owner models.CartOwner) (*models.CartView, error) {
+	cart, err := s.load(owner)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(cart)
+}
+
+func (s *cartService) AddItem(owner models.CartOwner, shopID primitive.ObjectID, productID primitive.ObjectID, quantity int) (*models.CartView, error) {
+	if shopID.IsZero() {
+		return nil, fmt.Errorf("%w: shop ID is required", models.ErrInvalidCartItem)
+	}
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be greater than 0", models.ErrInvalidCartItem)
+	}
+
+	cart, err := s.load(owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) > 0 && cart.ShopID != shopID {
+		return nil, models.ErrCartShopMismatch
+	}
+	price, err := s.price(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart.ShopID = shopID
+	if i := cart.Find(productID); i >= 0 {
+		cart.Items[i].Quantity += quantity
+		cart.Items[i].Price = price
+	} else {
+		cart.Items = append(cart.Items, models.CartItem{ProductID: productID, Quantity: quantity, Price: price})
+	}
+
+	if err := s.save(cart); err != nil {
+		return nil, err
+	}
+	return s.view(cart)
+}
+
+func (s *cartService) UpdateItem(owner models.CartOwner, productID primitive.ObjectID, quantity int) (*models.CartView, error) {
+	if quantity < 0 {
+		return nil, fmt.Errorf("%w: quantity cannot be negative", models.ErrInvalidCartItem)
+	}
+	if quantity == 0 {
+		return s.RemoveItem(owner, productID)
+	}
+
+	cart, err := s.load(owner)
+	if err != nil {
+		return nil, err
+	}
+	i := cart.Find(productID)
+	if i < 0 {
+		return nil, models.ErrCartItemNotFound
+	}
+	price, err := s.price(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart.Items[i].Quantity = quantity
+	cart.Items[i].Price = price
+	if err := s.save(cart); err != nil {
+		return nil, err
+	}
+	return s.view(cart)
+}
+
+func (s *cartService) RemoveItem(owner models.CartOwner, productID primitive.ObjectID) (*models.CartView, error) {
+	cart, err := s.load(owner)
+	if err != nil {
+		return nil, err
+	}
+	i := cart.Find(productID)
+	if i < 0 {
+		return nil, models.ErrCartItemNotFound
+	}
+
+	cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+	if len(cart.Items) == 0 {
+		cart.ShopID = primitive.NilObjectID
+	}
+	if err := s.save(cart); err != nil {
+		return nil, err
+	}
+	return s.view(cart)
+}
+
+func (s *cartService) ClearCart(owner models.CartOwner) error {
+	cart, err := s.cartRepo.Get(owner)
+	if errors.Is(err, models.ErrCartNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.cartRepo.Delete(cart)
+}
+
+func (s *cartService) MergeCart(userID primitive.ObjectID, token string) (*models.CartView, error) {
+	if token == "" {
+		return nil, models.ErrCartNotFound
+	}
+	anonymous, err := s.cartRepo.Get(models.CartOwner{Token: token})
+	if err != nil {
+		return nil, err
+	}
+	cart, err := s.load(models.CartOwner{UserID: &userID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cart.Items) > 0 && len(anonymous.Items) > 0 && cart.ShopID != anonymous.ShopID {
+		cart.Items = nil
+	}
+	if len(cart.Items) == 0 {
+		cart.ShopID = anonymous.ShopID
+	}
+	for _, item := range anonymous.Items {
+		if i := cart.Find(item.ProductID); i >= 0 {
+			cart.Items[i].Quantity += item.Quantity
+			cart.Items[i].Price = item.Price
+		} else {
+			cart.Items = append(cart.Items, item)
+		}
+	}
+
+	if err := s.save(cart); err != nil {
+		return nil, err
+	}
+	// A concurrent change to the anonymous cart only means it is left to
+	// expire; its items are already in the user's cart.
+	if err := s.cartRepo.Delete(anonymous); err != nil && !errors.Is(err, models.ErrCartConflict) {
+		return nil, err
+	}
+	return s.view(cart)
+}
+
+// Checkout removes the cart before placing the order, so that a second
+// checkout of the same cart finds nothing to order, and puts it back if
+// the order fails.
+func (s *cartService) Checkout(userID primitive.ObjectID, req models.CheckoutRequest) (*models.Order, error) {
+	cart, err := s.cartRepo.Get(models.CartOwner{UserID: &userID})
+	if errors.Is(err, models.ErrCartNotFound) {
+		return nil, models.ErrCartEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, models.ErrCartEmpty
+	}
+
+	view, err := s.view(cart)
+	if err != nil {
+		return nil, err
+	}
+	if !view.Valid {
+		return nil, &models.CartInvalidError{View: view}
+	}
+
+	if err := s.cartRepo.Delete(cart); err != nil {
+		return nil, err
+	}
+
+	order := &models.Order{
+		UserID:      userID,
+		Customer:    req.Customer,
+		ShopID:      cart.ShopID,
+		Items:       make([]models.OrderItem, len(cart.Items)),
+		CouponCodes: req.CouponCodes,
+		Currency:    req.Currency,
+	}
+	for i, item := range cart.Items {
+		order.Items[i] = models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+
+	if err := s.orderService.CreateOrder(order); err != nil {
+		if restoreErr := s.cartRepo.Save(cart); restoreErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to restore cart: %w", restoreErr))
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// load returns the owner's stored cart or a new empty one. Anonymous owners
+// whose token matches no cart get a new token rather than the one they
+// sent, so tokens are always generated here.
+func (s *cartService) load(owner models.CartOwner) (*models.Cart, error) {
+	if owner.UserID != nil || owner.Token != "" {
+		cart, err := s.cartRepo.Get(owner)
+		if err == nil {
+			return cart, nil
+		}
+		if !errors.Is(err, models.ErrCartNotFound) {
+			return nil, err
+		}
+	}
+
+	cart := &models.Cart{UserID: owner.UserID, Items: []models.CartItem{}}
+	if owner.UserID == nil {
+		token, err := newCartToken()
+		if err != nil {
+			return nil, err
+		}
+		cart.Token = token
+	}
+	return cart, nil
+}
+
+// save stores the cart, pushing back the expiry of anonymous carts.
+func (s *cartService) save(cart *models.Cart) error {
+	if cart.UserID == nil {
+		expiresAt := time.Now().Add(s.anonymousTTL)
+		cart.ExpiresAt = &expiresAt
+	}
+	return s.cartRepo.Save(cart)
+}
+
+func (s *cartService) price(productID primitive.ObjectID) (money.Money, error) {
+	snapshot, err := s.productCatalog.GetProduct(productID)
+	if errors.Is(err, models.ErrProductNotFound) {
+		return money.Money{}, &models.ProductUnavailableError{ProductIDs: []string{productID.Hex()}}
+	}
+	if err != nil {
+		return money.Money{}, err
+	}
+	return snapshot.Price, nil
+}
+
+// view prices the cart at current catalog prices and checks the shop's
+// warehouses can cover it.
+func (s *cartService) view(cart *models.Cart) (*models.CartView, error) {
+	view := &models.CartView{
+		Cart:  cart,
+		Lines: make([]models.CartLine, len(cart.Items)),
+		Valid: len(cart.Items) > 0,
+	}
+
+	var subtotal money.Money
+	mixed := false
+	items := make([]models.OrderItem, 0, len(cart.Items))
+	for i, item := range cart.Items {
+		line := &view.Lines[i]
+		line.ProductID = item.ProductID
+		line.Quantity = item.Quantity
+
+		snapshot, err := s.productCatalog.GetProduct(item.ProductID)
+		if errors.Is(err, models.ErrProductNotFound) {
+			line.Problem = models.CartProblemUnavailable
+			view.Valid = false
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line.Name = snapshot.Name
+		line.Price = snapshot.Price
+		line.Subtotal = snapshot.Price.Mul(int64(item.Quantity))
+		if item.Price != snapshot.Price {
+			previous := item.Price
+			line.PreviousPrice = &previous
+		}
+		if subtotal.SameCurrency(line.Subtotal) {
+			subtotal = subtotal.Add(line.Subtotal)
+		} else {
+			mixed = true
+		}
+		items = append(items, models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	if len(items) > 0 && !mixed {
+		view.Subtotal = &subtotal
+	}
+	if len(items) == 0 {
+		return view, nil
+	}
+
+	_, err := s.sourcer.PlanSourcing(cart.ShopID, items)
+	var stockErr *models.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		for _, shortage := range stockErr.Shortages {
+			for i := range view.Lines {
+				if view.Lines[i].ProductID.Hex() == shortage.ProductID {
+					available := shortage.Available
+					view.Lines[i].Available = &available
+					view.Lines[i].Problem = models.CartProblemOutOfStock
+				}
+			}
+		}
+		view.Valid = false
+	} else if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+func newCartToken() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+)
+
+type exchangeService struct {
+	exchangeRateRepo models.ExchangeRateRepository
+}
+
+func NewExchangeService(exchangeRateRepo models.ExchangeRateRepository) models.ExchangeService {
+	return &exchangeService{
+		exchangeRateRepo: exchangeRateRepo,
+	}
+}
+
+func (s *exchangeService) SetRate(rate *models.ExchangeRate) error {
+	if err := rate.Validate(); err != nil {
+		return err
+	}
+	return s.exchangeRateRepo.Upsert(rate)
+}
+
+func (s *exchangeService) ListRates() ([]models.ExchangeRate, error) {
+	return s.exchangeRateRepo.List()
+}
+
+func (s *exchangeService) DeleteRate(base string, quote string) error {
+	return s.exchangeRateRepo.Delete(strings.ToUpper(base), strings.ToUpper(quote))
+}
+
+// LoadRatesFile reads a JSON array of rates such as
+// [{"base": "USD", "quote": "EUR", "rate": 0.92}]. They replace any rate
+// set through the API for the same pair.
+func (s *exchangeService) LoadRatesFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var rates []models.ExchangeRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for i := range rates {
+		rates[i].Source = models.ExchangeRateSourceFile
+		if err := s.SetRate(&rates[i]); err != nil {
+			return i, fmt.Errorf("rate %d in %s: %w", i, path, err)
+		}
+	}
+	return len(rates), nil
+}
+
+// ConvertOrder converts each item priced in another currency at the
+// current rate. The catalog price is kept on the item and every rate used
+// is recorded once on the order.
+func (s *exchangeService) ConvertOrder(order *models.Order) error {
+	order.Currency = strings.ToUpper(strings.TrimSpace(order.Currency))
+	if !money.ValidCurrency(order.Currency) {
+		return fmt.Errorf("%w: %q is not an ISO 4217 code", models.ErrInvalidCurrency, order.Currency)
+	}
+
+	order.ExchangeRates = nil
+	rates := make(map[string]*models.AppliedExchangeRate)
+	for i := range order.Items {
+		item := &order.Items[i]
+		from := item.Price.Currency
+		if from == order.Currency {
+			continue
+		}
+
+		rate, seen := rates[from]
+		if !seen {
+			var err error
+			rate, err = s.lookup(from, order.Currency)
+			if err != nil {
+				return err
+			}
+			rates[from] = rate
+			order.ExchangeRates = append(order.ExchangeRates, *rate)
+		}
+
+		catalogPrice := item.Price
+		item.CatalogPrice = &catalogPrice
+		item.Price = catalogPrice.Convert(rate.Rate, order.Currency)
+		if !item.Price.IsPositive() {
+			return fmt.Errorf("%w: %s is worth nothing in %s", models.ErrInvalidCurrency, catalogPrice, order.Currency)
+		}
+	}
+	return nil
+}
+
+// lookup finds the rate from one currency to another, using the inverse of
+// the opposite pair when there is no direct rate.
+func (s *exchangeService) lookup(from string, to string) (*models.AppliedExchangeRate, error) {
+	rate, err := s.exchangeRateRepo.Get(from, to)
+	if err == nil {
+		return &models.AppliedExchangeRate{From: from, To: to, Rate: rate.Rate, Source: rate.Source, AsOf: rate.UpdatedAt}, nil
+	}
+	if !errors.Is(err, models.ErrExchangeRateNotFound) {
+		return nil, err
+	}
+
+	inverse, err := s.exchangeRateRepo.Get(to, from)
+	if errors.Is(err, models.ErrExchangeRateNotFound) {
+		return nil, fmt.Errorf("%w from %s to %s", models.ErrExchangeRateNotFound, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.AppliedExchangeRate{From: from, To: to, Rate: 1 / inverse.Rate, Source: inverse.Source, AsOf: inverse.UpdatedAt}, nil
+}
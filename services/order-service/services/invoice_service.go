@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/invoices"
+	"ecommerce/order-service/models"
+)
+
+type invoiceService struct {
+	invoiceRepo   models.InvoiceRepository
+	shopDirectory models.ShopDirectory
+}
+
+func NewInvoiceService(invoiceRepo models.InvoiceRepository, shopDirectory models.ShopDirectory) models.InvoiceService {
+	return &invoiceService{
+		invoiceRepo:   invoiceRepo,
+		shopDirectory: shopDirectory,
+	}
+}
+
+func (s *invoiceService) IssueInvoice(order *models.Order) (*models.Invoice, error) {
+	if order.Status != models.OrderStatusCompleted {
+		return nil, models.ErrOrderNotCompleted
+	}
+
+	existing, err := s.invoiceRepo.GetByOrderID(order.ID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, models.ErrInvoiceNotFound) {
+		return nil, err
+	}
+
+	shop, err := s.shopDirectory.GetShop(order.ShopID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up seller: %w", err)
+	}
+
+	return s.invoiceRepo.Issue(invoices.Build(order, shop, time.Now()))
+}
+
+func (s *invoiceService) GetInvoice(order *models.Order) (*models.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByOrderID(order.ID)
+	if errors.Is(err, models.ErrInvoiceNotFound) && order.Status == models.OrderStatusCompleted {
+		return s.IssueInvoice(order)
+	}
+	if errors.Is(err, models.ErrInvoiceNotFound) {
+		return nil, models.ErrOrderNotCompleted
+	}
+	return invoice, err
+}
@@ -1,20 +1,45 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"ecommerce/order-service/metrics"
 	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
 )
 
 type orderService struct {
-	orderRepo models.OrderRepository
+	orderRepo       models.OrderRepository
+	warehouseClient models.WarehouseClient
+	productCatalog  models.ProductCatalog
+	sourcer         models.Sourcer
+	exchange        models.ExchangeService
+	promotions      models.PromotionService
+	taxes           models.TaxService
+	paymentService  models.PaymentService
+	invoices        models.InvoiceService
+	reservationTTL  time.Duration
 }
 
-func NewOrderService(orderRepo models.OrderRepository) models.OrderService {
+func NewOrderService(orderRepo models.OrderRepository, warehouseClient models.WarehouseClient, productCatalog models.ProductCatalog, sourcer models.Sourcer, exchange models.ExchangeService, promotions models.PromotionService, taxes models.TaxService, paymentService models.PaymentService, invoices models.InvoiceService, reservationTTL time.Duration) models.OrderService {
 	return &orderService{
-		orderRepo: orderRepo,
+		orderRepo:       orderRepo,
+		warehouseClient: warehouseClient,
+		productCatalog:  productCatalog,
+		sourcer:         sourcer,
+		exchange:        exchange,
+		promotions:      promotions,
+		taxes:           taxes,
+		paymentService:  paymentService,
+		invoices:        invoices,
+		reservationTTL:  reservationTTL,
 	}
 }
 
@@ -28,22 +53,91 @@ func (s *orderService) CreateOrder(order *models.Order) error {
 	if len(order.Items) == 0 {
 		return errors.New("order must have at least one item")
 	}
-
-	// Calculate total amount
-	var totalAmount float64
 	for _, item := range order.Items {
 		if item.Quantity <= 0 {
 			return errors.New("item quantity must be greater than 0")
 		}
-		if item.Price <= 0 {
-			return errors.New("item price must be greater than 0")
-		}
-		totalAmount += float64(item.Quantity) * item.Price
 	}
-	order.TotalAmount = totalAmount
+
+	// Prices come from the catalog, never from the client
+	if err := s.snapshotItems(order.Items); err != nil {
+		return err
+	}
+
+	// Settle in the requested currency, or the first item's by default
+	if order.Currency == "" {
+		order.Currency = order.Items[0].Price.Currency
+	}
+	if err := s.exchange.ConvertOrder(order); err != nil {
+		return err
+	}
+
+	if err := s.promotions.ApplyCoupons(order); err != nil {
+		return err
+	}
+
+	if err := s.taxes.ApplyTaxes(order); err != nil {
+		return err
+	}
+
+	// There are no shipping rates yet, so shipping is never charged
+	order.Shipping = money.Zero(order.Currency)
+	order.CalculateTotals()
+	if !order.TotalAmount.IsPositive() {
+		return fmt.Errorf("%w: discounts cannot cover the whole order", models.ErrCouponNotApplicable)
+	}
 	order.Status = models.OrderStatusPending
+	order.StatusHistory = []models.StatusChange{{
+		To:    models.OrderStatusPending,
+		Actor: order.UserID.Hex(),
+		At:    time.Now(),
+	}}
+
+	// Pick the warehouses to ship from. Without a plan warehouse-service
+	// chooses for each item.
+	plan, err := s.sourcer.PlanSourcing(order.ShopID, order.Items)
+	if err != nil {
+		return err
+	}
+	order.Sourcing = plan
+	allocations := make([]models.Allocation, len(order.Items))
+	for i, item := range order.Items {
+		allocations[i] = models.Allocation{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+	if plan != nil {
+		allocations = plan.Allocations
+	}
+
+	// Reserve stock under the order's own ID so the reservations can be
+	// traced back to it before the order document exists.
+	order.ID = primitive.NewObjectID()
+	reservations, err := s.warehouseClient.ReserveStock(order.ID.Hex(), allocations, s.reservationTTL)
+	if err != nil {
+		return err
+	}
+	order.Reservations = reservations
+
+	if err := s.promotions.RedeemCoupons(order); err != nil {
+		s.releaseReservations(reservations)
+		return err
+	}
+
+	payment, err := s.paymentService.Authorize(order)
+	if err != nil {
+		s.releaseReservations(reservations)
+		s.promotions.ReleaseCoupons(order)
+		return err
+	}
+	order.PaymentID = &payment.ID
+
+	if err := s.orderRepo.Create(order); err != nil {
+		s.releaseReservations(reservations)
+		s.promotions.ReleaseCoupons(order)
+		s.paymentService.Void(payment.ID)
+		return err
+	}
 
-	return s.orderRepo.Create(order)
+	return nil
 }
 
 func (s *orderService) GetOrder(id primitive.ObjectID) (*models.Order, error) {
@@ -54,79 +148,782 @@ func (s *orderService) GetOrder(id primitive.ObjectID) (*models.Order, error) {
 	return order, nil
 }
 
-func (s *orderService) GetUserOrders(userID primitive.ObjectID) ([]models.Order, error) {
-	return s.orderRepo.GetByUserID(userID)
+func (s *orderService) GetUserOrders(userID primitive.ObjectID, query models.OrderQuery) (*models.OrderPage, error) {
+	query.Filter.UserID = &userID
+	return s.listOrders(query)
 }
 
-func (s *orderService) GetShopOrders(shopID primitive.ObjectID) ([]models.Order, error) {
-	return s.orderRepo.GetByShopID(shopID)
+func (s *orderService) GetShopOrders(shopID primitive.ObjectID, query models.OrderQuery) (*models.OrderPage, error) {
+	query.Filter.ShopID = &shopID
+	return s.listOrders(query)
 }
 
-func (s *orderService) UpdateOrder(order *models.Order) error {
-	if order.ID.IsZero() {
-		return errors.New("order ID is required")
+// SearchOrders lists orders across all users and shops, for support staff.
+func (s *orderService) SearchOrders(query models.OrderQuery) (*models.OrderPage, error) {
+	return s.listOrders(query)
+}
+
+// ExportOrders streams every matching order to fn without paging.
+func (s *orderService) ExportOrders(ctx context.Context, filter models.OrderFilter, sort models.OrderSort, fn func(*models.Order) error) error {
+	query := models.OrderQuery{Filter: filter, Sort: sort}
+	if err := validateOrderQuery(&query); err != nil {
+		return err
 	}
-	if order.UserID.IsZero() {
-		return errors.New("user ID is required")
+	return s.orderRepo.Stream(ctx, query.Filter, query.Sort, fn)
+}
+
+// listOrders validates the query, fills in the default sort and page size
+// and returns the requested page.
+func (s *orderService) listOrders(query models.OrderQuery) (*models.OrderPage, error) {
+	if err := validateOrderQuery(&query); err != nil {
+		return nil, err
 	}
-	if order.ShopID.IsZero() {
-		return errors.New("shop ID is required")
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultOrderPageSize
 	}
-	if len(order.Items) == 0 {
-		return errors.New("order must have at least one item")
+	if query.Limit > models.MaxOrderPageSize {
+		query.Limit = models.MaxOrderPageSize
+	}
+
+	return s.orderRepo.List(query)
+}
+
+// validateOrderQuery checks the query's sort and filter and fills in the
+// default sort.
+func validateOrderQuery(query *models.OrderQuery) error {
+	if query.Sort == "" {
+		query.Sort = models.OrderSortCreatedAtDesc
+	}
+	if !query.Sort.Valid() {
+		return fmt.Errorf("%w: unknown sort %q", models.ErrInvalidOrderQuery, query.Sort)
+	}
+	for _, status := range query.Filter.Statuses {
+		if !status.Valid() {
+			return fmt.Errorf("%w: unknown status %q", models.ErrInvalidOrderQuery, status)
+		}
+	}
+	filter := &query.Filter
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", models.ErrInvalidOrderQuery)
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil {
+		if !filter.MinAmount.SameCurrency(*filter.MaxAmount) {
+			return fmt.Errorf("%w: min_amount and max_amount must share a currency", models.ErrInvalidOrderQuery)
+		}
+		if filter.MinAmount.Cmp(*filter.MaxAmount) > 0 {
+			return fmt.Errorf("%w: min_amount must not exceed max_amount", models.ErrInvalidOrderQuery)
+		}
+	}
+	filter.Search = strings.TrimSpace(filter.Search)
+	if len(filter.Search) > models.MaxOrderSearchLength {
+		return fmt.Errorf("%w: search must be at most %d characters", models.ErrInvalidOrderQuery, models.MaxOrderSearchLength)
+	}
+	return nil
+}
+
+// AddOrderItem adds units of a product to a pending order. A product
+// already on the order keeps the price it was ordered at; a new one is
+// priced from the catalog like at checkout.
+func (s *orderService) AddOrderItem(id primitive.ObjectID, productID primitive.ObjectID, quantity int, actor string) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be greater than 0", models.ErrInvalidOrderEdit)
+	}
+	order, err := s.editableOrder(id, models.OrderEditAddItem)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := lineIndex(order.Items, productID); i >= 0 {
+		order.Items[i].Quantity += quantity
+	} else {
+		items := []models.OrderItem{{ProductID: productID, Quantity: quantity}}
+		if err := s.snapshotItems(items); err != nil {
+			return nil, err
+		}
+		if err := s.convertItem(order, &items[0]); err != nil {
+			return nil, err
+		}
+		order.Items = append(order.Items, items[0])
+	}
+
+	return s.applyEdit(order, models.OrderEdit{
+		Type:      models.OrderEditAddItem,
+		ProductID: productID,
+		Quantity:  quantity,
+		Actor:     actor,
+	})
+}
+
+// RemoveOrderItem drops a product's line from a pending order. The last
+// line cannot be removed; the order has to be cancelled instead.
+func (s *orderService) RemoveOrderItem(id primitive.ObjectID, productID primitive.ObjectID, actor string) (*models.Order, error) {
+	order, err := s.editableOrder(id, models.OrderEditRemoveItem)
+	if err != nil {
+		return nil, err
+	}
+
+	i := lineIndex(order.Items, productID)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: product %s is not part of the order", models.ErrInvalidOrderEdit, productID.Hex())
+	}
+	if len(order.Items) == 1 {
+		return nil, fmt.Errorf("%w: the order's last item cannot be removed, cancel the order instead", models.ErrInvalidOrderEdit)
+	}
+	quantity := order.Items[i].Quantity
+	order.Items = append(order.Items[:i:i], order.Items[i+1:]...)
+
+	return s.applyEdit(order, models.OrderEdit{
+		Type:      models.OrderEditRemoveItem,
+		ProductID: productID,
+		Quantity:  quantity,
+		Actor:     actor,
+	})
+}
+
+// CancelOrderItem cancels some units of a product on a pending or paid
+// order. Cancelling every unit of a line drops it, but an order cannot
+// lose all its items this way.
+func (s *orderService) CancelOrderItem(id primitive.ObjectID, productID primitive.ObjectID, quantity int, actor string, reason string) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be greater than 0", models.ErrInvalidOrderEdit)
+	}
+	order, err := s.editableOrder(id, models.OrderEditCancelItem)
+	if err != nil {
+		return nil, err
+	}
+
+	i := lineIndex(order.Items, productID)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: product %s is not part of the order", models.ErrInvalidOrderEdit, productID.Hex())
+	}
+	switch ordered := order.Items[i].Quantity; {
+	case quantity > ordered:
+		return nil, fmt.Errorf("%w: only %d units of product %s are ordered", models.ErrInvalidOrderEdit, ordered, productID.Hex())
+	case quantity < ordered:
+		order.Items[i].Quantity -= quantity
+	case len(order.Items) == 1:
+		return nil, fmt.Errorf("%w: cancelling every item cancels the order, cancel the order instead", models.ErrInvalidOrderEdit)
+	default:
+		order.Items = append(order.Items[:i:i], order.Items[i+1:]...)
+	}
+
+	return s.applyEdit(order, models.OrderEdit{
+		Type:      models.OrderEditCancelItem,
+		ProductID: productID,
+		Quantity:  quantity,
+		Actor:     actor,
+		Reason:    reason,
+	})
+}
+
+// editableOrder loads the order and checks its status allows the edit.
+func (s *orderService) editableOrder(id primitive.ObjectID, edit models.OrderEditType) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !order.Status.Editable(edit) {
+		return nil, fmt.Errorf("%w: order is %s", models.ErrOrderNotEditable, order.Status)
+	}
+	return order, nil
+}
+
+// applyEdit reprices the order after its lines changed, brings its stock
+// reservations and payment in line with them and saves it with the edit
+// logged. Stock is reserved and a pending order's payment reauthorized
+// before the save and undone if it fails; reservations are released and a
+// paid order refunded only once the edit is saved.
+func (s *orderService) applyEdit(order *models.Order, edit models.OrderEdit) (*models.Order, error) {
+	previous := order.TotalAmount
+	previousCoupons := order.AppliedCoupons()
+
+	if err := s.promotions.ApplyCoupons(order); err != nil {
+		return nil, err
+	}
+	if err := s.taxes.ApplyTaxes(order); err != nil {
+		return nil, err
+	}
+	order.CalculateTotals()
+	if !order.TotalAmount.IsPositive() {
+		return nil, fmt.Errorf("%w: discounts cannot cover the whole order", models.ErrCouponNotApplicable)
+	}
+	if order.Status == models.OrderStatusPaid && order.TotalAmount.Cmp(previous) > 0 {
+		return nil, fmt.Errorf("%w: the edit would raise the total of a paid order", models.ErrInvalidOrderEdit)
+	}
+
+	// Coupons that discount the order only after the edit count a use now;
+	// those it leaves without a discount give theirs back once it is saved
+	if err := s.promotions.RedeemCoupons(order, previousCoupons...); err != nil {
+		return nil, err
 	}
+	redeemed := idsNotIn(order.AppliedCoupons(), previousCoupons)
+	dropped := idsNotIn(previousCoupons, order.AppliedCoupons())
 
-	// Recalculate total amount
-	var totalAmount float64
+	reserved, released, err := s.adjustReservations(order)
+	if err != nil {
+		s.promotions.ReleaseCouponUses(order, redeemed)
+		return nil, err
+	}
+	if order.Status != models.OrderStatusPending {
+		if err := s.holdReservations(reserved); err != nil {
+			s.releaseReservations(reserved)
+			s.promotions.ReleaseCouponUses(order, redeemed)
+			return nil, err
+		}
+	}
+
+	reauthorized := false
+	if order.Status == models.OrderStatusPending && order.PaymentID != nil && order.TotalAmount.Cmp(previous) != 0 {
+		if _, err := s.paymentService.Reauthorize(*order.PaymentID, order); err != nil {
+			s.releaseReservations(reserved)
+			s.promotions.ReleaseCouponUses(order, redeemed)
+			return nil, err
+		}
+		reauthorized = true
+	}
+
+	edit.PreviousTotal = previous
+	edit.NewTotal = order.TotalAmount
+	edit.At = time.Now()
+	order.Edits = append(order.Edits, edit)
+	if err := s.orderRepo.Update(order); err != nil {
+		s.releaseReservations(reserved)
+		s.promotions.ReleaseCouponUses(order, redeemed)
+		if reauthorized {
+			restored := *order
+			restored.TotalAmount = previous
+			s.paymentService.Reauthorize(*order.PaymentID, &restored)
+		}
+		return nil, err
+	}
+	metrics.OrderOperations.WithLabelValues(string(edit.Type), string(order.Status)).Inc()
+
+	var errs []error
+	if err := s.releaseReservations(released); err != nil {
+		errs = append(errs, fmt.Errorf("failed to release stock reservations: %w", err))
+	}
+	if err := s.promotions.ReleaseCouponUses(order, dropped); err != nil {
+		errs = append(errs, fmt.Errorf("failed to release coupons: %w", err))
+	}
+	if refund := previous.Sub(order.TotalAmount); order.Status == models.OrderStatusPaid && order.PaymentID != nil && refund.IsPositive() {
+		if _, err := s.paymentService.Refund(*order.PaymentID, refund); err != nil {
+			errs = append(errs, fmt.Errorf("failed to refund %s: %w", refund, err))
+		}
+	}
+	if len(errs) > 0 {
+		return order, fmt.Errorf("order was edited but not settled: %w", errors.Join(errs...))
+	}
+	return order, nil
+}
+
+// adjustReservations makes the order's unconfirmed reservations cover its
+// lines again. Missing units are sourced and reserved; surplus units are
+// given back by releasing reservations newest first, re-reserving what is
+// still needed at the same warehouse when only part of one is surplus,
+// since warehouse-service cannot shrink a reservation. It returns the
+// reservations it made and the ones left for the caller to release, and
+// records the new set and sourcing on the order.
+func (s *orderService) adjustReservations(order *models.Order) ([]models.StockReservation, []models.StockReservation, error) {
+	wanted := make(map[primitive.ObjectID]int)
+	var products []primitive.ObjectID
 	for _, item := range order.Items {
-		if item.Quantity <= 0 {
-			return errors.New("item quantity must be greater than 0")
+		if _, seen := wanted[item.ProductID]; !seen {
+			products = append(products, item.ProductID)
+		}
+		wanted[item.ProductID] += item.Quantity
+	}
+	held := make(map[primitive.ObjectID]int)
+	for _, reservation := range order.Reservations {
+		if _, seen := wanted[reservation.ProductID]; !seen {
+			products = append(products, reservation.ProductID)
+			wanted[reservation.ProductID] = 0
+		}
+		held[reservation.ProductID] += reservation.Quantity
+	}
+
+	var missing []models.OrderItem
+	var kept []models.Allocation
+	releasing := make(map[string]bool)
+	for _, productID := range products {
+		surplus := held[productID] - wanted[productID]
+		if surplus < 0 {
+			missing = append(missing, models.OrderItem{ProductID: productID, Quantity: -surplus})
+			continue
+		}
+		for i := len(order.Reservations) - 1; i >= 0 && surplus > 0; i-- {
+			reservation := order.Reservations[i]
+			if reservation.ProductID != productID || reservation.Confirmed {
+				continue
+			}
+			releasing[reservation.ID] = true
+			if reservation.Quantity > surplus {
+				kept = append(kept, models.Allocation{ProductID: productID, WarehouseID: reservation.WarehouseID, Quantity: reservation.Quantity - surplus})
+			}
+			surplus -= reservation.Quantity
+		}
+	}
+
+	allocations := kept
+	if len(missing) > 0 {
+		plan, err := s.sourcer.PlanSourcing(order.ShopID, missing)
+		if err != nil {
+			return nil, nil, err
 		}
-		if item.Price <= 0 {
-			return errors.New("item price must be greater than 0")
+		if plan != nil {
+			allocations = append(allocations, plan.Allocations...)
+		} else {
+			for _, item := range missing {
+				allocations = append(allocations, models.Allocation{ProductID: item.ProductID, Quantity: item.Quantity})
+			}
 		}
-		totalAmount += float64(item.Quantity) * item.Price
 	}
-	order.TotalAmount = totalAmount
 
-	return s.orderRepo.Update(order)
+	var reserved []models.StockReservation
+	if len(allocations) > 0 {
+		var err error
+		reserved, err = s.warehouseClient.ReserveStock(order.ID.Hex(), allocations, s.reservationTTL)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var released []models.StockReservation
+	reservations := make([]models.StockReservation, 0, len(order.Reservations)+len(reserved))
+	for _, reservation := range order.Reservations {
+		if releasing[reservation.ID] {
+			released = append(released, reservation)
+		} else {
+			reservations = append(reservations, reservation)
+		}
+	}
+	order.Reservations = append(reservations, reserved...)
+
+	if order.Sourcing != nil {
+		order.Sourcing.Allocations = allocationsOf(order.Reservations)
+	}
+	return reserved, released, nil
+}
+
+// allocationsOf sums reservations per product and warehouse, in the order
+// they were made.
+func allocationsOf(reservations []models.StockReservation) []models.Allocation {
+	var allocations []models.Allocation
+	index := make(map[models.Allocation]int)
+	for _, reservation := range reservations {
+		key := models.Allocation{ProductID: reservation.ProductID, WarehouseID: reservation.WarehouseID}
+		if i, seen := index[key]; seen {
+			allocations[i].Quantity += reservation.Quantity
+			continue
+		}
+		index[key] = len(allocations)
+		key.Quantity = reservation.Quantity
+		allocations = append(allocations, key)
+	}
+	return allocations
+}
+
+// convertItem prices a new line in the order's currency and records the
+// exchange rate used unless the order lists it already.
+func (s *orderService) convertItem(order *models.Order, item *models.OrderItem) error {
+	line := &models.Order{Currency: order.Currency, Items: []models.OrderItem{*item}}
+	if err := s.exchange.ConvertOrder(line); err != nil {
+		return err
+	}
+	*item = line.Items[0]
+
+	for _, rate := range line.ExchangeRates {
+		known := false
+		for _, existing := range order.ExchangeRates {
+			known = known || existing.From == rate.From && existing.To == rate.To
+		}
+		if !known {
+			order.ExchangeRates = append(order.ExchangeRates, rate)
+		}
+	}
+	return nil
+}
+
+// lineIndex returns the position of the product's line, or -1.
+func lineIndex(items []models.OrderItem, productID primitive.ObjectID) int {
+	for i, item := range items {
+		if item.ProductID == productID {
+			return i
+		}
+	}
+	return -1
 }
 
-func (s *orderService) ProcessOrder(id primitive.ObjectID) error {
+// PayOrder captures the order's authorized payment and marks it paid. Its
+// reservations are held from then on so they outlive their TTL.
+func (s *orderService) PayOrder(id primitive.ObjectID, actor string) error {
 	order, err := s.orderRepo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	if order.Status != models.OrderStatusPending {
-		return errors.New("order must be in pending status to process")
+	return s.settle(order, models.OrderStatusPaid, actor, "", func() error {
+		if err := s.capturePayment(order); err != nil {
+			return err
+		}
+		return s.holdReservations(order.Reservations)
+	})
+}
+
+// ProcessOrder starts processing an order. An order processed straight
+// from pending has its reservations held, as a paid one has.
+func (s *orderService) ProcessOrder(id primitive.ObjectID, actor string) error {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.settle(order, models.OrderStatusProcessing, actor, "", func() error {
+		if order.Status != models.OrderStatusPending {
+			return nil
+		}
+		return s.holdReservations(order.Reservations)
+	})
+}
+
+// ShipOrder marks a processing order as shipped once its first shipment
+// leaves the warehouse. Its reservations are confirmed then, since the
+// stock is gone and the order may take longer to deliver than they live.
+func (s *orderService) ShipOrder(id primitive.ObjectID, actor string) error {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.settle(order, models.OrderStatusShipped, actor, "", func() error {
+		return s.confirmReservations(order)
+	})
+}
+
+// DeliverOrder marks a shipped order as delivered and completes it.
+func (s *orderService) DeliverOrder(id primitive.ObjectID, actor string) error {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.transition(order, models.OrderStatusDelivered, actor, ""); err != nil {
+		return err
 	}
 
-	return s.orderRepo.UpdateStatus(id, models.OrderStatusProcessing)
+	return s.complete(order, actor)
 }
 
-func (s *orderService) CompleteOrder(id primitive.ObjectID) error {
+func (s *orderService) CompleteOrder(id primitive.ObjectID, actor string) error {
 	order, err := s.orderRepo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	if order.Status != models.OrderStatusProcessing {
-		return errors.New("order must be in processing status to complete")
+	return s.complete(order, actor)
+}
+
+// complete captures the order's payment, turns its stock reservations into
+// deductions, marks it completed and issues its invoice. An invoice that
+// fails to issue here is logged rather than returned, since the order is
+// completed either way, and is issued when it is first requested.
+func (s *orderService) complete(order *models.Order, actor string) error {
+	err := s.settle(order, models.OrderStatusCompleted, actor, "", func() error {
+		if err := s.capturePayment(order); err != nil {
+			return err
+		}
+		return s.confirmReservations(order)
+	})
+	if err != nil {
+		return err
 	}
 
-	return s.orderRepo.UpdateStatus(id, models.OrderStatusCompleted)
+	if _, err := s.invoices.IssueInvoice(order); err != nil {
+		metrics.InvoiceIssueFailures.Inc()
+		zap.L().Warn("Order completed but its invoice was not issued", zap.String("order_id", order.ID.Hex()), zap.Error(err))
+	}
+	return nil
 }
 
-func (s *orderService) CancelOrder(id primitive.ObjectID) error {
+// confirmReservations confirms the order's reservations that are not
+// confirmed yet and saves which ones were, so a retry skips them.
+func (s *orderService) confirmReservations(order *models.Order) error {
+	var confirmErr error
+	changed := false
+	for i := range order.Reservations {
+		reservation := &order.Reservations[i]
+		if reservation.Confirmed {
+			continue
+		}
+		if err := s.warehouseClient.ConfirmReservation(reservation.ID); err != nil {
+			confirmErr = fmt.Errorf("failed to confirm stock reservation %s: %w", reservation.ID, err)
+			break
+		}
+		reservation.Confirmed = true
+		changed = true
+	}
+
+	if changed {
+		if err := s.orderRepo.Update(order); err != nil {
+			return errors.Join(confirmErr, err)
+		}
+	}
+	return confirmErr
+}
+
+// CancelOrder returns the order's stock and gives the money back: an
+// authorization is voided, a captured payment is refunded in full. Stock
+// confirmed by a completion that failed partway is restocked.
+func (s *orderService) CancelOrder(id primitive.ObjectID, actor string, reason string) error {
 	order, err := s.orderRepo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	if order.Status == models.OrderStatusCompleted {
-		return errors.New("completed order cannot be cancelled")
+	return s.settle(order, models.OrderStatusCancelled, actor, reason, func() error {
+		if err := s.returnStock(order); err != nil {
+			return fmt.Errorf("failed to return stock: %w", err)
+		}
+
+		if order.PaymentID != nil {
+			payment, err := s.paymentService.GetPayment(*order.PaymentID)
+			if err != nil {
+				return err
+			}
+			switch payment.Status {
+			case models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded:
+				_, err = s.paymentService.Refund(payment.ID, money.Money{})
+			default:
+				_, err = s.paymentService.Void(payment.ID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to return payment: %w", err)
+			}
+		}
+
+		if err := s.promotions.ReleaseCoupons(order); err != nil {
+			return fmt.Errorf("failed to release coupons: %w", err)
+		}
+		return nil
+	})
+}
+
+// RefundOrder refunds part or, with a zero amount, all of a completed
+// order's captured payment. The order moves to refunded once nothing is
+// left to refund.
+func (s *orderService) RefundOrder(id primitive.ObjectID, amount money.Money, actor string, reason string) (*models.Payment, error) {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.CheckTransition(order.Status, models.OrderStatusRefunded); err != nil {
+		return nil, err
+	}
+	if order.PaymentID == nil {
+		return nil, models.ErrPaymentNotFound
 	}
 
-	return s.orderRepo.UpdateStatus(id, models.OrderStatusCancelled)
+	payment, err := s.paymentService.Refund(*order.PaymentID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status == models.PaymentStatusRefunded {
+		if err := s.transition(order, models.OrderStatusRefunded, actor, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	return payment, nil
+}
+
+func (s *orderService) GetOrderHistory(id primitive.ObjectID) ([]models.StatusChange, error) {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return order.StatusHistory, nil
+}
+
+// settle claims the order's move to the given status before running the
+// move's side effects, so two transitions can never both run theirs, and
+// records the move once they succeed. If they fail, or the move cannot be
+// recorded, the claim is given up for a retry; a claim that cannot be
+// given up lapses after models.TransitionClaimTTL.
+func (s *orderService) settle(order *models.Order, to models.OrderStatus, actor string, reason string, effects func() error) error {
+	if err := models.CheckTransition(order.Status, to); err != nil {
+		return err
+	}
+	if err := s.orderRepo.ClaimTransition(order, to, actor); err != nil {
+		return err
+	}
+
+	if err := effects(); err != nil {
+		s.orderRepo.ReleaseTransition(order)
+		return err
+	}
+	if err := s.transition(order, to, actor, reason); err != nil {
+		s.orderRepo.ReleaseTransition(order)
+		return err
+	}
+	order.Claim = nil
+	return nil
+}
+
+// transition moves the order to the given status through the state machine
+// and records the change in its history and metrics.
+func (s *orderService) transition(order *models.Order, to models.OrderStatus, actor string, reason string) error {
+	if err := models.CheckTransition(order.Status, to); err != nil {
+		return err
+	}
+
+	change := models.StatusChange{
+		From:   order.Status,
+		To:     to,
+		Actor:  actor,
+		Reason: reason,
+		At:     time.Now(),
+	}
+	if err := s.orderRepo.UpdateStatus(order.ID, change); err != nil {
+		return err
+	}
+
+	order.Status = to
+	order.StatusHistory = append(order.StatusHistory, change)
+	order.Version++
+	metrics.OrderStatusTransitions.WithLabelValues(string(change.From), string(change.To)).Inc()
+	return nil
+}
+
+// capturePayment captures the order's payment unless it has no payment or
+// it was already captured.
+func (s *orderService) capturePayment(order *models.Order) error {
+	if order.PaymentID == nil {
+		return nil
+	}
+
+	payment, err := s.paymentService.GetPayment(*order.PaymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentStatusAuthorized {
+		return nil
+	}
+
+	if _, err := s.paymentService.Capture(payment.ID); err != nil {
+		return fmt.Errorf("failed to capture payment: %w", err)
+	}
+	return nil
+}
+
+// snapshotItems overwrites each item's name, category and unit price with
+// the current catalog values. Unknown or deleted products are collected and
+// reported together.
+func (s *orderService) snapshotItems(items []models.OrderItem) error {
+	snapshots := make(map[primitive.ObjectID]*models.ProductSnapshot)
+	var unavailable []string
+
+	for i := range items {
+		productID := items[i].ProductID
+		snapshot, seen := snapshots[productID]
+		if !seen {
+			var err error
+			snapshot, err = s.productCatalog.GetProduct(productID)
+			if err != nil && !errors.Is(err, models.ErrProductNotFound) {
+				return err
+			}
+			snapshots[productID] = snapshot
+			if snapshot == nil {
+				unavailable = append(unavailable, productID.Hex())
+			}
+		}
+		if snapshot == nil {
+			continue
+		}
+
+		if !snapshot.Price.IsPositive() {
+			return fmt.Errorf("product %s has no valid price", productID.Hex())
+		}
+
+		items[i].Name = snapshot.Name
+		items[i].CategoryID = snapshot.CategoryID
+		items[i].Price = snapshot.Price
+	}
+
+	if len(unavailable) > 0 {
+		return &models.ProductUnavailableError{ProductIDs: unavailable}
+	}
+	return nil
+}
+
+// holdReservations holds the reservations that are not confirmed yet.
+// Holding is idempotent on the warehouse side.
+func (s *orderService) holdReservations(reservations []models.StockReservation) error {
+	for _, reservation := range reservations {
+		if reservation.Confirmed {
+			continue
+		}
+		if err := s.warehouseClient.HoldReservation(reservation.ID); err != nil {
+			return fmt.Errorf("failed to hold stock reservation %s: %w", reservation.ID, err)
+		}
+	}
+	return nil
+}
+
+// returnStock gives a cancelled order's stock back. Unconfirmed
+// reservations are released; confirmed ones can no longer be, as
+// warehouse-service already took their units off its stock, so those
+// units are restocked instead. Restocked reservations are saved so a retry
+// does not put them back twice.
+func (s *orderService) returnStock(order *models.Order) error {
+	var unconfirmed []models.StockReservation
+	var errs []error
+	changed := false
+	for i := range order.Reservations {
+		reservation := &order.Reservations[i]
+		switch {
+		case !reservation.Confirmed:
+			unconfirmed = append(unconfirmed, *reservation)
+		case !reservation.Restocked:
+			if err := s.warehouseClient.RestockItem(reservation.WarehouseID, reservation.ProductID, reservation.Quantity); err != nil {
+				errs = append(errs, fmt.Errorf("reservation %s: %w", reservation.ID, err))
+				continue
+			}
+			reservation.Restocked = true
+			changed = true
+		}
+	}
+	if err := s.releaseReservations(unconfirmed); err != nil {
+		errs = append(errs, err)
+	}
+
+	if changed {
+		if err := s.orderRepo.Update(order); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// releaseReservations releases every reservation it can and reports the
+// ones that failed. Releasing is idempotent on the warehouse side.
+func (s *orderService) releaseReservations(reservations []models.StockReservation) error {
+	var errs []error
+	for _, reservation := range reservations {
+		if err := s.warehouseClient.ReleaseReservation(reservation.ID); err != nil {
+			errs = append(errs, fmt.Errorf("reservation %s: %w", reservation.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// idsNotIn returns the ids that are not among others.
+func idsNotIn(ids []primitive.ObjectID, others []primitive.ObjectID) []primitive.ObjectID {
+	var missing []primitive.ObjectID
+	for _, id := range ids {
+		if !containsID(others, id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
 }
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type paymentService struct {
+	paymentRepo models.PaymentRepository
+	provider    models.PaymentProvider
+}
+
+func NewPaymentService(paymentRepo models.PaymentRepository, provider models.PaymentProvider) models.PaymentService {
+	return &paymentService{
+		paymentRepo: paymentRepo,
+		provider:    provider,
+	}
+}
+
+// Authorize holds the order's total with the provider. The payment is stored
+// before the provider is called so a declined attempt is still on record.
+func (s *paymentService) Authorize(order *models.Order) (*models.Payment, error) {
+	if !order.TotalAmount.IsPositive() {
+		return nil, errors.New("payment amount must be greater than 0")
+	}
+
+	payment := &models.Payment{
+		OrderID:        order.ID,
+		Amount:         order.TotalAmount,
+		RefundedAmount: money.Zero(order.Currency),
+		Currency:       order.Currency,
+		Status:         models.PaymentStatusPending,
+		Provider:       s.provider.Name(),
+		Attempts:       []models.PaymentAttempt{},
+	}
+	if err := s.paymentRepo.Create(payment); err != nil {
+		return nil, err
+	}
+
+	providerReference, err := s.provider.Authorize(order.ID.Hex(), payment.Amount)
+	s.recordAttempt(payment, "authorize", payment.Amount, providerReference, err)
+	if err != nil {
+		payment.Status = models.PaymentStatusFailed
+		if updateErr := s.paymentRepo.Update(payment); updateErr != nil {
+			return nil, updateErr
+		}
+		if errors.Is(err, models.ErrPaymentDeclined) {
+			return payment, err
+		}
+		return payment, fmt.Errorf("%w: %v", models.ErrPaymentUnavailable, err)
+	}
+
+	payment.Status = models.PaymentStatusAuthorized
+	payment.ProviderReference = providerReference
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (s *paymentService) Capture(id primitive.ObjectID) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentStatusAuthorized {
+		return nil, models.ErrInvalidPaymentState
+	}
+
+	err = s.provider.Capture(payment.ProviderReference, payment.Amount)
+	s.recordAttempt(payment, "capture", payment.Amount, payment.ProviderReference, err)
+	if err == nil {
+		payment.Status = models.PaymentStatusCaptured
+	}
+
+	return s.save(payment, err)
+}
+
+func (s *paymentService) Void(id primitive.ObjectID) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	switch payment.Status {
+	case models.PaymentStatusVoided, models.PaymentStatusFailed:
+		// Nothing is held with the provider
+		return payment, nil
+	case models.PaymentStatusAuthorized:
+	default:
+		return nil, models.ErrInvalidPaymentState
+	}
+
+	err = s.provider.Void(payment.ProviderReference)
+	s.recordAttempt(payment, "void", payment.Amount, payment.ProviderReference, err)
+	if err == nil {
+		payment.Status = models.PaymentStatusVoided
+	}
+
+	return s.save(payment, err)
+}
+
+// Reauthorize holds the order's new total before letting go of the old
+// authorization, so a declined attempt leaves the payment as it was. A
+// failure to void the old authorization is recorded but not returned; the
+// provider lets it lapse.
+func (s *paymentService) Reauthorize(id primitive.ObjectID, order *models.Order) (*models.Payment, error) {
+	if !order.TotalAmount.IsPositive() {
+		return nil, errors.New("payment amount must be greater than 0")
+	}
+
+	payment, err := s.paymentRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentStatusAuthorized {
+		return nil, models.ErrInvalidPaymentState
+	}
+
+	amount := order.TotalAmount
+	if !amount.SameCurrency(payment.Amount) {
+		return nil, fmt.Errorf("order total is in %s but the payment is in %s", amount.Currency, payment.Amount.Currency)
+	}
+	providerReference, err := s.provider.Authorize(order.ID.Hex(), amount)
+	s.recordAttempt(payment, "authorize", amount, providerReference, err)
+	if err != nil {
+		if updateErr := s.paymentRepo.Update(payment); updateErr != nil {
+			return nil, updateErr
+		}
+		if errors.Is(err, models.ErrPaymentDeclined) {
+			return payment, err
+		}
+		return payment, fmt.Errorf("%w: %v", models.ErrPaymentUnavailable, err)
+	}
+
+	voidErr := s.provider.Void(payment.ProviderReference)
+	s.recordAttempt(payment, "void", payment.Amount, payment.ProviderReference, voidErr)
+
+	payment.Amount = amount
+	payment.ProviderReference = providerReference
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Refund returns money from a captured payment. A zero amount refunds
+// whatever has not been refunded yet.
+func (s *paymentService) Refund(id primitive.ObjectID, amount money.Money) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentStatusCaptured && payment.Status != models.PaymentStatusPartiallyRefunded {
+		return nil, models.ErrInvalidPaymentState
+	}
+
+	amount, err = inCurrency(amount, payment.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidRefund, err)
+	}
+	remaining := payment.Refundable()
+	if amount.IsZero() {
+		amount = remaining
+	}
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("%w: refund amount must be greater than 0", models.ErrInvalidRefund)
+	}
+	if amount.Cmp(remaining) > 0 {
+		return nil, models.ErrRefundExceedsAmount
+	}
+
+	err = s.provider.Refund(payment.ProviderReference, amount)
+	s.recordAttempt(payment, "refund", amount, payment.ProviderReference, err)
+	if err == nil {
+		payment.RefundedAmount = payment.RefundedAmount.Add(amount)
+		payment.Status = models.PaymentStatusPartiallyRefunded
+		if payment.Refundable().IsZero() {
+			payment.Status = models.PaymentStatusRefunded
+		}
+	}
+
+	return s.save(payment, err)
+}
+
+func (s *paymentService) GetPayment(id primitive.ObjectID) (*models.Payment, error) {
+	return s.paymentRepo.GetByID(id)
+}
+
+func (s *paymentService) GetOrderPayment(orderID primitive.ObjectID) (*models.Payment, error) {
+	return s.paymentRepo.GetByOrderID(orderID)
+}
+
+func (s *paymentService) recordAttempt(payment *models.Payment, operation string, amount money.Money, providerReference string, err error) {
+	attempt := models.PaymentAttempt{
+		Operation:         operation,
+		Amount:            amount,
+		Success:           err == nil,
+		ProviderReference: providerReference,
+		CreatedAt:         time.Now(),
+	}
+	status := "success"
+	if err != nil {
+		attempt.Error = err.Error()
+		status = "failed"
+	}
+	payment.Attempts = append(payment.Attempts, attempt)
+	metrics.PaymentOperations.WithLabelValues(operation, status).Inc()
+}
+
+// save persists the payment with its new attempt and returns the provider
+// error, if any, so a failed operation is still recorded.
+func (s *paymentService) save(payment *models.Payment, providerErr error) (*models.Payment, error) {
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return nil, err
+	}
+	if providerErr != nil {
+		return payment, fmt.Errorf("payment provider error: %w", providerErr)
+	}
+	return payment, nil
+}
+
+// inCurrency puts an amount sent without a currency into currency, and
+// rejects one in any other currency.
+func inCurrency(amount money.Money, currency string) (money.Money, error) {
+	if amount.Currency == "" {
+		return money.Parse(amount.Decimal(), currency)
+	}
+	if amount.Currency != currency {
+		return money.Money{}, fmt.Errorf("amount is in %s, expected %s", amount.Currency, currency)
+	}
+	return amount, nil
+}
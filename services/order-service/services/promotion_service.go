@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/promotions"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type promotionService struct {
+	couponRepo models.CouponRepository
+}
+
+func NewPromotionService(couponRepo models.CouponRepository) models.PromotionService {
+	return &promotionService{
+		couponRepo: couponRepo,
+	}
+}
+
+func (s *promotionService) CreateCoupon(coupon *models.Coupon) error {
+	coupon.Code = models.NormalizeCouponCode(coupon.Code)
+	if err := coupon.Validate(); err != nil {
+		return err
+	}
+	coupon.Redemptions = 0
+	return s.couponRepo.Create(coupon)
+}
+
+func (s *promotionService) GetCoupon(code string) (*models.Coupon, error) {
+	return s.couponRepo.GetByCode(models.NormalizeCouponCode(code))
+}
+
+func (s *promotionService) SetCouponActive(code string, active bool) (*models.Coupon, error) {
+	return s.couponRepo.SetActive(models.NormalizeCouponCode(code), active)
+}
+
+func (s *promotionService) ApplyCoupons(order *models.Order) error {
+	// Discounts are always worked out here, never taken from the client
+	for i := range order.Items {
+		order.Items[i].Adjustments = nil
+	}
+
+	seen := make(map[string]bool)
+	var codes []string
+	for _, code := range order.CouponCodes {
+		code = models.NormalizeCouponCode(code)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	order.CouponCodes = codes
+
+	// Orders being edited keep the coupons' terms from when they were placed
+	at := time.Now()
+	if !order.CreatedAt.IsZero() {
+		at = order.CreatedAt
+	}
+	for _, code := range codes {
+		coupon, err := s.couponRepo.GetByCode(code)
+		if errors.Is(err, models.ErrCouponNotFound) {
+			return fmt.Errorf("%w: %s", models.ErrCouponNotApplicable, code)
+		}
+		if err != nil {
+			return err
+		}
+		if err := promotions.Apply(coupon, order, at); err != nil {
+			metrics.CouponRedemptions.WithLabelValues("not_applicable").Inc()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *promotionService) RedeemCoupons(order *models.Order, counted ...primitive.ObjectID) error {
+	applied, err := s.appliedCoupons(order)
+	if err != nil {
+		return err
+	}
+	var coupons []*models.Coupon
+	for _, coupon := range applied {
+		if !containsID(counted, coupon.ID) {
+			coupons = append(coupons, coupon)
+		}
+	}
+
+	for n, coupon := range coupons {
+		if err := s.couponRepo.Redeem(coupon, order.UserID, order.ID); err != nil {
+			metrics.CouponRedemptions.WithLabelValues("rejected").Inc()
+			for _, redeemed := range coupons[:n] {
+				s.couponRepo.Release(redeemed.ID, order.ID)
+			}
+			return err
+		}
+	}
+	metrics.CouponRedemptions.WithLabelValues("redeemed").Add(float64(len(coupons)))
+	return nil
+}
+
+func (s *promotionService) ReleaseCoupons(order *models.Order) error {
+	return s.ReleaseCouponUses(order, order.AppliedCoupons())
+}
+
+func (s *promotionService) ReleaseCouponUses(order *models.Order, couponIDs []primitive.ObjectID) error {
+	for _, couponID := range couponIDs {
+		if err := s.couponRepo.Release(couponID, order.ID); err != nil {
+			return err
+		}
+		metrics.CouponRedemptions.WithLabelValues("released").Inc()
+	}
+	return nil
+}
+
+// appliedCoupons loads the coupons behind the order's adjustments, in the
+// order they were applied.
+func (s *promotionService) appliedCoupons(order *models.Order) ([]*models.Coupon, error) {
+	var coupons []*models.Coupon
+	for _, code := range order.CouponCodes {
+		applied := false
+		for _, item := range order.Items {
+			for _, adjustment := range item.Adjustments {
+				applied = applied || adjustment.Code == code
+			}
+		}
+		if !applied {
+			continue
+		}
+		coupon, err := s.couponRepo.GetByCode(code)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, nil
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type returnService struct {
+	returnRepo      models.ReturnRepository
+	orderService    models.OrderService
+	warehouseClient models.WarehouseClient
+}
+
+func NewReturnService(returnRepo models.ReturnRepository, orderService models.OrderService, warehouseClient models.WarehouseClient) models.ReturnService {
+	return &returnService{
+		returnRepo:      returnRepo,
+		orderService:    orderService,
+		warehouseClient: warehouseClient,
+	}
+}
+
+// OpenReturn records a customer's request to send back items of a completed
+// order. Quantities are checked against what was ordered minus what other
+// open returns already cover.
+//
+// Each line's total is split exactly across its units, and a return takes
+// the units after those of earlier returns, so the returns of a product
+// never add up to more than was paid for it.
+func (s *returnService) OpenReturn(orderID primitive.ObjectID, userID primitive.ObjectID, items []models.ReturnItem, reason string) (*models.Return, error) {
+	order, err := s.orderService.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, models.ErrOrderNotOwned
+	}
+	if order.Status != models.OrderStatusCompleted {
+		return nil, models.ErrOrderNotEligible
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", models.ErrInvalidReturn)
+	}
+
+	ordered := make(map[primitive.ObjectID]int)
+	units := make(map[primitive.ObjectID][]money.Money)
+	for _, item := range order.Items {
+		ordered[item.ProductID] += item.Quantity
+		weights := make([]int64, item.Quantity)
+		for i := range weights {
+			weights[i] = 1
+		}
+		units[item.ProductID] = append(units[item.ProductID], item.Total().Allocate(weights)...)
+	}
+
+	existing, err := s.returnRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	returned := make(map[primitive.ObjectID]int)
+	for _, other := range existing {
+		if !other.Status.IsOpen() {
+			continue
+		}
+		for _, item := range other.Items {
+			returned[item.ProductID] += item.Quantity
+		}
+	}
+
+	// Merge repeated products so each appears once on the return
+	var returnItems []models.ReturnItem
+	index := make(map[primitive.ObjectID]int)
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: item quantity must be greater than 0", models.ErrInvalidReturn)
+		}
+		if _, ok := ordered[item.ProductID]; !ok {
+			return nil, fmt.Errorf("%w: product %s is not part of the order", models.ErrInvalidReturn, item.ProductID.Hex())
+		}
+		if i, seen := index[item.ProductID]; seen {
+			returnItems[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(returnItems)
+		returnItems = append(returnItems, models.ReturnItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+	for i, item := range returnItems {
+		first := returned[item.ProductID]
+		if first+item.Quantity > ordered[item.ProductID] {
+			return nil, fmt.Errorf("%w: only %d of product %s can still be returned",
+				models.ErrInvalidReturn, ordered[item.ProductID]-first, item.ProductID.Hex())
+		}
+		returnItems[i].Value = money.Zero(order.Currency).Add(money.Sum(units[item.ProductID][first : first+item.Quantity]...))
+	}
+
+	orderReturn := &models.Return{
+		OrderID:  order.ID,
+		UserID:   order.UserID,
+		ShopID:   order.ShopID,
+		Items:    returnItems,
+		Reason:   reason,
+		Status:   models.ReturnStatusRequested,
+		Currency: order.Currency,
+		StatusHistory: []models.ReturnStatusChange{{
+			To:     models.ReturnStatusRequested,
+			Actor:  userID.Hex(),
+			Reason: reason,
+			At:     time.Now(),
+		}},
+	}
+	if err := s.returnRepo.Create(orderReturn); err != nil {
+		return nil, err
+	}
+
+	metrics.ReturnStatusTransitions.WithLabelValues("", string(models.ReturnStatusRequested)).Inc()
+	return orderReturn, nil
+}
+
+func (s *returnService) GetReturn(orderID primitive.ObjectID, id primitive.ObjectID) (*models.Return, error) {
+	orderReturn, err := s.returnRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if orderReturn.OrderID != orderID {
+		return nil, models.ErrReturnNotFound
+	}
+	return orderReturn, nil
+}
+
+func (s *returnService) GetOrderReturns(orderID primitive.ObjectID) ([]models.Return, error) {
+	return s.returnRepo.GetByOrderID(orderID)
+}
+
+// ApproveReturn accepts a requested return, puts its items back into stock
+// and refunds refundAmount, or the full value of the items when it is zero.
+// Approving a return that is already approved retries whichever of the
+// restock and refund did not go through.
+func (s *returnService) ApproveReturn(orderID primitive.ObjectID, id primitive.ObjectID, refundAmount money.Money, actor string) (*models.Return, error) {
+	orderReturn, err := s.GetReturn(orderID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if orderReturn.Status == models.ReturnStatusRequested {
+		itemsValue := orderReturn.ItemsValue()
+		refundAmount, err = inCurrency(refundAmount, orderReturn.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidReturn, err)
+		}
+		if refundAmount.IsNegative() {
+			return nil, fmt.Errorf("%w: refund amount must not be negative", models.ErrInvalidReturn)
+		}
+		if refundAmount.IsZero() {
+			refundAmount = itemsValue
+		}
+		if refundAmount.Cmp(itemsValue) > 0 {
+			return nil, models.ErrRefundTooLarge
+		}
+
+		orderReturn.RefundAmount = refundAmount
+		if err := s.transition(orderReturn, models.ReturnStatusApproved, actor, ""); err != nil {
+			return nil, err
+		}
+	}
+	if orderReturn.Status != models.ReturnStatusApproved {
+		return nil, &models.InvalidReturnTransitionError{From: orderReturn.Status, To: models.ReturnStatusApproved}
+	}
+
+	if err := s.settle(orderReturn, actor); err != nil {
+		return nil, err
+	}
+	return orderReturn, nil
+}
+
+func (s *returnService) RejectReturn(orderID primitive.ObjectID, id primitive.ObjectID, actor string, reason string) (*models.Return, error) {
+	orderReturn, err := s.GetReturn(orderID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.transition(orderReturn, models.ReturnStatusRejected, actor, reason); err != nil {
+		return nil, err
+	}
+	return orderReturn, nil
+}
+
+// CancelReturn lets the customer withdraw a return the shop has not decided
+// on yet.
+func (s *returnService) CancelReturn(orderID primitive.ObjectID, id primitive.ObjectID, userID primitive.ObjectID) (*models.Return, error) {
+	orderReturn, err := s.GetReturn(orderID, id)
+	if err != nil {
+		return nil, err
+	}
+	if orderReturn.UserID != userID {
+		return nil, models.ErrOrderNotOwned
+	}
+
+	if err := s.transition(orderReturn, models.ReturnStatusCancelled, userID.Hex(), ""); err != nil {
+		return nil, err
+	}
+	return orderReturn, nil
+}
+
+// settle restocks the items of an approved return that are not restocked
+// yet, issues its refund and marks it refunded. Progress is saved after
+// every warehouse call and after the refund, so a retry neither restocks
+// the same units twice nor refunds the return again.
+func (s *returnService) settle(orderReturn *models.Return, actor string) error {
+	order, err := s.orderService.GetOrder(orderReturn.OrderID)
+	if err != nil {
+		return err
+	}
+	returns, err := s.returnRepo.GetByOrderID(order.ID)
+	if err != nil {
+		return err
+	}
+	restocked := restockedUnits(returns, orderReturn.ID)
+
+	for i := range orderReturn.Items {
+		if orderReturn.Items[i].Restocked {
+			continue
+		}
+		if err := s.restock(order, orderReturn, i, restocked[orderReturn.Items[i].ProductID]); err != nil {
+			return s.saveProgress(orderReturn, err)
+		}
+		orderReturn.Items[i].Restocked = true
+	}
+
+	if orderReturn.RefundedAt == nil {
+		reason := "return " + orderReturn.ID.Hex()
+		if _, err := s.orderService.RefundOrder(order.ID, orderReturn.RefundAmount, actor, reason); err != nil {
+			return s.saveProgress(orderReturn, fmt.Errorf("failed to refund return: %w", err))
+		}
+		now := time.Now()
+		orderReturn.RefundedAt = &now
+		if err := s.saveProgress(orderReturn, nil); err != nil {
+			return err
+		}
+	}
+
+	return s.transition(orderReturn, models.ReturnStatusRefunded, actor, "")
+}
+
+// restock returns the units of the return's i-th item not put back yet to
+// the warehouses its order reserved them from, skipping units of each
+// reservation that other returns, given as units per reservation, or
+// earlier attempts already put back.
+func (s *returnService) restock(order *models.Order, orderReturn *models.Return, i int, elsewhere map[string]int) error {
+	item := &orderReturn.Items[i]
+	used := make(map[string]int, len(elsewhere))
+	for reservationID, quantity := range elsewhere {
+		used[reservationID] = quantity
+	}
+	remaining := item.Quantity
+	for _, restock := range item.Restocks {
+		used[restock.ReservationID] += restock.Quantity
+		remaining -= restock.Quantity
+	}
+
+	for _, reservation := range order.Reservations {
+		if remaining <= 0 {
+			break
+		}
+		if reservation.ProductID != item.ProductID || used[reservation.ID] >= reservation.Quantity {
+			continue
+		}
+		quantity := reservation.Quantity - used[reservation.ID]
+		if quantity > remaining {
+			quantity = remaining
+		}
+		if err := s.warehouseClient.RestockItem(reservation.WarehouseID, item.ProductID, quantity); err != nil {
+			return fmt.Errorf("failed to restock product %s: %w", item.ProductID.Hex(), err)
+		}
+		item.Restocks = append(item.Restocks, models.ReturnRestock{
+			ReservationID: reservation.ID,
+			WarehouseID:   reservation.WarehouseID,
+			Quantity:      quantity,
+		})
+		used[reservation.ID] += quantity
+		remaining -= quantity
+		if err := s.saveProgress(orderReturn, nil); err != nil {
+			return err
+		}
+	}
+	if remaining > 0 {
+		return fmt.Errorf("no warehouse recorded for %d units of product %s", remaining, item.ProductID.Hex())
+	}
+	return nil
+}
+
+// restockedUnits sums, per product and reservation, the units the order's
+// returns other than except have put back.
+func restockedUnits(returns []models.Return, except primitive.ObjectID) map[primitive.ObjectID]map[string]int {
+	restocked := make(map[primitive.ObjectID]map[string]int)
+	for _, other := range returns {
+		if other.ID == except {
+			continue
+		}
+		for _, item := range other.Items {
+			for _, restock := range item.Restocks {
+				if restocked[item.ProductID] == nil {
+					restocked[item.ProductID] = make(map[string]int)
+				}
+				restocked[item.ProductID][restock.ReservationID] += restock.Quantity
+			}
+		}
+	}
+	return restocked
+}
+
+// saveProgress stores the return as it is and returns cause, the error
+// that interrupted it, if any.
+func (s *returnService) saveProgress(orderReturn *models.Return, cause error) error {
+	if err := s.returnRepo.Update(orderReturn, orderReturn.Status); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to save return: %w", err))
+	}
+	return cause
+}
+
+func (s *returnService) transition(orderReturn *models.Return, to models.ReturnStatus, actor string, reason string) error {
+	from := orderReturn.Status
+	if !from.CanTransitionTo(to) {
+		return &models.InvalidReturnTransitionError{From: from, To: to}
+	}
+
+	change := models.ReturnStatusChange{
+		From:   from,
+		To:     to,
+		Actor:  actor,
+		Reason: reason,
+		At:     time.Now(),
+	}
+	orderReturn.Status = to
+	orderReturn.StatusHistory = append(orderReturn.StatusHistory, change)
+	if err := s.returnRepo.Update(orderReturn, from); err != nil {
+		orderReturn.Status = from
+		orderReturn.StatusHistory = orderReturn.StatusHistory[:len(orderReturn.StatusHistory)-1]
+		return err
+	}
+
+	metrics.ReturnStatusTransitions.WithLabelValues(string(from), string(to)).Inc()
+	return nil
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"ecommerce/order-service/metrics"
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type shipmentService struct {
+	shipmentRepo models.ShipmentRepository
+	orderService models.OrderService
+}
+
+func NewShipmentService(shipmentRepo models.ShipmentRepository, orderService models.OrderService) models.ShipmentService {
+	return &shipmentService{
+		shipmentRepo: shipmentRepo,
+		orderService: orderService,
+	}
+}
+
+// CreateShipment records a parcel for some of the order's items. An item
+// can only be shipped from a warehouse the order reserved it in, and never
+// more often than it was ordered. The shipment is numbered one past those
+// the quantities were checked against, so of two shipments checked against
+// the same ones only the first is stored; the other gets
+// ErrShipmentConflict.
+func (s *shipmentService) CreateShipment(orderID primitive.ObjectID, shipment *models.Shipment) error {
+	order, err := s.orderService.GetOrder(orderID)
+	if err != nil {
+		return err
+	}
+	if !shippable(order.Status) {
+		return models.ErrOrderNotShippable
+	}
+	if shipment.WarehouseID == "" {
+		return fmt.Errorf("%w: warehouse ID is required", models.ErrInvalidShipment)
+	}
+	if len(shipment.Items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", models.ErrInvalidShipment)
+	}
+
+	ordered := make(map[primitive.ObjectID]int)
+	for _, item := range order.Items {
+		ordered[item.ProductID] += item.Quantity
+	}
+	reserved := make(map[string]bool)
+	for _, reservation := range order.Reservations {
+		reserved[reservation.WarehouseID+"/"+reservation.ProductID.Hex()] = true
+	}
+
+	existing, err := s.shipmentRepo.GetByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	shipped := shippedQuantities(existing, "")
+
+	requested := make(map[primitive.ObjectID]int)
+	for _, item := range shipment.Items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: item quantity must be greater than 0", models.ErrInvalidShipment)
+		}
+		if _, ok := ordered[item.ProductID]; !ok {
+			return fmt.Errorf("%w: product %s is not part of the order", models.ErrInvalidShipment, item.ProductID.Hex())
+		}
+		if len(order.Reservations) > 0 && !reserved[shipment.WarehouseID+"/"+item.ProductID.Hex()] {
+			return fmt.Errorf("%w: product %s was not reserved in warehouse %s", models.ErrInvalidShipment, item.ProductID.Hex(), shipment.WarehouseID)
+		}
+		requested[item.ProductID] += item.Quantity
+	}
+	for productID, quantity := range requested {
+		if shipped[productID]+quantity > ordered[productID] {
+			return fmt.Errorf("%w: only %d of product %s are left to ship",
+				models.ErrInvalidShipment, ordered[productID]-shipped[productID], productID.Hex())
+		}
+	}
+
+	shipment.OrderID = order.ID
+	shipment.Sequence = len(existing) + 1
+	shipment.ShopID = order.ShopID
+	shipment.Status = models.ShipmentStatusPending
+	shipment.ShippedAt = nil
+	shipment.DeliveredAt = nil
+	if err := s.shipmentRepo.Create(shipment); err != nil {
+		return err
+	}
+
+	metrics.ShipmentStatusTransitions.WithLabelValues("", string(models.ShipmentStatusPending)).Inc()
+	return nil
+}
+
+func (s *shipmentService) GetShipment(orderID primitive.ObjectID, id primitive.ObjectID) (*models.Shipment, error) {
+	shipment, err := s.shipmentRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.OrderID != orderID {
+		return nil, models.ErrShipmentNotFound
+	}
+	return shipment, nil
+}
+
+func (s *shipmentService) GetOrderShipments(orderID primitive.ObjectID) ([]models.Shipment, error) {
+	return s.shipmentRepo.GetByOrderID(orderID)
+}
+
+func (s *shipmentService) UpdateTracking(orderID primitive.ObjectID, id primitive.ObjectID, carrier string, trackingNumber string) (*models.Shipment, error) {
+	shipment, err := s.GetShipment(orderID, id)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.Status == models.ShipmentStatusDelivered {
+		return nil, models.ErrShipmentDelivered
+	}
+
+	if carrier != "" {
+		shipment.Carrier = carrier
+	}
+	if trackingNumber != "" {
+		shipment.TrackingNumber = trackingNumber
+	}
+	if err := s.shipmentRepo.Update(shipment, shipment.Status); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// MarkShipped records that the shipment left its warehouse. The first
+// shipment to leave moves the order to shipped.
+func (s *shipmentService) MarkShipped(orderID primitive.ObjectID, id primitive.ObjectID, actor string) (*models.Shipment, error) {
+	shipment, err := s.GetShipment(orderID, id)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderService.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !shippable(order.Status) {
+		return nil, models.ErrOrderNotShippable
+	}
+	if shipment.Carrier == "" || shipment.TrackingNumber == "" {
+		return nil, fmt.Errorf("%w: carrier and tracking number are required before shipping", models.ErrInvalidShipment)
+	}
+
+	if shipment.Status == models.ShipmentStatusPending {
+		now := time.Now()
+		shipment.ShippedAt = &now
+		if err := s.transition(shipment, models.ShipmentStatusShipped); err != nil {
+			return nil, err
+		}
+	}
+	if shipment.Status != models.ShipmentStatusShipped {
+		return nil, &models.InvalidShipmentTransitionError{From: shipment.Status, To: models.ShipmentStatusShipped}
+	}
+
+	if order.Status == models.OrderStatusProcessing {
+		if err := s.orderService.ShipOrder(orderID, actor); err != nil {
+			return nil, err
+		}
+	}
+	return shipment, nil
+}
+
+// MarkDelivered records that the shipment reached the customer. Once every
+// ordered item has been delivered the order is delivered and completed.
+// Marking a delivered shipment again retries completing the order.
+func (s *shipmentService) MarkDelivered(orderID primitive.ObjectID, id primitive.ObjectID, actor string) (*models.Shipment, error) {
+	shipment, err := s.GetShipment(orderID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if shipment.Status == models.ShipmentStatusShipped {
+		now := time.Now()
+		shipment.DeliveredAt = &now
+		if err := s.transition(shipment, models.ShipmentStatusDelivered); err != nil {
+			return nil, err
+		}
+	}
+	if shipment.Status != models.ShipmentStatusDelivered {
+		return nil, &models.InvalidShipmentTransitionError{From: shipment.Status, To: models.ShipmentStatusDelivered}
+	}
+
+	if err := s.completeIfDelivered(orderID, actor); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// completeIfDelivered delivers and completes the order when its delivered
+// shipments cover every ordered item.
+func (s *shipmentService) completeIfDelivered(orderID primitive.ObjectID, actor string) error {
+	order, err := s.orderService.GetOrder(orderID)
+	if err != nil {
+		return err
+	}
+
+	shipments, err := s.shipmentRepo.GetByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	delivered := shippedQuantities(shipments, models.ShipmentStatusDelivered)
+
+	ordered := make(map[primitive.ObjectID]int)
+	for _, item := range order.Items {
+		ordered[item.ProductID] += item.Quantity
+	}
+	for productID, quantity := range ordered {
+		if delivered[productID] < quantity {
+			return nil
+		}
+	}
+
+	switch order.Status {
+	case models.OrderStatusShipped:
+		return s.orderService.DeliverOrder(orderID, actor)
+	case models.OrderStatusDelivered:
+		return s.orderService.CompleteOrder(orderID, actor)
+	default:
+		return nil
+	}
+}
+
+func (s *shipmentService) transition(shipment *models.Shipment, to models.ShipmentStatus) error {
+	from := shipment.Status
+	if !from.CanTransitionTo(to) {
+		return &models.InvalidShipmentTransitionError{From: from, To: to}
+	}
+
+	shipment.Status = to
+	if err := s.shipmentRepo.Update(shipment, from); err != nil {
+		shipment.Status = from
+		return err
+	}
+
+	metrics.ShipmentStatusTransitions.WithLabelValues(string(from), string(to)).Inc()
+	return nil
+}
+
+func shippable(status models.OrderStatus) bool {
+	return status == models.OrderStatusProcessing || status == models.OrderStatusShipped
+}
+
+// shippedQuantities sums the quantity per product over the shipments in the
+// given status, or over all of them when status is empty.
+func shippedQuantities(shipments []models.Shipment, status models.ShipmentStatus) map[primitive.ObjectID]int {
+	quantities := make(map[primitive.ObjectID]int)
+	for _, shipment := range shipments {
+		if status != "" && shipment.Status != status {
+			continue
+		}
+		for _, item := range shipment.Items {
+			quantities[item.ProductID] += item.Quantity
+		}
+	}
+	return quantities
+}
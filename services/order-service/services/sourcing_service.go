@@ -0,0 +1,67 @@
+package services
+
+import (
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/sourcing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type sourcingService struct {
+	shopDirectory   models.ShopDirectory
+	warehouseClient models.WarehouseClient
+	defaultStrategy models.SourcingStrategy
+}
+
+func NewSourcingService(shopDirectory models.ShopDirectory, warehouseClient models.WarehouseClient, defaultStrategy models.SourcingStrategy) models.Sourcer {
+	return &sourcingService{
+		shopDirectory:   shopDirectory,
+		warehouseClient: warehouseClient,
+		defaultStrategy: defaultStrategy,
+	}
+}
+
+// PlanSourcing looks up the shop's warehouses and their available stock of
+// every ordered product, then allocates the items with the shop's strategy.
+func (s *sourcingService) PlanSourcing(shopID primitive.ObjectID, items []models.OrderItem) (*models.SourcingPlan, error) {
+	shop, err := s.shopDirectory.GetShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+	if len(shop.Warehouses) == 0 {
+		return nil, nil
+	}
+
+	strategy := shop.SourcingStrategy
+	if strategy == "" {
+		strategy = s.defaultStrategy
+	}
+
+	stock := make(sourcing.Stock, len(shop.Warehouses))
+	for _, warehouseID := range shop.Warehouses {
+		stock[warehouseID] = make(map[primitive.ObjectID]int)
+		for _, item := range items {
+			if _, fetched := stock[warehouseID][item.ProductID]; fetched {
+				continue
+			}
+			available, err := s.warehouseClient.GetAvailableStock(warehouseID, item.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			stock[warehouseID][item.ProductID] = available
+		}
+	}
+
+	allocations, shortages, err := sourcing.Plan(strategy, items, shop.Warehouses, stock)
+	if err != nil {
+		return nil, err
+	}
+	if len(shortages) > 0 {
+		return nil, &models.InsufficientStockError{Shortages: shortages}
+	}
+
+	return &models.SourcingPlan{
+		Strategy:    strategy,
+		Allocations: allocations,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"strings"
+
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/taxes"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type taxService struct {
+	taxRateRepo   models.TaxRateRepository
+	shopDirectory models.ShopDirectory
+}
+
+func NewTaxService(taxRateRepo models.TaxRateRepository, shopDirectory models.ShopDirectory) models.TaxService {
+	return &taxService{
+		taxRateRepo:   taxRateRepo,
+		shopDirectory: shopDirectory,
+	}
+}
+
+func (s *taxService) CreateRate(rate *models.TaxRate) error {
+	rate.Location = strings.TrimSpace(rate.Location)
+	if err := rate.Validate(); err != nil {
+		return err
+	}
+	return s.taxRateRepo.Create(rate)
+}
+
+func (s *taxService) GetRate(id primitive.ObjectID) (*models.TaxRate, error) {
+	return s.taxRateRepo.GetByID(id)
+}
+
+func (s *taxService) ListRates() ([]models.TaxRate, error) {
+	return s.taxRateRepo.List()
+}
+
+func (s *taxService) UpdateRate(rate *models.TaxRate) error {
+	existing, err := s.taxRateRepo.GetByID(rate.ID)
+	if err != nil {
+		return err
+	}
+
+	rate.Location = strings.TrimSpace(rate.Location)
+	if err := rate.Validate(); err != nil {
+		return err
+	}
+	rate.CreatedAt = existing.CreatedAt
+	return s.taxRateRepo.Update(rate)
+}
+
+func (s *taxService) DeleteRate(id primitive.ObjectID) error {
+	return s.taxRateRepo.Delete(id)
+}
+
+func (s *taxService) ApplyTaxes(order *models.Order) error {
+	shop, err := s.shopDirectory.GetShop(order.ShopID)
+	if err != nil {
+		return err
+	}
+	rates, err := s.taxRateRepo.List()
+	if err != nil {
+		return err
+	}
+
+	taxes.Apply(order, rates, shop.Location)
+	return nil
+}
@@ -0,0 +1,152 @@
+package sourcing
+
+import (
+	"ecommerce/order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Stock is the available quantity per warehouse and product.
+type Stock map[string]map[primitive.ObjectID]int
+
+type demand struct {
+	productID primitive.ObjectID
+	requested int
+	remaining int
+}
+
+// Plan allocates the items to the warehouses, which are listed in priority
+// order, using the given strategy. Whatever cannot be covered is reported
+// as shortages; the allocations then cover only part of the items.
+func Plan(strategy models.SourcingStrategy, items []models.OrderItem, warehouses []string, stock Stock) ([]models.Allocation, []models.StockShortage, error) {
+	if !strategy.Valid() {
+		return nil, nil, models.ErrUnknownSourcingStrategy
+	}
+
+	// Sum repeated products, keeping the order they first appear in
+	var demands []*demand
+	index := make(map[primitive.ObjectID]*demand)
+	for _, item := range items {
+		if d, ok := index[item.ProductID]; ok {
+			d.requested += item.Quantity
+			d.remaining += item.Quantity
+			continue
+		}
+		d := &demand{productID: item.ProductID, requested: item.Quantity, remaining: item.Quantity}
+		index[item.ProductID] = d
+		demands = append(demands, d)
+	}
+
+	available := make(Stock, len(warehouses))
+	for _, warehouseID := range warehouses {
+		available[warehouseID] = make(map[primitive.ObjectID]int)
+		for productID, quantity := range stock[warehouseID] {
+			available[warehouseID][productID] = quantity
+		}
+	}
+
+	p := &planner{demands: demands, warehouses: warehouses, available: available}
+	switch strategy {
+	case models.SourcingSingleWarehouseFirst:
+		if !p.single() {
+			p.priority()
+		}
+	case models.SourcingMinimizeSplits:
+		p.minimizeSplits()
+	case models.SourcingPriority:
+		p.priority()
+	}
+
+	var shortages []models.StockShortage
+	for _, d := range demands {
+		if d.remaining > 0 {
+			shortages = append(shortages, models.StockShortage{
+				ProductID: d.productID.Hex(),
+				Requested: d.requested,
+				Available: d.requested - d.remaining,
+			})
+		}
+	}
+	return p.allocations, shortages, nil
+}
+
+type planner struct {
+	demands     []*demand
+	warehouses  []string
+	available   Stock
+	allocations []models.Allocation
+}
+
+// single allocates everything from the first warehouse that holds all of
+// it, and reports whether there was one.
+func (p *planner) single() bool {
+	for _, warehouseID := range p.warehouses {
+		if p.coverage(warehouseID) == p.remaining() {
+			p.take(warehouseID)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *planner) priority() {
+	for _, warehouseID := range p.warehouses {
+		p.take(warehouseID)
+	}
+}
+
+// minimizeSplits greedily takes from the warehouse that covers the most
+// remaining units, preferring earlier warehouses on ties.
+func (p *planner) minimizeSplits() {
+	used := make(map[string]bool)
+	for p.remaining() > 0 {
+		best, bestCoverage := "", 0
+		for _, warehouseID := range p.warehouses {
+			if used[warehouseID] {
+				continue
+			}
+			if coverage := p.coverage(warehouseID); coverage > bestCoverage {
+				best, bestCoverage = warehouseID, coverage
+			}
+		}
+		if bestCoverage == 0 {
+			return
+		}
+		used[best] = true
+		p.take(best)
+	}
+}
+
+// coverage is how many of the remaining units the warehouse could supply.
+func (p *planner) coverage(warehouseID string) int {
+	total := 0
+	for _, d := range p.demands {
+		total += min(d.remaining, p.available[warehouseID][d.productID])
+	}
+	return total
+}
+
+func (p *planner) remaining() int {
+	total := 0
+	for _, d := range p.demands {
+		total += d.remaining
+	}
+	return total
+}
+
+// take allocates as much of the remaining demand as the warehouse holds.
+func (p *planner) take(warehouseID string) {
+	for _, d := range p.demands {
+		quantity := min(d.remaining, p.available[warehouseID][d.productID])
+		if quantity <= 0 {
+			continue
+		}
+		p.allocations = append(p.allocations, models.Allocation{
+			ProductID:   d.productID,
+			WarehouseID: warehouseID,
+			Quantity:    quantity,
+		})
+		d.remaining -= quantity
+		p.available[warehouseID][d.productID] -= quantity
+	}
+}
@@ -0,0 +1,75 @@
+package taxes
+
+import (
+	"math"
+	"strings"
+
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Apply sets the tax of every order line, sold from a shop in location,
+// from the best matching rate. Taxes are worked out on the lines' values
+// after discounts.
+func Apply(order *models.Order, rates []models.TaxRate, location string) {
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Tax = Line(Match(rates, location, item.CategoryID), item.Net())
+	}
+}
+
+// Match picks the rate for a location and category. A rate for both beats
+// one for the location only, which beats one for the category only, which
+// beats a rate for neither. It returns nil when no rate matches.
+func Match(rates []models.TaxRate, location string, categoryID *primitive.ObjectID) *models.TaxRate {
+	var best *models.TaxRate
+	bestScore := -1
+	for i := range rates {
+		rate := &rates[i]
+		score := 0
+		if rate.Location != "" {
+			if !strings.EqualFold(strings.TrimSpace(rate.Location), strings.TrimSpace(location)) {
+				continue
+			}
+			score += 2
+		}
+		if rate.CategoryID != nil {
+			if categoryID == nil || *rate.CategoryID != *categoryID {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rate, score
+		}
+	}
+	return best
+}
+
+// Line works out the tax on a line worth amount. For inclusive rates the
+// tax is the part of amount that is tax; for exclusive ones it comes on
+// top.
+func Line(rate *models.TaxRate, amount money.Money) models.LineTax {
+	if rate == nil {
+		return models.LineTax{Mode: models.TaxModeExclusive, Amount: money.Zero(amount.Currency)}
+	}
+
+	tax := amount.Percent(rate.Rate)
+	if rate.Mode == models.TaxModeInclusive {
+		// amount is the net value plus its tax, so the net value is
+		// amount / (1 + rate), rounded to the minor unit
+		net := money.New(int64(math.Round(float64(amount.Amount)*100/(100+rate.Rate))), amount.Currency)
+		tax = amount.Sub(net)
+	}
+
+	id := rate.ID
+	return models.LineTax{
+		RateID: &id,
+		Name:   rate.Name,
+		Rate:   rate.Rate,
+		Mode:   rate.Mode,
+		Amount: tax,
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/middleware"
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testSecret = "test-secret"
+
+func newAuthorizedRouter(orderService models.OrderService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	auth := middleware.AuthMiddleware(testSecret)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/orders/:id", auth, middleware.RequireOrderAccess(orderService, models.OrderAccessView), ok)
+	router.POST("/orders/:id/process", auth, middleware.RequireOrderAccess(orderService, models.OrderAccessShop), ok)
+	router.POST("/orders/:id/returns", auth, middleware.RequireOrderAccess(orderService, models.OrderAccessCustomer), ok)
+	router.GET("/orders/shop/:shopId", auth, middleware.RequireShopAccess(), ok)
+	return router
+}
+
+func signToken(t *testing.T, claims jwt.MapClaims) string {
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	assert.NoError(t, err)
+	return token
+}
+
+func sendAuthorized(router *gin.Engine, method string, path string, token string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestOrderEndpointsEnforceOwnership(t *testing.T) {
+	customerID := primitive.NewObjectID()
+	shopID := primitive.NewObjectID()
+	order := &models.Order{ID: primitive.NewObjectID(), UserID: customerID, ShopID: shopID, Status: models.OrderStatusPending}
+
+	mockRepo := new(MockOrderRepository)
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	router := newAuthorizedRouter(services.NewOrderService(mockRepo, nil, nil, nil, nil, nil, nil, nil, nil, 30*time.Minute))
+
+	customer := signToken(t, jwt.MapClaims{"user_id": customerID.Hex()})
+	stranger := signToken(t, jwt.MapClaims{"user_id": primitive.NewObjectID().Hex()})
+	staff := signToken(t, jwt.MapClaims{
+		"user_id":  primitive.NewObjectID().Hex(),
+		"roles":    []string{models.RoleShopStaff},
+		"shop_ids": []string{shopID.Hex()},
+	})
+	otherStaff := signToken(t, jwt.MapClaims{
+		"user_id":  primitive.NewObjectID().Hex(),
+		"roles":    []string{models.RoleShopStaff},
+		"shop_ids": []string{primitive.NewObjectID().Hex()},
+	})
+	admin := signToken(t, jwt.MapClaims{"user_id": primitive.NewObjectID().Hex(), "roles": []string{models.RoleAdmin}})
+
+	orderPath := "/orders/" + order.ID.Hex()
+	shopPath := "/orders/shop/" + shopID.Hex()
+	cases := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{"customer views own order", customer, http.MethodGet, orderPath, http.StatusOK},
+		{"stranger cannot see order", stranger, http.MethodGet, orderPath, http.StatusNotFound},
+		{"staff views shop order", staff, http.MethodGet, orderPath, http.StatusOK},
+		{"other shop staff cannot see order", otherStaff, http.MethodGet, orderPath, http.StatusNotFound},
+		{"admin views any order", admin, http.MethodGet, orderPath, http.StatusOK},
+		{"customer cannot process", customer, http.MethodPost, orderPath + "/process", http.StatusForbidden},
+		{"staff processes shop order", staff, http.MethodPost, orderPath + "/process", http.StatusOK},
+		{"admin processes any order", admin, http.MethodPost, orderPath + "/process", http.StatusOK},
+		{"customer opens return", customer, http.MethodPost, orderPath + "/returns", http.StatusOK},
+		{"staff cannot open return", staff, http.MethodPost, orderPath + "/returns", http.StatusForbidden},
+		{"customer cannot list shop orders", customer, http.MethodGet, shopPath, http.StatusForbidden},
+		{"staff lists own shop orders", staff, http.MethodGet, shopPath, http.StatusOK},
+		{"other shop staff cannot list", otherStaff, http.MethodGet, shopPath, http.StatusForbidden},
+		{"admin lists any shop", admin, http.MethodGet, shopPath, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, sendAuthorized(router, tc.method, tc.path, tc.token))
+		})
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, sendAuthorized(router, http.MethodGet, orderPath, ""))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthMiddlewareRejectsMalformedShopClaims(t *testing.T) {
+	router := newAuthorizedRouter(services.NewOrderService(new(MockOrderRepository), nil, nil, nil, nil, nil, nil, nil, nil, 30*time.Minute))
+	token := signToken(t, jwt.MapClaims{
+		"user_id":  primitive.NewObjectID().Hex(),
+		"roles":    []string{models.RoleShopStaff},
+		"shop_ids": []string{"not-an-id"},
+	})
+
+	code := sendAuthorized(router, http.MethodGet, "/orders/shop/"+primitive.NewObjectID().Hex(), token)
+
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/jobs"
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// timeoutShopDirectory serves shops with their own pending order timeouts
+// and fails for shops it was told are unreachable.
+type timeoutShopDirectory struct {
+	timeouts    map[primitive.ObjectID]time.Duration
+	unreachable primitive.ObjectID
+}
+
+func (d *timeoutShopDirectory) GetShop(id primitive.ObjectID) (*models.ShopInfo, error) {
+	if id == d.unreachable {
+		return nil, errors.New("shop service request failed")
+	}
+	return &models.ShopInfo{ID: id, PendingOrderTimeout: d.timeouts[id]}, nil
+}
+
+func TestPendingOrderCancelJob(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	quickShop, defaultShop, slowShop, downShop := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	directory := &timeoutShopDirectory{
+		timeouts:    map[primitive.ObjectID]time.Duration{quickShop: 10 * time.Minute, slowShop: 2 * time.Hour},
+		unreachable: downShop,
+	}
+
+	now := time.Now()
+	pendingOrder := func(shopID primitive.ObjectID, age time.Duration, reservations ...models.StockReservation) models.Order {
+		return models.Order{ID: primitive.NewObjectID(), ShopID: shopID, Status: models.OrderStatusPending, CreatedAt: now.Add(-age), Reservations: reservations}
+	}
+	quickStale := pendingOrder(quickShop, 15*time.Minute, models.StockReservation{ID: "r1"})
+	defaultFresh := pendingOrder(defaultShop, 15*time.Minute)
+	defaultStale := pendingOrder(defaultShop, 45*time.Minute, models.StockReservation{ID: "r2"})
+	// Its reservations lapsed after 30 minutes, so its shop's two hours
+	// are not waited for
+	slowStale := pendingOrder(slowShop, 45*time.Minute)
+	unknownShop := pendingOrder(downShop, 2*time.Hour)
+
+	mockRepo.On("List", mock.MatchedBy(func(query models.OrderQuery) bool {
+		return len(query.Filter.Statuses) == 1 && query.Filter.Statuses[0] == models.OrderStatusPending &&
+			query.Sort == models.OrderSortCreatedAtAsc &&
+			query.Filter.CreatedTo.Equal(now.Add(-models.MinPendingOrderTimeout))
+	})).Return(&models.OrderPage{Orders: []models.Order{unknownShop, defaultStale, slowStale, quickStale, defaultFresh}}, nil)
+
+	for _, order := range []models.Order{quickStale, defaultStale, slowStale} {
+		order := order
+		mockRepo.On("GetByID", order.ID).Return(&order, nil)
+	}
+	mockWarehouse.On("ReleaseReservation", "r1").Return(nil)
+	mockWarehouse.On("ReleaseReservation", "r2").Return(nil)
+	mockRepo.On("ClaimTransition", mock.Anything, models.OrderStatusCancelled).Return(nil)
+	mockRepo.On("UpdateStatus", quickStale.ID, mock.MatchedBy(func(change models.StatusChange) bool {
+		return change.To == models.OrderStatusCancelled &&
+			change.Actor == models.AutoCancelActor &&
+			change.Reason == "payment not completed within 10m0s"
+	})).Return(nil)
+	mockRepo.On("UpdateStatus", defaultStale.ID, mock.MatchedBy(func(change models.StatusChange) bool {
+		return change.To == models.OrderStatusCancelled &&
+			change.Reason == "payment not completed within 30m0s"
+	})).Return(nil)
+	mockRepo.On("UpdateStatus", slowStale.ID, mock.MatchedBy(func(change models.StatusChange) bool {
+		return change.To == models.OrderStatusCancelled &&
+			change.Reason == "payment not completed within 30m0s"
+	})).Return(nil)
+
+	job := jobs.NewPendingOrderCancelJob(mockRepo, service, directory, 30*time.Minute, 30*time.Minute, time.Minute, zap.NewNop())
+	cancelled, err := job.CancelStale(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cancelled)
+
+	mockWarehouse.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetByID", defaultFresh.ID)
+	mockRepo.AssertNotCalled(t, "GetByID", unknownShop.ID)
+}
@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryCartRepository keeps carts by ID and checks versions like the
+// MongoDB repository.
+type memoryCartRepository struct {
+	carts map[primitive.ObjectID]models.Cart
+}
+
+func newMemoryCartRepository() *memoryCartRepository {
+	return &memoryCartRepository{carts: make(map[primitive.ObjectID]models.Cart)}
+}
+
+func (r *memoryCartRepository) Get(owner models.CartOwner) (*models.Cart, error) {
+	for _, cart := range r.carts {
+		if owner.UserID != nil && cart.UserID != nil && *cart.UserID == *owner.UserID ||
+			owner.UserID == nil && cart.Token == owner.Token {
+			cart.Items = append([]models.CartItem(nil), cart.Items...)
+			return &cart, nil
+		}
+	}
+	return nil, models.ErrCartNotFound
+}
+
+func (r *memoryCartRepository) Save(cart *models.Cart) error {
+	if cart.ID.IsZero() {
+		cart.ID = primitive.NewObjectID()
+	}
+	if stored, ok := r.carts[cart.ID]; ok && stored.Version != cart.Version {
+		return models.ErrCartConflict
+	}
+	cart.Version++
+	stored := *cart
+	stored.Items = append([]models.CartItem(nil), cart.Items...)
+	r.carts[cart.ID] = stored
+	return nil
+}
+
+func (r *memoryCartRepository) Delete(cart *models.Cart) error {
+	stored, ok := r.carts[cart.ID]
+	if !ok || stored.Version != cart.Version {
+		return models.ErrCartConflict
+	}
+	delete(r.carts, cart.ID)
+	return nil
+}
+
+func newCartService(repo models.CartRepository, catalog models.ProductCatalog, warehouseClient models.WarehouseClient, orderService models.OrderService) models.CartService {
+	return services.NewCartService(repo, catalog, newSourcer(warehouseClient, "wh-1"), orderService, 24*time.Hour)
+}
+
+func TestAnonymousCartMerge(t *testing.T) {
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	repo := newMemoryCartRepository()
+	service := newCartService(repo, mockCatalog, mockWarehouse, nil)
+
+	shopID := primitive.NewObjectID()
+	keyboard, mouse := primitive.NewObjectID(), primitive.NewObjectID()
+	mockCatalog.On("GetProduct", keyboard).Return(&models.ProductSnapshot{ID: keyboard, Name: "Keyboard", Price: usd(50)}, nil)
+	mockCatalog.On("GetProduct", mouse).Return(&models.ProductSnapshot{ID: mouse, Name: "Mouse", Price: usd(20)}, nil)
+	mockWarehouse.On("GetAvailableStock", "wh-1", mock.Anything).Return(10, nil)
+
+	// An anonymous cart gets a token and an expiry
+	view, err := service.AddItem(models.CartOwner{}, shopID, keyboard, 1)
+	assert.NoError(t, err)
+	token := view.Cart.Token
+	assert.NotEmpty(t, token)
+	assert.NotNil(t, view.Cart.ExpiresAt)
+
+	view, err = service.AddItem(models.CartOwner{Token: token}, shopID, mouse, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, token, view.Cart.Token)
+	assert.Equal(t, usd(90), *view.Subtotal)
+	assert.True(t, view.Valid)
+
+	_, err = service.AddItem(models.CartOwner{Token: token}, primitive.NewObjectID(), mouse, 1)
+	assert.ErrorIs(t, err, models.ErrCartShopMismatch)
+
+	userID := primitive.NewObjectID()
+	user := models.CartOwner{UserID: &userID}
+	_, err = service.AddItem(user, shopID, keyboard, 2)
+	assert.NoError(t, err)
+
+	view, err = service.MergeCart(userID, token)
+	assert.NoError(t, err)
+	assert.Nil(t, view.Cart.ExpiresAt)
+	assert.Len(t, view.Lines, 2)
+	assert.Equal(t, 3, view.Lines[0].Quantity)
+	assert.Equal(t, 2, view.Lines[1].Quantity)
+
+	// The anonymous cart is gone once merged
+	_, err = repo.Get(models.CartOwner{Token: token})
+	assert.ErrorIs(t, err, models.ErrCartNotFound)
+	_, err = service.MergeCart(userID, token)
+	assert.ErrorIs(t, err, models.ErrCartNotFound)
+}
+
+func TestCartRevalidation(t *testing.T) {
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	service := newCartService(newMemoryCartRepository(), mockCatalog, mockWarehouse, nil)
+
+	shopID := primitive.NewObjectID()
+	keyboard, mouse := primitive.NewObjectID(), primitive.NewObjectID()
+	mockCatalog.On("GetProduct", keyboard).Return(&models.ProductSnapshot{ID: keyboard, Name: "Keyboard", Price: usd(50)}, nil).Once()
+	mockCatalog.On("GetProduct", keyboard).Return(&models.ProductSnapshot{ID: keyboard, Name: "Keyboard", Price: usd(55)}, nil)
+	mockCatalog.On("GetProduct", mouse).Return(&models.ProductSnapshot{ID: mouse, Name: "Mouse", Price: usd(20)}, nil).Times(3)
+	mockCatalog.On("GetProduct", mouse).Return(nil, models.ErrProductNotFound)
+	mockWarehouse.On("GetAvailableStock", "wh-1", keyboard).Return(1, nil)
+	mockWarehouse.On("GetAvailableStock", "wh-1", mouse).Return(10, nil)
+
+	userID := primitive.NewObjectID()
+	user := models.CartOwner{UserID: &userID}
+	_, err := service.AddItem(user, shopID, keyboard, 1)
+	assert.NoError(t, err)
+	_, err = service.AddItem(user, shopID, mouse, 1)
+	assert.NoError(t, err)
+	view, err := service.UpdateItem(user, keyboard, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CartProblemOutOfStock, view.Lines[0].Problem)
+	assert.Equal(t, 1, *view.Lines[0].Available)
+	assert.False(t, view.Valid)
+
+	// The price went up since the keyboard was added, and the mouse is gone
+	view, err = service.UpdateItem(user, keyboard, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, usd(55), view.Lines[0].Price)
+	assert.Nil(t, view.Lines[0].PreviousPrice)
+	view, err = service.GetCart(user)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CartProblemUnavailable, view.Lines[1].Problem)
+	assert.False(t, view.Valid)
+
+	_, err = service.Checkout(userID, models.CheckoutRequest{})
+	var cartErr *models.CartInvalidError
+	assert.True(t, errors.As(err, &cartErr))
+
+	view, err = service.UpdateItem(user, mouse, 0)
+	assert.NoError(t, err)
+	assert.Len(t, view.Lines, 1)
+	assert.True(t, view.Valid)
+}
+
+func TestCartCheckout(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	orderService := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse, "wh-1"), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+	repo := newMemoryCartRepository()
+	service := newCartService(repo, mockCatalog, mockWarehouse, orderService)
+
+	shopID := primitive.NewObjectID()
+	productID := primitive.NewObjectID()
+	mockCatalog.On("GetProduct", productID).Return(&models.ProductSnapshot{ID: productID, Name: "Keyboard", Price: usd(50)}, nil)
+	mockWarehouse.On("GetAvailableStock", "wh-1", productID).Return(10, nil)
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), mock.Anything, 30*time.Minute).Return(nil, errors.New("warehouse unavailable")).Once()
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), mock.Anything, 30*time.Minute).Return([]models.StockReservation{}, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Return(nil)
+
+	userID := primitive.NewObjectID()
+	user := models.CartOwner{UserID: &userID}
+	_, err := service.Checkout(userID, models.CheckoutRequest{})
+	assert.ErrorIs(t, err, models.ErrCartEmpty)
+
+	_, err = service.AddItem(user, shopID, productID, 2)
+	assert.NoError(t, err)
+
+	// A failed order leaves the cart as it was
+	_, err = service.Checkout(userID, models.CheckoutRequest{})
+	assert.Error(t, err)
+	view, err := service.GetCart(user)
+	assert.NoError(t, err)
+	assert.Len(t, view.Lines, 1)
+
+	order, err := service.Checkout(userID, models.CheckoutRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, shopID, order.ShopID)
+	assert.Equal(t, usd(100), order.Subtotal)
+
+	_, err = repo.Get(user)
+	assert.ErrorIs(t, err, models.ErrCartNotFound)
+	_, err = service.Checkout(userID, models.CheckoutRequest{})
+	assert.ErrorIs(t, err, models.ErrCartEmpty)
+}
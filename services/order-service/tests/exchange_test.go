@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+	"ecommerce/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryExchangeRateRepository struct {
+	rates map[string]models.ExchangeRate
+}
+
+func (r *memoryExchangeRateRepository) Upsert(rate *models.ExchangeRate) error {
+	rate.UpdatedAt = time.Now()
+	r.rates[rate.Base+"/"+rate.Quote] = *rate
+	return nil
+}
+
+func (r *memoryExchangeRateRepository) Get(base string, quote string) (*models.ExchangeRate, error) {
+	rate, ok := r.rates[base+"/"+quote]
+	if !ok {
+		return nil, models.ErrExchangeRateNotFound
+	}
+	return &rate, nil
+}
+
+func (r *memoryExchangeRateRepository) List() ([]models.ExchangeRate, error) {
+	rates := []models.ExchangeRate{}
+	for _, rate := range r.rates {
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func (r *memoryExchangeRateRepository) Delete(base string, quote string) error {
+	if _, ok := r.rates[base+"/"+quote]; !ok {
+		return models.ErrExchangeRateNotFound
+	}
+	delete(r.rates, base+"/"+quote)
+	return nil
+}
+
+// newExchangeService converts nothing unless rates are given.
+func newExchangeService(rates ...models.ExchangeRate) models.ExchangeService {
+	repo := &memoryExchangeRateRepository{rates: make(map[string]models.ExchangeRate)}
+	for i := range rates {
+		repo.Upsert(&rates[i])
+	}
+	return services.NewExchangeService(repo)
+}
+
+func TestConvertOrder(t *testing.T) {
+	service := newExchangeService(models.ExchangeRate{Base: "EUR", Quote: "USD", Rate: 1.25, Source: models.ExchangeRateSourceAdmin})
+
+	order := &models.Order{
+		Currency: "usd",
+		Items: []models.OrderItem{
+			{ProductID: primitive.NewObjectID(), Quantity: 1, Price: money.New(800, "EUR")},
+			{ProductID: primitive.NewObjectID(), Quantity: 1, Price: usd(3)},
+			{ProductID: primitive.NewObjectID(), Quantity: 2, Price: money.New(100, "EUR")},
+		},
+	}
+	assert.NoError(t, service.ConvertOrder(order))
+	assert.Equal(t, "USD", order.Currency)
+	assert.Equal(t, usd(10), order.Items[0].Price)
+	assert.Equal(t, money.New(800, "EUR"), *order.Items[0].CatalogPrice)
+	assert.Nil(t, order.Items[1].CatalogPrice)
+	assert.Equal(t, usd(1.25), order.Items[2].Price)
+	// The rate is recorded once however many items used it
+	assert.Len(t, order.ExchangeRates, 1)
+	assert.Equal(t, "EUR", order.ExchangeRates[0].From)
+	assert.Equal(t, 1.25, order.ExchangeRates[0].Rate)
+
+	// The opposite pair is used at its inverse
+	order = &models.Order{Currency: "EUR", Items: []models.OrderItem{{Quantity: 1, Price: usd(10)}}}
+	assert.NoError(t, service.ConvertOrder(order))
+	assert.Equal(t, money.New(800, "EUR"), order.Items[0].Price)
+	assert.Equal(t, 0.8, order.ExchangeRates[0].Rate)
+
+	order = &models.Order{Currency: "JPY", Items: []models.OrderItem{{Quantity: 1, Price: usd(10)}}}
+	assert.ErrorIs(t, service.ConvertOrder(order), models.ErrExchangeRateNotFound)
+
+	order = &models.Order{Currency: "dollars", Items: []models.OrderItem{{Quantity: 1, Price: usd(10)}}}
+	assert.ErrorIs(t, service.ConvertOrder(order), models.ErrInvalidCurrency)
+}
+
+func TestLoadRatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"base": "usd", "quote": "IDR", "rate": 15800}]`), 0o600))
+
+	service := newExchangeService()
+	count, err := service.LoadRatesFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	rates, _ := service.ListRates()
+	assert.Equal(t, "USD", rates[0].Base)
+	assert.Equal(t, models.ExchangeRateSourceFile, rates[0].Source)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"base": "USD", "quote": "USD", "rate": 1}]`), 0o600))
+	_, err = service.LoadRatesFile(path)
+	assert.ErrorIs(t, err, models.ErrInvalidExchangeRate)
+}
+
+func TestCreateOrderInSettlementCurrency(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	exchangeService := newExchangeService(models.ExchangeRate{Base: "USD", Quote: "EUR", Rate: 0.9, Source: models.ExchangeRateSourceFile})
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), exchangeService, newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	productID := primitive.NewObjectID()
+	mockCatalog.On("GetProduct", productID).Return(&models.ProductSnapshot{ID: productID, Name: "Keyboard", Price: usd(50)}, nil)
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), mock.Anything, 30*time.Minute).Return([]models.StockReservation{}, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Return(nil)
+
+	order := &models.Order{
+		UserID:   primitive.NewObjectID(),
+		ShopID:   primitive.NewObjectID(),
+		Currency: "EUR",
+		Items:    []models.OrderItem{{ProductID: productID, Quantity: 2}},
+	}
+	assert.NoError(t, service.CreateOrder(order))
+	assert.Equal(t, money.New(9000, "EUR"), order.TotalAmount)
+	assert.Equal(t, usd(50), *order.Items[0].CatalogPrice)
+	assert.Equal(t, []models.AppliedExchangeRate{{
+		From:   "USD",
+		To:     "EUR",
+		Rate:   0.9,
+		Source: models.ExchangeRateSourceFile,
+		AsOf:   order.ExchangeRates[0].AsOf,
+	}}, order.ExchangeRates)
+}
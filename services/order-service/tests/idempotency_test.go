@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/middleware"
+	"ecommerce/order-service/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryIdempotencyRepository struct {
+	records map[string]models.IdempotencyRecord
+}
+
+func newMemoryIdempotencyRepository() *memoryIdempotencyRepository {
+	return &memoryIdempotencyRepository{records: make(map[string]models.IdempotencyRecord)}
+}
+
+func (r *memoryIdempotencyRepository) Reserve(record *models.IdempotencyRecord) (bool, error) {
+	if existing, ok := r.records[record.UserID+"/"+record.Key]; ok && existing.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	record.Status = models.IdempotencyStatusInProgress
+	r.records[record.UserID+"/"+record.Key] = *record
+	return true, nil
+}
+
+func (r *memoryIdempotencyRepository) Get(userID string, key string) (*models.IdempotencyRecord, error) {
+	record, ok := r.records[userID+"/"+key]
+	if !ok {
+		return nil, models.ErrIdempotencyRecordNotFound
+	}
+	return &record, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(userID string, key string, status int, contentType string, body []byte) error {
+	record := r.records[userID+"/"+key]
+	record.Status = models.IdempotencyStatusCompleted
+	record.ResponseStatus = status
+	record.ResponseType = contentType
+	record.ResponseBody = body
+	r.records[userID+"/"+key] = record
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Delete(userID string, key string) error {
+	delete(r.records, userID+"/"+key)
+	return nil
+}
+
+func newIdempotentRouter(repo models.IdempotencyRepository, calls *int, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders",
+		func(c *gin.Context) { c.Set("user_id", c.GetHeader("X-User")) },
+		middleware.Idempotency(repo, time.Hour),
+		func(c *gin.Context) {
+			*calls++
+			c.JSON(status, gin.H{"call": *calls})
+		},
+	)
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, user string, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyRepository(), &calls, http.StatusCreated)
+
+	first := sendIdempotent(router, "user-1", "key-1", `{"shop_id":"a"}`)
+	second := sendIdempotent(router, "user-1", "key-1", `{"shop_id":"a"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(middleware.IdempotentReplayedHeader))
+
+	// Keys are scoped to the user
+	other := sendIdempotent(router, "user-2", "key-1", `{"shop_id":"a"}`)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Equal(t, 2, calls)
+
+	// Requests without a key are never deduplicated
+	sendIdempotent(router, "user-1", "", `{"shop_id":"a"}`)
+	assert.Equal(t, 3, calls)
+}
+
+func TestIdempotencyRejectsDifferentPayload(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyRepository(), &calls, http.StatusCreated)
+
+	sendIdempotent(router, "user-1", "key-1", `{"shop_id":"a"}`)
+	w := sendIdempotent(router, "user-1", "key-1", `{"shop_id":"b"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(newMemoryIdempotencyRepository(), &calls, http.StatusInternalServerError)
+
+	sendIdempotent(router, "user-1", "key-1", `{}`)
+	sendIdempotent(router, "user-1", "key-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyReleasesKeyWhenHandlerPanics(t *testing.T) {
+	repo := newMemoryIdempotencyRepository()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	calls := 0
+	router.POST("/orders",
+		func(c *gin.Context) { c.Set("user_id", c.GetHeader("X-User")) },
+		middleware.Idempotency(repo, time.Hour),
+		func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				panic("lost the database")
+			}
+			c.JSON(http.StatusCreated, gin.H{"call": calls})
+		},
+	)
+
+	w := sendIdempotent(router, "user-1", "key-1", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, repo.records)
+
+	w = sendIdempotent(router, "user-1", "key-1", `{}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+}
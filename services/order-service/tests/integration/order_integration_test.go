@@ -8,6 +8,7 @@ import (
 	"ecommerce/order-service/config"
 	"ecommerce/order-service/models"
 	"ecommerce/order-service/repository"
+	"ecommerce/pkg/money"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -36,7 +37,7 @@ func TestOrderRepositoryIntegration(t *testing.T) {
 	collection, cleanup := setupTestDB(t)
 	defer cleanup()
 
-	repo := repository.NewMongoOrderRepository(collection)
+	repo := repository.NewMongoOrderRepository(collection, collection.Database().Collection("outbox"), nil)
 
 	t.Run("Create and Get Order", func(t *testing.T) {
 		order := &models.Order{
@@ -46,10 +47,10 @@ func TestOrderRepositoryIntegration(t *testing.T) {
 				{
 					ProductID: primitive.NewObjectID(),
 					Quantity:  2,
-					Price:     100.0,
+					Price:     money.New(10000, "USD"),
 				},
 			},
-			TotalAmount: 200.0,
+			TotalAmount: money.New(20000, "USD"),
 			Status:      models.OrderStatusPending,
 		}
 
@@ -68,13 +69,13 @@ func TestOrderRepositoryIntegration(t *testing.T) {
 		order1 := &models.Order{
 			UserID:      userID,
 			ShopID:      primitive.NewObjectID(),
-			TotalAmount: 100.0,
+			TotalAmount: money.New(10000, "USD"),
 			Status:      models.OrderStatusPending,
 		}
 		order2 := &models.Order{
 			UserID:      userID,
 			ShopID:      primitive.NewObjectID(),
-			TotalAmount: 200.0,
+			TotalAmount: money.New(20000, "USD"),
 			Status:      models.OrderStatusCompleted,
 		}
 
@@ -83,9 +84,24 @@ func TestOrderRepositoryIntegration(t *testing.T) {
 		err = repo.Create(order2)
 		assert.NoError(t, err)
 
-		orders, err := repo.GetByUserID(userID)
+		query := models.OrderQuery{
+			Filter: models.OrderFilter{UserID: &userID},
+			Sort:   models.OrderSortTotalAmountDesc,
+			Limit:  1,
+		}
+		page, err := repo.List(query)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), page.TotalCount)
+		assert.Len(t, page.Orders, 1)
+		assert.Equal(t, money.New(20000, "USD"), page.Orders[0].TotalAmount)
+		assert.NotEmpty(t, page.NextCursor)
+
+		query.Cursor = page.NextCursor
+		page, err = repo.List(query)
 		assert.NoError(t, err)
-		assert.Len(t, orders, 2)
+		assert.Len(t, page.Orders, 1)
+		assert.Equal(t, money.New(10000, "USD"), page.Orders[0].TotalAmount)
+		assert.Empty(t, page.NextCursor)
 	})
 
 	t.Run("Update Order Status", func(t *testing.T) {
@@ -93,17 +109,60 @@ func TestOrderRepositoryIntegration(t *testing.T) {
 			UserID:      primitive.NewObjectID(),
 			ShopID:      primitive.NewObjectID(),
 			Status:      models.OrderStatusPending,
-			TotalAmount: 150.0,
+			TotalAmount: money.New(15000, "USD"),
 		}
 
 		err := repo.Create(order)
 		assert.NoError(t, err)
 
-		err = repo.UpdateStatus(order.ID, models.OrderStatusProcessing)
+		err = repo.UpdateStatus(order.ID, models.StatusChange{
+			From:  models.OrderStatusPending,
+			To:    models.OrderStatusProcessing,
+			Actor: "test",
+			At:    time.Now(),
+		})
 		assert.NoError(t, err)
 
 		updated, err := repo.GetByID(order.ID)
 		assert.NoError(t, err)
 		assert.Equal(t, models.OrderStatusProcessing, updated.Status)
+		assert.Len(t, updated.StatusHistory, 1)
+	})
+
+	t.Run("Claimed Transition", func(t *testing.T) {
+		order := &models.Order{
+			UserID:      primitive.NewObjectID(),
+			ShopID:      primitive.NewObjectID(),
+			Status:      models.OrderStatusProcessing,
+			TotalAmount: money.New(15000, "USD"),
+		}
+		assert.NoError(t, repo.Create(order))
+
+		completing, err := repo.GetByID(order.ID)
+		assert.NoError(t, err)
+		cancelling, err := repo.GetByID(order.ID)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.ClaimTransition(completing, models.OrderStatusCompleted, "shop"))
+
+		// Neither a second claim nor a different status change gets
+		// through while the claim is live
+		var conflictErr *models.OrderConflictError
+		assert.ErrorAs(t, repo.ClaimTransition(cancelling, models.OrderStatusCancelled, "user"), &conflictErr)
+		assert.ErrorAs(t, repo.UpdateStatus(order.ID, models.StatusChange{
+			From: models.OrderStatusProcessing,
+			To:   models.OrderStatusCancelled,
+			At:   time.Now(),
+		}), &conflictErr)
+
+		assert.NoError(t, repo.UpdateStatus(order.ID, models.StatusChange{
+			From: models.OrderStatusProcessing,
+			To:   models.OrderStatusCompleted,
+			At:   time.Now(),
+		}))
+		updated, err := repo.GetByID(order.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.OrderStatusCompleted, updated.Status)
+		assert.Nil(t, updated.Claim)
 	})
 }
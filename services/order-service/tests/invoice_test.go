@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/invoices"
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryInvoiceRepository numbers invoices one past the shop's last, like
+// the MongoDB repository.
+type memoryInvoiceRepository struct {
+	invoices []models.Invoice
+}
+
+func (r *memoryInvoiceRepository) Issue(invoice *models.Invoice) (*models.Invoice, error) {
+	if existing, err := r.GetByOrderID(invoice.OrderID); err == nil {
+		return existing, nil
+	}
+	var last int64
+	for _, stored := range r.invoices {
+		if stored.ShopID == invoice.ShopID && stored.Sequence > last {
+			last = stored.Sequence
+		}
+	}
+	invoice.ID = primitive.NewObjectID()
+	invoice.Sequence = last + 1
+	invoice.Number = models.InvoiceNumber(invoice.ShopID, invoice.Sequence)
+	r.invoices = append(r.invoices, *invoice)
+	return invoice, nil
+}
+
+func (r *memoryInvoiceRepository) GetByOrderID(orderID primitive.ObjectID) (*models.Invoice, error) {
+	for _, invoice := range r.invoices {
+		if invoice.OrderID == orderID {
+			return &invoice, nil
+		}
+	}
+	return nil, models.ErrInvoiceNotFound
+}
+
+func newInvoiceService() models.InvoiceService {
+	return services.NewInvoiceService(&memoryInvoiceRepository{}, &locatedShopDirectory{location: "Jakarta"})
+}
+
+func completedOrder(shopID primitive.ObjectID) *models.Order {
+	order := &models.Order{
+		ID:       primitive.NewObjectID(),
+		UserID:   primitive.NewObjectID(),
+		ShopID:   shopID,
+		Customer: &models.Customer{Email: "buyer@example.com"},
+		Currency: "USD",
+		Status:   models.OrderStatusCompleted,
+		Shipping: usd(0),
+		Items: []models.OrderItem{
+			{Name: "Keyboard", Quantity: 2, Price: usd(50), Tax: models.LineTax{Name: "VAT", Rate: 10, Mode: models.TaxModeExclusive, Amount: usd(10)}},
+			{Name: "Mouse", Quantity: 1, Price: usd(20), Adjustments: []models.Adjustment{{Amount: usd(5)}}, Tax: models.LineTax{Name: "VAT", Rate: 10, Mode: models.TaxModeExclusive, Amount: usd(1.5)}},
+			{Name: "Novel", Quantity: 1, Price: usd(22), Tax: models.LineTax{Name: "Book VAT", Rate: 10, Mode: models.TaxModeInclusive, Amount: usd(2)}},
+		},
+	}
+	order.CalculateTotals()
+	return order
+}
+
+func TestBuildInvoice(t *testing.T) {
+	order := completedOrder(primitive.NewObjectID())
+	invoice := invoices.Build(order, &models.ShopInfo{ID: order.ShopID, Name: "Tokoku", Location: "Jakarta"}, time.Now())
+
+	assert.Equal(t, "Tokoku", invoice.Seller.Name)
+	assert.Equal(t, "buyer@example.com", invoice.Buyer.Email)
+	assert.Equal(t, usd(5), invoice.Lines[1].Discount)
+	assert.Equal(t, usd(15), invoice.Lines[1].Net)
+	assert.Equal(t, usd(16.5), invoice.Lines[1].Total)
+	assert.Equal(t, order.TotalAmount, invoice.Total)
+
+	// Lines taxed alike are summed into one entry
+	assert.Equal(t, []models.InvoiceTax{
+		{Name: "VAT", Rate: 10, Mode: models.TaxModeExclusive, Taxable: usd(115), Amount: usd(11.5)},
+		{Name: "Book VAT", Rate: 10, Mode: models.TaxModeInclusive, Taxable: usd(22), Amount: usd(2)},
+	}, invoice.Taxes)
+}
+
+func TestIssueInvoiceNumbersPerShop(t *testing.T) {
+	service := newInvoiceService()
+	shop, otherShop := primitive.NewObjectID(), primitive.NewObjectID()
+
+	first, err := service.IssueInvoice(completedOrder(shop))
+	assert.NoError(t, err)
+	second, err := service.IssueInvoice(completedOrder(shop))
+	assert.NoError(t, err)
+	other, err := service.IssueInvoice(completedOrder(otherShop))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), first.Sequence)
+	assert.Equal(t, int64(2), second.Sequence)
+	assert.Equal(t, int64(1), other.Sequence)
+	assert.Equal(t, models.InvoiceNumber(shop, 2), second.Number)
+
+	// An order keeps the invoice it was issued, whatever changes later
+	order := completedOrder(shop)
+	issued, err := service.IssueInvoice(order)
+	assert.NoError(t, err)
+	order.Items[0].Quantity = 5
+	again, err := service.GetInvoice(order)
+	assert.NoError(t, err)
+	assert.Equal(t, issued.Number, again.Number)
+	assert.Equal(t, 2, again.Lines[0].Quantity)
+
+	pending := completedOrder(shop)
+	pending.Status = models.OrderStatusPending
+	_, err = service.IssueInvoice(pending)
+	assert.ErrorIs(t, err, models.ErrOrderNotCompleted)
+	_, err = service.GetInvoice(pending)
+	assert.ErrorIs(t, err, models.ErrOrderNotCompleted)
+}
+
+func TestRenderInvoicePDF(t *testing.T) {
+	order := completedOrder(primitive.NewObjectID())
+	invoice, err := newInvoiceService().IssueInvoice(order)
+	assert.NoError(t, err)
+
+	pdf := invoices.RenderPDF(invoice)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "("+invoice.Number+")")
+	assert.Contains(t, string(pdf), "(buyer@example.com)")
+}
+
+// unavailableInvoiceService cannot issue invoices.
+type unavailableInvoiceService struct {
+	models.InvoiceService
+}
+
+func (unavailableInvoiceService) IssueInvoice(*models.Order) (*models.Invoice, error) {
+	return nil, errors.New("shop service unavailable")
+}
+
+func TestCompleteOrderSucceedsWithoutInvoice(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	paymentService := newFakePaymentService()
+	orderService := services.NewOrderService(mockRepo, new(MockWarehouseClient), new(MockProductCatalog), nil, newExchangeService(), newPromotionService(), newTaxService(), paymentService, unavailableInvoiceService{newInvoiceService()}, 30*time.Minute)
+
+	order := &models.Order{ID: primitive.NewObjectID(), TotalAmount: usd(100), Currency: "USD", Status: models.OrderStatusDelivered}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("ClaimTransition", order.ID, models.OrderStatusCompleted).Return(nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.AnythingOfType("models.StatusChange")).Return(nil)
+
+	assert.NoError(t, orderService.CompleteOrder(order.ID, "shop-1"))
+	assert.Equal(t, models.OrderStatusCompleted, order.Status)
+}
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// editableOrder builds an order whose lines are priced and reserved the way
+// CreateOrder leaves them, with its payment authorized or, for paid orders,
+// captured.
+func editableOrder(t *testing.T, paymentService models.PaymentService, status models.OrderStatus, items []models.OrderItem, reservations []models.StockReservation) *models.Order {
+	order := &models.Order{
+		ID:           primitive.NewObjectID(),
+		UserID:       primitive.NewObjectID(),
+		ShopID:       primitive.NewObjectID(),
+		Items:        items,
+		Currency:     "USD",
+		Shipping:     usd(0),
+		Status:       status,
+		Reservations: reservations,
+		CreatedAt:    time.Now().Add(-time.Hour),
+	}
+	order.CalculateTotals()
+
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	if status == models.OrderStatusPaid {
+		_, err = paymentService.Capture(payment.ID)
+		assert.NoError(t, err)
+	}
+	order.PaymentID = &payment.ID
+	return order
+}
+
+func TestAddOrderItem(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	keyboard, mouse := primitive.NewObjectID(), primitive.NewObjectID()
+	order := editableOrder(t, paymentService, models.OrderStatusPending,
+		[]models.OrderItem{{ProductID: keyboard, Name: "Keyboard", Quantity: 2, Price: usd(50)}},
+		[]models.StockReservation{{ID: "r1", ProductID: keyboard, Quantity: 2}},
+	)
+	authorized, _ := paymentService.GetPayment(*order.PaymentID)
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("Update", order).Return(nil)
+	mockCatalog.On("GetProduct", mouse).Return(&models.ProductSnapshot{ID: mouse, Name: "Mouse", Price: usd(20)}, nil)
+	mockWarehouse.On("ReserveStock", order.ID.Hex(), []models.Allocation{{ProductID: mouse, Quantity: 3}}, 30*time.Minute).
+		Return([]models.StockReservation{{ID: "r2", ProductID: mouse, Quantity: 3}}, nil)
+	mockWarehouse.On("ReserveStock", order.ID.Hex(), []models.Allocation{{ProductID: keyboard, Quantity: 1}}, 30*time.Minute).
+		Return([]models.StockReservation{{ID: "r3", ProductID: keyboard, Quantity: 1}}, nil)
+
+	edited, err := service.AddOrderItem(order.ID, mouse, 3, "customer-1")
+	assert.NoError(t, err)
+	assert.Len(t, edited.Items, 2)
+	assert.Equal(t, "Mouse", edited.Items[1].Name)
+	assert.Equal(t, usd(160), edited.TotalAmount)
+
+	// A product already ordered keeps its price and is not looked up again
+	edited, err = service.AddOrderItem(order.ID, keyboard, 1, "customer-1")
+	assert.NoError(t, err)
+	assert.Len(t, edited.Items, 2)
+	assert.Equal(t, 3, edited.Items[0].Quantity)
+	assert.Equal(t, usd(210), edited.TotalAmount)
+	assert.Equal(t, []string{"r1", "r2", "r3"}, reservationIDs(edited.Reservations))
+	mockCatalog.AssertNumberOfCalls(t, "GetProduct", 1)
+
+	assert.Len(t, edited.Edits, 2)
+	assert.Equal(t, models.OrderEditAddItem, edited.Edits[1].Type)
+	assert.Equal(t, usd(160), edited.Edits[1].PreviousTotal)
+	assert.Equal(t, usd(210), edited.Edits[1].NewTotal)
+
+	payment, err := paymentService.GetPayment(*order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusAuthorized, payment.Status)
+	assert.Equal(t, usd(210), payment.Amount)
+	assert.NotEqual(t, authorized.ProviderReference, payment.ProviderReference)
+	mockWarehouse.AssertExpectations(t)
+}
+
+func TestAddOrderItemRollsBackOnConflict(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	keyboard := primitive.NewObjectID()
+	order := editableOrder(t, paymentService, models.OrderStatusPending,
+		[]models.OrderItem{{ProductID: keyboard, Quantity: 1, Price: usd(50)}},
+		[]models.StockReservation{{ID: "r1", ProductID: keyboard, Quantity: 1}},
+	)
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("Update", order).Return(&models.OrderConflictError{OrderID: order.ID})
+	mockWarehouse.On("ReserveStock", order.ID.Hex(), []models.Allocation{{ProductID: keyboard, Quantity: 1}}, 30*time.Minute).
+		Return([]models.StockReservation{{ID: "r2", ProductID: keyboard, Quantity: 1}}, nil)
+	mockWarehouse.On("ReleaseReservation", "r2").Return(nil)
+
+	_, err := service.AddOrderItem(order.ID, keyboard, 1, "customer-1")
+	var conflictErr *models.OrderConflictError
+	assert.ErrorAs(t, err, &conflictErr)
+
+	payment, err := paymentService.GetPayment(*order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, usd(50), payment.Amount)
+	mockWarehouse.AssertExpectations(t)
+}
+
+func TestEditKeepsCouponUsesInStep(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	couponRepo := newMemoryCouponRepository()
+	promotionService := services.NewPromotionService(couponRepo)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), promotionService, newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	// One percent of a single sticker rounds to nothing, so the coupon only
+	// discounts the order, and counts a use, while it has three
+	sticker := primitive.NewObjectID()
+	coupon := &models.Coupon{Code: "TINY", Type: models.CouponTypePercentage, Percent: 1, ProductIDs: []primitive.ObjectID{sticker}, Active: true}
+	assert.NoError(t, promotionService.CreateCoupon(coupon))
+	order := editableOrder(t, paymentService, models.OrderStatusPending,
+		[]models.OrderItem{{ProductID: sticker, Quantity: 1, Price: usd(0.40)}},
+		[]models.StockReservation{{ID: "r1", ProductID: sticker, Quantity: 1}},
+	)
+	order.CouponCodes = []string{"TINY"}
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("Update", order).Return(nil)
+	mockWarehouse.On("ReserveStock", order.ID.Hex(), []models.Allocation{{ProductID: sticker, Quantity: 2}}, 30*time.Minute).
+		Return([]models.StockReservation{{ID: "r2", ProductID: sticker, Quantity: 2}}, nil)
+	mockWarehouse.On("ReleaseReservation", "r2").Return(nil)
+
+	edited, err := service.AddOrderItem(order.ID, sticker, 2, "customer-1")
+	assert.NoError(t, err)
+	assert.Equal(t, usd(0.01), edited.Discount)
+	assert.Equal(t, 1, couponRepo.byID(coupon.ID).Redemptions)
+
+	edited, err = service.CancelOrderItem(order.ID, sticker, 2, "customer-1", "changed my mind")
+	assert.NoError(t, err)
+	assert.Equal(t, usd(0), edited.Discount)
+	assert.Equal(t, 0, couponRepo.byID(coupon.ID).Redemptions)
+	mockWarehouse.AssertExpectations(t)
+}
+
+func TestCancelOrderItemRefundsPaidOrder(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	cable, charger := primitive.NewObjectID(), primitive.NewObjectID()
+	order := editableOrder(t, paymentService, models.OrderStatusPaid,
+		[]models.OrderItem{
+			{ProductID: cable, Quantity: 5, Price: usd(10)},
+			{ProductID: charger, Quantity: 1, Price: usd(30)},
+		},
+		[]models.StockReservation{
+			{ID: "r1", WarehouseID: "w1", ProductID: cable, Quantity: 3},
+			{ID: "r2", WarehouseID: "w2", ProductID: cable, Quantity: 2},
+			{ID: "r3", WarehouseID: "w1", ProductID: charger, Quantity: 1},
+		},
+	)
+	order.Sourcing = &models.SourcingPlan{Strategy: models.SourcingPriority, Allocations: []models.Allocation{
+		{ProductID: cable, WarehouseID: "w1", Quantity: 3},
+		{ProductID: cable, WarehouseID: "w2", Quantity: 2},
+		{ProductID: charger, WarehouseID: "w1", Quantity: 1},
+	}}
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("Update", order).Return(nil)
+	// Cancelling 3 cables frees w2's reservation and 1 of w1's, which is
+	// re-reserved at 2 units, and held since the order is paid, before the
+	// old one is released
+	mockWarehouse.On("ReserveStock", order.ID.Hex(), []models.Allocation{{ProductID: cable, WarehouseID: "w1", Quantity: 2}}, 30*time.Minute).
+		Return([]models.StockReservation{{ID: "r4", WarehouseID: "w1", ProductID: cable, Quantity: 2}}, nil)
+	mockWarehouse.On("HoldReservation", "r4").Return(nil)
+	mockWarehouse.On("ReleaseReservation", "r1").Return(nil)
+	mockWarehouse.On("ReleaseReservation", "r2").Return(nil)
+
+	edited, err := service.CancelOrderItem(order.ID, cable, 3, "shop-1", "out of stock")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, edited.Items[0].Quantity)
+	assert.Equal(t, usd(50), edited.TotalAmount)
+	assert.Equal(t, []string{"r3", "r4"}, reservationIDs(edited.Reservations))
+	assert.Equal(t, []models.Allocation{
+		{ProductID: charger, WarehouseID: "w1", Quantity: 1},
+		{ProductID: cable, WarehouseID: "w1", Quantity: 2},
+	}, edited.Sourcing.Allocations)
+	assert.Equal(t, "out of stock", edited.Edits[0].Reason)
+
+	payment, err := paymentService.GetPayment(*order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, payment.Status)
+	assert.Equal(t, usd(30), payment.RefundedAmount)
+	mockWarehouse.AssertExpectations(t)
+}
+
+func TestOrderEditRules(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, new(MockWarehouseClient), new(MockProductCatalog), newSourcer(nil), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	cable, charger := primitive.NewObjectID(), primitive.NewObjectID()
+	lines := func() []models.OrderItem {
+		return []models.OrderItem{{ProductID: cable, Quantity: 2, Price: usd(10)}}
+	}
+	pending := editableOrder(t, paymentService, models.OrderStatusPending, lines(), nil)
+	paid := editableOrder(t, paymentService, models.OrderStatusPaid, lines(), nil)
+	processing := editableOrder(t, paymentService, models.OrderStatusPaid, lines(), nil)
+	processing.Status = models.OrderStatusProcessing
+	for _, order := range []*models.Order{pending, paid, processing} {
+		mockRepo.On("GetByID", order.ID).Return(order, nil)
+	}
+
+	_, err := service.CancelOrderItem(processing.ID, cable, 1, "shop-1", "")
+	assert.ErrorIs(t, err, models.ErrOrderNotEditable)
+	_, err = service.AddOrderItem(paid.ID, cable, 1, "customer-1")
+	assert.ErrorIs(t, err, models.ErrOrderNotEditable)
+	_, err = service.RemoveOrderItem(paid.ID, cable, "customer-1")
+	assert.ErrorIs(t, err, models.ErrOrderNotEditable)
+
+	_, err = service.RemoveOrderItem(pending.ID, cable, "customer-1")
+	assert.ErrorIs(t, err, models.ErrInvalidOrderEdit)
+	_, err = service.RemoveOrderItem(pending.ID, charger, "customer-1")
+	assert.ErrorIs(t, err, models.ErrInvalidOrderEdit)
+	_, err = service.CancelOrderItem(pending.ID, cable, 3, "customer-1", "")
+	assert.ErrorIs(t, err, models.ErrInvalidOrderEdit)
+	_, err = service.CancelOrderItem(pending.ID, cable, 2, "customer-1", "")
+	assert.ErrorIs(t, err, models.ErrInvalidOrderEdit)
+	_, err = service.AddOrderItem(pending.ID, cable, 0, "customer-1")
+	assert.ErrorIs(t, err, models.ErrInvalidOrderEdit)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func reservationIDs(reservations []models.StockReservation) []string {
+	ids := make([]string, len(reservations))
+	for i, reservation := range reservations {
+		ids[i] = reservation.ID
+	}
+	return ids
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/handlers"
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearchOrders(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	service := services.NewOrderService(mockRepo, nil, nil, nil, nil, nil, nil, nil, nil, 30*time.Minute)
+
+	shopID := primitive.NewObjectID()
+	page := &models.OrderPage{Orders: []models.Order{{ID: primitive.NewObjectID(), ShopID: shopID}}, TotalCount: 1}
+	mockRepo.On("List", models.OrderQuery{
+		Filter: models.OrderFilter{ShopID: &shopID, Search: "buyer@example.com"},
+		Sort:   models.OrderSortCreatedAtDesc,
+		Limit:  models.DefaultOrderPageSize,
+	}).Return(page, nil)
+
+	result, err := service.SearchOrders(models.OrderQuery{Filter: models.OrderFilter{ShopID: &shopID, Search: "  buyer@example.com "}})
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
+
+	_, err = service.SearchOrders(models.OrderQuery{Filter: models.OrderFilter{Search: strings.Repeat("a", models.MaxOrderSearchLength+1)}})
+	assert.ErrorIs(t, err, models.ErrInvalidOrderQuery)
+}
+
+func newExportRouter(mockRepo *MockOrderRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := handlers.NewOrderHandler(services.NewOrderService(mockRepo, nil, nil, nil, nil, nil, nil, nil, nil, 30*time.Minute))
+	router.GET("/orders/export", handler.ExportOrders)
+	return router
+}
+
+func exportedOrders() []models.Order {
+	paymentID := primitive.NewObjectID()
+	return []models.Order{
+		{
+			ID:          primitive.NewObjectID(),
+			UserID:      primitive.NewObjectID(),
+			ShopID:      primitive.NewObjectID(),
+			Customer:    &models.Customer{Email: "=HYPERLINK(\"x\")", Phone: "+62 812 555"},
+			Items:       []models.OrderItem{{Quantity: 2}, {Quantity: 1}},
+			Currency:    "USD",
+			Subtotal:    usd(30),
+			Discount:    usd(5),
+			Tax:         usd(2.5),
+			Shipping:    usd(0),
+			TotalAmount: usd(27.5),
+			Status:      models.OrderStatusPaid,
+			PaymentID:   &paymentID,
+			CreatedAt:   time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			ID:          primitive.NewObjectID(),
+			Currency:    "USD",
+			TotalAmount: usd(10),
+			Status:      models.OrderStatusPending,
+		},
+	}
+}
+
+func TestExportOrdersCSV(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	orders := exportedOrders()
+	mockRepo.On("Stream", models.OrderFilter{
+		Statuses: []models.OrderStatus{models.OrderStatusPaid, models.OrderStatusPending},
+		Search:   "example",
+	}, models.OrderSortCreatedAtAsc).Return(orders, nil)
+
+	w := httptest.NewRecorder()
+	newExportRouter(mockRepo).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?q=example&status=paid,pending&sort=created_at_asc", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, "order_id", rows[0][0])
+	assert.Equal(t, []string{
+		orders[0].ID.Hex(), "2026-10-01T09:30:00Z", "paid", orders[0].ShopID.Hex(), orders[0].UserID.Hex(),
+		"'=HYPERLINK(\"x\")", "'+62 812 555", "3", "USD", "30.00", "5.00", "2.50", "0.00", "27.50", orders[0].PaymentID.Hex(),
+	}, rows[1])
+	assert.Equal(t, "", w.Header().Get("X-Export-Error"))
+}
+
+func TestExportOrdersNDJSON(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	orders := exportedOrders()
+	mockRepo.On("Stream", mock.Anything, models.OrderSortCreatedAtDesc).Return(orders, nil)
+
+	w := httptest.NewRecorder()
+	newExportRouter(mockRepo).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?format=ndjson", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	var decoded models.Order
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &decoded))
+	assert.Equal(t, orders[1].ID, decoded.ID)
+}
+
+func TestExportOrdersErrors(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	router := newExportRouter(mockRepo)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?format=xml", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?sort=name", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	// A failure after the first order cuts the export short and is
+	// reported in the trailer
+	mockRepo.On("Stream", mock.Anything, mock.Anything).Return(exportedOrders()[:1], errors.New("cursor lost")).Once()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "cursor lost", w.Header().Get("X-Export-Error"))
+
+	// A failure before any order is an ordinary error response
+	mockRepo.On("Stream", mock.Anything, mock.Anything).Return(nil, errors.New("no connection")).Once()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "no connection")
+}
+
+func TestCustomerEmailIsStoredLowerCase(t *testing.T) {
+	principal := &models.Principal{Email: " Buyer@Example.COM ", Phone: "+62 812 555"}
+	assert.Equal(t, &models.Customer{Email: "buyer@example.com", Phone: "+62 812 555"}, principal.Customer())
+	assert.Nil(t, (&models.Principal{}).Customer())
+}
@@ -1,16 +1,27 @@
 package tests
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"ecommerce/order-service/models"
+	"ecommerce/order-service/payments"
+	"ecommerce/order-service/services"
+	"ecommerce/order-service/sourcing"
+	"ecommerce/pkg/money"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// usd is amount dollars, written in major units for readability.
+func usd(amount float64) money.Money {
+	return money.FromMajor(amount, "USD")
+}
+
 type MockOrderRepository struct {
 	mock.Mock
 }
@@ -28,14 +39,24 @@ func (m *MockOrderRepository) GetByID(id primitive.ObjectID) (*models.Order, err
 	return args.Get(0).(*models.Order), args.Error(1)
 }
 
-func (m *MockOrderRepository) GetByUserID(userID primitive.ObjectID) ([]models.Order, error) {
-	args := m.Called(userID)
-	return args.Get(0).([]models.Order), args.Error(1)
+func (m *MockOrderRepository) List(query models.OrderQuery) (*models.OrderPage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OrderPage), args.Error(1)
 }
 
-func (m *MockOrderRepository) GetByShopID(shopID primitive.ObjectID) ([]models.Order, error) {
-	args := m.Called(shopID)
-	return args.Get(0).([]models.Order), args.Error(1)
+// Stream hands fn the orders given to Return, then returns its error.
+func (m *MockOrderRepository) Stream(ctx context.Context, filter models.OrderFilter, sort models.OrderSort, fn func(*models.Order) error) error {
+	args := m.Called(filter, sort)
+	orders, _ := args.Get(0).([]models.Order)
+	for i := range orders {
+		if err := fn(&orders[i]); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
 }
 
 func (m *MockOrderRepository) Update(order *models.Order) error {
@@ -43,8 +64,18 @@ func (m *MockOrderRepository) Update(order *models.Order) error {
 	return args.Error(0)
 }
 
-func (m *MockOrderRepository) UpdateStatus(id primitive.ObjectID, status models.OrderStatus) error {
-	args := m.Called(id, status)
+func (m *MockOrderRepository) ClaimTransition(order *models.Order, to models.OrderStatus, actor string) error {
+	args := m.Called(order.ID, to)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) ReleaseTransition(order *models.Order) error {
+	args := m.Called(order.ID)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) UpdateStatus(id primitive.ObjectID, change models.StatusChange) error {
+	args := m.Called(id, change)
 	return args.Error(0)
 }
 
@@ -53,6 +84,111 @@ func (m *MockOrderRepository) Delete(id primitive.ObjectID) error {
 	return args.Error(0)
 }
 
+type MockWarehouseClient struct {
+	mock.Mock
+}
+
+func (m *MockWarehouseClient) ReserveStock(reference string, allocations []models.Allocation, ttl time.Duration) ([]models.StockReservation, error) {
+	args := m.Called(reference, allocations, ttl)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.StockReservation), args.Error(1)
+}
+
+func (m *MockWarehouseClient) GetAvailableStock(warehouseID string, productID primitive.ObjectID) (int, error) {
+	args := m.Called(warehouseID, productID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWarehouseClient) HoldReservation(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseClient) ConfirmReservation(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseClient) ReleaseReservation(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseClient) RestockItem(warehouseID string, productID primitive.ObjectID, quantity int) error {
+	args := m.Called(warehouseID, productID, quantity)
+	return args.Error(0)
+}
+
+// staticShopDirectory serves every shop with the same warehouses.
+type staticShopDirectory struct {
+	warehouses []string
+	strategy   models.SourcingStrategy
+}
+
+func (d *staticShopDirectory) GetShop(id primitive.ObjectID) (*models.ShopInfo, error) {
+	return &models.ShopInfo{ID: id, Warehouses: d.warehouses, SourcingStrategy: d.strategy}, nil
+}
+
+func newSourcer(warehouseClient models.WarehouseClient, warehouses ...string) models.Sourcer {
+	return services.NewSourcingService(&staticShopDirectory{warehouses: warehouses}, warehouseClient, models.SourcingPriority)
+}
+
+type MockProductCatalog struct {
+	mock.Mock
+}
+
+func (m *MockProductCatalog) GetProduct(id primitive.ObjectID) (*models.ProductSnapshot, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ProductSnapshot), args.Error(1)
+}
+
+// memoryPaymentRepository keeps payments in a map so payment flows can run
+// end to end against the fake provider.
+type memoryPaymentRepository struct {
+	payments map[primitive.ObjectID]models.Payment
+}
+
+func newMemoryPaymentRepository() *memoryPaymentRepository {
+	return &memoryPaymentRepository{payments: make(map[primitive.ObjectID]models.Payment)}
+}
+
+func (r *memoryPaymentRepository) Create(payment *models.Payment) error {
+	payment.ID = primitive.NewObjectID()
+	r.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *memoryPaymentRepository) GetByID(id primitive.ObjectID) (*models.Payment, error) {
+	payment, ok := r.payments[id]
+	if !ok {
+		return nil, models.ErrPaymentNotFound
+	}
+	return &payment, nil
+}
+
+func (r *memoryPaymentRepository) GetByOrderID(orderID primitive.ObjectID) (*models.Payment, error) {
+	for _, payment := range r.payments {
+		if payment.OrderID == orderID {
+			return &payment, nil
+		}
+	}
+	return nil, models.ErrPaymentNotFound
+}
+
+func (r *memoryPaymentRepository) Update(payment *models.Payment) error {
+	r.payments[payment.ID] = *payment
+	return nil
+}
+
+func newFakePaymentService() models.PaymentService {
+	return services.NewPaymentService(newMemoryPaymentRepository(), payments.NewFakeProvider(1000))
+}
+
 func TestCreateOrder(t *testing.T) {
 	mockRepo := new(MockOrderRepository)
 	order := &models.Order{
@@ -62,7 +198,7 @@ func TestCreateOrder(t *testing.T) {
 			{
 				ProductID: primitive.NewObjectID(),
 				Quantity:  2,
-				Price:     100.0,
+				Price:     usd(100),
 			},
 		},
 		Status:    models.OrderStatusPending,
@@ -84,7 +220,7 @@ func TestGetOrder(t *testing.T) {
 		UserID:      primitive.NewObjectID(),
 		ShopID:      primitive.NewObjectID(),
 		Status:      models.OrderStatusPending,
-		TotalAmount: 200.0,
+		TotalAmount: usd(200),
 	}
 
 	mockRepo.On("GetByID", id).Return(expectedOrder, nil)
@@ -103,32 +239,906 @@ func TestGetUserOrders(t *testing.T) {
 			ID:          primitive.NewObjectID(),
 			UserID:      userID,
 			Status:      models.OrderStatusPending,
-			TotalAmount: 100.0,
+			TotalAmount: usd(100),
 		},
 		{
 			ID:          primitive.NewObjectID(),
 			UserID:      userID,
 			Status:      models.OrderStatusCompleted,
-			TotalAmount: 200.0,
+			TotalAmount: usd(200),
 		},
 	}
 
-	mockRepo.On("GetByUserID", userID).Return(expectedOrders, nil)
+	service := services.NewOrderService(mockRepo, nil, nil, nil, nil, nil, nil, nil, nil, 30*time.Minute)
+	expectedPage := &models.OrderPage{Orders: expectedOrders, TotalCount: 2}
+
+	// Defaults are filled in before the repository is queried
+	mockRepo.On("List", models.OrderQuery{
+		Filter: models.OrderFilter{UserID: &userID, Statuses: []models.OrderStatus{models.OrderStatusPending, models.OrderStatusCompleted}},
+		Sort:   models.OrderSortCreatedAtDesc,
+		Limit:  models.DefaultOrderPageSize,
+	}).Return(expectedPage, nil)
 
-	orders, err := mockRepo.GetByUserID(userID)
+	page, err := service.GetUserOrders(userID, models.OrderQuery{
+		Filter: models.OrderFilter{Statuses: []models.OrderStatus{models.OrderStatusPending, models.OrderStatusCompleted}},
+	})
 	assert.NoError(t, err)
-	assert.Equal(t, expectedOrders, orders)
+	assert.Equal(t, expectedPage, page)
 	mockRepo.AssertExpectations(t)
+
+	_, err = service.GetUserOrders(userID, models.OrderQuery{Filter: models.OrderFilter{Statuses: []models.OrderStatus{"lost"}}})
+	assert.ErrorIs(t, err, models.ErrInvalidOrderQuery)
+
+	minAmount, maxAmount := usd(100), usd(50)
+	_, err = service.GetUserOrders(userID, models.OrderQuery{Filter: models.OrderFilter{MinAmount: &minAmount, MaxAmount: &maxAmount}})
+	assert.ErrorIs(t, err, models.ErrInvalidOrderQuery)
+
+	maxAmount = money.New(15000, "EUR")
+	_, err = service.GetUserOrders(userID, models.OrderQuery{Filter: models.OrderFilter{MinAmount: &minAmount, MaxAmount: &maxAmount}})
+	assert.ErrorIs(t, err, models.ErrInvalidOrderQuery)
 }
 
 func TestUpdateOrderStatus(t *testing.T) {
 	mockRepo := new(MockOrderRepository)
 	id := primitive.NewObjectID()
-	newStatus := models.OrderStatusProcessing
+	change := models.StatusChange{
+		From:  models.OrderStatusPending,
+		To:    models.OrderStatusProcessing,
+		Actor: "user",
+		At:    time.Now(),
+	}
 
-	mockRepo.On("UpdateStatus", id, newStatus).Return(nil)
+	mockRepo.On("UpdateStatus", id, change).Return(nil)
 
-	err := mockRepo.UpdateStatus(id, newStatus)
+	err := mockRepo.UpdateStatus(id, change)
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestCreateOrderReservesStock(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		UserID: primitive.NewObjectID(),
+		ShopID: primitive.NewObjectID(),
+		Items:  []models.OrderItem{{ProductID: productID, Quantity: 2, Price: usd(0.01)}},
+	}
+	reservations := []models.StockReservation{{ID: "r1", WarehouseID: "w1", ProductID: productID, Quantity: 2}}
+
+	mockCatalog.On("GetProduct", productID).Return(&models.ProductSnapshot{
+		ID: productID, Name: "Keyboard", Price: usd(50),
+	}, nil)
+
+	allocations := []models.Allocation{{ProductID: productID, Quantity: 2}}
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), allocations, 30*time.Minute).Return(reservations, nil)
+	mockRepo.On("Create", order).Return(nil)
+
+	assert.NoError(t, service.CreateOrder(order))
+	assert.Equal(t, reservations, order.Reservations)
+	assert.Equal(t, usd(100), order.TotalAmount)
+	assert.Equal(t, "Keyboard", order.Items[0].Name)
+	assert.Equal(t, usd(50), order.Items[0].Price)
+	assert.Equal(t, "USD", order.Currency)
+	assert.NotNil(t, order.PaymentID)
+	mockWarehouse.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateOrderInsufficientStock(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		UserID: primitive.NewObjectID(),
+		ShopID: primitive.NewObjectID(),
+		Items:  []models.OrderItem{{ProductID: productID, Quantity: 5}},
+	}
+	mockCatalog.On("GetProduct", productID).Return(&models.ProductSnapshot{
+		ID: productID, Name: "Mouse", Price: usd(10),
+	}, nil)
+	shortage := &models.InsufficientStockError{Shortages: []models.StockShortage{
+		{ProductID: productID.Hex(), Requested: 5, Available: 1},
+	}}
+
+	allocations := []models.Allocation{{ProductID: productID, Quantity: 5}}
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), allocations, 30*time.Minute).Return(nil, shortage)
+
+	err := service.CreateOrder(order)
+	assert.ErrorIs(t, err, shortage)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestCreateOrderUnknownProduct(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	knownID := primitive.NewObjectID()
+	missingID := primitive.NewObjectID()
+	order := &models.Order{
+		UserID: primitive.NewObjectID(),
+		ShopID: primitive.NewObjectID(),
+		Items: []models.OrderItem{
+			{ProductID: knownID, Quantity: 1},
+			{ProductID: missingID, Quantity: 1},
+		},
+	}
+	mockCatalog.On("GetProduct", knownID).Return(&models.ProductSnapshot{ID: knownID, Price: usd(10)}, nil)
+	mockCatalog.On("GetProduct", missingID).Return(nil, models.ErrProductNotFound)
+
+	err := service.CreateOrder(order)
+	var productErr *models.ProductUnavailableError
+	assert.ErrorAs(t, err, &productErr)
+	assert.Equal(t, []string{missingID.Hex()}, productErr.ProductIDs)
+	mockWarehouse.AssertNotCalled(t, "ReserveStock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelOrderReleasesReservations(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	id := primitive.NewObjectID()
+	order := &models.Order{
+		ID:           id,
+		Status:       models.OrderStatusPending,
+		Reservations: []models.StockReservation{{ID: "r1"}, {ID: "r2"}},
+	}
+
+	mockRepo.On("GetByID", id).Return(order, nil)
+	mockWarehouse.On("ReleaseReservation", "r1").Return(nil)
+	mockWarehouse.On("ReleaseReservation", "r2").Return(nil)
+	mockRepo.On("ClaimTransition", id, models.OrderStatusCancelled).Return(nil)
+	mockRepo.On("UpdateStatus", id, mock.MatchedBy(func(change models.StatusChange) bool {
+		return change.From == models.OrderStatusPending &&
+			change.To == models.OrderStatusCancelled &&
+			change.Actor == "user-1" &&
+			change.Reason == "changed my mind"
+	})).Return(nil)
+
+	assert.NoError(t, service.CancelOrder(id, "user-1", "changed my mind"))
+	mockWarehouse.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCancelCompletedOrderRejected(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	id := primitive.NewObjectID()
+	mockRepo.On("GetByID", id).Return(&models.Order{ID: id, Status: models.OrderStatusCompleted}, nil)
+
+	err := service.CancelOrder(id, "user-1", "")
+	var transitionErr *models.InvalidTransitionError
+	assert.ErrorAs(t, err, &transitionErr)
+	mockWarehouse.AssertNotCalled(t, "ReleaseReservation", mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything)
+}
+
+func TestPayOrderHoldsReservations(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	order := &models.Order{
+		ID:           primitive.NewObjectID(),
+		TotalAmount:  usd(20),
+		Currency:     "USD",
+		Status:       models.OrderStatusPending,
+		Reservations: []models.StockReservation{{ID: "r1"}, {ID: "r2"}},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("ClaimTransition", order.ID, mock.Anything).Return(nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.AnythingOfType("models.StatusChange")).Return(nil)
+	mockWarehouse.On("HoldReservation", "r1").Return(nil)
+	mockWarehouse.On("HoldReservation", "r2").Return(nil)
+
+	assert.NoError(t, service.PayOrder(order.ID, "user-1"))
+	assert.Equal(t, models.OrderStatusPaid, order.Status)
+	mockWarehouse.AssertExpectations(t)
+
+	// Processing a paid order leaves the held reservations alone
+	assert.NoError(t, service.ProcessOrder(order.ID, "shop-1"))
+	mockWarehouse.AssertNumberOfCalls(t, "HoldReservation", 2)
+}
+
+func TestOrderStateMachine(t *testing.T) {
+	assert.True(t, models.OrderStatusPending.CanTransitionTo(models.OrderStatusPaid))
+	assert.True(t, models.OrderStatusPaid.CanTransitionTo(models.OrderStatusProcessing))
+	assert.True(t, models.OrderStatusShipped.CanTransitionTo(models.OrderStatusDelivered))
+	assert.True(t, models.OrderStatusCompleted.CanTransitionTo(models.OrderStatusRefunded))
+	assert.False(t, models.OrderStatusShipped.CanTransitionTo(models.OrderStatusCancelled))
+	assert.False(t, models.OrderStatusCompleted.CanTransitionTo(models.OrderStatusCancelled))
+	assert.True(t, models.OrderStatusCancelled.IsTerminal())
+	assert.True(t, models.OrderStatusRefunded.IsTerminal())
+}
+
+func TestPaymentLifecycle(t *testing.T) {
+	paymentService := newFakePaymentService()
+	order := &models.Order{ID: primitive.NewObjectID(), TotalAmount: usd(120), Currency: "USD"}
+
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusAuthorized, payment.Status)
+
+	payment, err = paymentService.Capture(payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusCaptured, payment.Status)
+
+	_, err = paymentService.Void(payment.ID)
+	assert.ErrorIs(t, err, models.ErrInvalidPaymentState)
+
+	payment, err = paymentService.Refund(payment.ID, usd(20))
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, payment.Status)
+
+	_, err = paymentService.Refund(payment.ID, usd(200))
+	assert.ErrorIs(t, err, models.ErrRefundExceedsAmount)
+	_, err = paymentService.Refund(payment.ID, money.New(500, "EUR"))
+	assert.ErrorIs(t, err, models.ErrInvalidRefund)
+
+	// An amount without a currency is in the payment's
+	payment, err = paymentService.Refund(payment.ID, money.Money{Amount: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, usd(30), payment.RefundedAmount)
+
+	payment, err = paymentService.Refund(payment.ID, money.Money{})
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusRefunded, payment.Status)
+	assert.Equal(t, usd(120), payment.RefundedAmount)
+	assert.Len(t, payment.Attempts, 5)
+}
+
+func TestPaymentDeclined(t *testing.T) {
+	paymentService := newFakePaymentService()
+	order := &models.Order{ID: primitive.NewObjectID(), TotalAmount: usd(5000), Currency: "USD"}
+
+	payment, err := paymentService.Authorize(order)
+	assert.ErrorIs(t, err, models.ErrPaymentDeclined)
+	assert.Equal(t, models.PaymentStatusFailed, payment.Status)
+	assert.False(t, payment.Attempts[0].Success)
+}
+
+// unavailableProvider fails every authorization the way an unreachable
+// provider would.
+type unavailableProvider struct {
+	*payments.FakeProvider
+}
+
+func (unavailableProvider) Authorize(string, money.Money) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func TestPaymentProviderUnavailable(t *testing.T) {
+	paymentService := services.NewPaymentService(newMemoryPaymentRepository(), unavailableProvider{payments.NewFakeProvider(1000)})
+	order := &models.Order{ID: primitive.NewObjectID(), TotalAmount: usd(50), Currency: "USD"}
+
+	payment, err := paymentService.Authorize(order)
+	assert.ErrorIs(t, err, models.ErrPaymentUnavailable)
+	assert.NotErrorIs(t, err, models.ErrPaymentDeclined)
+	assert.Equal(t, models.PaymentStatusFailed, payment.Status)
+}
+
+func TestProcessOrderConflict(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	service := services.NewOrderService(mockRepo, new(MockWarehouseClient), new(MockProductCatalog), newSourcer(nil), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	id := primitive.NewObjectID()
+	mockRepo.On("GetByID", id).Return(&models.Order{ID: id, Status: models.OrderStatusPending, Version: 3}, nil)
+	mockRepo.On("ClaimTransition", id, models.OrderStatusProcessing).Return(&models.OrderConflictError{OrderID: id})
+
+	err := service.ProcessOrder(id, "shop-1")
+	var conflictErr *models.OrderConflictError
+	assert.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, id, conflictErr.OrderID)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything)
+}
+
+func TestCancelOrderClaimedByAnotherTransition(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	order := &models.Order{
+		ID:           primitive.NewObjectID(),
+		TotalAmount:  usd(20),
+		Currency:     "USD",
+		Status:       models.OrderStatusProcessing,
+		Reservations: []models.StockReservation{{ID: "r1"}},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	// The order is being completed, so cancelling it must not release its
+	// stock or void its payment
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("ClaimTransition", order.ID, models.OrderStatusCancelled).Return(&models.OrderConflictError{OrderID: order.ID})
+
+	var conflictErr *models.OrderConflictError
+	assert.ErrorAs(t, service.CancelOrder(order.ID, "user-1", ""), &conflictErr)
+	mockWarehouse.AssertNotCalled(t, "ReleaseReservation", mock.Anything)
+	payment, err = paymentService.GetPayment(payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusAuthorized, payment.Status)
+}
+
+func TestCancelOrderRestocksConfirmedReservations(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	// A completion captured the payment and confirmed r1 before failing
+	// to confirm r2
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		ID:          primitive.NewObjectID(),
+		TotalAmount: usd(20),
+		Currency:    "USD",
+		Status:      models.OrderStatusProcessing,
+		Reservations: []models.StockReservation{
+			{ID: "r1", WarehouseID: "wh-1", ProductID: productID, Quantity: 2, Confirmed: true},
+			{ID: "r2", WarehouseID: "wh-2", ProductID: productID, Quantity: 1},
+		},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	_, err = paymentService.Capture(payment.ID)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("ClaimTransition", order.ID, models.OrderStatusCancelled).Return(nil)
+	mockRepo.On("ReleaseTransition", order.ID).Return(nil)
+	mockRepo.On("Update", order).Return(nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.Anything).Return(nil)
+	mockWarehouse.On("RestockItem", "wh-1", productID, 2).Return(nil).Once()
+	mockWarehouse.On("ReleaseReservation", "r2").Return(errors.New("warehouse unavailable")).Once()
+	mockWarehouse.On("ReleaseReservation", "r2").Return(nil)
+
+	// A retry does not restock r1 again
+	assert.Error(t, service.CancelOrder(order.ID, "user-1", ""))
+	assert.True(t, order.Reservations[0].Restocked)
+	assert.NoError(t, service.CancelOrder(order.ID, "user-1", ""))
+
+	mockWarehouse.AssertExpectations(t)
+	mockWarehouse.AssertNotCalled(t, "ReleaseReservation", "r1")
+	payment, err = paymentService.GetPayment(payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusRefunded, payment.Status)
+}
+
+func TestShipOrderReleasesClaimWhenConfirmFails(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	service := services.NewOrderService(mockRepo, mockWarehouse, new(MockProductCatalog), newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	order := &models.Order{
+		ID:           primitive.NewObjectID(),
+		Status:       models.OrderStatusProcessing,
+		Reservations: []models.StockReservation{{ID: "r1"}},
+	}
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("ClaimTransition", order.ID, models.OrderStatusShipped).Return(nil)
+	mockRepo.On("ReleaseTransition", order.ID).Return(nil)
+	mockWarehouse.On("ConfirmReservation", "r1").Return(errors.New("warehouse unavailable"))
+
+	assert.Error(t, service.ShipOrder(order.ID, "shop-1"))
+	assert.Equal(t, models.OrderStatusProcessing, order.Status)
+	mockRepo.AssertCalled(t, "ReleaseTransition", order.ID)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything)
+}
+
+type memoryReturnRepository struct {
+	returns map[primitive.ObjectID]models.Return
+}
+
+func newMemoryReturnRepository() *memoryReturnRepository {
+	return &memoryReturnRepository{returns: make(map[primitive.ObjectID]models.Return)}
+}
+
+func (r *memoryReturnRepository) Create(orderReturn *models.Return) error {
+	orderReturn.ID = primitive.NewObjectID()
+	r.returns[orderReturn.ID] = *orderReturn
+	return nil
+}
+
+func (r *memoryReturnRepository) GetByID(id primitive.ObjectID) (*models.Return, error) {
+	orderReturn, ok := r.returns[id]
+	if !ok {
+		return nil, models.ErrReturnNotFound
+	}
+	return &orderReturn, nil
+}
+
+func (r *memoryReturnRepository) GetByOrderID(orderID primitive.ObjectID) ([]models.Return, error) {
+	var returns []models.Return
+	for _, orderReturn := range r.returns {
+		if orderReturn.OrderID == orderID {
+			returns = append(returns, orderReturn)
+		}
+	}
+	return returns, nil
+}
+
+func (r *memoryReturnRepository) Update(orderReturn *models.Return, from models.ReturnStatus) error {
+	stored, ok := r.returns[orderReturn.ID]
+	if !ok {
+		return models.ErrReturnNotFound
+	}
+	if stored.Status != from {
+		return models.ErrReturnConflict
+	}
+	r.returns[orderReturn.ID] = *orderReturn
+	return nil
+}
+
+func TestReturnApprovalRestocksAndRefunds(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	warehouseClient := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	orderService := services.NewOrderService(mockRepo, warehouseClient, new(MockProductCatalog), newSourcer(warehouseClient), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+	returnService := services.NewReturnService(newMemoryReturnRepository(), orderService, warehouseClient)
+
+	userID := primitive.NewObjectID()
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		Items:       []models.OrderItem{{ProductID: productID, Quantity: 2, Price: usd(50)}},
+		TotalAmount: usd(100),
+		Currency:    "USD",
+		Status:      models.OrderStatusCompleted,
+		Reservations: []models.StockReservation{
+			{ID: "res-1", WarehouseID: "wh-1", ProductID: productID, Quantity: 2},
+		},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	_, err = paymentService.Capture(payment.ID)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	warehouseClient.On("RestockItem", "wh-1", productID, 1).Return(nil)
+
+	_, err = returnService.OpenReturn(order.ID, primitive.NewObjectID(), []models.ReturnItem{{ProductID: productID, Quantity: 1}}, "damaged")
+	assert.ErrorIs(t, err, models.ErrOrderNotOwned)
+
+	orderReturn, err := returnService.OpenReturn(order.ID, userID, []models.ReturnItem{{ProductID: productID, Quantity: 1}}, "damaged")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReturnStatusRequested, orderReturn.Status)
+
+	// Only one unit is left to return
+	_, err = returnService.OpenReturn(order.ID, userID, []models.ReturnItem{{ProductID: productID, Quantity: 2}}, "damaged")
+	assert.ErrorIs(t, err, models.ErrInvalidReturn)
+
+	_, err = returnService.ApproveReturn(order.ID, orderReturn.ID, usd(80), "shop-1")
+	assert.ErrorIs(t, err, models.ErrRefundTooLarge)
+
+	orderReturn, err = returnService.ApproveReturn(order.ID, orderReturn.ID, money.Money{}, "shop-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReturnStatusRefunded, orderReturn.Status)
+	assert.Equal(t, usd(50), orderReturn.RefundAmount)
+	assert.True(t, orderReturn.Items[0].Restocked)
+	warehouseClient.AssertExpectations(t)
+
+	payment, err = paymentService.GetPayment(payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, payment.Status)
+	assert.Equal(t, usd(50), payment.RefundedAmount)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything)
+
+	_, err = returnService.RejectReturn(order.ID, orderReturn.ID, "shop-1", "too late")
+	var transitionErr *models.InvalidReturnTransitionError
+	assert.ErrorAs(t, err, &transitionErr)
+}
+
+func TestReturnsSplitLineTotalExactly(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	warehouseClient := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	orderService := services.NewOrderService(mockRepo, warehouseClient, new(MockProductCatalog), newSourcer(warehouseClient), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+	returnService := services.NewReturnService(newMemoryReturnRepository(), orderService, warehouseClient)
+
+	// Three units for 10.00 in all cannot cost the same each
+	userID := primitive.NewObjectID()
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		ID:     primitive.NewObjectID(),
+		UserID: userID,
+		Items: []models.OrderItem{{
+			ProductID:   productID,
+			Quantity:    3,
+			Price:       usd(5),
+			Adjustments: []models.Adjustment{{Code: "SAVE5", Amount: usd(5)}},
+		}},
+		TotalAmount: usd(10),
+		Currency:    "USD",
+		Status:      models.OrderStatusCompleted,
+		Reservations: []models.StockReservation{
+			{ID: "res-1", WarehouseID: "wh-1", ProductID: productID, Quantity: 3},
+		},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	_, err = paymentService.Capture(payment.ID)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.MatchedBy(func(change models.StatusChange) bool {
+		return change.To == models.OrderStatusRefunded
+	})).Return(nil)
+	warehouseClient.On("RestockItem", "wh-1", productID, mock.Anything).Return(nil)
+
+	first, err := returnService.OpenReturn(order.ID, userID, []models.ReturnItem{{ProductID: productID, Quantity: 1}}, "damaged")
+	assert.NoError(t, err)
+	second, err := returnService.OpenReturn(order.ID, userID, []models.ReturnItem{{ProductID: productID, Quantity: 2}}, "damaged")
+	assert.NoError(t, err)
+	assert.Equal(t, usd(10), first.ItemsValue().Add(second.ItemsValue()))
+
+	for _, orderReturn := range []*models.Return{first, second} {
+		_, err := returnService.ApproveReturn(order.ID, orderReturn.ID, money.Money{}, "shop-1")
+		assert.NoError(t, err)
+	}
+
+	payment, err = paymentService.GetPayment(payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusRefunded, payment.Status)
+	assert.Equal(t, usd(10), payment.RefundedAmount)
+}
+
+// failingReturnRepository fails the next update that would mark a return
+// refunded.
+type failingReturnRepository struct {
+	*memoryReturnRepository
+	failRefunded bool
+}
+
+func (r *failingReturnRepository) Update(orderReturn *models.Return, from models.ReturnStatus) error {
+	if r.failRefunded && orderReturn.Status == models.ReturnStatusRefunded {
+		r.failRefunded = false
+		return errors.New("connection reset")
+	}
+	return r.memoryReturnRepository.Update(orderReturn, from)
+}
+
+// newReturnFixture places a completed, captured order of four units of one
+// product reserved as two in wh-1 and two in wh-2.
+func newReturnFixture(t *testing.T, returnRepo models.ReturnRepository) (models.ReturnService, models.PaymentService, *MockWarehouseClient, *models.Order) {
+	mockRepo := new(MockOrderRepository)
+	warehouseClient := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	orderService := services.NewOrderService(mockRepo, warehouseClient, new(MockProductCatalog), newSourcer(warehouseClient), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		ID:          primitive.NewObjectID(),
+		UserID:      primitive.NewObjectID(),
+		Items:       []models.OrderItem{{ProductID: productID, Quantity: 4, Price: usd(25)}},
+		TotalAmount: usd(100),
+		Currency:    "USD",
+		Status:      models.OrderStatusCompleted,
+		Reservations: []models.StockReservation{
+			{ID: "res-1", WarehouseID: "wh-1", ProductID: productID, Quantity: 2},
+			{ID: "res-2", WarehouseID: "wh-2", ProductID: productID, Quantity: 2},
+		},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	_, err = paymentService.Capture(payment.ID)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.Anything).Return(nil)
+
+	return services.NewReturnService(returnRepo, orderService, warehouseClient), paymentService, warehouseClient, order
+}
+
+func TestReturnRetryDoesNotRefundTwice(t *testing.T) {
+	returnRepo := &failingReturnRepository{memoryReturnRepository: newMemoryReturnRepository(), failRefunded: true}
+	returnService, paymentService, warehouseClient, order := newReturnFixture(t, returnRepo)
+	productID := order.Items[0].ProductID
+	warehouseClient.On("RestockItem", "wh-1", productID, 1).Return(nil)
+
+	orderReturn, err := returnService.OpenReturn(order.ID, order.UserID, []models.ReturnItem{{ProductID: productID, Quantity: 1}}, "damaged")
+	assert.NoError(t, err)
+
+	// The refund goes through but the return cannot be marked refunded
+	_, err = returnService.ApproveReturn(order.ID, orderReturn.ID, money.Money{}, "shop-1")
+	assert.Error(t, err)
+	stored, _ := returnRepo.GetByID(orderReturn.ID)
+	assert.Equal(t, models.ReturnStatusApproved, stored.Status)
+	assert.NotNil(t, stored.RefundedAt)
+
+	orderReturn, err = returnService.ApproveReturn(order.ID, orderReturn.ID, money.Money{}, "shop-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReturnStatusRefunded, orderReturn.Status)
+
+	payment, err := paymentService.GetPayment(*order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, usd(25), payment.RefundedAmount)
+	warehouseClient.AssertNumberOfCalls(t, "RestockItem", 1)
+}
+
+func TestReturnsRestockEachReservationOnce(t *testing.T) {
+	returnService, _, warehouseClient, order := newReturnFixture(t, newMemoryReturnRepository())
+	productID := order.Items[0].ProductID
+
+	first, err := returnService.OpenReturn(order.ID, order.UserID, []models.ReturnItem{{ProductID: productID, Quantity: 1}}, "damaged")
+	assert.NoError(t, err)
+	warehouseClient.On("RestockItem", "wh-1", productID, 1).Return(nil).Once()
+	_, err = returnService.ApproveReturn(order.ID, first.ID, money.Money{}, "shop-1")
+	assert.NoError(t, err)
+
+	// The second return spans both warehouses, and wh-2 is down at first
+	second, err := returnService.OpenReturn(order.ID, order.UserID, []models.ReturnItem{{ProductID: productID, Quantity: 3}}, "damaged")
+	assert.NoError(t, err)
+	warehouseClient.On("RestockItem", "wh-1", productID, 1).Return(nil).Once()
+	warehouseClient.On("RestockItem", "wh-2", productID, 2).Return(errors.New("warehouse unavailable")).Once()
+	_, err = returnService.ApproveReturn(order.ID, second.ID, money.Money{}, "shop-1")
+	assert.Error(t, err)
+
+	warehouseClient.On("RestockItem", "wh-2", productID, 2).Return(nil).Once()
+	second, err = returnService.ApproveReturn(order.ID, second.ID, money.Money{}, "shop-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ReturnRestock{
+		{ReservationID: "res-1", WarehouseID: "wh-1", Quantity: 1},
+		{ReservationID: "res-2", WarehouseID: "wh-2", Quantity: 2},
+	}, second.Items[0].Restocks)
+	warehouseClient.AssertExpectations(t)
+	warehouseClient.AssertNumberOfCalls(t, "RestockItem", 4)
+}
+
+type memoryShipmentRepository struct {
+	shipments map[primitive.ObjectID]models.Shipment
+}
+
+func newMemoryShipmentRepository() *memoryShipmentRepository {
+	return &memoryShipmentRepository{shipments: make(map[primitive.ObjectID]models.Shipment)}
+}
+
+func (r *memoryShipmentRepository) Create(shipment *models.Shipment) error {
+	for _, stored := range r.shipments {
+		if stored.OrderID == shipment.OrderID && stored.Sequence == shipment.Sequence {
+			return models.ErrShipmentConflict
+		}
+	}
+	shipment.ID = primitive.NewObjectID()
+	r.shipments[shipment.ID] = *shipment
+	return nil
+}
+
+func (r *memoryShipmentRepository) GetByID(id primitive.ObjectID) (*models.Shipment, error) {
+	shipment, ok := r.shipments[id]
+	if !ok {
+		return nil, models.ErrShipmentNotFound
+	}
+	return &shipment, nil
+}
+
+func (r *memoryShipmentRepository) GetByOrderID(orderID primitive.ObjectID) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	for _, shipment := range r.shipments {
+		if shipment.OrderID == orderID {
+			shipments = append(shipments, shipment)
+		}
+	}
+	return shipments, nil
+}
+
+func (r *memoryShipmentRepository) Update(shipment *models.Shipment, from models.ShipmentStatus) error {
+	stored, ok := r.shipments[shipment.ID]
+	if !ok {
+		return models.ErrShipmentNotFound
+	}
+	if stored.Status != from {
+		return models.ErrShipmentConflict
+	}
+	r.shipments[shipment.ID] = *shipment
+	return nil
+}
+
+func TestShipmentsCompleteOrderWhenDelivered(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	warehouseClient := new(MockWarehouseClient)
+	paymentService := newFakePaymentService()
+	orderService := services.NewOrderService(mockRepo, warehouseClient, new(MockProductCatalog), newSourcer(warehouseClient), newExchangeService(), newPromotionService(), newTaxService(), paymentService, newInvoiceService(), 30*time.Minute)
+	shipmentService := services.NewShipmentService(newMemoryShipmentRepository(), orderService)
+
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		ID:          primitive.NewObjectID(),
+		Items:       []models.OrderItem{{ProductID: productID, Quantity: 2, Price: usd(50)}},
+		TotalAmount: usd(100),
+		Currency:    "USD",
+		Status:      models.OrderStatusProcessing,
+		Reservations: []models.StockReservation{
+			{ID: "res-1", WarehouseID: "wh-1", ProductID: productID, Quantity: 2},
+		},
+	}
+	payment, err := paymentService.Authorize(order)
+	assert.NoError(t, err)
+	order.PaymentID = &payment.ID
+
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("Update", order).Return(nil)
+	mockRepo.On("ClaimTransition", order.ID, mock.Anything).Return(nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.AnythingOfType("models.StatusChange")).Return(nil)
+	warehouseClient.On("ConfirmReservation", "res-1").Return(nil).Once()
+
+	newShipment := func(warehouseID string) *models.Shipment {
+		return &models.Shipment{
+			WarehouseID:    warehouseID,
+			Carrier:        "DHL",
+			TrackingNumber: "TRACK-" + warehouseID,
+			Items:          []models.ShipmentItem{{ProductID: productID, Quantity: 1}},
+		}
+	}
+
+	first, second := newShipment("wh-1"), newShipment("wh-1")
+	assert.NoError(t, shipmentService.CreateShipment(order.ID, first))
+	assert.NoError(t, shipmentService.CreateShipment(order.ID, second))
+	assert.ErrorIs(t, shipmentService.CreateShipment(order.ID, newShipment("wh-1")), models.ErrInvalidShipment)
+	assert.ErrorIs(t, shipmentService.CreateShipment(order.ID, newShipment("wh-2")), models.ErrInvalidShipment)
+
+	_, err = shipmentService.MarkDelivered(order.ID, first.ID, "shop-1")
+	var transitionErr *models.InvalidShipmentTransitionError
+	assert.ErrorAs(t, err, &transitionErr)
+
+	shipment, err := shipmentService.MarkShipped(order.ID, first.ID, "shop-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, shipment.ShippedAt)
+	assert.Equal(t, models.OrderStatusShipped, order.Status)
+	assert.True(t, order.Reservations[0].Confirmed)
+
+	_, err = shipmentService.MarkDelivered(order.ID, first.ID, "shop-1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusShipped, order.Status)
+
+	_, err = shipmentService.MarkShipped(order.ID, second.ID, "shop-1")
+	assert.NoError(t, err)
+	shipment, err = shipmentService.MarkDelivered(order.ID, second.ID, "shop-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, shipment.DeliveredAt)
+	assert.Equal(t, models.OrderStatusCompleted, order.Status)
+	warehouseClient.AssertExpectations(t)
+
+	payment, err = paymentService.GetPayment(payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusCaptured, payment.Status)
+}
+
+// staleShipmentRepository lists an order's shipments as they were before
+// any was created, like two requests reading them at the same time.
+type staleShipmentRepository struct {
+	*memoryShipmentRepository
+}
+
+func (r staleShipmentRepository) GetByOrderID(orderID primitive.ObjectID) ([]models.Shipment, error) {
+	return nil, nil
+}
+
+func TestConcurrentShipmentsCannotExceedOrder(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	warehouseClient := new(MockWarehouseClient)
+	orderService := services.NewOrderService(mockRepo, warehouseClient, new(MockProductCatalog), newSourcer(warehouseClient), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+	shipmentRepo := newMemoryShipmentRepository()
+	shipmentService := services.NewShipmentService(staleShipmentRepository{shipmentRepo}, orderService)
+
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		ID:     primitive.NewObjectID(),
+		Items:  []models.OrderItem{{ProductID: productID, Quantity: 1, Price: usd(50)}},
+		Status: models.OrderStatusProcessing,
+	}
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+
+	newShipment := func() *models.Shipment {
+		return &models.Shipment{WarehouseID: "wh-1", Items: []models.ShipmentItem{{ProductID: productID, Quantity: 1}}}
+	}
+	assert.NoError(t, shipmentService.CreateShipment(order.ID, newShipment()))
+	assert.ErrorIs(t, shipmentService.CreateShipment(order.ID, newShipment()), models.ErrShipmentConflict)
+
+	shipments, err := shipmentRepo.GetByOrderID(order.ID)
+	assert.NoError(t, err)
+	assert.Len(t, shipments, 1)
+}
+
+func TestSourcingStrategies(t *testing.T) {
+	keyboard := primitive.NewObjectID()
+	mouse := primitive.NewObjectID()
+	items := []models.OrderItem{
+		{ProductID: keyboard, Quantity: 2},
+		{ProductID: mouse, Quantity: 1},
+	}
+	warehouses := []string{"wh-1", "wh-2", "wh-3"}
+	stock := sourcing.Stock{
+		"wh-1": {keyboard: 1},
+		"wh-2": {keyboard: 1, mouse: 1},
+		"wh-3": {keyboard: 2, mouse: 1},
+	}
+
+	allocations, shortages, err := sourcing.Plan(models.SourcingSingleWarehouseFirst, items, warehouses, stock)
+	assert.NoError(t, err)
+	assert.Empty(t, shortages)
+	assert.Equal(t, []models.Allocation{
+		{ProductID: keyboard, WarehouseID: "wh-3", Quantity: 2},
+		{ProductID: mouse, WarehouseID: "wh-3", Quantity: 1},
+	}, allocations)
+
+	allocations, _, err = sourcing.Plan(models.SourcingPriority, items, warehouses, stock)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Allocation{
+		{ProductID: keyboard, WarehouseID: "wh-1", Quantity: 1},
+		{ProductID: keyboard, WarehouseID: "wh-2", Quantity: 1},
+		{ProductID: mouse, WarehouseID: "wh-2", Quantity: 1},
+	}, allocations)
+
+	// No warehouse holds everything: wh-2 covers the most, wh-1 the rest
+	stock["wh-3"] = map[primitive.ObjectID]int{}
+	stock["wh-2"][keyboard] = 2
+	items[0].Quantity = 3
+	allocations, shortages, err = sourcing.Plan(models.SourcingMinimizeSplits, items, warehouses, stock)
+	assert.NoError(t, err)
+	assert.Empty(t, shortages)
+	assert.Equal(t, []models.Allocation{
+		{ProductID: keyboard, WarehouseID: "wh-2", Quantity: 2},
+		{ProductID: mouse, WarehouseID: "wh-2", Quantity: 1},
+		{ProductID: keyboard, WarehouseID: "wh-1", Quantity: 1},
+	}, allocations)
+
+	items[0].Quantity = 5
+	_, shortages, err = sourcing.Plan(models.SourcingPriority, items, warehouses, stock)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.StockShortage{{ProductID: keyboard.Hex(), Requested: 5, Available: 3}}, shortages)
+
+	_, _, err = sourcing.Plan("closest", items, warehouses, stock)
+	assert.ErrorIs(t, err, models.ErrUnknownSourcingStrategy)
+}
+
+func TestCreateOrderReservesSourcingPlan(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse, "wh-1", "wh-2"), newExchangeService(), newPromotionService(), newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	productID := primitive.NewObjectID()
+	order := &models.Order{
+		UserID: primitive.NewObjectID(),
+		ShopID: primitive.NewObjectID(),
+		Items:  []models.OrderItem{{ProductID: productID, Quantity: 3}},
+	}
+	mockCatalog.On("GetProduct", productID).Return(&models.ProductSnapshot{ID: productID, Price: usd(10)}, nil)
+	mockWarehouse.On("GetAvailableStock", "wh-1", productID).Return(1, nil)
+	mockWarehouse.On("GetAvailableStock", "wh-2", productID).Return(5, nil)
+
+	allocations := []models.Allocation{
+		{ProductID: productID, WarehouseID: "wh-1", Quantity: 1},
+		{ProductID: productID, WarehouseID: "wh-2", Quantity: 2},
+	}
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), allocations, 30*time.Minute).Return([]models.StockReservation{}, nil)
+	mockRepo.On("Create", order).Return(nil)
+
+	assert.NoError(t, service.CreateOrder(order))
+	assert.Equal(t, models.SourcingPriority, order.Sourcing.Strategy)
+	assert.Equal(t, allocations, order.Sourcing.Allocations)
+	mockWarehouse.AssertExpectations(t)
+}
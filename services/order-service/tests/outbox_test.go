@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce/order-service/jobs"
+	"ecommerce/order-service/models"
+	"ecommerce/pkg/events"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type memoryOutboxRepository struct {
+	entries []models.OutboxEntry
+}
+
+func (r *memoryOutboxRepository) add(event events.Event) {
+	r.entries = append(r.entries, models.OutboxEntry{Event: event, CreatedAt: time.Now()})
+}
+
+func (r *memoryOutboxRepository) Pending(limit int) ([]models.OutboxEntry, error) {
+	pending := []models.OutboxEntry{}
+	for _, entry := range r.entries {
+		if entry.PublishedAt == nil && entry.DeadAt == nil && len(pending) < limit {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+func (r *memoryOutboxRepository) MarkPublished(id string) error {
+	for i := range r.entries {
+		if r.entries[i].Event.ID == id {
+			now := time.Now()
+			r.entries[i].PublishedAt = &now
+			r.entries[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (r *memoryOutboxRepository) MarkFailed(id string, cause error) error {
+	for i := range r.entries {
+		if r.entries[i].Event.ID == id {
+			r.entries[i].LastError = cause.Error()
+			r.entries[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (r *memoryOutboxRepository) MarkDead(id string, cause error) error {
+	for i := range r.entries {
+		if r.entries[i].Event.ID == id {
+			now := time.Now()
+			r.entries[i].DeadAt = &now
+			r.entries[i].LastError = cause.Error()
+			r.entries[i].Attempts++
+		}
+	}
+	return nil
+}
+
+// flakyPublisher records what it publishes, fails while down and always
+// rejects the events in poison.
+type flakyPublisher struct {
+	down      bool
+	poison    map[string]bool
+	published []events.Event
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event events.Event) error {
+	if p.down {
+		return errors.New("broker unavailable")
+	}
+	if p.poison[event.ID] {
+		return errors.New("event rejected")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func TestNewOrderEvent(t *testing.T) {
+	order := &models.Order{
+		ID:          primitive.NewObjectID(),
+		ShopID:      primitive.NewObjectID(),
+		Status:      models.OrderStatusCancelled,
+		Items:       []models.OrderItem{{ProductID: primitive.NewObjectID(), Name: "Keyboard", Quantity: 2, Price: usd(50)}},
+		TotalAmount: usd(100),
+	}
+	change := models.StatusChange{From: models.OrderStatusPending, To: models.OrderStatusCancelled, Reason: "changed mind", At: time.Now()}
+
+	event, err := models.NewOrderEvent(models.StatusEventType(change.To), order, &change)
+	assert.NoError(t, err)
+	assert.Equal(t, models.EventOrderCancelled, event.Type)
+	assert.Equal(t, order.ID.Hex(), event.Subject)
+	assert.Equal(t, change.At, event.OccurredAt)
+
+	var data models.OrderEventData
+	assert.NoError(t, event.Data.Decode(&data))
+	assert.Equal(t, order.ShopID, data.ShopID)
+	assert.Equal(t, "changed mind", data.Change.Reason)
+	assert.Equal(t, usd(100), data.Total)
+	assert.Equal(t, 2, data.Items[0].Quantity)
+}
+
+func TestOutboxRelay(t *testing.T) {
+	outbox := &memoryOutboxRepository{}
+	publisher := &flakyPublisher{down: true}
+	relay := jobs.NewOutboxRelay(outbox, publisher, time.Second, 2, 5, zap.NewNop())
+
+	order := &models.Order{ID: primitive.NewObjectID(), Status: models.OrderStatusPending, TotalAmount: usd(10)}
+	for _, eventType := range []string{models.EventOrderCreated, models.EventOrderPaid, models.EventOrderProcessing} {
+		event, err := models.NewOrderEvent(eventType, order, nil)
+		assert.NoError(t, err)
+		outbox.add(event)
+	}
+
+	// Nothing after a failed event is published, so it stays first
+	published, err := relay.Relay(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 0, published)
+	assert.Equal(t, "broker unavailable", outbox.entries[0].LastError)
+	assert.Equal(t, 1, outbox.entries[0].Attempts)
+	assert.Nil(t, outbox.entries[1].PublishedAt)
+
+	publisher.down = false
+	published, err = relay.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, published)
+	assert.Equal(t, models.EventOrderCreated, publisher.published[0].Type)
+	assert.Equal(t, models.EventOrderProcessing, publisher.published[2].Type)
+
+	pending, _ := outbox.Pending(10)
+	assert.Empty(t, pending)
+}
+
+func TestOutboxRelayDeadLettersPoisonEvent(t *testing.T) {
+	outbox := &memoryOutboxRepository{}
+	publisher := &flakyPublisher{poison: map[string]bool{}}
+	relay := jobs.NewOutboxRelay(outbox, publisher, time.Second, 10, 3, zap.NewNop())
+
+	order := &models.Order{ID: primitive.NewObjectID(), Status: models.OrderStatusPending, TotalAmount: usd(10)}
+	for _, eventType := range []string{models.EventOrderCreated, models.EventOrderPaid} {
+		event, err := models.NewOrderEvent(eventType, order, nil)
+		assert.NoError(t, err)
+		outbox.add(event)
+	}
+	publisher.poison[outbox.entries[0].Event.ID] = true
+
+	for run := 1; run < 3; run++ {
+		published, err := relay.Relay(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, 0, published)
+	}
+
+	// The third failure dead-letters the event and the next one goes out
+	published, err := relay.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.NotNil(t, outbox.entries[0].DeadAt)
+	assert.Equal(t, 3, outbox.entries[0].Attempts)
+	assert.Equal(t, "event rejected", outbox.entries[0].LastError)
+	assert.Equal(t, models.EventOrderPaid, publisher.published[0].Type)
+
+	pending, _ := outbox.Pending(10)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/promotions"
+	"ecommerce/order-service/services"
+	"ecommerce/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func moneyPtr(amount money.Money) *money.Money {
+	return &amount
+}
+
+// memoryCouponRepository counts redemptions the way the Mongo repository
+// does: once per coupon and order, within the global and per-user limits.
+type memoryCouponRepository struct {
+	coupons     map[string]*models.Coupon
+	usages      map[string]int
+	redemptions map[string]primitive.ObjectID
+}
+
+func newMemoryCouponRepository() *memoryCouponRepository {
+	return &memoryCouponRepository{
+		coupons:     make(map[string]*models.Coupon),
+		usages:      make(map[string]int),
+		redemptions: make(map[string]primitive.ObjectID),
+	}
+}
+
+func newPromotionService() models.PromotionService {
+	return services.NewPromotionService(newMemoryCouponRepository())
+}
+
+func (r *memoryCouponRepository) Create(coupon *models.Coupon) error {
+	if _, ok := r.coupons[coupon.Code]; ok {
+		return models.ErrCouponExists
+	}
+	coupon.ID = primitive.NewObjectID()
+	stored := *coupon
+	r.coupons[coupon.Code] = &stored
+	return nil
+}
+
+func (r *memoryCouponRepository) GetByCode(code string) (*models.Coupon, error) {
+	coupon, ok := r.coupons[code]
+	if !ok {
+		return nil, models.ErrCouponNotFound
+	}
+	copied := *coupon
+	return &copied, nil
+}
+
+func (r *memoryCouponRepository) SetActive(code string, active bool) (*models.Coupon, error) {
+	coupon, ok := r.coupons[code]
+	if !ok {
+		return nil, models.ErrCouponNotFound
+	}
+	coupon.Active = active
+	copied := *coupon
+	return &copied, nil
+}
+
+func (r *memoryCouponRepository) byID(id primitive.ObjectID) *models.Coupon {
+	for _, coupon := range r.coupons {
+		if coupon.ID == id {
+			return coupon
+		}
+	}
+	return nil
+}
+
+func (r *memoryCouponRepository) Redeem(coupon *models.Coupon, userID primitive.ObjectID, orderID primitive.ObjectID) error {
+	redemption := coupon.ID.Hex() + "/" + orderID.Hex()
+	if _, ok := r.redemptions[redemption]; ok {
+		return nil
+	}
+	stored := r.byID(coupon.ID)
+	usage := coupon.ID.Hex() + "/" + userID.Hex()
+	if coupon.MaxRedemptionsPerUser > 0 && r.usages[usage] >= coupon.MaxRedemptionsPerUser {
+		return models.ErrCouponUserLimitReached
+	}
+	if coupon.MaxRedemptions > 0 && stored.Redemptions >= coupon.MaxRedemptions {
+		return models.ErrCouponExhausted
+	}
+	r.redemptions[redemption] = userID
+	r.usages[usage]++
+	stored.Redemptions++
+	return nil
+}
+
+func (r *memoryCouponRepository) Release(couponID primitive.ObjectID, orderID primitive.ObjectID) error {
+	redemption := couponID.Hex() + "/" + orderID.Hex()
+	userID, ok := r.redemptions[redemption]
+	if !ok {
+		return nil
+	}
+	delete(r.redemptions, redemption)
+	r.usages[couponID.Hex()+"/"+userID.Hex()]--
+	r.byID(couponID).Redemptions--
+	return nil
+}
+
+func TestApplyCouponDiscounts(t *testing.T) {
+	shopID := primitive.NewObjectID()
+	keyboard, mouse := primitive.NewObjectID(), primitive.NewObjectID()
+	newOrder := func() *models.Order {
+		return &models.Order{
+			ShopID:   shopID,
+			Currency: "USD",
+			Items: []models.OrderItem{
+				{ProductID: keyboard, Quantity: 2, Price: usd(50)},
+				{ProductID: mouse, Quantity: 1, Price: usd(25)},
+			},
+		}
+	}
+	now := time.Now()
+
+	// Percentage coupons only touch the products in scope
+	order := newOrder()
+	percentage := &models.Coupon{Code: "KEYS10", Type: models.CouponTypePercentage, Percent: 10, ProductIDs: []primitive.ObjectID{keyboard}, Active: true}
+	assert.NoError(t, promotions.Apply(percentage, order, now))
+	assert.Equal(t, usd(10), order.Items[0].Discount())
+	assert.Empty(t, order.Items[1].Adjustments)
+
+	// Fixed amounts are split by line total and stack on earlier discounts
+	fixed := &models.Coupon{Code: "OFF23", Type: models.CouponTypeFixedAmount, Amount: moneyPtr(usd(23)), Active: true}
+	assert.NoError(t, promotions.Apply(fixed, order, now))
+	assert.Equal(t, usd(10+18), order.Items[0].Discount())
+	assert.Equal(t, usd(5), order.Items[1].Discount())
+	assert.Len(t, order.Items[0].Adjustments, 2)
+
+	// A fixed amount larger than the order only brings it to zero
+	order = newOrder()
+	large := &models.Coupon{Code: "HUGE", Type: models.CouponTypeFixedAmount, Amount: moneyPtr(usd(500)), Active: true}
+	assert.NoError(t, promotions.Apply(large, order, now))
+	assert.Equal(t, usd(0), order.Items[0].Net().Add(order.Items[1].Net()))
+
+	past := now.Add(-time.Hour)
+	otherShop := primitive.NewObjectID()
+	notApplicable := []*models.Coupon{
+		{Code: "INACTIVE", Type: models.CouponTypePercentage, Percent: 10},
+		{Code: "EXPIRED", Type: models.CouponTypePercentage, Percent: 10, ValidUntil: &past, Active: true},
+		{Code: "SHOP", Type: models.CouponTypePercentage, Percent: 10, ShopID: &otherShop, Active: true},
+		{Code: "MIN", Type: models.CouponTypePercentage, Percent: 10, MinOrderValue: moneyPtr(usd(200)), Active: true},
+		{Code: "EUR", Type: models.CouponTypeFixedAmount, Amount: moneyPtr(money.New(500, "EUR")), Active: true},
+		{Code: "MINEUR", Type: models.CouponTypePercentage, Percent: 10, MinOrderValue: moneyPtr(money.New(100, "EUR")), Active: true},
+		{Code: "SCOPE", Type: models.CouponTypePercentage, Percent: 10, ProductIDs: []primitive.ObjectID{primitive.NewObjectID()}, Active: true},
+	}
+	for _, coupon := range notApplicable {
+		order := newOrder()
+		assert.ErrorIs(t, promotions.Apply(coupon, order, now), models.ErrCouponNotApplicable, coupon.Code)
+		assert.Empty(t, order.Items[0].Adjustments, coupon.Code)
+	}
+}
+
+func TestCouponCurrencies(t *testing.T) {
+	coupon := &models.Coupon{
+		Code:          "OFF5",
+		Type:          models.CouponTypeFixedAmount,
+		Amount:        &money.Money{Amount: 500, Currency: " usd"},
+		MinOrderValue: &money.Money{Amount: 2000, Currency: "Usd"},
+	}
+	assert.NoError(t, coupon.Validate())
+	assert.Equal(t, usd(5), *coupon.Amount)
+	assert.Equal(t, usd(20), *coupon.MinOrderValue)
+
+	for _, coupon := range []*models.Coupon{
+		{Code: "NONE", Type: models.CouponTypeFixedAmount, Amount: &money.Money{Amount: 500}},
+		{Code: "SHORT", Type: models.CouponTypeFixedAmount, Amount: &money.Money{Amount: 500, Currency: "US"}},
+		{Code: "DIGITS", Type: models.CouponTypeFixedAmount, Amount: &money.Money{Amount: 500, Currency: "U5D"}},
+		{Code: "MIN", Type: models.CouponTypePercentage, Percent: 10, MinOrderValue: &money.Money{Amount: 2000, Currency: "dollars"}},
+		{Code: "MIXED", Type: models.CouponTypeFixedAmount, Amount: moneyPtr(usd(5)), MinOrderValue: moneyPtr(money.New(2000, "EUR"))},
+	} {
+		assert.ErrorIs(t, coupon.Validate(), models.ErrInvalidCoupon, coupon.Code)
+	}
+}
+
+func TestCreateOrderRedeemsCoupons(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	couponRepo := newMemoryCouponRepository()
+	promotionService := services.NewPromotionService(couponRepo)
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), newExchangeService(), promotionService, newTaxService(), newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	coupon := &models.Coupon{Code: "welcome", Type: models.CouponTypePercentage, Percent: 20, MaxRedemptionsPerUser: 1, Active: true}
+	assert.NoError(t, promotionService.CreateCoupon(coupon))
+	assert.Equal(t, "WELCOME", coupon.Code)
+
+	productID := primitive.NewObjectID()
+	userID := primitive.NewObjectID()
+	newOrder := func() *models.Order {
+		return &models.Order{
+			UserID:      userID,
+			ShopID:      primitive.NewObjectID(),
+			CouponCodes: []string{"welcome"},
+			Items:       []models.OrderItem{{ProductID: productID, Quantity: 2}},
+		}
+	}
+
+	mockCatalog.On("GetProduct", productID).Return(&models.ProductSnapshot{ID: productID, Name: "Keyboard", Price: usd(50)}, nil)
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), mock.Anything, 30*time.Minute).
+		Return([]models.StockReservation{{ID: "r1", ProductID: productID, Quantity: 2}}, nil)
+	mockWarehouse.On("ReleaseReservation", "r1").Return(nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Return(nil)
+
+	order := newOrder()
+	assert.NoError(t, service.CreateOrder(order))
+	assert.Equal(t, usd(80), order.TotalAmount)
+	assert.Equal(t, usd(20), order.Discount)
+	assert.Equal(t, []string{"WELCOME"}, order.CouponCodes)
+	assert.Equal(t, []models.Adjustment{{CouponID: coupon.ID, Code: "WELCOME", Amount: usd(20)}}, order.Items[0].Adjustments)
+	assert.Equal(t, 1, couponRepo.coupons["WELCOME"].Redemptions)
+
+	// The per-user limit is reached, so the second order is refused and its
+	// stock released
+	assert.ErrorIs(t, service.CreateOrder(newOrder()), models.ErrCouponUserLimitReached)
+	assert.Equal(t, 1, couponRepo.coupons["WELCOME"].Redemptions)
+	mockWarehouse.AssertCalled(t, "ReleaseReservation", "r1")
+
+	// Cancelling the first order gives the use back
+	mockRepo.On("GetByID", order.ID).Return(order, nil)
+	mockRepo.On("ClaimTransition", order.ID, models.OrderStatusCancelled).Return(nil)
+	mockRepo.On("UpdateStatus", order.ID, mock.AnythingOfType("models.StatusChange")).Return(nil)
+	assert.NoError(t, service.CancelOrder(order.ID, userID.Hex(), "changed my mind"))
+	assert.Equal(t, 0, couponRepo.coupons["WELCOME"].Redemptions)
+	assert.NoError(t, service.CreateOrder(newOrder()))
+}
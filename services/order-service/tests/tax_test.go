@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"ecommerce/order-service/models"
+	"ecommerce/order-service/services"
+	"ecommerce/order-service/taxes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryTaxRateRepository struct {
+	rates []models.TaxRate
+}
+
+func (r *memoryTaxRateRepository) Create(rate *models.TaxRate) error {
+	rate.ID = primitive.NewObjectID()
+	r.rates = append(r.rates, *rate)
+	return nil
+}
+
+func (r *memoryTaxRateRepository) GetByID(id primitive.ObjectID) (*models.TaxRate, error) {
+	for _, rate := range r.rates {
+		if rate.ID == id {
+			return &rate, nil
+		}
+	}
+	return nil, models.ErrTaxRateNotFound
+}
+
+func (r *memoryTaxRateRepository) List() ([]models.TaxRate, error) {
+	return append([]models.TaxRate{}, r.rates...), nil
+}
+
+func (r *memoryTaxRateRepository) Update(rate *models.TaxRate) error {
+	for i := range r.rates {
+		if r.rates[i].ID == rate.ID {
+			r.rates[i] = *rate
+			return nil
+		}
+	}
+	return models.ErrTaxRateNotFound
+}
+
+func (r *memoryTaxRateRepository) Delete(id primitive.ObjectID) error {
+	for i := range r.rates {
+		if r.rates[i].ID == id {
+			r.rates = append(r.rates[:i], r.rates[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrTaxRateNotFound
+}
+
+// locatedShopDirectory serves every shop from the same location and
+// without warehouses.
+type locatedShopDirectory struct {
+	location string
+}
+
+func (d *locatedShopDirectory) GetShop(id primitive.ObjectID) (*models.ShopInfo, error) {
+	return &models.ShopInfo{ID: id, Location: d.location}, nil
+}
+
+// newTaxService charges no tax unless rates are given.
+func newTaxService(rates ...models.TaxRate) models.TaxService {
+	return services.NewTaxService(&memoryTaxRateRepository{rates: rates}, &locatedShopDirectory{location: "Jakarta"})
+}
+
+func TestMatchTaxRate(t *testing.T) {
+	books := primitive.NewObjectID()
+	rates := []models.TaxRate{
+		{Name: "default", Rate: 5, Mode: models.TaxModeExclusive},
+		{Name: "books", CategoryID: &books, Rate: 2, Mode: models.TaxModeExclusive},
+		{Name: "jakarta", Location: "Jakarta", Rate: 11, Mode: models.TaxModeInclusive},
+		{Name: "jakarta books", Location: "Jakarta", CategoryID: &books, Rate: 0, Mode: models.TaxModeInclusive},
+	}
+	other := primitive.NewObjectID()
+
+	assert.Equal(t, "jakarta books", taxes.Match(rates, "jakarta", &books).Name)
+	assert.Equal(t, "jakarta", taxes.Match(rates, "Jakarta", &other).Name)
+	assert.Equal(t, "jakarta", taxes.Match(rates, "Jakarta", nil).Name)
+	assert.Equal(t, "books", taxes.Match(rates, "Bandung", &books).Name)
+	assert.Equal(t, "default", taxes.Match(rates, "Bandung", nil).Name)
+	assert.Nil(t, taxes.Match(rates[1:3], "Bandung", nil))
+}
+
+func TestTaxLine(t *testing.T) {
+	exclusive := &models.TaxRate{Name: "VAT", Rate: 10, Mode: models.TaxModeExclusive}
+	inclusive := &models.TaxRate{Name: "VAT", Rate: 10, Mode: models.TaxModeInclusive}
+
+	assert.Equal(t, usd(10), taxes.Line(exclusive, usd(100)).Amount)
+	assert.Equal(t, usd(10), taxes.Line(inclusive, usd(110)).Amount)
+	// 9.99 including 10% tax is 9.08 net, so no cent is lost or invented
+	assert.Equal(t, usd(0.91), taxes.Line(inclusive, usd(9.99)).Amount)
+	assert.Equal(t, models.LineTax{Mode: models.TaxModeExclusive, Amount: usd(0)}, taxes.Line(nil, usd(100)))
+}
+
+func TestCreateOrderChargesTax(t *testing.T) {
+	mockRepo := new(MockOrderRepository)
+	mockWarehouse := new(MockWarehouseClient)
+	mockCatalog := new(MockProductCatalog)
+	books := primitive.NewObjectID()
+	taxService := newTaxService(
+		models.TaxRate{ID: primitive.NewObjectID(), Name: "VAT", Location: "Jakarta", Rate: 10, Mode: models.TaxModeExclusive},
+		models.TaxRate{ID: primitive.NewObjectID(), Name: "Book VAT", Location: "Jakarta", CategoryID: &books, Rate: 10, Mode: models.TaxModeInclusive},
+	)
+	service := services.NewOrderService(mockRepo, mockWarehouse, mockCatalog, newSourcer(mockWarehouse), newExchangeService(), newPromotionService(), taxService, newFakePaymentService(), newInvoiceService(), 30*time.Minute)
+
+	keyboard, novel := primitive.NewObjectID(), primitive.NewObjectID()
+	mockCatalog.On("GetProduct", keyboard).Return(&models.ProductSnapshot{ID: keyboard, Name: "Keyboard", Price: usd(50)}, nil)
+	mockCatalog.On("GetProduct", novel).Return(&models.ProductSnapshot{ID: novel, Name: "Novel", CategoryID: &books, Price: usd(22)}, nil)
+	mockWarehouse.On("ReserveStock", mock.AnythingOfType("string"), mock.Anything, 30*time.Minute).Return([]models.StockReservation{}, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Return(nil)
+
+	order := &models.Order{
+		UserID: primitive.NewObjectID(),
+		ShopID: primitive.NewObjectID(),
+		Items: []models.OrderItem{
+			{ProductID: keyboard, Quantity: 2},
+			{ProductID: novel, Quantity: 1},
+		},
+	}
+	assert.NoError(t, service.CreateOrder(order))
+
+	assert.Equal(t, "VAT", order.Items[0].Tax.Name)
+	assert.Equal(t, usd(10), order.Items[0].Tax.Amount)
+	assert.Equal(t, models.TaxModeInclusive, order.Items[1].Tax.Mode)
+	assert.Equal(t, usd(2), order.Items[1].Tax.Amount)
+	assert.Equal(t, usd(122), order.Subtotal)
+	assert.Equal(t, usd(12), order.Tax)
+	assert.Equal(t, usd(0), order.Discount)
+	assert.Equal(t, usd(0), order.Shipping)
+	// Only the exclusive tax is added on top of the subtotal
+	assert.Equal(t, usd(132), order.TotalAmount)
+}
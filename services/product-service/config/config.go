@@ -11,6 +11,7 @@ type Config struct {
 	MongoDB  MongoDBConfig
 	JWT      JWTConfig
 	LogLevel string
+	Pricing  PricingConfig
 }
 
 type ServerConfig struct {
@@ -30,6 +31,10 @@ type JWTConfig struct {
 	ExpiryHour int
 }
 
+type PricingConfig struct {
+	DefaultCurrency string
+}
+
 func LoadConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -47,6 +52,9 @@ func LoadConfig() *Config {
 			ExpiryHour: getEnvAsInt("JWT_EXPIRY_HOUR", 24),
 		},
 		LogLevel: getEnv("LOG_LEVEL", "info"),
+		Pricing: PricingConfig{
+			DefaultCurrency: getEnv("DEFAULT_CURRENCY", "USD"),
+		},
 	}
 }
 
@@ -3,6 +3,7 @@ module ecommerce/product-service
 go 1.21
 
 require (
+	ecommerce/pkg v0.0.0
 	github.com/gin-gonic/gin v1.9.1
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/prometheus/client_golang v1.18.0
@@ -69,3 +70,4 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
+replace ecommerce/pkg => ../../pkg
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce/product-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CategoryHandler struct {
+	categoryService models.CategoryService
+}
+
+func NewCategoryHandler(categoryService models.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+	}
+}
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Create a category, at the root of the tree or below a parent. The slug is derived from the name when none is given.
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param category body models.CategoryRequest true "Category"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 409 {object} map[string]interface{} "Slug already taken"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /categories [post]
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.categoryService.CreateCategory(req)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// ListCategories godoc
+// @Summary List categories
+// @Description Get the category tree: the root categories with their subcategories nested below them
+// @Tags categories
+// @Produce  json
+// @Success 200 {array} models.CategoryNode
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /categories [get]
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	tree, err := h.categoryService.ListCategoryTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Get a category by its ID or slug
+// @Tags categories
+// @Produce  json
+// @Param id path string true "Category ID or slug"
+// @Success 200 {object} models.Category
+// @Failure 404 {object} map[string]interface{} "Category not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /categories/{id} [get]
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	category, err := h.categoryService.GetCategory(c.Param("id"))
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Rename a category or move it, with its subcategories, below another parent
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Param category body models.CategoryRequest true "Category"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Category not found"
+// @Failure 409 {object} map[string]interface{} "Slug already taken"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /categories/{id} [put]
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.categoryService.UpdateCategory(id, req)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Delete a category that has no subcategories and no products
+// @Tags categories
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} map[string]interface{} "Success message"
+// @Failure 400 {object} map[string]interface{} "Invalid ID format"
+// @Failure 404 {object} map[string]interface{} "Category not found"
+// @Failure 409 {object} map[string]interface{} "Category in use"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /categories/{id} [delete]
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := h.categoryService.DeleteCategory(id); err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// ListCategoryProducts godoc
+// @Summary List a category's products
+// @Description Get the products of a category and, unless descendants is false, of every category below it
+// @Tags categories
+// @Produce  json
+// @Param id path string true "Category ID or slug"
+// @Param descendants query bool false "Include products of subcategories" default(true)
+// @Success 200 {array} models.Product
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 404 {object} map[string]interface{} "Category not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /categories/{id}/products [get]
+func (h *CategoryHandler) ListCategoryProducts(c *gin.Context) {
+	descendants, err := strconv.ParseBool(c.DefaultQuery("descendants", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid descendants flag"})
+		return
+	}
+
+	products, err := h.categoryService.ListCategoryProducts(c.Param("id"), descendants)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+func categoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidCategory):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrCategoryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrCategorySlugTaken), errors.Is(err, models.ErrCategoryInUse):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"ecommerce/product-service/models"
@@ -39,7 +40,7 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	}
 
 	if err := h.productService.CreateProduct(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(productErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -120,7 +121,7 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	product.ID = id
 
 	if err := h.productService.UpdateProduct(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(productErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -189,3 +190,12 @@ func (h *ProductHandler) UpdateStock(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Stock updated successfully"})
 }
+
+// productErrorStatus reports a product filed under a missing category as
+// a bad request.
+func productErrorStatus(err error) int {
+	if errors.Is(err, models.ErrCategoryNotFound) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
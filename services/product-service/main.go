@@ -12,6 +12,7 @@ import (
 	"ecommerce/product-service/config"
 	"ecommerce/product-service/handlers"
 	"ecommerce/product-service/middleware"
+	"ecommerce/product-service/migrations"
 	"ecommerce/product-service/repository"
 	"ecommerce/product-service/services"
 	"ecommerce/product-service/utils"
@@ -64,16 +65,26 @@ func main() {
 	defer mongoClient.Disconnect(ctx)
 
 	db := mongoClient.Database(cfg.MongoDB.Database)
+
+	// Run database migrations
+	if err := migrations.RunMigrations(db, cfg.Pricing.DefaultCurrency); err != nil {
+		logger.Fatal("Failed to run migrations", zap.Error(err))
+	}
+
 	productCollection := db.Collection("products")
+	categoryCollection := db.Collection("categories")
 
 	// Initialize repositories
 	productRepo := repository.NewMongoProductRepository(productCollection)
+	categoryRepo := repository.NewMongoCategoryRepository(categoryCollection)
 
 	// Initialize services
-	productService := services.NewProductService(productRepo)
+	productService := services.NewProductService(productRepo, categoryRepo, cfg.Pricing.DefaultCurrency)
+	categoryService := services.NewCategoryService(categoryRepo, productRepo)
 
 	// Initialize handlers
 	productHandler := handlers.NewProductHandler(productService)
+	categoryHandler := handlers.NewCategoryHandler(categoryService)
 	healthHandler := handlers.NewHealthHandler()
 
 	// Initialize Gin router
@@ -106,6 +117,16 @@ func main() {
 			products.DELETE("/:id", middleware.AuthMiddleware(cfg.JWT.Secret), productHandler.DeleteProduct)
 			products.PATCH("/:id/stock", middleware.AuthMiddleware(cfg.JWT.Secret), productHandler.UpdateStock)
 		}
+
+		categories := api.Group("/categories")
+		{
+			categories.POST("/", middleware.AuthMiddleware(cfg.JWT.Secret), categoryHandler.CreateCategory)
+			categories.GET("/", categoryHandler.ListCategories)
+			categories.GET("/:id", categoryHandler.GetCategory)
+			categories.PUT("/:id", middleware.AuthMiddleware(cfg.JWT.Secret), categoryHandler.UpdateCategory)
+			categories.DELETE("/:id", middleware.AuthMiddleware(cfg.JWT.Secret), categoryHandler.DeleteCategory)
+			categories.GET("/:id/products", categoryHandler.ListCategoryProducts)
+		}
 	}
 
 	// Start server
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CategoriesMigration indexes the category tree: slugs are unique, and
+// categories are looked up by parent and by any ancestor.
+func CategoriesMigration(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("categories").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "parent_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "ancestors", Value: 1}},
+		},
+	})
+	return err
+}
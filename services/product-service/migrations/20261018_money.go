@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MoneyMigration converts product prices stored as float64 major units
+// into money documents in currency. Each update only applies while the
+// price is still a number, so running it twice is harmless.
+func MoneyMigration(currency string) func(*mongo.Database) error {
+	return func(db *mongo.Database) error {
+		ctx := context.Background()
+		products := db.Collection("products")
+
+		cursor, err := products.Find(ctx, bson.M{"price": bson.M{"$type": "number"}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		converted := 0
+		for cursor.Next(ctx) {
+			var doc struct {
+				ID    primitive.ObjectID `bson:"_id"`
+				Price bson.RawValue      `bson:"price"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				return err
+			}
+			price, ok := money.FromBSONNumber(doc.Price, currency)
+			if !ok {
+				continue
+			}
+
+			_, err := products.UpdateOne(ctx,
+				bson.M{"_id": doc.ID, "price": bson.M{"$type": "number"}},
+				bson.M{"$set": bson.M{"price": price}},
+			)
+			if err != nil {
+				return err
+			}
+			converted++
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		log.Printf("Converted %d product prices to %s money", converted, currency)
+		return nil
+	}
+}
@@ -16,23 +16,41 @@ type Migration struct {
 	Down      func(*mongo.Database) error
 }
 
-var migrations = []Migration{
-	{
-		Version:   1,
-		Name:      "init",
-		Timestamp: time.Now(),
-		Up:        InitMigration,
-		Down:      nil,
-	},
+// migrations lists every migration in version order. Prices in documents
+// older than the money type are taken to be in defaultCurrency.
+func migrations(defaultCurrency string) []Migration {
+	return []Migration{
+		{
+			Version:   1,
+			Name:      "init",
+			Timestamp: time.Now(),
+			Up:        InitMigration,
+			Down:      nil,
+		},
+		{
+			Version:   2,
+			Name:      "money",
+			Timestamp: time.Now(),
+			Up:        MoneyMigration(defaultCurrency),
+			Down:      nil,
+		},
+		{
+			Version:   3,
+			Name:      "categories",
+			Timestamp: time.Now(),
+			Up:        CategoriesMigration,
+			Down:      nil,
+		},
+	}
 }
 
-func RunMigrations(db *mongo.Database) error {
+func RunMigrations(db *mongo.Database, defaultCurrency string) error {
 	ctx := context.Background()
 
 	// Create migrations collection if not exists
 	migrationsCollection := db.Collection("migrations")
 
-	for _, migration := range migrations {
+	for _, migration := range migrations(defaultCurrency) {
 		// Check if migration has been applied
 		count, err := migrationsCollection.CountDocuments(ctx, map[string]interface{}{
 			"version": migration.Version,
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrCategoryNotFound  = errors.New("category not found")
+	ErrInvalidCategory   = errors.New("invalid category")
+	ErrCategorySlugTaken = errors.New("category slug is already taken")
+	ErrCategoryInUse     = errors.New("category has subcategories or products")
+)
+
+// Category is a node of the product category tree. Ancestors lists the
+// IDs of every category above it, root first, so a subtree can be found
+// with one query.
+type Category struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name        string               `bson:"name" json:"name"`
+	Slug        string               `bson:"slug" json:"slug"`
+	Description string               `bson:"description" json:"description"`
+	ParentID    *primitive.ObjectID  `bson:"parent_id" json:"parent_id"`
+	Ancestors   []primitive.ObjectID `bson:"ancestors" json:"ancestors"`
+	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// CategoryRequest creates or updates a category. A missing slug is derived
+// from the name; a missing parent makes the category a root.
+type CategoryRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	Slug        string              `json:"slug"`
+	Description string              `json:"description"`
+	ParentID    *primitive.ObjectID `json:"parent_id"`
+}
+
+// CategoryNode is a category with its subcategories, as listed in the
+// category tree.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+type CategoryRepository interface {
+	// Create stores the category, or returns ErrCategorySlugTaken.
+	Create(category *Category) error
+	GetByID(id primitive.ObjectID) (*Category, error)
+	GetBySlug(slug string) (*Category, error)
+	GetAll() ([]Category, error)
+	// ListDescendants returns every category below id, at any depth.
+	ListDescendants(id primitive.ObjectID) ([]Category, error)
+	// MoveDescendants follows a move of category id to below ancestors:
+	// every category below it gets ancestors in place of what came before
+	// id in its own ancestors, in a single write.
+	MoveDescendants(id primitive.ObjectID, ancestors []primitive.ObjectID) error
+	CountChildren(id primitive.ObjectID) (int64, error)
+	// Update stores the category, or returns ErrCategorySlugTaken.
+	Update(category *Category) error
+	Delete(id primitive.ObjectID) error
+}
+
+type CategoryService interface {
+	CreateCategory(req CategoryRequest) (*Category, error)
+	// GetCategory looks a category up by ID or, failing that, by slug.
+	GetCategory(idOrSlug string) (*Category, error)
+	ListCategoryTree() ([]*CategoryNode, error)
+	UpdateCategory(id primitive.ObjectID, req CategoryRequest) (*Category, error)
+	// DeleteCategory only deletes categories without subcategories or
+	// products.
+	DeleteCategory(id primitive.ObjectID) error
+	// ListCategoryProducts returns the category's products and, with
+	// descendants, those of every category below it.
+	ListCategoryProducts(idOrSlug string, descendants bool) ([]Product, error)
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"ecommerce/pkg/money"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -10,9 +12,9 @@ type Product struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description" json:"description"`
-	Price       float64            `bson:"price" json:"price"`
+	Price       money.Money        `bson:"price" json:"price"`
 	Stock       int                `bson:"stock" json:"stock"`
-	CategoryID  primitive.ObjectID `bson:"category_id" json:"category_id"`
+	CategoryID  primitive.ObjectID `bson:"category_id" json:"category_id"` // zero when uncategorized
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -24,6 +26,8 @@ type ProductRepository interface {
 	Update(product *Product) error
 	Delete(id primitive.ObjectID) error
 	UpdateStock(id primitive.ObjectID, quantity int) error
+	ListByCategories(categoryIDs []primitive.ObjectID) ([]Product, error)
+	CountByCategory(categoryID primitive.ObjectID) (int64, error)
 }
 
 type ProductService interface {
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/product-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoCategoryRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoCategoryRepository(db *mongo.Collection) models.CategoryRepository {
+	return &mongoCategoryRepository{
+		db: db,
+	}
+}
+
+func (r *mongoCategoryRepository) Create(category *models.Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+
+	result, err := r.db.InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrCategorySlugTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	category.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoCategoryRepository) GetByID(id primitive.ObjectID) (*models.Category, error) {
+	return r.findOne(bson.M{"_id": id})
+}
+
+func (r *mongoCategoryRepository) GetBySlug(slug string) (*models.Category, error) {
+	return r.findOne(bson.M{"slug": slug})
+}
+
+func (r *mongoCategoryRepository) findOne(filter bson.M) (*models.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var category models.Category
+	err := r.db.FindOne(ctx, filter).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+func (r *mongoCategoryRepository) GetAll() ([]models.Category, error) {
+	return r.find(bson.M{})
+}
+
+func (r *mongoCategoryRepository) ListDescendants(id primitive.ObjectID) ([]models.Category, error) {
+	return r.find(bson.M{"ancestors": id})
+}
+
+func (r *mongoCategoryRepository) MoveDescendants(id primitive.ObjectID, ancestors []primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if ancestors == nil {
+		ancestors = []primitive.ObjectID{}
+	}
+	// Each descendant keeps its ancestors from id on, so the new path is
+	// computed from the stored one rather than from a copy read earlier
+	below := bson.M{"$slice": bson.A{
+		"$ancestors",
+		bson.M{"$indexOfArray": bson.A{"$ancestors", id}},
+		bson.M{"$size": "$ancestors"},
+	}}
+	_, err := r.db.UpdateMany(ctx, bson.M{"ancestors": id}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"ancestors":  bson.M{"$concatArrays": bson.A{ancestors, below}},
+			"updated_at": time.Now(),
+		}}},
+	})
+	return err
+}
+
+func (r *mongoCategoryRepository) find(filter bson.M) ([]models.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.db.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []models.Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (r *mongoCategoryRepository) CountChildren(id primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return r.db.CountDocuments(ctx, bson.M{"parent_id": id})
+}
+
+func (r *mongoCategoryRepository) Update(category *models.Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	category.UpdatedAt = time.Now()
+
+	result, err := r.db.UpdateOne(ctx, bson.M{"_id": category.ID}, bson.M{"$set": category})
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrCategorySlugTaken
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoCategoryRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.db.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrCategoryNotFound
+	}
+
+	return nil
+}
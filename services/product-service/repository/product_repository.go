@@ -9,6 +9,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoProductRepository struct {
@@ -105,4 +106,32 @@ func (r *mongoProductRepository) UpdateStock(id primitive.ObjectID, quantity int
 	return err
 }
 
+func (r *mongoProductRepository) ListByCategories(categoryIDs []primitive.ObjectID) ([]models.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.db.Find(ctx,
+		bson.M{"category_id": bson.M{"$in": categoryIDs}},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []models.Product{}
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (r *mongoProductRepository) CountByCategory(categoryID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return r.db.CountDocuments(ctx, bson.M{"category_id": categoryID})
+}
+
 // ... rest of the repository methods with updated import paths ...
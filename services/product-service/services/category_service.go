@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ecommerce/product-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxSlugLength = 100
+
+var (
+	slugPattern   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type categoryService struct {
+	categoryRepo models.CategoryRepository
+	productRepo  models.ProductRepository
+}
+
+func NewCategoryService(categoryRepo models.CategoryRepository, productRepo models.ProductRepository) models.CategoryService {
+	return &categoryService{
+		categoryRepo: categoryRepo,
+		productRepo:  productRepo,
+	}
+}
+
+func (s *categoryService) CreateCategory(req models.CategoryRequest) (*models.Category, error) {
+	category := &models.Category{}
+	if err := s.apply(category, req); err != nil {
+		return nil, err
+	}
+	if err := s.categoryRepo.Create(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *categoryService) GetCategory(idOrSlug string) (*models.Category, error) {
+	if id, err := primitive.ObjectIDFromHex(idOrSlug); err == nil {
+		return s.categoryRepo.GetByID(id)
+	}
+	return s.categoryRepo.GetBySlug(idOrSlug)
+}
+
+// ListCategoryTree returns the root categories with their subcategories
+// nested below them, each level sorted by name.
+func (s *categoryService) ListCategoryTree() ([]*models.CategoryNode, error) {
+	categories, err := s.categoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[primitive.ObjectID]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &models.CategoryNode{Category: category, Children: []*models.CategoryNode{}}
+	}
+
+	roots := []*models.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return roots, nil
+}
+
+// UpdateCategory moves the category's whole subtree when its parent
+// changes. The subcategories are updated together in one write after the
+// category itself.
+func (s *categoryService) UpdateCategory(id primitive.ObjectID, req models.CategoryRequest) (*models.Category, error) {
+	category, err := s.categoryRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := category.Ancestors
+	if err := s.apply(category, req); err != nil {
+		return nil, err
+	}
+	if err := s.categoryRepo.Update(category); err != nil {
+		return nil, err
+	}
+	if equalIDs(previous, category.Ancestors) {
+		return category, nil
+	}
+
+	if err := s.categoryRepo.MoveDescendants(id, category.Ancestors); err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (s *categoryService) DeleteCategory(id primitive.ObjectID) error {
+	if _, err := s.categoryRepo.GetByID(id); err != nil {
+		return err
+	}
+
+	children, err := s.categoryRepo.CountChildren(id)
+	if err != nil {
+		return err
+	}
+	products, err := s.productRepo.CountByCategory(id)
+	if err != nil {
+		return err
+	}
+	if children > 0 || products > 0 {
+		return fmt.Errorf("%w: %d subcategories, %d products", models.ErrCategoryInUse, children, products)
+	}
+
+	return s.categoryRepo.Delete(id)
+}
+
+func (s *categoryService) ListCategoryProducts(idOrSlug string, descendants bool) ([]models.Product, error) {
+	category, err := s.GetCategory(idOrSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryIDs := []primitive.ObjectID{category.ID}
+	if descendants {
+		below, err := s.categoryRepo.ListDescendants(category.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, descendant := range below {
+			categoryIDs = append(categoryIDs, descendant.ID)
+		}
+	}
+
+	return s.productRepo.ListByCategories(categoryIDs)
+}
+
+// apply validates the request and copies it onto the category, placing
+// the category under its new parent. A category cannot be moved below
+// itself.
+func (s *categoryService) apply(category *models.Category, req models.CategoryRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", models.ErrInvalidCategory)
+	}
+
+	slug := req.Slug
+	if slug == "" {
+		slug = strings.Trim(slugSeparator.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	}
+	if !slugPattern.MatchString(slug) || len(slug) > maxSlugLength {
+		return fmt.Errorf("%w: slug must be lower-case letters and digits separated by hyphens, at most %d characters", models.ErrInvalidCategory, maxSlugLength)
+	}
+	// Categories are looked up by ID or slug, so a slug must not read as
+	// an ID
+	if primitive.IsValidObjectID(slug) {
+		return fmt.Errorf("%w: slug cannot look like a category ID", models.ErrInvalidCategory)
+	}
+
+	ancestors := []primitive.ObjectID{}
+	if req.ParentID != nil {
+		if *req.ParentID == category.ID {
+			return fmt.Errorf("%w: a category cannot be its own parent", models.ErrInvalidCategory)
+		}
+		parent, err := s.categoryRepo.GetByID(*req.ParentID)
+		if errors.Is(err, models.ErrCategoryNotFound) {
+			return fmt.Errorf("%w: parent category not found", models.ErrInvalidCategory)
+		}
+		if err != nil {
+			return err
+		}
+		if !category.ID.IsZero() && indexOfID(parent.Ancestors, category.ID) >= 0 {
+			return fmt.Errorf("%w: a category cannot be moved below its own subcategory", models.ErrInvalidCategory)
+		}
+		ancestors = append(append(ancestors, parent.Ancestors...), parent.ID)
+	}
+
+	category.Name = name
+	category.Slug = slug
+	category.Description = req.Description
+	category.ParentID = req.ParentID
+	category.Ancestors = ancestors
+	return nil
+}
+
+func indexOfID(ids []primitive.ObjectID, id primitive.ObjectID) int {
+	for i, candidate := range ids {
+		if candidate == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,18 +3,25 @@ package services
 import (
 	"errors"
 
+	"ecommerce/pkg/money"
 	"ecommerce/product-service/models"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type productService struct {
-	productRepo models.ProductRepository
+	productRepo     models.ProductRepository
+	categoryRepo    models.CategoryRepository
+	defaultCurrency string
 }
 
-func NewProductService(productRepo models.ProductRepository) models.ProductService {
+// NewProductService prices products sent without a currency in
+// defaultCurrency.
+func NewProductService(productRepo models.ProductRepository, categoryRepo models.CategoryRepository, defaultCurrency string) models.ProductService {
 	return &productService{
-		productRepo: productRepo,
+		productRepo:     productRepo,
+		categoryRepo:    categoryRepo,
+		defaultCurrency: defaultCurrency,
 	}
 }
 
@@ -22,12 +29,15 @@ func (s *productService) CreateProduct(product *models.Product) error {
 	if product.Name == "" {
 		return errors.New("product name is required")
 	}
-	if product.Price <= 0 {
-		return errors.New("product price must be greater than 0")
+	if err := s.checkPrice(product); err != nil {
+		return err
 	}
 	if product.Stock < 0 {
 		return errors.New("product stock cannot be negative")
 	}
+	if err := s.checkCategory(product); err != nil {
+		return err
+	}
 
 	return s.productRepo.Create(product)
 }
@@ -51,12 +61,15 @@ func (s *productService) UpdateProduct(product *models.Product) error {
 	if product.Name == "" {
 		return errors.New("product name is required")
 	}
-	if product.Price <= 0 {
-		return errors.New("product price must be greater than 0")
+	if err := s.checkPrice(product); err != nil {
+		return err
 	}
 	if product.Stock < 0 {
 		return errors.New("product stock cannot be negative")
 	}
+	if err := s.checkCategory(product); err != nil {
+		return err
+	}
 
 	return s.productRepo.Update(product)
 }
@@ -77,3 +90,33 @@ func (s *productService) UpdateProductStock(id primitive.ObjectID, quantity int)
 
 	return s.productRepo.UpdateStock(id, quantity)
 }
+
+// checkPrice fills in the default currency and rejects prices that are not
+// positive. Prices sent as a bare number arrive without a currency.
+func (s *productService) checkPrice(product *models.Product) error {
+	if product.Price.Currency == "" {
+		// Re-read the amount, since its precision depends on the currency
+		price, err := money.Parse(product.Price.Decimal(), s.defaultCurrency)
+		if err != nil {
+			return err
+		}
+		product.Price = price
+	}
+	if !money.ValidCurrency(product.Price.Currency) {
+		return errors.New("product price currency must be an ISO 4217 code")
+	}
+	if !product.Price.IsPositive() {
+		return errors.New("product price must be greater than 0")
+	}
+	return nil
+}
+
+// checkCategory rejects products filed under a category that does not
+// exist. Uncategorized products are allowed.
+func (s *productService) checkCategory(product *models.Product) error {
+	if product.CategoryID.IsZero() {
+		return nil
+	}
+	_, err := s.categoryRepo.GetByID(product.CategoryID)
+	return err
+}
@@ -0,0 +1,264 @@
+package tests
+
+import (
+	"sort"
+	"testing"
+
+	"ecommerce/pkg/money"
+	"ecommerce/product-service/models"
+	"ecommerce/product-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryCategoryRepository keeps categories in memory, with slugs unique
+// as the MongoDB index makes them.
+type memoryCategoryRepository struct {
+	categories map[primitive.ObjectID]models.Category
+}
+
+func newMemoryCategoryRepository() *memoryCategoryRepository {
+	return &memoryCategoryRepository{categories: map[primitive.ObjectID]models.Category{}}
+}
+
+func (r *memoryCategoryRepository) slugTaken(category *models.Category) bool {
+	for _, stored := range r.categories {
+		if stored.Slug == category.Slug && stored.ID != category.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryCategoryRepository) Create(category *models.Category) error {
+	if r.slugTaken(category) {
+		return models.ErrCategorySlugTaken
+	}
+	category.ID = primitive.NewObjectID()
+	r.categories[category.ID] = *category
+	return nil
+}
+
+func (r *memoryCategoryRepository) GetByID(id primitive.ObjectID) (*models.Category, error) {
+	category, ok := r.categories[id]
+	if !ok {
+		return nil, models.ErrCategoryNotFound
+	}
+	return &category, nil
+}
+
+func (r *memoryCategoryRepository) GetBySlug(slug string) (*models.Category, error) {
+	for _, category := range r.categories {
+		if category.Slug == slug {
+			return &category, nil
+		}
+	}
+	return nil, models.ErrCategoryNotFound
+}
+
+func (r *memoryCategoryRepository) GetAll() ([]models.Category, error) {
+	return r.find(func(models.Category) bool { return true }), nil
+}
+
+func (r *memoryCategoryRepository) ListDescendants(id primitive.ObjectID) ([]models.Category, error) {
+	return r.find(func(category models.Category) bool {
+		for _, ancestor := range category.Ancestors {
+			if ancestor == id {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *memoryCategoryRepository) MoveDescendants(id primitive.ObjectID, ancestors []primitive.ObjectID) error {
+	for categoryID, category := range r.categories {
+		for i, ancestor := range category.Ancestors {
+			if ancestor == id {
+				category.Ancestors = append(append([]primitive.ObjectID{}, ancestors...), category.Ancestors[i:]...)
+				r.categories[categoryID] = category
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *memoryCategoryRepository) CountChildren(id primitive.ObjectID) (int64, error) {
+	return int64(len(r.find(func(category models.Category) bool {
+		return category.ParentID != nil && *category.ParentID == id
+	}))), nil
+}
+
+func (r *memoryCategoryRepository) find(match func(models.Category) bool) []models.Category {
+	categories := []models.Category{}
+	for _, category := range r.categories {
+		if match(category) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories
+}
+
+func (r *memoryCategoryRepository) Update(category *models.Category) error {
+	if _, ok := r.categories[category.ID]; !ok {
+		return models.ErrCategoryNotFound
+	}
+	if r.slugTaken(category) {
+		return models.ErrCategorySlugTaken
+	}
+	r.categories[category.ID] = *category
+	return nil
+}
+
+func (r *memoryCategoryRepository) Delete(id primitive.ObjectID) error {
+	if _, ok := r.categories[id]; !ok {
+		return models.ErrCategoryNotFound
+	}
+	delete(r.categories, id)
+	return nil
+}
+
+func createCategory(t *testing.T, service models.CategoryService, name string, parent *models.Category) *models.Category {
+	req := models.CategoryRequest{Name: name}
+	if parent != nil {
+		req.ParentID = &parent.ID
+	}
+	category, err := service.CreateCategory(req)
+	assert.NoError(t, err)
+	return category
+}
+
+func TestCreateCategory(t *testing.T) {
+	categoryRepo := newMemoryCategoryRepository()
+	service := services.NewCategoryService(categoryRepo, new(MockProductRepository))
+
+	electronics := createCategory(t, service, "Electronics", nil)
+	audio := createCategory(t, service, "Audio & Hi-Fi", electronics)
+	headphones := createCategory(t, service, "Headphones", audio)
+
+	assert.Equal(t, "audio-hi-fi", audio.Slug)
+	assert.Equal(t, []primitive.ObjectID{electronics.ID, audio.ID}, headphones.Ancestors)
+	assert.Empty(t, electronics.Ancestors)
+
+	found, err := service.GetCategory("headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, headphones.ID, found.ID)
+	found, err = service.GetCategory(headphones.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, "Headphones", found.Name)
+
+	tree, err := service.ListCategoryTree()
+	assert.NoError(t, err)
+	assert.Len(t, tree, 1)
+	assert.Equal(t, audio.ID, tree[0].Children[0].ID)
+	assert.Equal(t, headphones.ID, tree[0].Children[0].Children[0].ID)
+
+	_, err = service.CreateCategory(models.CategoryRequest{Name: "Headphones"})
+	assert.ErrorIs(t, err, models.ErrCategorySlugTaken)
+
+	missing := primitive.NewObjectID()
+	for _, req := range []models.CategoryRequest{
+		{Name: "  "},
+		{Name: "Toys", Slug: "Toys!"},
+		{Name: "Toys", Slug: primitive.NewObjectID().Hex()},
+		{Name: "Toys", ParentID: &missing},
+	} {
+		_, err := service.CreateCategory(req)
+		assert.ErrorIs(t, err, models.ErrInvalidCategory)
+	}
+}
+
+func TestMoveCategoryMovesSubtree(t *testing.T) {
+	categoryRepo := newMemoryCategoryRepository()
+	service := services.NewCategoryService(categoryRepo, new(MockProductRepository))
+
+	electronics := createCategory(t, service, "Electronics", nil)
+	audio := createCategory(t, service, "Audio", electronics)
+	headphones := createCategory(t, service, "Headphones", audio)
+	earbuds := createCategory(t, service, "Earbuds", headphones)
+	music := createCategory(t, service, "Music", nil)
+
+	moved, err := service.UpdateCategory(audio.ID, models.CategoryRequest{Name: "Audio", ParentID: &music.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{music.ID}, moved.Ancestors)
+
+	stored, _ := categoryRepo.GetByID(headphones.ID)
+	assert.Equal(t, []primitive.ObjectID{music.ID, audio.ID}, stored.Ancestors)
+	stored, _ = categoryRepo.GetByID(earbuds.ID)
+	assert.Equal(t, []primitive.ObjectID{music.ID, audio.ID, headphones.ID}, stored.Ancestors)
+
+	// Moving to the root drops everything above the category
+	_, err = service.UpdateCategory(headphones.ID, models.CategoryRequest{Name: "Headphones"})
+	assert.NoError(t, err)
+	stored, _ = categoryRepo.GetByID(earbuds.ID)
+	assert.Equal(t, []primitive.ObjectID{headphones.ID}, stored.Ancestors)
+
+	// A category cannot end up below itself
+	_, err = service.UpdateCategory(music.ID, models.CategoryRequest{Name: "Music", ParentID: &audio.ID})
+	assert.ErrorIs(t, err, models.ErrInvalidCategory)
+	_, err = service.UpdateCategory(music.ID, models.CategoryRequest{Name: "Music", ParentID: &music.ID})
+	assert.ErrorIs(t, err, models.ErrInvalidCategory)
+}
+
+func TestListCategoryProducts(t *testing.T) {
+	categoryRepo := newMemoryCategoryRepository()
+	productRepo := new(MockProductRepository)
+	service := services.NewCategoryService(categoryRepo, productRepo)
+
+	electronics := createCategory(t, service, "Electronics", nil)
+	audio := createCategory(t, service, "Audio", electronics)
+	headphones := createCategory(t, service, "Headphones", audio)
+
+	products := []models.Product{{Name: "Earbuds", CategoryID: headphones.ID}}
+	productRepo.On("ListByCategories", []primitive.ObjectID{electronics.ID, audio.ID, headphones.ID}).Return(products, nil)
+	productRepo.On("ListByCategories", []primitive.ObjectID{electronics.ID}).Return([]models.Product{}, nil)
+
+	listed, err := service.ListCategoryProducts("electronics", true)
+	assert.NoError(t, err)
+	assert.Equal(t, products, listed)
+
+	listed, err = service.ListCategoryProducts("electronics", false)
+	assert.NoError(t, err)
+	assert.Empty(t, listed)
+
+	_, err = service.ListCategoryProducts("toys", true)
+	assert.ErrorIs(t, err, models.ErrCategoryNotFound)
+}
+
+func TestDeleteCategory(t *testing.T) {
+	categoryRepo := newMemoryCategoryRepository()
+	productRepo := new(MockProductRepository)
+	service := services.NewCategoryService(categoryRepo, productRepo)
+
+	electronics := createCategory(t, service, "Electronics", nil)
+	audio := createCategory(t, service, "Audio", electronics)
+	productRepo.On("CountByCategory", electronics.ID).Return(int64(0), nil)
+	productRepo.On("CountByCategory", audio.ID).Return(int64(2), nil)
+
+	assert.ErrorIs(t, service.DeleteCategory(electronics.ID), models.ErrCategoryInUse)
+	assert.ErrorIs(t, service.DeleteCategory(audio.ID), models.ErrCategoryInUse)
+
+	productRepo.On("CountByCategory", mock.Anything).Return(int64(0), nil)
+	other := createCategory(t, service, "Garden", nil)
+	assert.NoError(t, service.DeleteCategory(other.ID))
+	assert.ErrorIs(t, service.DeleteCategory(other.ID), models.ErrCategoryNotFound)
+}
+
+func TestProductCategoryMustExist(t *testing.T) {
+	categoryRepo := newMemoryCategoryRepository()
+	productRepo := new(MockProductRepository)
+	productService := services.NewProductService(productRepo, categoryRepo, "USD")
+
+	audio := createCategory(t, services.NewCategoryService(categoryRepo, productRepo), "Audio", nil)
+	product := &models.Product{Name: "Earbuds", Price: money.New(4999, "USD"), CategoryID: audio.ID}
+	productRepo.On("Create", product).Return(nil)
+	assert.NoError(t, productService.CreateProduct(product))
+
+	orphan := &models.Product{Name: "Earbuds", Price: money.New(4999, "USD"), CategoryID: primitive.NewObjectID()}
+	assert.ErrorIs(t, productService.CreateProduct(orphan), models.ErrCategoryNotFound)
+	productRepo.AssertNumberOfCalls(t, "Create", 1)
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"ecommerce/pkg/money"
 	"ecommerce/product-service/config"
 	"ecommerce/product-service/models"
 	"ecommerce/product-service/repository"
@@ -41,7 +42,7 @@ func TestProductRepositoryIntegration(t *testing.T) {
 		product := &models.Product{
 			Name:        "Integration Test Product",
 			Description: "Test Description",
-			Price:       100.0,
+			Price:       money.New(10000, "USD"),
 			Stock:       10,
 		}
 
@@ -58,7 +59,7 @@ func TestProductRepositoryIntegration(t *testing.T) {
 		product := &models.Product{
 			Name:        "Product to Update",
 			Description: "Original Description",
-			Price:       100.0,
+			Price:       money.New(10000, "USD"),
 			Stock:       10,
 		}
 
@@ -77,7 +78,7 @@ func TestProductRepositoryIntegration(t *testing.T) {
 	t.Run("Delete Product", func(t *testing.T) {
 		product := &models.Product{
 			Name:  "Product to Delete",
-			Price: 100.0,
+			Price: money.New(10000, "USD"),
 			Stock: 10,
 		}
 
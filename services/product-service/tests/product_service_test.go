@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"ecommerce/pkg/money"
 	"ecommerce/product-service/models"
 	"ecommerce/product-service/services"
 
@@ -49,14 +50,24 @@ func (m *MockProductRepository) UpdateStock(id primitive.ObjectID, quantity int)
 	return args.Error(0)
 }
 
+func (m *MockProductRepository) ListByCategories(categoryIDs []primitive.ObjectID) ([]models.Product, error) {
+	args := m.Called(categoryIDs)
+	return args.Get(0).([]models.Product), args.Error(1)
+}
+
+func (m *MockProductRepository) CountByCategory(categoryID primitive.ObjectID) (int64, error) {
+	args := m.Called(categoryID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestCreateProduct(t *testing.T) {
 	mockRepo := new(MockProductRepository)
-	productService := services.NewProductService(mockRepo)
+	productService := services.NewProductService(mockRepo, newMemoryCategoryRepository(), "USD")
 
 	product := &models.Product{
 		Name:        "Test Product",
 		Description: "Test Description",
-		Price:       100.0,
+		Price:       money.New(10000, "USD"),
 		Stock:       10,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
@@ -71,14 +82,14 @@ func TestCreateProduct(t *testing.T) {
 
 func TestGetProduct(t *testing.T) {
 	mockRepo := new(MockProductRepository)
-	productService := services.NewProductService(mockRepo)
+	productService := services.NewProductService(mockRepo, newMemoryCategoryRepository(), "USD")
 
 	id := primitive.NewObjectID()
 	expectedProduct := &models.Product{
 		ID:          id,
 		Name:        "Test Product",
 		Description: "Test Description",
-		Price:       100.0,
+		Price:       money.New(10000, "USD"),
 		Stock:       10,
 	}
 
@@ -92,7 +103,7 @@ func TestGetProduct(t *testing.T) {
 
 func TestUpdateProductStock(t *testing.T) {
 	mockRepo := new(MockProductRepository)
-	productService := services.NewProductService(mockRepo)
+	productService := services.NewProductService(mockRepo, newMemoryCategoryRepository(), "USD")
 
 	id := primitive.NewObjectID()
 	existingProduct := &models.Product{
@@ -107,3 +118,17 @@ func TestUpdateProductStock(t *testing.T) {
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestCreateProductDefaultsCurrency(t *testing.T) {
+	mockRepo := new(MockProductRepository)
+	productService := services.NewProductService(mockRepo, newMemoryCategoryRepository(), "USD")
+
+	product := &models.Product{Name: "Test Product", Price: money.New(1999, ""), Stock: 1}
+	mockRepo.On("Create", product).Return(nil)
+
+	assert.NoError(t, productService.CreateProduct(product))
+	assert.Equal(t, money.New(1999, "USD"), product.Price)
+
+	negative := &models.Product{Name: "Test Product", Price: money.New(-1, "USD")}
+	assert.Error(t, productService.CreateProduct(negative))
+}
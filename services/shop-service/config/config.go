@@ -7,10 +7,13 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	MongoDB  MongoDBConfig
-	JWT      JWTConfig
-	LogLevel string
+	Server    ServerConfig
+	MongoDB   MongoDBConfig
+	JWT       JWTConfig
+	Events    EventsConfig
+	Webhooks  WebhookConfig
+	Analytics AnalyticsConfig
+	LogLevel  string
 }
 
 type ServerConfig struct {
@@ -30,6 +33,33 @@ type JWTConfig struct {
 	ExpiryHour int
 }
 
+// EventsConfig locates the MongoDB event bus order-service publishes to.
+// It lives on the same server as this service's database.
+type EventsConfig struct {
+	BusDatabase  string
+	PollInterval time.Duration
+}
+
+// WebhookConfig controls how order events are delivered to shop webhooks.
+// A failing delivery is retried after RetryBaseDelay, doubling up to
+// RetryMaxDelay, and dead-lettered after MaxAttempts attempts. Webhooks
+// may only reach loopback and private addresses with AllowPrivateAddresses.
+type WebhookConfig struct {
+	MaxAttempts           int
+	RetryBaseDelay        time.Duration
+	RetryMaxDelay         time.Duration
+	Timeout               time.Duration
+	DispatchInterval      time.Duration
+	AllowPrivateAddresses bool
+}
+
+// AnalyticsConfig controls shop analytics. Reports are cached for CacheTTL
+// and are in DefaultCurrency unless the caller asks for another.
+type AnalyticsConfig struct {
+	CacheTTL        time.Duration
+	DefaultCurrency string
+}
+
 func LoadConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -46,6 +76,22 @@ func LoadConfig() *Config {
 			Secret:     getEnv("JWT_SECRET", "your-secret-key"),
 			ExpiryHour: getEnvAsInt("JWT_EXPIRY_HOUR", 24),
 		},
+		Events: EventsConfig{
+			BusDatabase:  getEnv("EVENT_BUS_DATABASE", "event_bus"),
+			PollInterval: time.Duration(getEnvAsInt("EVENT_POLL_INTERVAL_SECONDS", 2)) * time.Second,
+		},
+		Webhooks: WebhookConfig{
+			MaxAttempts:           getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			RetryBaseDelay:        time.Duration(getEnvAsInt("WEBHOOK_RETRY_BASE_SECONDS", 30)) * time.Second,
+			RetryMaxDelay:         time.Duration(getEnvAsInt("WEBHOOK_RETRY_MAX_SECONDS", 3600)) * time.Second,
+			Timeout:               time.Duration(getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+			DispatchInterval:      time.Duration(getEnvAsInt("WEBHOOK_DISPATCH_INTERVAL_SECONDS", 5)) * time.Second,
+			AllowPrivateAddresses: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", false),
+		},
+		Analytics: AnalyticsConfig{
+			CacheTTL:        time.Duration(getEnvAsInt("ANALYTICS_CACHE_TTL_SECONDS", 300)) * time.Second,
+			DefaultCurrency: getEnv("ANALYTICS_DEFAULT_CURRENCY", "USD"),
+		},
 		LogLevel: getEnv("LOG_LEVEL", "info"),
 	}
 }
@@ -65,3 +111,12 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
@@ -3,6 +3,7 @@ module ecommerce/shop-service
 go 1.21
 
 require (
+	ecommerce/pkg v0.0.0
 	github.com/gin-gonic/gin v1.9.1
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/prometheus/client_golang v1.18.0
@@ -71,3 +72,5 @@ require (
 )
 
 // ... rest of dependencies will be same as product-service
+
+replace ecommerce/pkg => ../../pkg
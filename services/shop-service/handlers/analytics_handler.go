@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce/shop-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AnalyticsHandler struct {
+	analyticsService models.AnalyticsService
+	defaultCurrency  string
+}
+
+func NewAnalyticsHandler(analyticsService models.AnalyticsService, defaultCurrency string) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsService: analyticsService,
+		defaultCurrency:  defaultCurrency,
+	}
+}
+
+// GetShopAnalytics godoc
+// @Summary Get shop analytics
+// @Description Revenue, order count, average order value, cancellation and return rates per day, week or month, with the shop's best-selling products. Only orders in the requested currency are counted. The range is widened to whole periods, and reports are cached for a few minutes.
+// @Tags shops
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param period query string false "Bucket length: day, week or month" default(day)
+// @Param from query string false "Start of the range, RFC 3339 or YYYY-MM-DD; defaults to 30 periods before to"
+// @Param to query string false "End of the range, RFC 3339 or YYYY-MM-DD; defaults to now"
+// @Param currency query string false "Currency of the orders counted"
+// @Param top query int false "Number of top products" default(10)
+// @Success 200 {object} models.ShopAnalytics
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Shop not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/analytics [get]
+func (h *AnalyticsHandler) GetShopAnalytics(c *gin.Context) {
+	shopID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop ID format"})
+		return
+	}
+
+	query := models.AnalyticsQuery{
+		ShopID:   shopID,
+		Period:   models.AnalyticsPeriod(c.DefaultQuery("period", string(models.PeriodDay))),
+		Currency: c.DefaultQuery("currency", h.defaultCurrency),
+	}
+	if query.From, err = parseAnalyticsTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	if query.To, err = parseAnalyticsTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+		return
+	}
+	if top := c.Query("top"); top != "" {
+		if query.TopProducts, err = strconv.Atoi(top); err != nil || query.TopProducts < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid top"})
+			return
+		}
+	}
+
+	analytics, err := h.analyticsService.GetShopAnalytics(query)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidAnalyticsQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrShopNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
+
+// parseAnalyticsTime accepts a timestamp or a date, read as midnight UTC.
+// An empty value is the zero time.
+func parseAnalyticsTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
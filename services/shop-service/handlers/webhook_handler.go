@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce/shop-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type WebhookHandler struct {
+	webhookService models.WebhookService
+}
+
+func NewWebhookHandler(webhookService models.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhook godoc
+// @Summary Create a webhook
+// @Description Subscribe a URL to the shop's order events. The response is the only one that includes the signing secret, which is generated when none is given. The URL must resolve to public addresses; redirects are not followed.
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param webhook body models.WebhookRequest true "Webhook"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Shop not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	shopID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop ID format"})
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(shopID, req)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// ListWebhooks godoc
+// @Summary List webhooks
+// @Description List the shop's webhooks without their secrets
+// @Tags webhooks
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Success 200 {array} models.Webhook
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	shopID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop ID format"})
+		return
+	}
+
+	webhooks, err := h.webhookService.ListWebhooks(shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook
+// @Description Get one of the shop's webhooks without its secret
+// @Tags webhooks
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param webhookId path string true "Webhook ID"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks/{webhookId} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	shopID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(shopID, webhookID)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Change a webhook's URL, event types or active flag, or rotate its secret by sending a new one
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param webhookId path string true "Webhook ID"
+// @Param webhook body models.WebhookRequest true "Webhook"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks/{webhookId} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	shopID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(shopID, webhookID, req)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Delete a webhook; its pending deliveries are dead-lettered
+// @Tags webhooks
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param webhookId path string true "Webhook ID"
+// @Success 200 {object} map[string]interface{} "Success message"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks/{webhookId} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	shopID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(shopID, webhookID); err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description List a webhook's deliveries newest first, each with its attempt log
+// @Tags webhooks
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param webhookId path string true "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, succeeded, dead)
+// @Param limit query int false "Maximum number of deliveries (default 50, max 200)"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks/{webhookId}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	shopID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(shopID, webhookID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver godoc
+// @Summary Redeliver a webhook delivery
+// @Description Send a delivery again now, restarting its retries even if it was dead-lettered
+// @Tags webhooks
+// @Produce  json
+// @Param id path string true "Shop ID"
+// @Param webhookId path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Webhook or delivery not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Security BearerAuth
+// @Router /shops/{id}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	shopID, webhookID, ok := webhookParams(c)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := primitive.ObjectIDFromHex(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID format"})
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(shopID, webhookID, deliveryID)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func webhookParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	shopID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	webhookID, err := primitive.ObjectIDFromHex(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID format"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return shopID, webhookID, true
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidWebhook):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrShopNotFound),
+		errors.Is(err, models.ErrWebhookNotFound),
+		errors.Is(err, models.ErrDeliveryNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
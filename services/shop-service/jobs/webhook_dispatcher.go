@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/shop-service/models"
+
+	"go.uber.org/zap"
+)
+
+// WebhookDispatcher sends the webhook deliveries that are due, first
+// attempts and retries alike.
+type WebhookDispatcher struct {
+	webhookService models.WebhookService
+	interval       time.Duration
+	logger         *zap.Logger
+}
+
+func NewWebhookDispatcher(webhookService models.WebhookService, interval time.Duration, logger *zap.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookService: webhookService,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+func (j *WebhookDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				attempted, err := j.webhookService.DeliverDue(ctx)
+				if err != nil && ctx.Err() == nil {
+					j.logger.Error("Webhook dispatch failed", zap.Int("attempted", attempted), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
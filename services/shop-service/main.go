@@ -9,12 +9,16 @@ import (
 	"syscall"
 	"time"
 
+	"ecommerce/pkg/events"
 	"ecommerce/shop-service/config"
 	"ecommerce/shop-service/handlers"
+	"ecommerce/shop-service/jobs"
 	"ecommerce/shop-service/middleware"
+	"ecommerce/shop-service/models"
 	"ecommerce/shop-service/repository"
 	"ecommerce/shop-service/services"
 	"ecommerce/shop-service/utils"
+	"ecommerce/shop-service/webhooks"
 
 	_ "ecommerce/shop-service/docs"
 
@@ -65,15 +69,64 @@ func main() {
 
 	db := mongoClient.Database(cfg.MongoDB.Database)
 	shopCollection := db.Collection("shops")
+	webhookCollection := db.Collection("webhooks")
+	deliveryCollection := db.Collection("webhook_deliveries")
+	orderFactCollection := db.Collection("order_facts")
+
+	if err := repository.EnsureWebhookIndexes(webhookCollection); err != nil {
+		logger.Fatal("Failed to create webhook indexes", zap.Error(err))
+	}
+	if err := repository.EnsureWebhookDeliveryIndexes(deliveryCollection); err != nil {
+		logger.Fatal("Failed to create webhook delivery indexes", zap.Error(err))
+	}
+	if err := repository.EnsureOrderFactIndexes(orderFactCollection); err != nil {
+		logger.Fatal("Failed to create order fact indexes", zap.Error(err))
+	}
 
 	// Initialize repositories
 	shopRepo := repository.NewMongoShopRepository(shopCollection)
+	webhookRepo := repository.NewMongoWebhookRepository(webhookCollection)
+	deliveryRepo := repository.NewMongoWebhookDeliveryRepository(deliveryCollection)
+	orderFactRepo := repository.NewMongoOrderFactRepository(orderFactCollection)
 
 	// Initialize services
 	shopService := services.NewShopService(shopRepo)
+	webhookService := services.NewWebhookService(
+		webhookRepo,
+		deliveryRepo,
+		shopRepo,
+		&webhooks.Guard{AllowPrivate: cfg.Webhooks.AllowPrivateAddresses},
+		cfg.Webhooks.Timeout,
+		models.RetryPolicy{
+			MaxAttempts: cfg.Webhooks.MaxAttempts,
+			BaseDelay:   cfg.Webhooks.RetryBaseDelay,
+			MaxDelay:    cfg.Webhooks.RetryMaxDelay,
+		},
+	)
+	analyticsService := services.NewAnalyticsService(orderFactRepo, shopRepo, cfg.Analytics.CacheTTL)
+
+	// Queue order events for shop webhooks and deliver them in the
+	// background, and keep the order facts analytics are computed from
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	broker := events.NewMongoBroker(mongoClient.Database(cfg.Events.BusDatabase).Collection("events"), cfg.Events.PollInterval)
+	go func() {
+		if err := broker.Subscribe(jobsCtx, "shop-service.webhooks", webhookService.HandleEvent); err != nil && jobsCtx.Err() == nil {
+			logger.Error("Order event subscription stopped", zap.Error(err))
+		}
+	}()
+	go func() {
+		if err := broker.Subscribe(jobsCtx, "shop-service.analytics", analyticsService.HandleEvent); err != nil && jobsCtx.Err() == nil {
+			logger.Error("Order analytics subscription stopped", zap.Error(err))
+		}
+	}()
+	jobs.NewWebhookDispatcher(webhookService, cfg.Webhooks.DispatchInterval, logger).Start(jobsCtx)
 
 	// Initialize handlers
 	shopHandler := handlers.NewShopHandler(shopService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, cfg.Analytics.DefaultCurrency)
 	healthHandler := handlers.NewHealthHandler()
 
 	// Initialize Gin router
@@ -106,6 +159,19 @@ func main() {
 			shops.DELETE("/:id", middleware.AuthMiddleware(cfg.JWT.Secret), shopHandler.DeleteShop)
 			shops.POST("/:id/warehouses/:warehouseId", middleware.AuthMiddleware(cfg.JWT.Secret), shopHandler.AddWarehouse)
 			shops.DELETE("/:id/warehouses/:warehouseId", middleware.AuthMiddleware(cfg.JWT.Secret), shopHandler.RemoveWarehouse)
+
+			shops.GET("/:id/analytics", middleware.AuthMiddleware(cfg.JWT.Secret), middleware.RequireShopAccess(), analyticsHandler.GetShopAnalytics)
+
+			webhooks := shops.Group("/:id/webhooks", middleware.AuthMiddleware(cfg.JWT.Secret), middleware.RequireShopAccess())
+			{
+				webhooks.POST("/", webhookHandler.CreateWebhook)
+				webhooks.GET("/", webhookHandler.ListWebhooks)
+				webhooks.GET("/:webhookId", webhookHandler.GetWebhook)
+				webhooks.PUT("/:webhookId", webhookHandler.UpdateWebhook)
+				webhooks.DELETE("/:webhookId", webhookHandler.DeleteWebhook)
+				webhooks.GET("/:webhookId/deliveries", webhookHandler.ListDeliveries)
+				webhooks.POST("/:webhookId/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+			}
 		}
 	}
 
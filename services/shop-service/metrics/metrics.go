@@ -38,4 +38,20 @@ var (
 		},
 		[]string{"operation", "shop_id"},
 	)
+
+	WebhookDeliveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shop_service_webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts by outcome",
+		},
+		[]string{"event_type", "result"},
+	)
+
+	AnalyticsRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shop_service_analytics_requests_total",
+			Help: "Total number of shop analytics requests by cache outcome",
+		},
+		[]string{"cache"},
+	)
 )
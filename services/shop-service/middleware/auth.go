@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"ecommerce/shop-service/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func AuthMiddleware(secretKey string) gin.HandlerFunc {
@@ -41,7 +46,61 @@ func AuthMiddleware(secretKey string) gin.HandlerFunc {
 			return
 		}
 
+		principal, err := principalFromClaims(claims)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims["user_id"])
+		c.Set(principalKey, principal)
 		c.Next()
 	}
 }
+
+// principalFromClaims reads the optional roles and shop_ids claims.
+func principalFromClaims(claims jwt.MapClaims) (*models.Principal, error) {
+	principal := &models.Principal{}
+	principal.UserID, _ = claims["user_id"].(string)
+
+	roles, err := stringsClaim(claims, "roles")
+	if err != nil {
+		return nil, err
+	}
+	principal.Roles = roles
+
+	shopIDs, err := stringsClaim(claims, "shop_ids")
+	if err != nil {
+		return nil, err
+	}
+	for _, hex := range shopIDs {
+		shopID, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, errors.New("Invalid shop ID in token")
+		}
+		principal.ShopIDs = append(principal.ShopIDs, shopID)
+	}
+
+	return principal, nil
+}
+
+func stringsClaim(claims jwt.MapClaims, name string) ([]string, error) {
+	raw, exists := claims[name]
+	if !exists {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s in token", name)
+	}
+	values := make([]string, len(list))
+	for i, item := range list {
+		value, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid %s in token", name)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
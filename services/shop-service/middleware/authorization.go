@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+
+	"ecommerce/shop-service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const principalKey = "principal"
+
+// PrincipalFromContext returns the caller set by AuthMiddleware.
+func PrincipalFromContext(c *gin.Context) (*models.Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*models.Principal)
+	return principal, ok
+}
+
+// RequireShopAccess lets the request through only for admins and staff of
+// the shop named by the :id parameter. It must run after AuthMiddleware.
+func RequireShopAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		shopID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop ID format"})
+			c.Abort()
+			return
+		}
+
+		if !principal.IsAdmin() && !principal.IsShopStaff(shopID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to manage this shop"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidAnalyticsQuery = errors.New("invalid analytics query")
+
+const (
+	DefaultTopProducts = 10
+	MaxTopProducts     = 50
+	// MaxAnalyticsBuckets bounds how many periods one query may span.
+	MaxAnalyticsBuckets = 366
+)
+
+// AnalyticsPeriod is the length of the buckets analytics are grouped into.
+// Buckets start at midnight UTC; weeks start on Monday.
+type AnalyticsPeriod string
+
+const (
+	PeriodDay   AnalyticsPeriod = "day"
+	PeriodWeek  AnalyticsPeriod = "week"
+	PeriodMonth AnalyticsPeriod = "month"
+)
+
+func (p AnalyticsPeriod) Valid() bool {
+	return p == PeriodDay || p == PeriodWeek || p == PeriodMonth
+}
+
+// Start returns the start of the bucket t falls in.
+func (p AnalyticsPeriod) Start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// Next returns the start of the bucket after the one starting at start.
+func (p AnalyticsPeriod) Next(start time.Time) time.Time {
+	switch p {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Order statuses as order-service publishes them. Orders count towards
+// revenue from payment until they are refunded.
+var RevenueOrderStatuses = []string{"paid", "processing", "shipped", "delivered", "completed"}
+
+const (
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// OrderFact is this service's copy of an order, kept current from
+// order-service's events and aggregated for shop analytics. Item revenue
+// is the line total before order-level discounts.
+type OrderFact struct {
+	OrderID   primitive.ObjectID `bson:"_id" json:"order_id"`
+	ShopID    primitive.ObjectID `bson:"shop_id" json:"shop_id"`
+	Status    string             `bson:"status" json:"status"`
+	Items     []OrderFactItem    `bson:"items" json:"items"`
+	Total     money.Money        `bson:"total" json:"total"`
+	Version   int                `bson:"version" json:"version"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type OrderFactItem struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Name      string             `bson:"name" json:"name"`
+	Quantity  int                `bson:"quantity" json:"quantity"`
+	Revenue   money.Money        `bson:"revenue" json:"revenue"`
+}
+
+// AnalyticsQuery selects a shop's orders created in [From, To) in
+// Currency. Orders in other currencies are left out rather than
+// converted.
+type AnalyticsQuery struct {
+	ShopID      primitive.ObjectID
+	Period      AnalyticsPeriod
+	From        time.Time
+	To          time.Time
+	Currency    string
+	TopProducts int
+}
+
+// AnalyticsBucket summarizes the orders created in one period, or in the
+// whole range for a report's totals. Paid orders are those counted in
+// revenue; the return rate is the share of orders paid for that were
+// refunded.
+type AnalyticsBucket struct {
+	Start             *time.Time  `json:"start,omitempty"`
+	Orders            int64       `json:"orders"`
+	PaidOrders        int64       `json:"paid_orders"`
+	CancelledOrders   int64       `json:"cancelled_orders"`
+	ReturnedOrders    int64       `json:"returned_orders"`
+	Revenue           money.Money `json:"revenue"`
+	AverageOrderValue money.Money `json:"average_order_value"`
+	CancellationRate  float64     `json:"cancellation_rate"`
+	ReturnRate        float64     `json:"return_rate"`
+}
+
+// ProductSales is a product's share of a shop's paid orders.
+type ProductSales struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	Name      string             `json:"name"`
+	Quantity  int64              `json:"quantity"`
+	Revenue   money.Money        `json:"revenue"`
+}
+
+type ShopAnalytics struct {
+	ShopID      primitive.ObjectID `json:"shop_id"`
+	Period      AnalyticsPeriod    `json:"period"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Currency    string             `json:"currency"`
+	Totals      AnalyticsBucket    `json:"totals"`
+	Buckets     []AnalyticsBucket  `json:"buckets"`
+	TopProducts []ProductSales     `json:"top_products"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+type OrderFactRepository interface {
+	// Upsert stores the fact unless a fact of the same or a later version
+	// of the order is already stored.
+	Upsert(fact *OrderFact) error
+	// Buckets returns the counts and revenue of each period that has
+	// orders, oldest first. Averages and rates are left for the caller.
+	Buckets(query AnalyticsQuery) ([]AnalyticsBucket, error)
+	// TopProducts returns the products with the most revenue in the
+	// query's paid orders.
+	TopProducts(query AnalyticsQuery) ([]ProductSales, error)
+}
+
+type AnalyticsService interface {
+	GetShopAnalytics(query AnalyticsQuery) (*ShopAnalytics, error)
+	// HandleEvent records the order carried by an order event.
+	HandleEvent(ctx context.Context, event events.Event) error
+}
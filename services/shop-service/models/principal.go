@@ -0,0 +1,43 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleShopStaff = "shop_staff"
+)
+
+// Principal is the authenticated caller as described by its JWT claims.
+type Principal struct {
+	UserID  string
+	Roles   []string
+	ShopIDs []primitive.ObjectID
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// IsShopStaff reports whether the caller works for the given shop.
+func (p *Principal) IsShopStaff(shopID primitive.ObjectID) bool {
+	if !p.HasRole(RoleShopStaff) {
+		return false
+	}
+	for _, id := range p.ShopIDs {
+		if id == shopID {
+			return true
+		}
+	}
+	return false
+}
@@ -1,20 +1,54 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var ErrShopNotFound = errors.New("shop not found")
+
+// Sourcing strategies order-service uses to pick the warehouses an order
+// ships from. Warehouses are tried in the order they are listed on the shop.
+const (
+	SourcingSingleWarehouseFirst = "single_warehouse_first"
+	SourcingMinimizeSplits       = "minimize_splits"
+	SourcingPriority             = "priority"
+)
+
 type Shop struct {
-	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
-	Name        string               `bson:"name" json:"name"`
-	Description string               `bson:"description" json:"description"`
-	Location    string               `bson:"location" json:"location"`
-	Status      string               `bson:"status" json:"status"` // active, inactive
-	Warehouses  []primitive.ObjectID `bson:"warehouses" json:"warehouses"`
-	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`
+	ID                         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name                       string               `bson:"name" json:"name"`
+	Description                string               `bson:"description" json:"description"`
+	Location                   string               `bson:"location" json:"location"`
+	Status                     string               `bson:"status" json:"status"` // active, inactive
+	Warehouses                 []primitive.ObjectID `bson:"warehouses" json:"warehouses"`
+	SourcingStrategy           string               `bson:"sourcing_strategy,omitempty" json:"sourcing_strategy,omitempty"`
+	PendingOrderTimeoutMinutes int                  `bson:"pending_order_timeout_minutes,omitempty" json:"pending_order_timeout_minutes,omitempty"` // 0 uses order-service's default
+	CreatedAt                  time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt                  time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// ValidSourcingStrategy reports whether strategy is empty, meaning
+// order-service's default, or one of the known strategies.
+func ValidSourcingStrategy(strategy string) bool {
+	switch strategy {
+	case "", SourcingSingleWarehouseFirst, SourcingMinimizeSplits, SourcingPriority:
+		return true
+	default:
+		return false
+	}
+}
+
+// MinPendingOrderTimeoutMinutes is the shortest pending order timeout a
+// shop may set. order-service only looks at orders older than this.
+const MinPendingOrderTimeoutMinutes = 5
+
+// ValidPendingOrderTimeout reports whether minutes is zero, meaning
+// order-service's default, or at least MinPendingOrderTimeoutMinutes.
+func ValidPendingOrderTimeout(minutes int) bool {
+	return minutes == 0 || minutes >= MinPendingOrderTimeoutMinutes
 }
 
 func NewShop() *Shop {
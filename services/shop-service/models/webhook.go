@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ecommerce/pkg/events"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// Order events published by order-service that shops can subscribe to.
+var WebhookEventTypes = []string{
+	"order.created",
+	"order.updated",
+	"order.paid",
+	"order.processing",
+	"order.shipped",
+	"order.delivered",
+	"order.completed",
+	"order.cancelled",
+	"order.refunded",
+}
+
+// ValidWebhookEventType reports whether shops can subscribe to eventType.
+func ValidWebhookEventType(eventType string) bool {
+	for _, known := range WebhookEventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is a shop's subscription to order events. Each matching event is
+// POSTed to URL as JSON and signed with Secret.
+type Webhook struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ShopID     primitive.ObjectID `bson:"shop_id" json:"shop_id"`
+	URL        string             `bson:"url" json:"url"`
+	Secret     string             `bson:"secret" json:"secret,omitempty"`
+	EventTypes []string           `bson:"event_types" json:"event_types"`
+	Active     bool               `bson:"active" json:"active"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, subscribed := range w.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookRequest creates or updates a webhook. A missing secret is
+// generated on creation and left unchanged on update; a missing active
+// flag means active.
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types" binding:"required"`
+	Active     *bool    `json:"active"`
+}
+
+// Delivery states. A pending delivery waits for its next attempt; one that
+// keeps failing is dead-lettered and only sent again when redelivered.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one event on its way to one webhook, with the log of
+// every attempt to send it.
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID     primitive.ObjectID `bson:"webhook_id" json:"webhook_id"`
+	ShopID        primitive.ObjectID `bson:"shop_id" json:"shop_id"`
+	Event         events.Event       `bson:"event" json:"event"`
+	Status        string             `bson:"status" json:"status"`
+	Failures      int                `bson:"failures" json:"failures"`
+	NextAttemptAt *time.Time         `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	Attempts      []DeliveryAttempt  `bson:"attempts" json:"attempts"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// DeliveryAttempt records one POST to the webhook URL. StatusCode is zero
+// when no response was received.
+type DeliveryAttempt struct {
+	At         time.Time     `bson:"at" json:"at"`
+	StatusCode int           `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration"`
+}
+
+// RetryPolicy spaces out the attempts of a failing delivery: the delay
+// doubles after every failure from BaseDelay up to MaxDelay, and the
+// delivery is dead-lettered after MaxAttempts failures.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay returns how long to wait after the given number of consecutive
+// failures.
+func (p RetryPolicy) Delay(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+type WebhookRepository interface {
+	Create(webhook *Webhook) error
+	GetByID(id primitive.ObjectID) (*Webhook, error)
+	ListByShop(shopID primitive.ObjectID) ([]Webhook, error)
+	// ListSubscribed returns the shop's active webhooks subscribed to the
+	// event type.
+	ListSubscribed(shopID primitive.ObjectID, eventType string) ([]Webhook, error)
+	Update(webhook *Webhook) error
+	Delete(id primitive.ObjectID) error
+}
+
+type WebhookDeliveryRepository interface {
+	// Create stores a pending delivery. Creating a second delivery of the
+	// same event to the same webhook is not an error and stores nothing.
+	Create(delivery *WebhookDelivery) error
+	GetByID(id primitive.ObjectID) (*WebhookDelivery, error)
+	ListByWebhook(webhookID primitive.ObjectID, status string, limit int) ([]WebhookDelivery, error)
+	// ClaimDue returns a pending delivery whose next attempt is due and
+	// holds it back from other callers for lease, or ErrDeliveryNotFound.
+	ClaimDue(now time.Time, lease time.Duration) (*WebhookDelivery, error)
+	Update(delivery *WebhookDelivery) error
+}
+
+type WebhookService interface {
+	CreateWebhook(shopID primitive.ObjectID, req WebhookRequest) (*Webhook, error)
+	GetWebhook(shopID, id primitive.ObjectID) (*Webhook, error)
+	ListWebhooks(shopID primitive.ObjectID) ([]Webhook, error)
+	UpdateWebhook(shopID, id primitive.ObjectID, req WebhookRequest) (*Webhook, error)
+	DeleteWebhook(shopID, id primitive.ObjectID) error
+	ListDeliveries(shopID, webhookID primitive.ObjectID, status string, limit int) ([]WebhookDelivery, error)
+	// Redeliver schedules a delivery, dead or not, to be sent again now.
+	Redeliver(shopID, webhookID, deliveryID primitive.ObjectID) (*WebhookDelivery, error)
+	// HandleEvent queues the event for every webhook of the order's shop
+	// that subscribes to it.
+	HandleEvent(ctx context.Context, event events.Event) error
+	// DeliverDue sends every delivery that is due and returns how many
+	// were attempted.
+	DeliverDue(ctx context.Context) (int, error)
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/pkg/money"
+	"ecommerce/shop-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoOrderFactRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoOrderFactRepository(db *mongo.Collection) models.OrderFactRepository {
+	return &mongoOrderFactRepository{
+		db: db,
+	}
+}
+
+// EnsureOrderFactIndexes supports selecting a shop's orders by creation
+// time and currency.
+func EnsureOrderFactIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "shop_id", Value: 1}, {Key: "total.currency", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	return err
+}
+
+// Upsert only matches a stored fact of an earlier version, so a stale
+// event falls through to an insert that collides on _id and is dropped.
+func (r *mongoOrderFactRepository) Upsert(fact *models.OrderFact) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fact.UpdatedAt = time.Now()
+
+	_, err := r.db.ReplaceOne(ctx,
+		bson.M{"_id": fact.OrderID, "version": bson.M{"$lt": fact.Version}},
+		fact,
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoOrderFactRepository) Buckets(query models.AnalyticsQuery) ([]models.AnalyticsBucket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	paid := bson.M{"$in": bson.A{"$status", models.RevenueOrderStatuses}}
+	count := func(condition interface{}) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{condition, 1, 0}}}
+	}
+
+	cursor, err := r.db.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: matchQuery(query)}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateTrunc": bson.M{
+				"date":        "$created_at",
+				"unit":        string(query.Period),
+				"timezone":    "UTC",
+				"startOfWeek": "monday",
+			}},
+			"orders":    bson.M{"$sum": 1},
+			"paid":      count(paid),
+			"cancelled": count(bson.M{"$eq": bson.A{"$status", models.OrderStatusCancelled}}),
+			"returned":  count(bson.M{"$eq": bson.A{"$status", models.OrderStatusRefunded}}),
+			"revenue":   bson.M{"$sum": bson.M{"$cond": bson.A{paid, "$total.amount", 0}}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Start     time.Time `bson:"_id"`
+		Orders    int64     `bson:"orders"`
+		Paid      int64     `bson:"paid"`
+		Cancelled int64     `bson:"cancelled"`
+		Returned  int64     `bson:"returned"`
+		Revenue   int64     `bson:"revenue"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]models.AnalyticsBucket, len(rows))
+	for i, row := range rows {
+		start := row.Start.UTC()
+		buckets[i] = models.AnalyticsBucket{
+			Start:           &start,
+			Orders:          row.Orders,
+			PaidOrders:      row.Paid,
+			CancelledOrders: row.Cancelled,
+			ReturnedOrders:  row.Returned,
+			Revenue:         money.New(row.Revenue, query.Currency),
+		}
+	}
+	return buckets, nil
+}
+
+func (r *mongoOrderFactRepository) TopProducts(query models.AnalyticsQuery) ([]models.ProductSales, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	match := matchQuery(query)
+	match["status"] = bson.M{"$in": models.RevenueOrderStatuses}
+
+	cursor, err := r.db.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$items"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$items.product_id",
+			"name":     bson.M{"$last": "$items.name"},
+			"quantity": bson.M{"$sum": "$items.quantity"},
+			"revenue":  bson.M{"$sum": "$items.revenue.amount"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "revenue", Value: -1}, {Key: "quantity", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: query.TopProducts}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ProductID primitive.ObjectID `bson:"_id"`
+		Name      string             `bson:"name"`
+		Quantity  int64              `bson:"quantity"`
+		Revenue   int64              `bson:"revenue"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	products := make([]models.ProductSales, len(rows))
+	for i, row := range rows {
+		products[i] = models.ProductSales{
+			ProductID: row.ProductID,
+			Name:      row.Name,
+			Quantity:  row.Quantity,
+			Revenue:   money.New(row.Revenue, query.Currency),
+		}
+	}
+	return products, nil
+}
+
+func matchQuery(query models.AnalyticsQuery) bson.M {
+	return bson.M{
+		"shop_id":        query.ShopID,
+		"total.currency": query.Currency,
+		"created_at":     bson.M{"$gte": query.From, "$lt": query.To},
+	}
+}
@@ -45,6 +45,9 @@ func (r *mongoShopRepository) GetByID(id primitive.ObjectID) (*models.Shop, erro
 
 	var shop models.Shop
 	err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&shop)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrShopNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
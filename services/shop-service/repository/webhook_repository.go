@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce/shop-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoWebhookRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoWebhookRepository(db *mongo.Collection) models.WebhookRepository {
+	return &mongoWebhookRepository{
+		db: db,
+	}
+}
+
+// EnsureWebhookIndexes supports looking up a shop's webhooks by event type.
+func EnsureWebhookIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "shop_id", Value: 1}, {Key: "event_types", Value: 1}},
+	})
+	return err
+}
+
+func (r *mongoWebhookRepository) Create(webhook *models.Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+
+	result, err := r.db.InsertOne(ctx, webhook)
+	if err != nil {
+		return err
+	}
+
+	webhook.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoWebhookRepository) GetByID(id primitive.ObjectID) (*models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var webhook models.Webhook
+	err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (r *mongoWebhookRepository) ListByShop(shopID primitive.ObjectID) ([]models.Webhook, error) {
+	return r.find(bson.M{"shop_id": shopID})
+}
+
+func (r *mongoWebhookRepository) ListSubscribed(shopID primitive.ObjectID, eventType string) ([]models.Webhook, error) {
+	return r.find(bson.M{"shop_id": shopID, "event_types": eventType, "active": true})
+}
+
+func (r *mongoWebhookRepository) find(filter bson.M) ([]models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.db.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []models.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *mongoWebhookRepository) Update(webhook *models.Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	webhook.UpdatedAt = time.Now()
+
+	result, err := r.db.UpdateOne(ctx,
+		bson.M{"_id": webhook.ID},
+		bson.M{"$set": bson.M{
+			"url":         webhook.URL,
+			"secret":      webhook.Secret,
+			"event_types": webhook.EventTypes,
+			"active":      webhook.Active,
+			"updated_at":  webhook.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *mongoWebhookRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.db.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+type mongoWebhookDeliveryRepository struct {
+	db *mongo.Collection
+}
+
+func NewMongoWebhookDeliveryRepository(db *mongo.Collection) models.WebhookDeliveryRepository {
+	return &mongoWebhookDeliveryRepository{
+		db: db,
+	}
+}
+
+// EnsureWebhookDeliveryIndexes queues each event once per webhook, finds
+// due deliveries, and lists a webhook's deliveries newest first.
+func EnsureWebhookDeliveryIndexes(db *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "event._id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"status": models.DeliveryPending}),
+		},
+		{
+			Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+func (r *mongoWebhookDeliveryRepository) Create(delivery *models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = delivery.CreatedAt
+
+	result, err := r.db.InsertOne(ctx, delivery)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	delivery.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoWebhookDeliveryRepository) GetByID(id primitive.ObjectID) (*models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var delivery models.WebhookDelivery
+	err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (r *mongoWebhookDeliveryRepository) ListByWebhook(webhookID primitive.ObjectID, status string, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"webhook_id": webhookID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := r.db.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDue pushes the claimed delivery's next attempt back by lease, so
+// another instance only picks it up if this one dies before recording the
+// outcome.
+func (r *mongoWebhookDeliveryRepository) ClaimDue(now time.Time, lease time.Duration) (*models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var delivery models.WebhookDelivery
+	err := r.db.FindOneAndUpdate(ctx,
+		bson.M{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.Before),
+	).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (r *mongoWebhookDeliveryRepository) Update(delivery *models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	delivery.UpdatedAt = time.Now()
+
+	set := bson.M{
+		"status":     delivery.Status,
+		"failures":   delivery.Failures,
+		"attempts":   delivery.Attempts,
+		"updated_at": delivery.UpdatedAt,
+	}
+	update := bson.M{"$set": set}
+	if delivery.NextAttemptAt != nil {
+		set["next_attempt_at"] = *delivery.NextAttemptAt
+	} else {
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+	}
+
+	result, err := r.db.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrDeliveryNotFound
+	}
+	return nil
+}
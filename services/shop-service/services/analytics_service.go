@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/money"
+	"ecommerce/shop-service/metrics"
+	"ecommerce/shop-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultAnalyticsBuckets is how many periods a query without a start
+// covers, ending with the current one.
+const defaultAnalyticsBuckets = 30
+
+type analyticsService struct {
+	factRepo models.OrderFactRepository
+	shopRepo models.ShopRepository
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedAnalytics
+}
+
+type cachedAnalytics struct {
+	analytics *models.ShopAnalytics
+	expires   time.Time
+}
+
+// NewAnalyticsService computes shop analytics from the order facts and
+// keeps each report for ttl. A zero ttl disables the cache.
+func NewAnalyticsService(factRepo models.OrderFactRepository, shopRepo models.ShopRepository, ttl time.Duration) models.AnalyticsService {
+	return &analyticsService{
+		factRepo: factRepo,
+		shopRepo: shopRepo,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    make(map[string]cachedAnalytics),
+	}
+}
+
+// GetShopAnalytics widens the range to whole periods, so the first and
+// last buckets are not partial, and reports every period in it, empty or
+// not.
+func (s *analyticsService) GetShopAnalytics(query models.AnalyticsQuery) (*models.ShopAnalytics, error) {
+	if err := s.normalizeQuery(&query); err != nil {
+		return nil, err
+	}
+
+	key := analyticsCacheKey(query)
+	if analytics, ok := s.cached(key); ok {
+		metrics.AnalyticsRequests.WithLabelValues("hit").Inc()
+		return analytics, nil
+	}
+	metrics.AnalyticsRequests.WithLabelValues("miss").Inc()
+
+	if _, err := s.shopRepo.GetByID(query.ShopID); err != nil {
+		return nil, err
+	}
+
+	found, err := s.factRepo.Buckets(query)
+	if err != nil {
+		return nil, err
+	}
+	topProducts, err := s.factRepo.TopProducts(query)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &models.ShopAnalytics{
+		ShopID:      query.ShopID,
+		Period:      query.Period,
+		From:        query.From,
+		To:          query.To,
+		Currency:    query.Currency,
+		Totals:      models.AnalyticsBucket{Revenue: money.Zero(query.Currency)},
+		Buckets:     []models.AnalyticsBucket{},
+		TopProducts: topProducts,
+		GeneratedAt: s.now().UTC(),
+	}
+	if analytics.TopProducts == nil {
+		analytics.TopProducts = []models.ProductSales{}
+	}
+
+	byStart := make(map[time.Time]models.AnalyticsBucket, len(found))
+	for _, bucket := range found {
+		byStart[bucket.Start.UTC()] = bucket
+	}
+	for start := query.From; start.Before(query.To); start = query.Period.Next(start) {
+		bucket, ok := byStart[start]
+		if !ok {
+			bucket = models.AnalyticsBucket{Revenue: money.Zero(query.Currency)}
+		}
+		bucketStart := start
+		bucket.Start = &bucketStart
+		finishBucket(&bucket)
+		analytics.Buckets = append(analytics.Buckets, bucket)
+
+		totals := &analytics.Totals
+		totals.Orders += bucket.Orders
+		totals.PaidOrders += bucket.PaidOrders
+		totals.CancelledOrders += bucket.CancelledOrders
+		totals.ReturnedOrders += bucket.ReturnedOrders
+		totals.Revenue = totals.Revenue.Add(bucket.Revenue)
+	}
+	finishBucket(&analytics.Totals)
+
+	s.store(key, analytics)
+	return analytics, nil
+}
+
+// normalizeQuery fills in the defaults and aligns the range to whole
+// periods. Without a start the range covers defaultAnalyticsBuckets
+// periods; without an end it runs to the end of the current period.
+func (s *analyticsService) normalizeQuery(query *models.AnalyticsQuery) error {
+	if query.Period == "" {
+		query.Period = models.PeriodDay
+	}
+	if !query.Period.Valid() {
+		return fmt.Errorf("%w: unknown period %q", models.ErrInvalidAnalyticsQuery, query.Period)
+	}
+
+	query.Currency = strings.ToUpper(query.Currency)
+	if !money.ValidCurrency(query.Currency) {
+		return fmt.Errorf("%w: invalid currency %q", models.ErrInvalidAnalyticsQuery, query.Currency)
+	}
+
+	if query.TopProducts == 0 {
+		query.TopProducts = models.DefaultTopProducts
+	}
+	if query.TopProducts < 0 || query.TopProducts > models.MaxTopProducts {
+		return fmt.Errorf("%w: top must be between 1 and %d", models.ErrInvalidAnalyticsQuery, models.MaxTopProducts)
+	}
+
+	to := query.To
+	if to.IsZero() {
+		to = s.now()
+	}
+	query.To = query.Period.Start(to)
+	if !query.To.Equal(to) {
+		query.To = query.Period.Next(query.To)
+	}
+
+	if query.From.IsZero() {
+		query.From = query.To
+		for i := 0; i < defaultAnalyticsBuckets; i++ {
+			query.From = query.Period.Start(query.From.AddDate(0, 0, -1))
+		}
+	} else {
+		query.From = query.Period.Start(query.From)
+	}
+	if !query.From.Before(query.To) {
+		return fmt.Errorf("%w: from must be before to", models.ErrInvalidAnalyticsQuery)
+	}
+
+	buckets := 0
+	for start := query.From; start.Before(query.To); start = query.Period.Next(start) {
+		if buckets++; buckets > models.MaxAnalyticsBuckets {
+			return fmt.Errorf("%w: range spans more than %d periods", models.ErrInvalidAnalyticsQuery, models.MaxAnalyticsBuckets)
+		}
+	}
+	return nil
+}
+
+// finishBucket derives the average order value and rates from a bucket's
+// counts and revenue.
+func finishBucket(bucket *models.AnalyticsBucket) {
+	bucket.AverageOrderValue = money.Zero(bucket.Revenue.Currency)
+	if bucket.PaidOrders > 0 {
+		bucket.AverageOrderValue = money.New(roundDiv(bucket.Revenue.Amount, bucket.PaidOrders), bucket.Revenue.Currency)
+	}
+	bucket.CancellationRate = rate(bucket.CancelledOrders, bucket.Orders)
+	bucket.ReturnRate = rate(bucket.ReturnedOrders, bucket.PaidOrders+bucket.ReturnedOrders)
+}
+
+// roundDiv divides rounding half away from zero.
+func roundDiv(amount, count int64) int64 {
+	if amount < 0 {
+		return -roundDiv(-amount, count)
+	}
+	return (amount + count/2) / count
+}
+
+func rate(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+func analyticsCacheKey(query models.AnalyticsQuery) string {
+	return fmt.Sprintf("%s|%s|%d|%d|%s|%d",
+		query.ShopID.Hex(), query.Period, query.From.Unix(), query.To.Unix(), query.Currency, query.TopProducts)
+}
+
+func (s *analyticsService) cached(key string) (*models.ShopAnalytics, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.analytics, true
+}
+
+// store caches the report and drops the expired ones, so reports no one
+// asks for again do not pile up.
+func (s *analyticsService) store(key string, analytics *models.ShopAnalytics) {
+	if s.ttl <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for cachedKey, entry := range s.cache {
+		if !now.Before(entry.expires) {
+			delete(s.cache, cachedKey)
+		}
+	}
+	s.cache[key] = cachedAnalytics{analytics: analytics, expires: now.Add(s.ttl)}
+}
+
+// HandleEvent ignores events that do not carry an order of a shop, such
+// as events from other services sharing the bus. Events may arrive out of
+// order; the repository keeps the latest version of each order.
+func (s *analyticsService) HandleEvent(ctx context.Context, event events.Event) error {
+	if !models.ValidWebhookEventType(event.Type) {
+		return nil
+	}
+
+	var payload struct {
+		OrderID primitive.ObjectID `json:"order_id"`
+		ShopID  primitive.ObjectID `json:"shop_id"`
+		Status  string             `json:"status"`
+		Items   []struct {
+			ProductID primitive.ObjectID `json:"product_id"`
+			Name      string             `json:"name"`
+			Quantity  int                `json:"quantity"`
+			Price     money.Money        `json:"price"`
+		} `json:"items"`
+		Total     money.Money `json:"total"`
+		Version   int         `json:"version"`
+		CreatedAt time.Time   `json:"created_at"`
+	}
+	if err := event.Data.Decode(&payload); err != nil || payload.OrderID.IsZero() || payload.ShopID.IsZero() {
+		return nil
+	}
+
+	fact := &models.OrderFact{
+		OrderID:   payload.OrderID,
+		ShopID:    payload.ShopID,
+		Status:    payload.Status,
+		Items:     make([]models.OrderFactItem, len(payload.Items)),
+		Total:     payload.Total,
+		Version:   payload.Version,
+		CreatedAt: payload.CreatedAt.UTC(),
+	}
+	for i, item := range payload.Items {
+		fact.Items[i] = models.OrderFactItem{
+			ProductID: item.ProductID,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			Revenue:   item.Price.Mul(int64(item.Quantity)),
+		}
+	}
+
+	return s.factRepo.Upsert(fact)
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"fmt"
 
 	"ecommerce/shop-service/models"
 
@@ -25,6 +26,12 @@ func (s *shopService) CreateShop(shop *models.Shop) error {
 	if shop.Location == "" {
 		return errors.New("shop location is required")
 	}
+	if !models.ValidSourcingStrategy(shop.SourcingStrategy) {
+		return errors.New("unknown sourcing strategy")
+	}
+	if !models.ValidPendingOrderTimeout(shop.PendingOrderTimeoutMinutes) {
+		return fmt.Errorf("pending order timeout must be 0 or at least %d minutes", models.MinPendingOrderTimeoutMinutes)
+	}
 
 	return s.shopRepo.Create(shop)
 }
@@ -51,6 +58,12 @@ func (s *shopService) UpdateShop(shop *models.Shop) error {
 	if shop.Location == "" {
 		return errors.New("shop location is required")
 	}
+	if !models.ValidSourcingStrategy(shop.SourcingStrategy) {
+		return errors.New("unknown sourcing strategy")
+	}
+	if !models.ValidPendingOrderTimeout(shop.PendingOrderTimeoutMinutes) {
+		return fmt.Errorf("pending order timeout must be 0 or at least %d minutes", models.MinPendingOrderTimeoutMinutes)
+	}
 
 	return s.shopRepo.Update(shop)
 }
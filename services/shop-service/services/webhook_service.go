@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecommerce/pkg/events"
+	"ecommerce/shop-service/metrics"
+	"ecommerce/shop-service/models"
+	"ecommerce/shop-service/webhooks"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxResponseBody bounds how much of a webhook response is read before the
+// connection is reused; the body itself is ignored.
+const maxResponseBody = 64 << 10
+
+// resolveTimeout bounds the lookup of a webhook's host when it is
+// registered.
+const resolveTimeout = 5 * time.Second
+
+type webhookService struct {
+	webhookRepo  models.WebhookRepository
+	deliveryRepo models.WebhookDeliveryRepository
+	shopRepo     models.ShopRepository
+	guard        *webhooks.Guard
+	client       *http.Client
+	policy       models.RetryPolicy
+}
+
+// NewWebhookService delivers webhooks with requests that time out after
+// timeout and only reach addresses the guard allows.
+func NewWebhookService(
+	webhookRepo models.WebhookRepository,
+	deliveryRepo models.WebhookDeliveryRepository,
+	shopRepo models.ShopRepository,
+	guard *webhooks.Guard,
+	timeout time.Duration,
+	policy models.RetryPolicy,
+) models.WebhookService {
+	return &webhookService{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		shopRepo:     shopRepo,
+		guard:        guard,
+		client:       guard.Client(timeout),
+		policy:       policy,
+	}
+}
+
+func (s *webhookService) CreateWebhook(shopID primitive.ObjectID, req models.WebhookRequest) (*models.Webhook, error) {
+	if err := s.validateWebhookRequest(req); err != nil {
+		return nil, err
+	}
+	if _, err := s.shopRepo.GetByID(shopID); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := webhooks.NewSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	webhook := &models.Webhook{
+		ShopID:     shopID,
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: uniqueEventTypes(req.EventTypes),
+		Active:     req.Active == nil || *req.Active,
+	}
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (s *webhookService) GetWebhook(shopID, id primitive.ObjectID) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	// Webhooks of other shops are reported as missing so IDs cannot be
+	// probed through a shop the caller manages
+	if webhook.ShopID != shopID {
+		return nil, models.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) ListWebhooks(shopID primitive.ObjectID) ([]models.Webhook, error) {
+	return s.webhookRepo.ListByShop(shopID)
+}
+
+func (s *webhookService) UpdateWebhook(shopID, id primitive.ObjectID, req models.WebhookRequest) (*models.Webhook, error) {
+	if err := s.validateWebhookRequest(req); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.GetWebhook(shopID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.URL = req.URL
+	webhook.EventTypes = uniqueEventTypes(req.EventTypes)
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	if err := s.webhookRepo.Update(webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (s *webhookService) DeleteWebhook(shopID, id primitive.ObjectID) error {
+	if _, err := s.GetWebhook(shopID, id); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(id)
+}
+
+func (s *webhookService) ListDeliveries(shopID, webhookID primitive.ObjectID, status string, limit int) ([]models.WebhookDelivery, error) {
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: unknown delivery status %q", models.ErrInvalidWebhook, status)
+	}
+	if _, err := s.GetWebhook(shopID, webhookID); err != nil {
+		return nil, err
+	}
+	return s.deliveryRepo.ListByWebhook(webhookID, status, limit)
+}
+
+// Redeliver starts the delivery's retries over, so a dead-lettered
+// delivery gets the full number of attempts again. Its attempt log is
+// kept.
+func (s *webhookService) Redeliver(shopID, webhookID, deliveryID primitive.ObjectID) (*models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(shopID, webhookID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.deliveryRepo.GetByID(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, models.ErrDeliveryNotFound
+	}
+
+	now := time.Now()
+	delivery.Status = models.DeliveryPending
+	delivery.Failures = 0
+	delivery.NextAttemptAt = &now
+	if err := s.deliveryRepo.Update(delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// HandleEvent ignores events that do not name a shop, such as events from
+// other services sharing the bus.
+func (s *webhookService) HandleEvent(ctx context.Context, event events.Event) error {
+	if !models.ValidWebhookEventType(event.Type) {
+		return nil
+	}
+
+	var payload struct {
+		ShopID primitive.ObjectID `json:"shop_id"`
+	}
+	if err := event.Data.Decode(&payload); err != nil || payload.ShopID.IsZero() {
+		return nil
+	}
+
+	subscribed, err := s.webhookRepo.ListSubscribed(payload.ShopID, event.Type)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range subscribed {
+		delivery := &models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			ShopID:        webhook.ShopID,
+			Event:         event,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+			Attempts:      []models.DeliveryAttempt{},
+		}
+		if err := s.deliveryRepo.Create(delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *webhookService) DeliverDue(ctx context.Context) (int, error) {
+	// Hold a claimed delivery long enough for the request to time out
+	lease := time.Minute
+	if s.client.Timeout > 0 && 2*s.client.Timeout > lease {
+		lease = 2 * s.client.Timeout
+	}
+
+	attempted := 0
+	for ctx.Err() == nil {
+		delivery, err := s.deliveryRepo.ClaimDue(time.Now(), lease)
+		if err == models.ErrDeliveryNotFound {
+			return attempted, nil
+		}
+		if err != nil {
+			return attempted, err
+		}
+
+		if err := s.attempt(ctx, delivery); err != nil {
+			return attempted, err
+		}
+		attempted++
+	}
+	return attempted, ctx.Err()
+}
+
+// attempt sends the delivery once and records the outcome. Deliveries to
+// webhooks that were deleted or disabled since are dead-lettered without
+// being sent.
+func (s *webhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	webhook, err := s.webhookRepo.GetByID(delivery.WebhookID)
+	if err != nil && err != models.ErrWebhookNotFound {
+		return err
+	}
+
+	var record models.DeliveryAttempt
+	switch {
+	case webhook == nil:
+		record = models.DeliveryAttempt{At: time.Now(), Error: "webhook was deleted"}
+	case !webhook.Active:
+		record = models.DeliveryAttempt{At: time.Now(), Error: "webhook is disabled"}
+	default:
+		record = s.send(ctx, webhook, delivery)
+	}
+	delivery.Attempts = append(delivery.Attempts, record)
+
+	switch {
+	case record.Error == "":
+		delivery.Status = models.DeliverySucceeded
+		delivery.NextAttemptAt = nil
+	case webhook == nil || !webhook.Active || delivery.Failures+1 >= s.policy.MaxAttempts:
+		delivery.Failures++
+		delivery.Status = models.DeliveryDead
+		delivery.NextAttemptAt = nil
+	default:
+		delivery.Failures++
+		next := record.At.Add(s.policy.Delay(delivery.Failures))
+		delivery.NextAttemptAt = &next
+	}
+	result := delivery.Status
+	if result == models.DeliveryPending {
+		result = "retrying"
+	}
+	metrics.WebhookDeliveries.WithLabelValues(delivery.Event.Type, result).Inc()
+
+	return s.deliveryRepo.Update(delivery)
+}
+
+// send POSTs the event to the webhook; any response other than 2xx is a
+// failure.
+func (s *webhookService) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) models.DeliveryAttempt {
+	started := time.Now()
+	record := models.DeliveryAttempt{At: started}
+
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ecommerce-webhooks/1.0")
+	req.Header.Set(webhooks.HeaderEvent, delivery.Event.Type)
+	req.Header.Set(webhooks.HeaderEventID, delivery.Event.ID)
+	req.Header.Set(webhooks.HeaderDeliveryID, delivery.ID.Hex())
+	req.Header.Set(webhooks.HeaderTimestamp, fmt.Sprint(started.Unix()))
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(webhook.Secret, started, body))
+
+	resp, err := s.client.Do(req)
+	record.Duration = time.Since(started)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	record.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		record.Error = fmt.Sprintf("webhook responded with %s", resp.Status)
+	}
+	return record
+}
+
+// validateWebhookRequest checks the request and that the URL's host
+// resolves to public addresses only.
+func (s *webhookService) validateWebhookRequest(req models.WebhookRequest) error {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", models.ErrInvalidWebhook)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	if err := s.guard.CheckURL(ctx, target); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidWebhook, err)
+	}
+	if len(req.EventTypes) == 0 {
+		return fmt.Errorf("%w: at least one event type is required", models.ErrInvalidWebhook)
+	}
+	for _, eventType := range req.EventTypes {
+		if !models.ValidWebhookEventType(eventType) {
+			return fmt.Errorf("%w: unknown event type %q, expected one of %s",
+				models.ErrInvalidWebhook, eventType, strings.Join(models.WebhookEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+func uniqueEventTypes(eventTypes []string) []string {
+	unique := make([]string, 0, len(eventTypes))
+	seen := map[string]bool{}
+	for _, eventType := range eventTypes {
+		if !seen[eventType] {
+			seen[eventType] = true
+			unique = append(unique, eventType)
+		}
+	}
+	return unique
+}
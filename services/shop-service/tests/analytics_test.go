@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"ecommerce/pkg/events"
+	"ecommerce/pkg/money"
+	"ecommerce/shop-service/models"
+	"ecommerce/shop-service/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryOrderFactRepository aggregates in memory the way the MongoDB
+// pipelines do.
+type memoryOrderFactRepository struct {
+	facts   map[primitive.ObjectID]models.OrderFact
+	queries int
+}
+
+func (r *memoryOrderFactRepository) Upsert(fact *models.OrderFact) error {
+	if stored, ok := r.facts[fact.OrderID]; ok && stored.Version >= fact.Version {
+		return nil
+	}
+	r.facts[fact.OrderID] = *fact
+	return nil
+}
+
+func (r *memoryOrderFactRepository) matching(query models.AnalyticsQuery) []models.OrderFact {
+	facts := []models.OrderFact{}
+	for _, fact := range r.facts {
+		if fact.ShopID == query.ShopID && fact.Total.Currency == query.Currency &&
+			!fact.CreatedAt.Before(query.From) && fact.CreatedAt.Before(query.To) {
+			facts = append(facts, fact)
+		}
+	}
+	return facts
+}
+
+func paidStatus(status string) bool {
+	for _, paid := range models.RevenueOrderStatuses {
+		if paid == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryOrderFactRepository) Buckets(query models.AnalyticsQuery) ([]models.AnalyticsBucket, error) {
+	r.queries++
+	byStart := map[time.Time]*models.AnalyticsBucket{}
+	for _, fact := range r.matching(query) {
+		start := query.Period.Start(fact.CreatedAt)
+		bucket, ok := byStart[start]
+		if !ok {
+			bucket = &models.AnalyticsBucket{Start: &start, Revenue: money.Zero(query.Currency)}
+			byStart[start] = bucket
+		}
+		bucket.Orders++
+		switch {
+		case paidStatus(fact.Status):
+			bucket.PaidOrders++
+			bucket.Revenue = bucket.Revenue.Add(fact.Total)
+		case fact.Status == models.OrderStatusCancelled:
+			bucket.CancelledOrders++
+		case fact.Status == models.OrderStatusRefunded:
+			bucket.ReturnedOrders++
+		}
+	}
+
+	buckets := []models.AnalyticsBucket{}
+	for _, bucket := range byStart {
+		buckets = append(buckets, *bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(*buckets[j].Start) })
+	return buckets, nil
+}
+
+func (r *memoryOrderFactRepository) TopProducts(query models.AnalyticsQuery) ([]models.ProductSales, error) {
+	byProduct := map[primitive.ObjectID]*models.ProductSales{}
+	for _, fact := range r.matching(query) {
+		if !paidStatus(fact.Status) {
+			continue
+		}
+		for _, item := range fact.Items {
+			sales, ok := byProduct[item.ProductID]
+			if !ok {
+				sales = &models.ProductSales{ProductID: item.ProductID, Revenue: money.Zero(query.Currency)}
+				byProduct[item.ProductID] = sales
+			}
+			sales.Name = item.Name
+			sales.Quantity += int64(item.Quantity)
+			sales.Revenue = sales.Revenue.Add(item.Revenue)
+		}
+	}
+
+	products := []models.ProductSales{}
+	for _, sales := range byProduct {
+		products = append(products, *sales)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Revenue.Cmp(products[j].Revenue) > 0 })
+	if len(products) > query.TopProducts {
+		products = products[:query.TopProducts]
+	}
+	return products, nil
+}
+
+type analyticsFixture struct {
+	service models.AnalyticsService
+	facts   *memoryOrderFactRepository
+	shopID  primitive.ObjectID
+}
+
+func newAnalyticsFixture(ttl time.Duration) *analyticsFixture {
+	shopID := primitive.NewObjectID()
+	shopRepo := new(MockShopRepository)
+	shopRepo.On("GetByID", shopID).Return(&models.Shop{ID: shopID, Name: "Test Shop"}, nil)
+	shopRepo.On("GetByID", mock.Anything).Return(nil, models.ErrShopNotFound)
+
+	facts := &memoryOrderFactRepository{facts: map[primitive.ObjectID]models.OrderFact{}}
+	return &analyticsFixture{
+		service: services.NewAnalyticsService(facts, shopRepo, ttl),
+		facts:   facts,
+		shopID:  shopID,
+	}
+}
+
+type analyticsItem struct {
+	id       primitive.ObjectID
+	name     string
+	quantity int
+	price    string
+}
+
+// record feeds the analytics service the event an order of the fixture's
+// shop publishes on reaching status.
+func (f *analyticsFixture) record(t *testing.T, orderID primitive.ObjectID, status string, version int, createdAt time.Time, total string, items ...analyticsItem) {
+	lines := []map[string]interface{}{}
+	for _, item := range items {
+		lines = append(lines, map[string]interface{}{
+			"product_id": item.id, "name": item.name, "quantity": item.quantity, "price": usdAmount(t, item.price),
+		})
+	}
+	data, err := events.NewData(map[string]interface{}{
+		"order_id":   orderID,
+		"shop_id":    f.shopID,
+		"status":     status,
+		"items":      lines,
+		"total":      usdAmount(t, total),
+		"version":    version,
+		"created_at": createdAt,
+	})
+	assert.NoError(t, err)
+
+	eventType := "order." + status
+	if status == "pending" {
+		eventType = "order.created"
+	}
+	event := events.Event{ID: primitive.NewObjectID().Hex(), Type: eventType, Source: "order-service", OccurredAt: time.Now(), Data: data}
+	assert.NoError(t, f.service.HandleEvent(context.Background(), event))
+}
+
+func usdAmount(t *testing.T, amount string) money.Money {
+	value, err := money.Parse(amount, "USD")
+	assert.NoError(t, err)
+	return value
+}
+
+func TestAnalyticsPeriodStart(t *testing.T) {
+	at := time.Date(2026, 10, 18, 15, 4, 5, 0, time.UTC) // a Sunday
+
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), models.PeriodDay.Start(at))
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), models.PeriodWeek.Start(at))
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), models.PeriodMonth.Start(at))
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), models.PeriodMonth.Next(models.PeriodMonth.Start(at)))
+}
+
+func TestHandleOrderEventKeepsLatestVersion(t *testing.T) {
+	fixture := newAnalyticsFixture(0)
+	orderID := primitive.NewObjectID()
+	createdAt := time.Date(2026, 10, 5, 10, 0, 0, 0, time.UTC)
+	mug := analyticsItem{primitive.NewObjectID(), "Mug", 2, "12.50"}
+
+	fixture.record(t, orderID, "paid", 2, createdAt, "25.00", mug)
+	// A redelivered or late event of an earlier version changes nothing
+	fixture.record(t, orderID, "pending", 1, createdAt, "25.00", mug)
+
+	fact := fixture.facts.facts[orderID]
+	assert.Equal(t, "paid", fact.Status)
+	assert.Equal(t, 2, fact.Version)
+	assert.Equal(t, usdAmount(t, "25.00"), fact.Items[0].Revenue)
+
+	// Events that carry no order are ignored
+	assert.NoError(t, fixture.service.HandleEvent(context.Background(), events.Event{Type: "product.created"}))
+	assert.Len(t, fixture.facts.facts, 1)
+}
+
+func TestGetShopAnalytics(t *testing.T) {
+	fixture := newAnalyticsFixture(time.Minute)
+	mug := primitive.NewObjectID()
+	lamp := primitive.NewObjectID()
+	day := func(d, hour int) time.Time { return time.Date(2026, 10, d, hour, 0, 0, 0, time.UTC) }
+
+	fixture.record(t, primitive.NewObjectID(), "paid", 1, day(5, 9), "30.00",
+		analyticsItem{mug, "Mug", 2, "10.00"}, analyticsItem{lamp, "Lamp", 1, "10.00"})
+	fixture.record(t, primitive.NewObjectID(), "completed", 4, day(5, 18), "45.00", analyticsItem{lamp, "Lamp", 3, "15.00"})
+	fixture.record(t, primitive.NewObjectID(), "cancelled", 2, day(5, 20), "99.00", analyticsItem{mug, "Mug", 9, "11.00"})
+	fixture.record(t, primitive.NewObjectID(), "refunded", 5, day(7, 12), "20.00", analyticsItem{mug, "Mug", 2, "10.00"})
+	fixture.record(t, primitive.NewObjectID(), "pending", 1, day(7, 13), "10.00", analyticsItem{mug, "Mug", 1, "10.00"})
+	fixture.record(t, primitive.NewObjectID(), "paid", 1, day(20, 9), "500.00", analyticsItem{mug, "Mug", 50, "10.00"})
+
+	query := models.AnalyticsQuery{
+		ShopID:   fixture.shopID,
+		Period:   models.PeriodDay,
+		From:     day(5, 0),
+		To:       day(7, 15),
+		Currency: "usd",
+	}
+	analytics, err := fixture.service.GetShopAnalytics(query)
+	assert.NoError(t, err)
+
+	// The range is widened to whole days and empty days are reported
+	assert.Equal(t, day(8, 0), analytics.To)
+	assert.Equal(t, "USD", analytics.Currency)
+	assert.Len(t, analytics.Buckets, 3)
+	assert.Equal(t, int64(0), analytics.Buckets[1].Orders)
+
+	first := analytics.Buckets[0]
+	assert.Equal(t, day(5, 0), *first.Start)
+	assert.Equal(t, int64(3), first.Orders)
+	assert.Equal(t, int64(2), first.PaidOrders)
+	assert.Equal(t, usdAmount(t, "75.00"), first.Revenue)
+	assert.Equal(t, usdAmount(t, "37.50"), first.AverageOrderValue)
+	assert.InDelta(t, 1.0/3, first.CancellationRate, 1e-9)
+
+	totals := analytics.Totals
+	assert.Nil(t, totals.Start)
+	assert.Equal(t, int64(5), totals.Orders)
+	assert.Equal(t, usdAmount(t, "75.00"), totals.Revenue)
+	assert.InDelta(t, 0.2, totals.CancellationRate, 1e-9)
+	assert.InDelta(t, 1.0/3, totals.ReturnRate, 1e-9)
+
+	// Cancelled and refunded orders do not count towards product sales
+	assert.Equal(t, []models.ProductSales{
+		{ProductID: lamp, Name: "Lamp", Quantity: 4, Revenue: usdAmount(t, "55.00")},
+		{ProductID: mug, Name: "Mug", Quantity: 2, Revenue: usdAmount(t, "20.00")},
+	}, analytics.TopProducts)
+
+	// The report is served from the cache until it expires
+	cached, err := fixture.service.GetShopAnalytics(query)
+	assert.NoError(t, err)
+	assert.Same(t, analytics, cached)
+	assert.Equal(t, 1, fixture.facts.queries)
+
+	weekly, err := fixture.service.GetShopAnalytics(models.AnalyticsQuery{
+		ShopID: fixture.shopID, Period: models.PeriodWeek, From: day(5, 0), To: day(25, 0), Currency: "USD",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, weekly.Buckets, 3)
+	assert.Equal(t, usdAmount(t, "500.00"), weekly.Buckets[2].Revenue)
+}
+
+func TestGetShopAnalyticsValidation(t *testing.T) {
+	fixture := newAnalyticsFixture(time.Minute)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, query := range []models.AnalyticsQuery{
+		{ShopID: fixture.shopID, Period: "year", Currency: "USD"},
+		{ShopID: fixture.shopID, Currency: "dollars"},
+		{ShopID: fixture.shopID, Currency: "USD", TopProducts: models.MaxTopProducts + 1},
+		{ShopID: fixture.shopID, Currency: "USD", From: from, To: from.AddDate(0, 0, -1)},
+		{ShopID: fixture.shopID, Currency: "USD", From: from, To: from.AddDate(2, 0, 0)},
+	} {
+		_, err := fixture.service.GetShopAnalytics(query)
+		assert.ErrorIs(t, err, models.ErrInvalidAnalyticsQuery)
+	}
+
+	_, err := fixture.service.GetShopAnalytics(models.AnalyticsQuery{ShopID: primitive.NewObjectID(), Currency: "USD"})
+	assert.ErrorIs(t, err, models.ErrShopNotFound)
+}
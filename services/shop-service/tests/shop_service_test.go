@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"ecommerce/shop-service/models"
+	"ecommerce/shop-service/services"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -105,3 +106,15 @@ func TestAddWarehouse(t *testing.T) {
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestShopPendingOrderTimeout(t *testing.T) {
+	mockRepo := new(MockShopRepository)
+	service := services.NewShopService(mockRepo)
+	mockRepo.On("Create", mock.Anything).Return(nil)
+
+	for minutes, valid := range map[int]bool{0: true, 5: true, 120: true, 4: false, -1: false} {
+		err := service.CreateShop(&models.Shop{Name: "Test Shop", Location: "Test Location", PendingOrderTimeoutMinutes: minutes})
+		assert.Equal(t, valid, err == nil, "timeout of %d minutes", minutes)
+	}
+	mockRepo.AssertNumberOfCalls(t, "Create", 3)
+}
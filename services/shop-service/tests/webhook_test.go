@@ -0,0 +1,438 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"ecommerce/pkg/events"
+	"ecommerce/shop-service/models"
+	"ecommerce/shop-service/services"
+	"ecommerce/shop-service/webhooks"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryWebhookRepository struct {
+	webhooks map[primitive.ObjectID]models.Webhook
+}
+
+func (r *memoryWebhookRepository) Create(webhook *models.Webhook) error {
+	webhook.ID = primitive.NewObjectID()
+	r.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (r *memoryWebhookRepository) GetByID(id primitive.ObjectID) (*models.Webhook, error) {
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, models.ErrWebhookNotFound
+	}
+	return &webhook, nil
+}
+
+func (r *memoryWebhookRepository) ListByShop(shopID primitive.ObjectID) ([]models.Webhook, error) {
+	webhooks := []models.Webhook{}
+	for _, webhook := range r.webhooks {
+		if webhook.ShopID == shopID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) ListSubscribed(shopID primitive.ObjectID, eventType string) ([]models.Webhook, error) {
+	webhooks := []models.Webhook{}
+	for _, webhook := range r.webhooks {
+		if webhook.ShopID == shopID && webhook.Active && webhook.Subscribes(eventType) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) Update(webhook *models.Webhook) error {
+	if _, ok := r.webhooks[webhook.ID]; !ok {
+		return models.ErrWebhookNotFound
+	}
+	r.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (r *memoryWebhookRepository) Delete(id primitive.ObjectID) error {
+	if _, ok := r.webhooks[id]; !ok {
+		return models.ErrWebhookNotFound
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+type memoryDeliveryRepository struct {
+	deliveries []models.WebhookDelivery
+}
+
+func (r *memoryDeliveryRepository) Create(delivery *models.WebhookDelivery) error {
+	for _, existing := range r.deliveries {
+		if existing.WebhookID == delivery.WebhookID && existing.Event.ID == delivery.Event.ID {
+			return nil
+		}
+	}
+	delivery.ID = primitive.NewObjectID()
+	delivery.CreatedAt = time.Now()
+	r.deliveries = append(r.deliveries, *delivery)
+	return nil
+}
+
+func (r *memoryDeliveryRepository) GetByID(id primitive.ObjectID) (*models.WebhookDelivery, error) {
+	for _, delivery := range r.deliveries {
+		if delivery.ID == id {
+			return &delivery, nil
+		}
+	}
+	return nil, models.ErrDeliveryNotFound
+}
+
+func (r *memoryDeliveryRepository) ListByWebhook(webhookID primitive.ObjectID, status string, limit int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	for i := len(r.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		delivery := r.deliveries[i]
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memoryDeliveryRepository) ClaimDue(now time.Time, lease time.Duration) (*models.WebhookDelivery, error) {
+	for i, delivery := range r.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			leased := now.Add(lease)
+			r.deliveries[i].NextAttemptAt = &leased
+			return &delivery, nil
+		}
+	}
+	return nil, models.ErrDeliveryNotFound
+}
+
+func (r *memoryDeliveryRepository) Update(delivery *models.WebhookDelivery) error {
+	for i := range r.deliveries {
+		if r.deliveries[i].ID == delivery.ID {
+			r.deliveries[i] = *delivery
+			return nil
+		}
+	}
+	return models.ErrDeliveryNotFound
+}
+
+// makeDue lets the test skip the wait before every pending retry.
+func (r *memoryDeliveryRepository) makeDue() {
+	now := time.Now()
+	for i := range r.deliveries {
+		if r.deliveries[i].Status == models.DeliveryPending {
+			r.deliveries[i].NextAttemptAt = &now
+		}
+	}
+}
+
+type webhookFixture struct {
+	service    models.WebhookService
+	webhooks   *memoryWebhookRepository
+	deliveries *memoryDeliveryRepository
+	shopID     primitive.ObjectID
+}
+
+// newWebhookFixture lets webhooks reach loopback, where test receivers
+// listen.
+func newWebhookFixture(maxAttempts int) *webhookFixture {
+	return newGuardedWebhookFixture(maxAttempts, &webhooks.Guard{AllowPrivate: true})
+}
+
+func newGuardedWebhookFixture(maxAttempts int, guard *webhooks.Guard) *webhookFixture {
+	shopID := primitive.NewObjectID()
+	shopRepo := new(MockShopRepository)
+	shopRepo.On("GetByID", shopID).Return(&models.Shop{ID: shopID, Name: "Test Shop"}, nil)
+
+	fixture := &webhookFixture{
+		webhooks:   &memoryWebhookRepository{webhooks: map[primitive.ObjectID]models.Webhook{}},
+		deliveries: &memoryDeliveryRepository{},
+		shopID:     shopID,
+	}
+	fixture.service = services.NewWebhookService(
+		fixture.webhooks,
+		fixture.deliveries,
+		shopRepo,
+		guard,
+		time.Second,
+		models.RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Second, MaxDelay: 4 * time.Second},
+	)
+	return fixture
+}
+
+func orderEvent(t *testing.T, id, eventType string, shopID primitive.ObjectID) events.Event {
+	data, err := events.NewData(map[string]interface{}{"order_id": primitive.NewObjectID(), "shop_id": shopID})
+	assert.NoError(t, err)
+	return events.Event{ID: id, Type: eventType, Source: "order-service", OccurredAt: time.Now(), Data: data}
+}
+
+// recordingEndpoint answers with the queued status codes in turn, then 200,
+// and keeps the requests it received.
+type recordingEndpoint struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (e *recordingEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	status := http.StatusOK
+	if len(e.statuses) > 0 {
+		status, e.statuses = e.statuses[0], e.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := models.RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+
+	assert.Equal(t, 30*time.Second, policy.Delay(1))
+	assert.Equal(t, time.Minute, policy.Delay(2))
+	assert.Equal(t, 4*time.Minute, policy.Delay(4))
+	assert.Equal(t, 5*time.Minute, policy.Delay(5))
+	assert.Equal(t, 5*time.Minute, policy.Delay(50))
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Now()
+	signature := webhooks.Sign("secret", now, body)
+	sent := strconv.FormatInt(now.Unix(), 10)
+
+	assert.True(t, webhooks.Verify("secret", sent, signature, body, time.Minute))
+	assert.False(t, webhooks.Verify("other", sent, signature, body, time.Minute))
+	assert.False(t, webhooks.Verify("secret", sent, signature, []byte(`{"id":"2"}`), time.Minute))
+
+	stale := now.Add(-time.Hour)
+	assert.False(t, webhooks.Verify("secret", strconv.FormatInt(stale.Unix(), 10), webhooks.Sign("secret", stale, body), body, time.Minute))
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	fixture := newWebhookFixture(3)
+
+	_, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: "ftp://example.com", EventTypes: []string{"order.created"}})
+	assert.ErrorIs(t, err, models.ErrInvalidWebhook)
+
+	_, err = fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: "https://example.com/hook", EventTypes: []string{"order.teleported"}})
+	assert.ErrorIs(t, err, models.ErrInvalidWebhook)
+
+	webhook, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{
+		URL:        "https://example.com/hook",
+		EventTypes: []string{"order.created", "order.paid", "order.created"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, webhook.Active)
+	assert.NotEmpty(t, webhook.Secret)
+	assert.Equal(t, []string{"order.created", "order.paid"}, webhook.EventTypes)
+
+	_, err = fixture.service.GetWebhook(primitive.NewObjectID(), webhook.ID)
+	assert.ErrorIs(t, err, models.ErrWebhookNotFound)
+}
+
+// fakeResolver resolves hosts from a fixed table.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func TestWebhookGuardRejectsInternalAddresses(t *testing.T) {
+	guard := &webhooks.Guard{Resolver: fakeResolver{
+		"localhost":        {"127.0.0.1", "::1"},
+		"example.com":      {"93.184.216.34"},
+		"internal.example": {"10.0.0.5"},
+		"rebind.example":   {"93.184.216.34", "127.0.0.1"},
+	}}
+	fixture := newGuardedWebhookFixture(3, guard)
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://internal.example/hook",
+		"http://rebind.example/hook",
+	} {
+		_, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: target, EventTypes: []string{"order.paid"}})
+		assert.ErrorIs(t, err, models.ErrInvalidWebhook, target)
+	}
+
+	webhook, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: "https://example.com/hook", EventTypes: []string{"order.paid"}})
+	assert.NoError(t, err)
+	_, err = fixture.service.UpdateWebhook(fixture.shopID, webhook.ID, models.WebhookRequest{URL: "http://internal.example/hook", EventTypes: []string{"order.paid"}})
+	assert.ErrorIs(t, err, models.ErrInvalidWebhook)
+}
+
+func TestWebhookGuardChecksEveryConnection(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	// A host that resolved to a public address at registration may point
+	// at an internal one by the time it is dialled
+	_, err := (&webhooks.Guard{}).Client(time.Second).Get(server.URL)
+	assert.ErrorIs(t, err, webhooks.ErrForbiddenAddress)
+	assert.Empty(t, endpoint.requests)
+
+	_, err = (&webhooks.Guard{AllowPrivate: true}).Client(time.Second).Get(server.URL)
+	assert.NoError(t, err)
+}
+
+func TestWebhookGuardDoesNotFollowRedirects(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	target := httptest.NewServer(endpoint)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	fixture := newWebhookFixture(1)
+	_, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: redirect.URL, EventTypes: []string{"order.paid"}})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, fixture.service.HandleEvent(ctx, orderEvent(t, "e1", "order.paid", fixture.shopID)))
+	fixture.service.DeliverDue(ctx)
+
+	delivery := fixture.deliveries.deliveries[0]
+	assert.Equal(t, models.DeliveryDead, delivery.Status)
+	assert.Equal(t, http.StatusTemporaryRedirect, delivery.Attempts[0].StatusCode)
+	assert.Empty(t, endpoint.requests)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	endpoint := &recordingEndpoint{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	fixture := newWebhookFixture(3)
+	subscribed, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: server.URL, Secret: "s3cret", EventTypes: []string{"order.created"}})
+	assert.NoError(t, err)
+	disabled := false
+	_, err = fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: server.URL, EventTypes: []string{"order.created"}, Active: &disabled})
+	assert.NoError(t, err)
+	_, err = fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: server.URL, EventTypes: []string{"order.paid"}})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, fixture.service.HandleEvent(ctx, orderEvent(t, "e1", "order.created", fixture.shopID)))
+	// A redelivered event and another shop's event queue nothing
+	assert.NoError(t, fixture.service.HandleEvent(ctx, orderEvent(t, "e1", "order.created", fixture.shopID)))
+	assert.NoError(t, fixture.service.HandleEvent(ctx, orderEvent(t, "e2", "order.created", primitive.NewObjectID())))
+	assert.Len(t, fixture.deliveries.deliveries, 1)
+
+	attempted, err := fixture.service.DeliverDue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+
+	request := endpoint.requests[0]
+	assert.Equal(t, "order.created", request.Header.Get(webhooks.HeaderEvent))
+	assert.Equal(t, "e1", request.Header.Get(webhooks.HeaderEventID))
+	assert.True(t, webhooks.Verify("s3cret", request.Header.Get(webhooks.HeaderTimestamp), request.Header.Get(webhooks.HeaderSignature), endpoint.bodies[0], time.Minute))
+
+	deliveries, err := fixture.service.ListDeliveries(fixture.shopID, subscribed.ID, "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].Attempts[0].StatusCode)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+}
+
+func TestWebhookRetryDeadLetterAndRedeliver(t *testing.T) {
+	endpoint := &recordingEndpoint{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	fixture := newWebhookFixture(3)
+	webhook, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: server.URL, EventTypes: []string{"order.cancelled"}})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, fixture.service.HandleEvent(ctx, orderEvent(t, "e1", "order.cancelled", fixture.shopID)))
+
+	before := time.Now()
+	fixture.service.DeliverDue(ctx)
+	delivery := fixture.deliveries.deliveries[0]
+	assert.Equal(t, models.DeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Failures)
+	assert.WithinDuration(t, before.Add(time.Second), *delivery.NextAttemptAt, 500*time.Millisecond)
+
+	// Not due yet
+	attempted, _ := fixture.service.DeliverDue(ctx)
+	assert.Equal(t, 0, attempted)
+
+	fixture.deliveries.makeDue()
+	fixture.service.DeliverDue(ctx)
+	delivery = fixture.deliveries.deliveries[0]
+	assert.Equal(t, 2, delivery.Failures)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), *delivery.NextAttemptAt, 500*time.Millisecond)
+
+	fixture.deliveries.makeDue()
+	fixture.service.DeliverDue(ctx)
+	dead, err := fixture.service.ListDeliveries(fixture.shopID, webhook.ID, models.DeliveryDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Len(t, dead[0].Attempts, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, dead[0].Attempts[2].StatusCode)
+
+	redelivered, err := fixture.service.Redeliver(fixture.shopID, webhook.ID, dead[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, redelivered.Status)
+	assert.Equal(t, 0, redelivered.Failures)
+
+	fixture.service.DeliverDue(ctx)
+	delivered, _ := fixture.deliveries.GetByID(dead[0].ID)
+	assert.Equal(t, models.DeliverySucceeded, delivered.Status)
+	assert.Len(t, delivered.Attempts, 4)
+	assert.Len(t, endpoint.requests, 4)
+
+	_, err = fixture.service.Redeliver(fixture.shopID, primitive.NewObjectID(), dead[0].ID)
+	assert.ErrorIs(t, err, models.ErrWebhookNotFound)
+}
+
+func TestDeletedWebhookDeadLetters(t *testing.T) {
+	fixture := newWebhookFixture(3)
+	webhook, err := fixture.service.CreateWebhook(fixture.shopID, models.WebhookRequest{URL: "http://127.0.0.1:1/hook", EventTypes: []string{"order.paid"}})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, fixture.service.HandleEvent(ctx, orderEvent(t, "e1", "order.paid", fixture.shopID)))
+	assert.NoError(t, fixture.service.DeleteWebhook(fixture.shopID, webhook.ID))
+
+	fixture.service.DeliverDue(ctx)
+	delivery := fixture.deliveries.deliveries[0]
+	assert.Equal(t, models.DeliveryDead, delivery.Status)
+	assert.Equal(t, "webhook was deleted", delivery.Attempts[0].Error)
+}
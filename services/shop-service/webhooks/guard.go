@@ -0,0 +1,140 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs that lead into the
+// service's own network.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// internalNetworks are the ranges, besides those net.IP classifies itself,
+// that never belong to a public receiver: "this network", carrier-grade NAT
+// (which Kubernetes and cloud VPCs use internally), IETF protocol
+// assignments, benchmarking, reserved and NAT64.
+var internalNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+)
+
+// Resolver looks up the addresses of a host; *net.Resolver is one.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Guard keeps webhook requests away from the service's own network: hosts
+// resolving to loopback, private, link-local or other internal addresses
+// are refused when a webhook is registered and again on every connection,
+// since DNS can change in between. Redirects are not followed.
+type Guard struct {
+	// AllowPrivate turns the address checks off, for local development
+	// with receivers on the same machine or network.
+	AllowPrivate bool
+	// Resolver defaults to net.DefaultResolver.
+	Resolver Resolver
+}
+
+// Allowed reports whether requests may be sent to ip.
+func (g *Guard) Allowed(ip net.IP) bool {
+	if g.AllowPrivate {
+		return true
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL resolves the URL's host and fails with ErrForbiddenAddress if
+// any of its addresses is not allowed.
+func (g *Guard) CheckURL(ctx context.Context, target *url.URL) error {
+	if g.AllowPrivate {
+		return nil
+	}
+
+	host := target.Hostname()
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		resolver := g.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return fmt.Errorf("cannot resolve %s: %w", host, err)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if !g.Allowed(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, ip)
+		}
+	}
+	return nil
+}
+
+// Client returns an HTTP client for webhook deliveries that checks every
+// address it connects to, ignores proxy settings and hands redirects back
+// as responses.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: g.control}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// control runs once the address is resolved and before connecting, so it
+// sees the address actually dialled.
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !g.Allowed(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
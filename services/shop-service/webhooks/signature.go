@@ -0,0 +1,66 @@
+// Package webhooks signs the requests shop-service sends to webhook URLs.
+//
+// Every request carries the time it was signed and an HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook's secret:
+//
+//	X-Webhook-Timestamp: 1717171717
+//	X-Webhook-Signature: sha256=<hex digest>
+//
+// Receivers recompute the digest over the raw body, compare it in constant
+// time, and reject stale timestamps to stop replays.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderEvent      = "X-Webhook-Event"
+	HeaderEventID    = "X-Webhook-Event-Id"
+	HeaderDeliveryID = "X-Webhook-Delivery"
+	HeaderTimestamp  = "X-Webhook-Timestamp"
+	HeaderSignature  = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature was made with secret for body sent at
+// timestamp, which must be within tolerance of now.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	sentAt := time.Unix(seconds, 0)
+	if age := time.Since(sentAt); age > tolerance || age < -tolerance {
+		return false
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, sentAt, body)))
+}
+
+// NewSecret returns a random secret for a webhook created without one.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"ecommerce/warehouse-service/metrics"
+	"ecommerce/warehouse-service/models"
+	"ecommerce/warehouse-service/repository"
+	"ecommerce/warehouse-service/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReservationHandler struct {
+	reservationRepo repository.ReservationRepository
+}
+
+func NewReservationHandler(repo repository.ReservationRepository) *ReservationHandler {
+	return &ReservationHandler{
+		reservationRepo: repo,
+	}
+}
+
+// @Summary Reserve stock
+// @Description Reserve stock for a set of items on behalf of an owner reference
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param request body models.ReservationRequest true "Reservation Request"
+// @Success 201 {array} models.Reservation
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/reservations [post]
+func (h *ReservationHandler) CreateReservation(c *gin.Context) {
+	var req models.ReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Logger.Errorf("Failed to bind JSON: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateStruct(req); err != nil {
+		utils.Logger.Errorf("Validation failed: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reservations, shortages, err := h.reservationRepo.Reserve(c.Request.Context(), &req)
+	if err != nil {
+		utils.Logger.Errorf("Failed to reserve stock: %v", err)
+		metrics.ReservationOperations.WithLabelValues("reserve", "failed").Inc()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(shortages) > 0 {
+		metrics.ReservationOperations.WithLabelValues("reserve", "shortage").Inc()
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     utils.ErrInsufficientStock.Error(),
+			"shortages": shortages,
+		})
+		return
+	}
+
+	metrics.ReservationOperations.WithLabelValues("reserve", "success").Inc()
+	c.JSON(http.StatusCreated, reservations)
+}
+
+// @Summary Get reservation by ID
+// @Description Get reservation details by ID
+// @Tags reservations
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/reservations/{id} [get]
+func (h *ReservationHandler) GetReservation(c *gin.Context) {
+	reservation, err := h.reservationRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.Logger.Errorf("Failed to get reservation: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// @Summary Hold reservation
+// @Description Keep an active reservation's stock until it is confirmed or released, without expiring
+// @Tags reservations
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/reservations/{id}/hold [post]
+func (h *ReservationHandler) HoldReservation(c *gin.Context) {
+	reservation, err := h.reservationRepo.Hold(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.Logger.Errorf("Failed to hold reservation: %v", err)
+		metrics.ReservationOperations.WithLabelValues("hold", "failed").Inc()
+		c.JSON(reservationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	metrics.ReservationOperations.WithLabelValues("hold", "success").Inc()
+	c.JSON(http.StatusOK, reservation)
+}
+
+// @Summary Confirm reservation
+// @Description Deduct an active or held reservation from on-hand stock
+// @Tags reservations
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/reservations/{id}/confirm [post]
+func (h *ReservationHandler) ConfirmReservation(c *gin.Context) {
+	reservation, err := h.reservationRepo.Confirm(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.Logger.Errorf("Failed to confirm reservation: %v", err)
+		metrics.ReservationOperations.WithLabelValues("confirm", "failed").Inc()
+		c.JSON(reservationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	metrics.ReservationOperations.WithLabelValues("confirm", "success").Inc()
+	c.JSON(http.StatusOK, reservation)
+}
+
+// @Summary Release reservation
+// @Description Return reserved stock to available stock
+// @Tags reservations
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/reservations/{id}/release [post]
+func (h *ReservationHandler) ReleaseReservation(c *gin.Context) {
+	reservation, err := h.reservationRepo.Release(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.Logger.Errorf("Failed to release reservation: %v", err)
+		metrics.ReservationOperations.WithLabelValues("release", "failed").Inc()
+		c.JSON(reservationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	metrics.ReservationOperations.WithLabelValues("release", "success").Inc()
+	c.JSON(http.StatusOK, reservation)
+}
+
+func reservationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrReservationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, utils.ErrReservationNotActive):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -73,6 +73,33 @@ func (h *WarehouseHandler) GetWarehouse(c *gin.Context) {
 	c.JSON(http.StatusOK, warehouse)
 }
 
+// @Summary Get stock level
+// @Description Get on-hand, reserved and available stock of a product in a warehouse
+// @Tags warehouses
+// @Produce json
+// @Param id path string true "Warehouse ID"
+// @Param productId path string true "Product ID"
+// @Success 200 {object} models.StockLevel
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/warehouses/{id}/stock/{productId} [get]
+func (h *WarehouseHandler) GetStockLevel(c *gin.Context) {
+	productID := c.Param("productId")
+	warehouse, err := h.warehouseRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.Logger.Errorf("Failed to get warehouse: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StockLevel{
+		WarehouseID: warehouse.ID.Hex(),
+		ProductID:   productID,
+		OnHand:      warehouse.Stock[productID],
+		Reserved:    warehouse.Reserved[productID],
+		Available:   warehouse.Available(productID),
+	})
+}
+
 // @Summary Update stock
 // @Description Update product stock in warehouse
 // @Tags warehouses
@@ -87,7 +114,7 @@ func (h *WarehouseHandler) GetWarehouse(c *gin.Context) {
 func (h *WarehouseHandler) UpdateStock(c *gin.Context) {
 	warehouseID := c.Param("id")
 	var request struct {
-		ProductID string `json:"product_id" binding:"required"`
+		ProductID string `json:"product_id" binding:"required,mongodb"`
 		Quantity  int    `json:"quantity" binding:"required"`
 	}
 
@@ -2,20 +2,21 @@ package jobs
 
 import (
 	"context"
+	"ecommerce/warehouse-service/metrics"
 	"ecommerce/warehouse-service/repository"
 	"ecommerce/warehouse-service/utils"
 	"time"
 )
 
 type StockReleaseJob struct {
-	warehouseRepo repository.WarehouseRepository
-	interval      time.Duration
+	reservationRepo repository.ReservationRepository
+	interval        time.Duration
 }
 
-func NewStockReleaseJob(repo repository.WarehouseRepository, interval time.Duration) *StockReleaseJob {
+func NewStockReleaseJob(repo repository.ReservationRepository, interval time.Duration) *StockReleaseJob {
 	return &StockReleaseJob{
-		warehouseRepo: repo,
-		interval:      interval,
+		reservationRepo: repo,
+		interval:        interval,
 	}
 }
 
@@ -35,11 +36,20 @@ func (j *StockReleaseJob) Start(ctx context.Context) {
 }
 
 func (j *StockReleaseJob) releaseExpiredStock(ctx context.Context) {
-	expirationTime := time.Now().Add(-15 * time.Minute)
-	utils.Logger.Infof("Running stock release job for transfers before %v", expirationTime)
+	now := time.Now()
+	utils.Logger.Infof("Running stock release job for reservations expired before %v", now)
 
-	// TODO: Implement the actual stock release logic
-	// 1. Find all pending transfers older than expirationTime
-	// 2. Release the reserved stock back to the source warehouse
-	// 3. Update transfer status to "expired"
+	expired, err := j.reservationRepo.ExpireStale(ctx, now)
+	for _, reservation := range expired {
+		metrics.ReservationsExpired.Inc()
+		metrics.ExpiredStockReleased.WithLabelValues(reservation.WarehouseID).Add(float64(reservation.Quantity))
+	}
+	if err != nil {
+		utils.Logger.Errorf("Stock release job failed after expiring %d reservations: %v", len(expired), err)
+		return
+	}
+
+	if len(expired) > 0 {
+		utils.Logger.Infof("Stock release job expired %d reservations", len(expired))
+	}
 }
@@ -58,9 +58,11 @@ func main() {
 
 	// Initialize repository
 	warehouseRepo := repository.NewMongoWarehouseRepository(db)
+	reservationRepo := repository.NewMongoReservationRepository(db)
 
 	// Initialize handler
 	warehouseHandler := handlers.NewWarehouseHandler(warehouseRepo)
+	reservationHandler := handlers.NewReservationHandler(reservationRepo)
 	authHandler := handlers.NewAuthHandler()
 
 	// Setup Gin
@@ -92,11 +94,22 @@ func main() {
 		{
 			warehouses.POST("/", middleware.AuthMiddleware(), middleware.RequireRoles("admin"), warehouseHandler.CreateWarehouse)
 			warehouses.GET("/:id", middleware.AuthMiddleware(), warehouseHandler.GetWarehouse)
+			warehouses.GET("/:id/stock/:productId", middleware.AuthMiddleware(), warehouseHandler.GetStockLevel)
 			warehouses.PUT("/:id/stock", middleware.AuthMiddleware(), middleware.RequireRoles("admin", "inventory"), warehouseHandler.UpdateStock)
 			warehouses.POST("/transfer", middleware.AuthMiddleware(), middleware.RequireRoles("admin", "inventory"), warehouseHandler.TransferStock)
 			warehouses.PUT("/:id/:status", middleware.AuthMiddleware(), middleware.RequireRoles("admin"), warehouseHandler.UpdateWarehouseStatus)
 			warehouses.GET("/", middleware.AuthMiddleware(), warehouseHandler.GetAllWarehouses)
 		}
+
+		// Reservation routes
+		reservations := api.Group("/reservations")
+		{
+			reservations.POST("/", middleware.AuthMiddleware(), middleware.RequireRoles("admin", "inventory"), reservationHandler.CreateReservation)
+			reservations.GET("/:id", middleware.AuthMiddleware(), reservationHandler.GetReservation)
+			reservations.POST("/:id/hold", middleware.AuthMiddleware(), middleware.RequireRoles("admin", "inventory"), reservationHandler.HoldReservation)
+			reservations.POST("/:id/confirm", middleware.AuthMiddleware(), middleware.RequireRoles("admin", "inventory"), reservationHandler.ConfirmReservation)
+			reservations.POST("/:id/release", middleware.AuthMiddleware(), middleware.RequireRoles("admin", "inventory"), reservationHandler.ReleaseReservation)
+		}
 	}
 
 	// Setup health check
@@ -124,7 +137,7 @@ func main() {
 	}
 
 	// Initialize and start background jobs
-	stockReleaseJob := jobs.NewStockReleaseJob(warehouseRepo, 5*time.Minute)
+	stockReleaseJob := jobs.NewStockReleaseJob(reservationRepo, 5*time.Minute)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	stockReleaseJob.Start(ctx)
@@ -45,4 +45,27 @@ var (
 			Help: "The total number of active warehouses",
 		},
 	)
+
+	ReservationOperations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "warehouse_reservation_operations_total",
+			Help: "The total number of stock reservation operations",
+		},
+		[]string{"operation", "status"},
+	)
+
+	ReservationsExpired = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "warehouse_reservations_expired_total",
+			Help: "The total number of stock reservations expired by the release job",
+		},
+	)
+
+	ExpiredStockReleased = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "warehouse_expired_stock_released_total",
+			Help: "The total quantity returned to available stock by expired reservations",
+		},
+		[]string{"warehouse_id"},
+	)
 )
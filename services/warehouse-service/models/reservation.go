@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusHeld      = "held"
+	ReservationStatusConfirmed = "confirmed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
+
+// Reservation holds stock of a single product in a single warehouse on
+// behalf of an owner (usually an order) until it is confirmed, released or
+// expires. While active or held, its quantity counts against the
+// warehouse's available stock but not its on-hand stock. A held
+// reservation no longer expires; owners hold a reservation once they are
+// committed to it, such as an order that was paid.
+type Reservation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WarehouseID string             `bson:"warehouse_id" json:"warehouse_id"`
+	ProductID   string             `bson:"product_id" json:"product_id"`
+	Quantity    int                `bson:"quantity" json:"quantity"`
+	Reference   string             `bson:"reference" json:"reference"`
+	Status      string             `bson:"status" json:"status"` // "active", "held", "confirmed", "released", "expired"
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ReservationItem asks for a quantity of a product. ProductID must be a
+// product's ObjectID in hex, since it becomes part of the field paths the
+// warehouse's stock is updated through.
+type ReservationItem struct {
+	ProductID   string `json:"product_id" validate:"required,mongodb"`
+	Quantity    int    `json:"quantity" validate:"required,gt=0"`
+	WarehouseID string `json:"warehouse_id,omitempty"`
+}
+
+type ReservationRequest struct {
+	Reference  string            `json:"reference" validate:"required"`
+	TTLSeconds int               `json:"ttl_seconds" validate:"required,gt=0"`
+	Items      []ReservationItem `json:"items" validate:"required,min=1,dive"`
+}
+
+// StockShortage reports an item that could not be reserved.
+type StockShortage struct {
+	ProductID   string `json:"product_id"`
+	WarehouseID string `json:"warehouse_id,omitempty"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+type StockLevel struct {
+	WarehouseID string `json:"warehouse_id"`
+	ProductID   string `json:"product_id"`
+	OnHand      int    `json:"on_hand"`
+	Reserved    int    `json:"reserved"`
+	Available   int    `json:"available"`
+}
@@ -10,12 +10,19 @@ type Warehouse struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name      string             `bson:"name" json:"name" validate:"required"`
 	Location  string             `bson:"location" json:"location" validate:"required"`
-	Status    string             `bson:"status" json:"status"` // "active" atau "inactive"
-	Stock     map[string]int     `bson:"stock" json:"stock"`   // map productID to quantity
+	Status    string             `bson:"status" json:"status"`     // "active" atau "inactive"
+	Stock     map[string]int     `bson:"stock" json:"stock"`       // map productID to on-hand quantity
+	Reserved  map[string]int     `bson:"reserved" json:"reserved"` // map productID to quantity held by active reservations
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
+// Available returns the on-hand quantity of a product that is not held by
+// an active reservation.
+func (w *Warehouse) Available(productID string) int {
+	return w.Stock[productID] - w.Reserved[productID]
+}
+
 type StockTransfer struct {
 	ProductID     string    `bson:"product_id" json:"product_id" validate:"required"`
 	Quantity      int       `bson:"quantity" json:"quantity" validate:"required,gt=0"`
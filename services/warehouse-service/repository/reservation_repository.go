@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"context"
+	"ecommerce/warehouse-service/models"
+	"ecommerce/warehouse-service/utils"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReservationRepository interface {
+	Reserve(ctx context.Context, req *models.ReservationRequest) ([]*models.Reservation, []models.StockShortage, error)
+	GetByID(ctx context.Context, id string) (*models.Reservation, error)
+	Hold(ctx context.Context, id string) (*models.Reservation, error)
+	Confirm(ctx context.Context, id string) (*models.Reservation, error)
+	Release(ctx context.Context, id string) (*models.Reservation, error)
+	ExpireStale(ctx context.Context, now time.Time) ([]*models.Reservation, error)
+}
+
+type mongoReservationRepository struct {
+	warehouses   *mongo.Collection
+	reservations *mongo.Collection
+}
+
+func NewMongoReservationRepository(db *mongo.Database) ReservationRepository {
+	return &mongoReservationRepository{
+		warehouses:   db.Collection("warehouses"),
+		reservations: db.Collection("reservations"),
+	}
+}
+
+// Reserve holds available stock for every requested item. Items without a
+// warehouse are served from the first active warehouse whose available
+// stock covers the full quantity. If any item is short, holds placed for the
+// other items are dropped and the shortages are returned instead of
+// reservations.
+func (r *mongoReservationRepository) Reserve(ctx context.Context, req *models.ReservationRequest) ([]*models.Reservation, []models.StockShortage, error) {
+	if err := utils.ValidateStruct(req); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(req.TTLSeconds) * time.Second)
+
+	var reservations []*models.Reservation
+	var shortages []models.StockShortage
+
+	for _, item := range req.Items {
+		warehouseID, err := r.takeStock(ctx, item)
+		if err != nil {
+			r.unreserve(ctx, reservations)
+			return nil, nil, err
+		}
+
+		if warehouseID == "" {
+			available, err := r.availableStock(ctx, item)
+			if err != nil {
+				r.unreserve(ctx, reservations)
+				return nil, nil, err
+			}
+			shortages = append(shortages, models.StockShortage{
+				ProductID:   item.ProductID,
+				WarehouseID: item.WarehouseID,
+				Requested:   item.Quantity,
+				Available:   available,
+			})
+			continue
+		}
+
+		reservations = append(reservations, &models.Reservation{
+			ID:          primitive.NewObjectID(),
+			WarehouseID: warehouseID,
+			ProductID:   item.ProductID,
+			Quantity:    item.Quantity,
+			Reference:   req.Reference,
+			Status:      models.ReservationStatusActive,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+
+	if len(shortages) > 0 {
+		r.unreserve(ctx, reservations)
+		return nil, shortages, nil
+	}
+
+	docs := make([]interface{}, len(reservations))
+	for i, reservation := range reservations {
+		docs[i] = reservation
+	}
+
+	if _, err := r.reservations.InsertMany(ctx, docs); err != nil {
+		utils.Logger.Errorf("Failed to store reservations: %v", err)
+		r.unreserve(ctx, reservations)
+		return nil, nil, err
+	}
+
+	return reservations, nil, nil
+}
+
+func (r *mongoReservationRepository) GetByID(ctx context.Context, id string) (*models.Reservation, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservation models.Reservation
+	err = r.reservations.FindOne(ctx, bson.M{"_id": objectID}).Decode(&reservation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, utils.ErrReservationNotFound
+		}
+		utils.Logger.Errorf("Failed to get reservation: %v", err)
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// Hold keeps an active reservation's stock until it is confirmed or
+// released, however long that takes: held reservations are never expired.
+// Holding a reservation that is already held is a no-op so callers can
+// safely retry.
+func (r *mongoReservationRepository) Hold(ctx context.Context, id string) (*models.Reservation, error) {
+	reservation, err := r.transition(ctx, id, models.ReservationStatusHeld, models.ReservationStatusActive)
+	if errors.Is(err, utils.ErrReservationNotActive) {
+		current, getErr := r.GetByID(ctx, id)
+		if getErr == nil && current.Status == models.ReservationStatusHeld {
+			return current, nil
+		}
+		return nil, err
+	}
+	return reservation, err
+}
+
+// Confirm turns an active or held reservation into a permanent deduction:
+// the quantity leaves both on-hand and reserved stock.
+func (r *mongoReservationRepository) Confirm(ctx context.Context, id string) (*models.Reservation, error) {
+	return r.settle(ctx, id, models.ReservationStatusConfirmed, true, models.ReservationStatusActive, models.ReservationStatusHeld)
+}
+
+// Release returns the reserved quantity to available stock. Releasing a
+// reservation that was already released or has expired is a no-op so
+// callers can safely retry.
+func (r *mongoReservationRepository) Release(ctx context.Context, id string) (*models.Reservation, error) {
+	reservation, err := r.settle(ctx, id, models.ReservationStatusReleased, false, models.ReservationStatusActive, models.ReservationStatusHeld)
+	if errors.Is(err, utils.ErrReservationNotActive) {
+		current, getErr := r.GetByID(ctx, id)
+		if getErr == nil && (current.Status == models.ReservationStatusReleased || current.Status == models.ReservationStatusExpired) {
+			return current, nil
+		}
+		return nil, err
+	}
+	return reservation, err
+}
+
+// ExpireStale moves every active reservation whose expiry has passed to
+// expired and returns its quantity to available stock. Held reservations
+// are left alone.
+func (r *mongoReservationRepository) ExpireStale(ctx context.Context, now time.Time) ([]*models.Reservation, error) {
+	cursor, err := r.reservations.Find(ctx, bson.M{
+		"status":     models.ReservationStatusActive,
+		"expires_at": bson.M{"$lte": now},
+	})
+	if err != nil {
+		utils.Logger.Errorf("Failed to find expired reservations: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stale []*models.Reservation
+	if err = cursor.All(ctx, &stale); err != nil {
+		utils.Logger.Errorf("Failed to decode expired reservations: %v", err)
+		return nil, err
+	}
+
+	var expired []*models.Reservation
+	for _, candidate := range stale {
+		reservation, err := r.settle(ctx, candidate.ID.Hex(), models.ReservationStatusExpired, false, models.ReservationStatusActive)
+		if errors.Is(err, utils.ErrReservationNotActive) {
+			// Held, confirmed or released since it was read.
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, reservation)
+	}
+
+	return expired, nil
+}
+
+// settle moves the reservation to status and takes its quantity off
+// reserved stock, and with deduct off on-hand stock too, in one
+// transaction: a reservation never leaves the active or held statuses
+// while its stock is still counted as reserved.
+func (r *mongoReservationRepository) settle(ctx context.Context, id string, status string, deduct bool, from ...string) (*models.Reservation, error) {
+	session, err := r.reservations.Database().Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	settled, err := session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		reservation, err := r.transition(sessionCtx, id, status, from...)
+		if err != nil {
+			return nil, err
+		}
+
+		onHand := 0
+		if deduct {
+			onHand = -reservation.Quantity
+		}
+		if err := r.adjustStock(sessionCtx, reservation, onHand, -reservation.Quantity); err != nil {
+			utils.Logger.Errorf("Failed to adjust stock for reservation %s: %v", id, err)
+			return nil, err
+		}
+		return reservation, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settled.(*models.Reservation), nil
+}
+
+// transition moves the reservation to status if it is in one of the from
+// statuses, or returns ErrReservationNotActive.
+func (r *mongoReservationRepository) transition(ctx context.Context, id string, status string, from ...string) (*models.Reservation, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservation models.Reservation
+	err = r.reservations.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objectID, "status": bson.M{"$in": from}},
+		bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reservation)
+	if err == nil {
+		return &reservation, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		utils.Logger.Errorf("Failed to update reservation: %v", err)
+		return nil, err
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, utils.ErrReservationNotActive
+}
+
+// takeStock atomically adds the item quantity to the reserved stock of a
+// warehouse with enough available stock and returns that warehouse's ID, or
+// "" when none has.
+func (r *mongoReservationRepository) takeStock(ctx context.Context, item models.ReservationItem) (string, error) {
+	filter := bson.M{
+		"status": bson.M{"$ne": "inactive"},
+		"$expr": bson.M{
+			"$gte": bson.A{
+				bson.M{"$subtract": bson.A{
+					bson.M{"$ifNull": bson.A{"$stock." + item.ProductID, 0}},
+					bson.M{"$ifNull": bson.A{"$reserved." + item.ProductID, 0}},
+				}},
+				item.Quantity,
+			},
+		},
+	}
+	if item.WarehouseID != "" {
+		objectID, err := primitive.ObjectIDFromHex(item.WarehouseID)
+		if err != nil {
+			return "", err
+		}
+		filter["_id"] = objectID
+	}
+
+	var warehouse models.Warehouse
+	err := r.warehouses.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.M{
+			"$inc": bson.M{"reserved." + item.ProductID: item.Quantity},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+	).Decode(&warehouse)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		utils.Logger.Errorf("Failed to reserve stock: %v", err)
+		return "", err
+	}
+
+	return warehouse.ID.Hex(), nil
+}
+
+// availableStock returns the largest available quantity of the item held by
+// a single eligible warehouse.
+func (r *mongoReservationRepository) availableStock(ctx context.Context, item models.ReservationItem) (int, error) {
+	filter := bson.M{"status": bson.M{"$ne": "inactive"}}
+	if item.WarehouseID != "" {
+		objectID, err := primitive.ObjectIDFromHex(item.WarehouseID)
+		if err != nil {
+			return 0, err
+		}
+		filter["_id"] = objectID
+	}
+
+	cursor, err := r.warehouses.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	available := 0
+	for cursor.Next(ctx) {
+		var warehouse models.Warehouse
+		if err := cursor.Decode(&warehouse); err != nil {
+			return 0, err
+		}
+		if qty := warehouse.Available(item.ProductID); qty > available {
+			available = qty
+		}
+	}
+
+	return available, cursor.Err()
+}
+
+func (r *mongoReservationRepository) unreserve(ctx context.Context, reservations []*models.Reservation) {
+	for _, reservation := range reservations {
+		if err := r.adjustStock(ctx, reservation, 0, -reservation.Quantity); err != nil {
+			utils.Logger.Errorf("Failed to roll back reservation for product %s in warehouse %s: %v",
+				reservation.ProductID, reservation.WarehouseID, err)
+		}
+	}
+}
+
+// adjustStock applies on-hand and reserved deltas for the reservation's
+// product in its warehouse.
+func (r *mongoReservationRepository) adjustStock(ctx context.Context, reservation *models.Reservation, onHand int, reserved int) error {
+	objectID, err := primitive.ObjectIDFromHex(reservation.WarehouseID)
+	if err != nil {
+		return err
+	}
+
+	inc := bson.M{"reserved." + reservation.ProductID: reserved}
+	if onHand != 0 {
+		inc["stock."+reservation.ProductID] = onHand
+	}
+
+	result, err := r.warehouses.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{
+			"$inc": inc,
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("warehouse not found")
+	}
+
+	return nil
+}
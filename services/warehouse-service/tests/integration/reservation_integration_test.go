@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"context"
+	"ecommerce/warehouse-service/models"
+	"ecommerce/warehouse-service/repository"
+	"ecommerce/warehouse-service/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestExpireStaleReservations(t *testing.T) {
+	utils.Logger = zap.NewNop().Sugar()
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	warehouseRepo := repository.NewMongoWarehouseRepository(db)
+	reservationRepo := repository.NewMongoReservationRepository(db)
+
+	warehouse := &models.Warehouse{
+		Name:     "Reservation Warehouse",
+		Location: "Test Location",
+		Status:   "active",
+		Stock:    map[string]int{"product1": 10},
+	}
+	assert.NoError(t, warehouseRepo.Create(ctx, warehouse))
+
+	reserve := func(quantity int) *models.Reservation {
+		reservations, shortages, err := reservationRepo.Reserve(ctx, &models.ReservationRequest{
+			Reference:  "order-1",
+			TTLSeconds: 60,
+			Items:      []models.ReservationItem{{ProductID: "product1", Quantity: quantity, WarehouseID: warehouse.ID.Hex()}},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, shortages)
+		return reservations[0]
+	}
+	active, held, confirmed := reserve(2), reserve(3), reserve(1)
+
+	_, err := reservationRepo.Hold(ctx, held.ID.Hex())
+	assert.NoError(t, err)
+	_, err = reservationRepo.Confirm(ctx, confirmed.ID.Hex())
+	assert.NoError(t, err)
+
+	// Nothing has expired yet
+	expired, err := reservationRepo.ExpireStale(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+
+	expired, err = reservationRepo.ExpireStale(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, active.ID, expired[0].ID)
+		assert.Equal(t, models.ReservationStatusExpired, expired[0].Status)
+	}
+
+	// The expired reservation's units are available again; the held and
+	// confirmed ones keep theirs
+	updated, err := warehouseRepo.GetByID(ctx, warehouse.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, 9, updated.Stock["product1"])
+	assert.Equal(t, 3, updated.Reserved["product1"])
+	assert.Equal(t, 6, updated.Available("product1"))
+
+	current, err := reservationRepo.GetByID(ctx, held.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReservationStatusHeld, current.Status)
+
+	// Expiring again is a no-op and releasing the expired reservation
+	// does not return its stock twice
+	expired, err = reservationRepo.ExpireStale(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+	_, err = reservationRepo.Release(ctx, active.ID.Hex())
+	assert.NoError(t, err)
+
+	updated, err = warehouseRepo.GetByID(ctx, warehouse.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, updated.Reserved["product1"])
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"ecommerce/warehouse-service/jobs"
+	"ecommerce/warehouse-service/metrics"
+	"ecommerce/warehouse-service/models"
+	"ecommerce/warehouse-service/repository"
+	"ecommerce/warehouse-service/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type MockReservationRepo struct {
+	mock.Mock
+	repository.ReservationRepository
+}
+
+func (m *MockReservationRepo) ExpireStale(ctx context.Context, now time.Time) ([]*models.Reservation, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Reservation), args.Error(1)
+}
+
+func TestStockReleaseJob(t *testing.T) {
+	utils.Logger = zap.NewNop().Sugar()
+
+	t.Run("RecordsExpiredStock", func(t *testing.T) {
+		mockRepo := new(MockReservationRepo)
+		expired := []*models.Reservation{
+			{ID: primitive.NewObjectID(), WarehouseID: "release-wh-1", ProductID: "p1", Quantity: 3},
+			{ID: primitive.NewObjectID(), WarehouseID: "release-wh-1", ProductID: "p2", Quantity: 2},
+		}
+		ran := make(chan struct{})
+		mockRepo.On("ExpireStale", mock.Anything, mock.AnythingOfType("time.Time")).
+			Return(expired, nil).
+			Once().
+			Run(func(mock.Arguments) { close(ran) })
+		mockRepo.On("ExpireStale", mock.Anything, mock.AnythingOfType("time.Time")).Return(nil, nil)
+
+		released := metrics.ExpiredStockReleased.WithLabelValues("release-wh-1")
+		before := testutil.ToFloat64(released)
+		ctx, cancel := context.WithCancel(context.Background())
+		jobs.NewStockReleaseJob(mockRepo, 10*time.Millisecond).Start(ctx)
+
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatal("stock release job did not run")
+		}
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(released)-before == 5
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("RecordsPartialProgressOnError", func(t *testing.T) {
+		mockRepo := new(MockReservationRepo)
+		expired := []*models.Reservation{
+			{ID: primitive.NewObjectID(), WarehouseID: "release-wh-2", ProductID: "p1", Quantity: 4},
+		}
+		ran := make(chan struct{})
+		mockRepo.On("ExpireStale", mock.Anything, mock.AnythingOfType("time.Time")).
+			Return(expired, errors.New("warehouse not found")).
+			Once().
+			Run(func(mock.Arguments) { close(ran) })
+		mockRepo.On("ExpireStale", mock.Anything, mock.AnythingOfType("time.Time")).Return(nil, nil)
+
+		released := metrics.ExpiredStockReleased.WithLabelValues("release-wh-2")
+		before := testutil.ToFloat64(released)
+		ctx, cancel := context.WithCancel(context.Background())
+		jobs.NewStockReleaseJob(mockRepo, 10*time.Millisecond).Start(ctx)
+
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatal("stock release job did not run")
+		}
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(released)-before == 4
+		}, time.Second, 10*time.Millisecond)
+	})
+}
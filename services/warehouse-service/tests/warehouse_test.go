@@ -4,6 +4,7 @@ import (
 	"context"
 	"ecommerce/warehouse-service/models"
 	"ecommerce/warehouse-service/repository"
+	"ecommerce/warehouse-service/utils"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -64,4 +65,30 @@ func TestWarehouseOperations(t *testing.T) {
 		assert.Equal(t, warehouse, result)
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("AvailableStock", func(t *testing.T) {
+		warehouse := &models.Warehouse{
+			Stock:    map[string]int{"p1": 10, "p2": 3},
+			Reserved: map[string]int{"p1": 4},
+		}
+
+		assert.Equal(t, 6, warehouse.Available("p1"))
+		assert.Equal(t, 3, warehouse.Available("p2"))
+		assert.Equal(t, 0, warehouse.Available("p3"))
+	})
+}
+
+func TestReservationItemsNeedProductObjectIDs(t *testing.T) {
+	request := func(productID string) models.ReservationRequest {
+		return models.ReservationRequest{
+			Reference:  "order-1",
+			TTLSeconds: 60,
+			Items:      []models.ReservationItem{{ProductID: productID, Quantity: 1}},
+		}
+	}
+
+	assert.NoError(t, utils.ValidateStruct(request(primitive.NewObjectID().Hex())))
+	for _, productID := range []string{"", "a.b", "$where", "reserved", primitive.NewObjectID().Hex() + ".x"} {
+		assert.Error(t, utils.ValidateStruct(request(productID)), productID)
+	}
 }
@@ -11,6 +11,9 @@ var (
 	ErrUnauthorized      = errors.New("unauthorized access")
 	ErrForbidden         = errors.New("forbidden access")
 	ErrInternalServer    = errors.New("internal server error")
+
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrReservationNotActive = errors.New("reservation is not active")
 )
 
 type AppError struct {